@@ -1,12 +1,19 @@
 package clock
 
 import (
+	"flag"
 	"fmt"
+	"time"
 
 	"git.fiblab.net/sim/protos/v2/go/city/clock/v1/clockv1connect"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
 )
 
+var (
+	realtimeFactor = flag.Float64("clock.realtime_factor", 0, "仿真节奏与墙钟时间的比例，即每仿真秒对应的真实秒数（<=0表示不限速）")
+	outputInterval = flag.Int("output.interval_steps", 1, "输出间隔步数（按外部步数计），每N步进行一次轨迹输出与加速度采样，与积分步长解耦")
+)
+
 // Clock 仿真时钟管理器
 // 功能：管理仿真系统的时间推进，支持子循环机制以提高仿真精度
 // 说明：维护当前仿真时间、步数等信息，提供时间格式化和RPC服务
@@ -20,6 +27,9 @@ type Clock struct {
 
 	T            float64 // 当前时间（秒）
 	InternalStep int32   // 当前内部步数
+
+	realtimeStart  time.Time // 墙钟限速的起点（真实时间）
+	realtimeStartT float64   // 墙钟限速起点对应的仿真时间（秒）
 }
 
 // New 根据配置创建新的时钟实例
@@ -81,6 +91,14 @@ func (c *Clock) NoInSubloop() bool {
 	return c.InternalStep%c.SUBLOOP == 0
 }
 
+// OutputStep 检查当前是否为输出步
+// 功能：不在子循环内且外部步数是output.interval_steps的整数倍时返回true
+// 返回：true表示进行输出
+// 说明：在NoInSubloop的基础上进一步按输出间隔降低输出频率，间隔<=1时与NoInSubloop一致
+func (c *Clock) OutputStep() bool {
+	return c.NoInSubloop() && c.ExternalStep()%int32(max(*outputInterval, 1)) == 0
+}
+
 // String 获取时钟的字符串表示
 // 功能：将当前时间格式化为可读的字符串
 // 返回：格式化的时间字符串（Day X: HH:MM:SS）
@@ -110,3 +128,26 @@ func (c *Clock) GetHourMinuteSecond() (int, int, float64) {
 	second := c.T - float64(hour*3600+minute*60)
 	return hour, minute, second
 }
+
+// WaitRealtime 按墙钟时间限速
+// 功能：启用clock.realtime_factor时，阻塞至当前仿真时间对应的真实时间，用于交互式可视化的慢放与快放
+// 算法说明：
+// 1. 首次调用时记录真实时间与仿真时间作为起点
+// 2. 目标真实时间 = 起点真实时间 + (当前仿真时间 - 起点仿真时间) * 比例
+// 3. 若尚未到达目标时间则休眠至目标时间，否则立即返回
+// 说明：以起点为基准计算目标时间，单步耗时的波动不会累积成漂移；仿真落后于墙钟时不休眠
+func (c *Clock) WaitRealtime() {
+	factor := *realtimeFactor
+	if factor <= 0 {
+		return
+	}
+	if c.realtimeStart.IsZero() {
+		c.realtimeStart = time.Now()
+		c.realtimeStartT = c.T
+		return
+	}
+	target := c.realtimeStart.Add(time.Duration((c.T - c.realtimeStartT) * factor * float64(time.Second)))
+	if d := time.Until(target); d > 0 {
+		time.Sleep(d)
+	}
+}
@@ -0,0 +1,55 @@
+package clock
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitRealtime(t *testing.T) {
+	defer flag.Set("clock.realtime_factor", "0")
+	step := func(c *Clock, n int) time.Duration {
+		start := time.Now()
+		for i := 0; i < n; i++ {
+			c.InternalStep++
+			c.T = float64(c.InternalStep) * c.DT
+			c.WaitRealtime()
+		}
+		return time.Since(start)
+	}
+
+	// 不限速时不休眠
+	assert.Less(t, step(&Clock{DT: 1}, 10), 50*time.Millisecond)
+
+	// 每仿真秒对应0.05真实秒，首步作为起点，其后10步共10仿真秒，应耗时约0.5秒
+	flag.Set("clock.realtime_factor", "0.05")
+	c := &Clock{DT: 1}
+	step(c, 1)
+	elapsed := step(c, 10)
+	assert.InDelta(t, 500*time.Millisecond, elapsed, float64(150*time.Millisecond))
+}
+
+func TestOutputStep(t *testing.T) {
+	defer flag.Set("output.interval_steps", "1")
+	outputs := func(c *Clock, n int) []int32 {
+		var steps []int32
+		for i := 0; i < n; i++ {
+			if c.OutputStep() {
+				steps = append(steps, c.InternalStep)
+			}
+			c.InternalStep++
+		}
+		return steps
+	}
+
+	// 默认每步输出
+	assert.Equal(t, []int32{0, 1, 2, 3}, outputs(&Clock{SUBLOOP: 1}, 4))
+
+	// 每3步输出一次
+	flag.Set("output.interval_steps", "3")
+	assert.Equal(t, []int32{0, 3, 6, 9}, outputs(&Clock{SUBLOOP: 1}, 10))
+	// 子循环内不输出，间隔按外部步数计
+	assert.Equal(t, []int32{0, 6, 12}, outputs(&Clock{SUBLOOP: 2}, 14))
+}
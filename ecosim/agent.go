@@ -8,8 +8,9 @@ import (
 
 // Agent 代表经济系统中的个体代理
 type Agent struct {
-	base *economyv2.Agent
-	mu   sync.Mutex
+	base    *economyv2.Agent
+	mu      sync.Mutex
+	version int64 // 版本号，每次修改后递增，用于乐观并发控制
 }
 
 // NewAgent 创建新的代理实例
@@ -26,6 +27,13 @@ func (a *Agent) GetID() int32 {
 	return a.base.Id
 }
 
+// GetVersion 获取版本号
+func (a *Agent) GetVersion() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.version
+}
+
 // GetCurrency 获取代理持有的货币量
 func (a *Agent) GetCurrency() float32 {
 	a.mu.Lock()
@@ -40,6 +48,7 @@ func (a *Agent) GetCurrency() float32 {
 func (a *Agent) SetCurrency(value float32) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	a.version++
 	a.base.Currency = &value
 }
 
@@ -54,6 +63,7 @@ func (a *Agent) GetFirmID() *int32 {
 func (a *Agent) SetFirmID(value *int32) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	a.version++
 	a.base.FirmId = value
 }
 
@@ -68,6 +78,7 @@ func (a *Agent) GetSkill() *float32 {
 func (a *Agent) SetSkill(value *float32) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	a.version++
 	a.base.Skill = value
 }
 
@@ -82,6 +93,7 @@ func (a *Agent) GetConsumption() *float32 {
 func (a *Agent) SetConsumption(value *float32) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	a.version++
 	a.base.Consumption = value
 }
 
@@ -96,5 +108,6 @@ func (a *Agent) GetIncome() *float32 {
 func (a *Agent) SetIncome(value *float32) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	a.version++
 	a.base.Income = value
 }
@@ -0,0 +1,86 @@
+package ecosim
+
+import (
+	"fmt"
+	"math"
+)
+
+const cpiWeightTolerance = 1e-3 // 消费篮子权重之和与1的最大允许偏差
+
+// SetCPIBase 设置国家统计局的CPI基期消费篮子
+// 功能：记录篮子中各企业的当前价格作为基期价格，覆盖已有的基期
+// 参数：nbsID-国家统计局ID，firmIDs-篮子中的企业ID
+func (e *EconomySim) SetCPIBase(nbsID int32, firmIDs []int32) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	nbs, exists := e.nbs[nbsID]
+	if !exists {
+		return fmt.Errorf("NBS %d not found", nbsID)
+	}
+	if len(firmIDs) == 0 {
+		return fmt.Errorf("empty CPI basket")
+	}
+	basePrices := make(map[int32]float32, len(firmIDs))
+	for _, firmID := range firmIDs {
+		firm, exists := e.firms[firmID]
+		if !exists {
+			return fmt.Errorf("firm %d not found", firmID)
+		}
+		if firm.GetPrice() <= 0 {
+			return fmt.Errorf("invalid price %f of firm %d", firm.GetPrice(), firmID)
+		}
+		basePrices[firmID] = firm.GetPrice()
+	}
+	nbs.SetCPIBasePrices(basePrices)
+	return nil
+}
+
+// ComputeCPI 计算消费者价格指数
+// 功能：以国家统计局记录的基期价格为基准，计算消费篮子的加权价格指数，不修改任何状态
+// 参数：nbsID-国家统计局ID，firmIDs-篮子中的企业ID（须已在基期篮子中），weights-各企业的权重（非负，和约为1）
+// 返回：CPI=Σ(weight*当前价格)/Σ(weight*基期价格)，基期时为1
+func (e *EconomySim) ComputeCPI(nbsID int32, firmIDs []int32, weights []float32) (float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	nbs, exists := e.nbs[nbsID]
+	if !exists {
+		return 0, fmt.Errorf("NBS %d not found", nbsID)
+	}
+	if len(firmIDs) == 0 {
+		return 0, fmt.Errorf("empty CPI basket")
+	}
+	if len(firmIDs) != len(weights) {
+		return 0, fmt.Errorf("number of firms and weights must match")
+	}
+	var weightSum float64
+	for _, w := range weights {
+		if w < 0 {
+			return 0, fmt.Errorf("invalid weight %f", w)
+		}
+		weightSum += float64(w)
+	}
+	if math.Abs(weightSum-1) > cpiWeightTolerance {
+		return 0, fmt.Errorf("weights sum to %f instead of 1", weightSum)
+	}
+
+	basePrices := nbs.GetCPIBasePrices()
+	var current, base float64
+	for i, firmID := range firmIDs {
+		firm, exists := e.firms[firmID]
+		if !exists {
+			return 0, fmt.Errorf("firm %d not found", firmID)
+		}
+		basePrice, ok := basePrices[firmID]
+		if !ok {
+			return 0, fmt.Errorf("firm %d not in the CPI base basket of NBS %d", firmID, nbsID)
+		}
+		current += float64(weights[i]) * float64(firm.GetPrice())
+		base += float64(weights[i]) * float64(basePrice)
+	}
+	if base == 0 {
+		return 0, fmt.Errorf("zero base price level")
+	}
+	return float32(current / base), nil
+}
@@ -1,11 +1,16 @@
 package ecosim
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
 	"os"
 	"sync"
 
 	economyv2 "git.fiblab.net/sim/protos/v2/go/city/economy/v2"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -17,6 +22,11 @@ type EconomySim struct {
 	govs   map[int32]*Government
 	banks  map[int32]*Bank
 	mu     sync.Mutex
+
+	tick       config.EconomyTick // 内置动态的配置
+	tickCount  int64              // 已推进的步数
+	lastDemand map[int32]float32  // 上一次推进时各企业的累计需求量，用于计算每步新增的需求量
+	recordKeys []string           // 内置动态写入统计局时间序列的键（按写入顺序），用于限制记录数
 }
 
 // SimError 自定义错误类型
@@ -166,10 +176,13 @@ func (e *EconomySim) UpdateFirm(firm *economyv2.Firm) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if _, exists := e.firms[firm.Id]; !exists {
+	old, exists := e.firms[firm.Id]
+	if !exists {
 		return fmt.Errorf("firm %d not found", firm.Id)
 	}
-	e.firms[firm.Id] = NewFirm(firm)
+	newFirm := NewFirm(firm)
+	newFirm.version = old.version + 1
+	e.firms[firm.Id] = newFirm
 	return nil
 }
 
@@ -178,10 +191,15 @@ func (e *EconomySim) UpdateNBS(nbs *economyv2.NBS) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if _, exists := e.nbs[nbs.Id]; !exists {
+	old, exists := e.nbs[nbs.Id]
+	if !exists {
 		return fmt.Errorf("NBS %d not found", nbs.Id)
 	}
-	e.nbs[nbs.Id] = NewNBS(nbs)
+	// CPI基期价格不在proto中，更新时保留
+	newNBS := NewNBS(nbs)
+	newNBS.cpiBasePrices = old.cpiBasePrices
+	newNBS.version = old.version + 1
+	e.nbs[nbs.Id] = newNBS
 	return nil
 }
 
@@ -190,10 +208,13 @@ func (e *EconomySim) UpdateGovernment(gov *economyv2.Government) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if _, exists := e.govs[gov.Id]; !exists {
+	old, exists := e.govs[gov.Id]
+	if !exists {
 		return fmt.Errorf("government %d not found", gov.Id)
 	}
-	e.govs[gov.Id] = NewGovernment(gov)
+	newGov := NewGovernment(gov)
+	newGov.version = old.version + 1
+	e.govs[gov.Id] = newGov
 	return nil
 }
 
@@ -202,10 +223,15 @@ func (e *EconomySim) UpdateBank(bank *economyv2.Bank) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if _, exists := e.banks[bank.Id]; !exists {
+	old, exists := e.banks[bank.Id]
+	if !exists {
 		return fmt.Errorf("bank %d not found", bank.Id)
 	}
-	e.banks[bank.Id] = NewBank(bank)
+	// 储蓄不在proto中，更新时保留
+	newBank := NewBank(bank)
+	newBank.savings = old.savings
+	newBank.version = old.version + 1
+	e.banks[bank.Id] = newBank
 	return nil
 }
 
@@ -236,25 +262,39 @@ func (e *EconomySim) UpdateOrg(org interface{}) error {
 
 	switch o := org.(type) {
 	case *economyv2.Firm:
-		if _, exists := e.firms[o.Id]; !exists {
+		old, exists := e.firms[o.Id]
+		if !exists {
 			return &SimError{Message: fmt.Sprintf("firm %d not found", o.Id)}
 		}
-		e.firms[o.Id] = NewFirm(o)
+		newFirm := NewFirm(o)
+		newFirm.version = old.version + 1
+		e.firms[o.Id] = newFirm
 	case *economyv2.NBS:
-		if _, exists := e.nbs[o.Id]; !exists {
+		old, exists := e.nbs[o.Id]
+		if !exists {
 			return &SimError{Message: fmt.Sprintf("NBS %d not found", o.Id)}
 		}
-		e.nbs[o.Id] = NewNBS(o)
+		newNBS := NewNBS(o)
+		newNBS.cpiBasePrices = old.cpiBasePrices
+		newNBS.version = old.version + 1
+		e.nbs[o.Id] = newNBS
 	case *economyv2.Government:
-		if _, exists := e.govs[o.Id]; !exists {
+		old, exists := e.govs[o.Id]
+		if !exists {
 			return &SimError{Message: fmt.Sprintf("government %d not found", o.Id)}
 		}
-		e.govs[o.Id] = NewGovernment(o)
+		newGov := NewGovernment(o)
+		newGov.version = old.version + 1
+		e.govs[o.Id] = newGov
 	case *economyv2.Bank:
-		if _, exists := e.banks[o.Id]; !exists {
+		old, exists := e.banks[o.Id]
+		if !exists {
 			return &SimError{Message: fmt.Sprintf("bank %d not found", o.Id)}
 		}
-		e.banks[o.Id] = NewBank(o)
+		newBank := NewBank(o)
+		newBank.savings = old.savings
+		newBank.version = old.version + 1
+		e.banks[o.Id] = newBank
 	default:
 		return &SimError{Message: "unsupported organization type"}
 	}
@@ -285,6 +325,7 @@ func (e *EconomySim) UpdateAgent(agent *economyv2.Agent) error {
 	}
 
 	existingAgent.base = agent
+	existingAgent.version++
 	return nil
 }
 
@@ -360,20 +401,29 @@ func (e *EconomySim) CalculateTaxesDue(governmentID int32, agentIDs []int32, inc
 	return totalTax, updatedIncomes, nil
 }
 
+// FirmSale 一次消费中单个企业的实际销售情况
+type FirmSale struct {
+	FirmID int32   // 企业ID
+	Sales  int32   // 实际售出数量
+	Cost   float32 // 代理支付的货币
+}
+
 // CalculateConsumption 计算消费
-func (e *EconomySim) CalculateConsumption(firmIDs []int32, agentID int32, demands []int32, consumptionAccumulation bool) (float32, bool, error) {
+// dryRun为true时仅计算并返回各企业的实际销售明细，不修改代理与企业的状态
+// 返回：消费总额、需求是否全部满足、各企业的实际销售明细（不含销售量为0的企业）
+func (e *EconomySim) CalculateConsumption(firmIDs []int32, agentID int32, demands []int32, consumptionAccumulation bool, dryRun bool) (float32, bool, []FirmSale, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	// 检查参数
 	if len(firmIDs) != len(demands) {
-		return 0, false, fmt.Errorf("number of firms and demands must match")
+		return 0, false, nil, fmt.Errorf("number of firms and demands must match")
 	}
 
 	// 获取代理实例
 	agent, exists := e.agents[agentID]
 	if !exists {
-		return 0, false, fmt.Errorf("agent %d not found", agentID)
+		return 0, false, nil, fmt.Errorf("agent %d not found", agentID)
 	}
 
 	// 获取代理的货币量
@@ -383,18 +433,13 @@ func (e *EconomySim) CalculateConsumption(firmIDs []int32, agentID int32, demand
 	var totalConsumption float32
 	var success bool = true
 
-	type salesInfo struct {
-		firmID      int32
-		actualSales int32
-		cost        float32
-	}
-	var sales []salesInfo
+	var sales []FirmSale
 
 	// 计算每个企业的销售情况
 	for i, firmID := range firmIDs {
 		firm, exists := e.firms[firmID]
 		if !exists {
-			return 0, false, fmt.Errorf("firm %d not found", firmID)
+			return 0, false, nil, fmt.Errorf("firm %d not found", firmID)
 		}
 
 		demand := demands[i]
@@ -418,18 +463,18 @@ func (e *EconomySim) CalculateConsumption(firmIDs []int32, agentID int32, demand
 		}
 
 		if actualSales > 0 {
-			sales = append(sales, salesInfo{
-				firmID:      firmID,
-				actualSales: actualSales,
-				cost:        cost,
+			sales = append(sales, FirmSale{
+				FirmID: firmID,
+				Sales:  actualSales,
+				Cost:   cost,
 			})
 			totalConsumption += cost
 			agentCurrency -= cost
 		}
 	}
 
-	// 如果不累积消费，则更新代理和企业的状态
-	if !consumptionAccumulation {
+	// 如果不累积消费且不是试算，则更新代理和企业的状态
+	if !consumptionAccumulation && !dryRun {
 		// 更新代理的货币量
 		agent.SetCurrency(agentCurrency)
 
@@ -443,22 +488,68 @@ func (e *EconomySim) CalculateConsumption(firmIDs []int32, agentID int32, demand
 
 		// 更新企业的状态
 		for _, sale := range sales {
-			firm := e.firms[sale.firmID]
-			firm.SetCurrency(firm.GetCurrency() + sale.cost)
-			firm.SetInventory(firm.GetInventory() - sale.actualSales)
-			firm.SetDemand(firm.GetDemand() + float32(sale.actualSales))
-			firm.SetSales(firm.GetSales() + float32(sale.actualSales))
+			firm := e.firms[sale.FirmID]
+			firm.SetCurrency(firm.GetCurrency() + sale.Cost)
+			firm.SetInventory(firm.GetInventory() - sale.Sales)
+			firm.SetDemand(firm.GetDemand() + float32(sale.Sales))
+			firm.SetSales(firm.GetSales() + float32(sale.Sales))
 		}
 	}
 
-	return totalConsumption, success, nil
+	return totalConsumption, success, sales, nil
 }
 
 // CalculateInterest 计算利息
-func (e *EconomySim) CalculateInterest(bankID int32, agentIDs []int32) (float32, []float32, error) {
+// 利息按代理可支配的货币量计算并计入货币（与CalculateInterest RPC的语义一致），不涉及储蓄
+// periods为一次计息内的复利子周期数，利息为currency*((1+rate/periods)^periods-1)，
+// periods<=1时按单利currency*rate计算
+// 银行货币不足以支付全部利息时返回错误，不修改任何代理的货币量
+func (e *EconomySim) CalculateInterest(bankID int32, agentIDs []int32, periods int32) (float32, []float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	agents := make([]*Agent, len(agentIDs))
+	for i, agentID := range agentIDs {
+		agent, exists := e.agents[agentID]
+		if !exists {
+			return 0, nil, fmt.Errorf("agent %d not found", agentID)
+		}
+		agents[i] = agent
+	}
+	return e.payInterest(bankID, len(agentIDs), periods,
+		func(i int) float32 { return agents[i].GetCurrency() },
+		func(i int, value float32) { agents[i].SetCurrency(value) },
+	)
+}
+
+// CalculateSavingsInterest 计算储蓄利息
+// 利息按代理在该银行的储蓄余额计算并计入储蓄，代理可支配的货币不计息，periods的含义同CalculateInterest
+// 返回：利息总额与各代理计息后的储蓄余额；银行货币不足以支付全部利息时返回错误，不修改任何储蓄
+func (e *EconomySim) CalculateSavingsInterest(bankID int32, agentIDs []int32, periods int32) (float32, []float32, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	return e.calculateSavingsInterest(bankID, agentIDs, periods)
+}
+
+// calculateSavingsInterest 计算储蓄利息（调用方需持有锁）
+func (e *EconomySim) calculateSavingsInterest(bankID int32, agentIDs []int32, periods int32) (float32, []float32, error) {
+	for _, agentID := range agentIDs {
+		if _, exists := e.agents[agentID]; !exists {
+			return 0, nil, fmt.Errorf("agent %d not found", agentID)
+		}
+	}
+	bank := e.banks[bankID]
+	return e.payInterest(bankID, len(agentIDs), periods,
+		func(i int) float32 { return bank.GetSavings(agentIDs[i]) },
+		func(i int, value float32) { bank.SetSavings(agentIDs[i], value) },
+	)
+}
+
+// payInterest 由银行向n个代理的本金支付利息（调用方需持有锁）
+// 参数：get/set-读取/写入第i个代理的本金
+// 返回：利息总额与各代理计息后的本金；银行货币不足以支付全部利息时返回错误，不修改任何本金
+func (e *EconomySim) payInterest(bankID int32, n int, periods int32, get func(i int) float32, set func(i int, value float32)) (float32, []float32, error) {
 	// 获取银行实例
 	bank, exists := e.banks[bankID]
 	if !exists {
@@ -470,25 +561,21 @@ func (e *EconomySim) CalculateInterest(bankID int32, agentIDs []int32) (float32,
 	if interestRate <= 0 {
 		return 0, nil, fmt.Errorf("invalid interest rate for bank %d", bankID)
 	}
+	// 单位本金的利息
+	factor := interestRate
+	if periods > 1 {
+		n := float64(periods)
+		factor = float32(math.Pow(1+float64(interestRate)/n, n) - 1)
+	}
 
 	// 计算每个代理的利息
 	var totalInterest float32
-	updatedCurrencies := make([]float32, len(agentIDs))
-
-	for i, agentID := range agentIDs {
-		agent, exists := e.agents[agentID]
-		if !exists {
-			return 0, nil, fmt.Errorf("agent %d not found", agentID)
-		}
-
-		currency := agent.GetCurrency()
-		interest := currency * interestRate
+	updated := make([]float32, n)
+	for i := range updated {
+		principal := get(i)
+		interest := principal * factor
 		totalInterest += interest
-
-		// 更新代理的货币量
-		newCurrency := currency + interest
-		agent.SetCurrency(newCurrency)
-		updatedCurrencies[i] = newCurrency
+		updated[i] = principal + interest
 	}
 
 	// 检查银行是否有足够的货币支付利息
@@ -497,58 +584,166 @@ func (e *EconomySim) CalculateInterest(bankID int32, agentIDs []int32) (float32,
 		return 0, nil, fmt.Errorf("bank %d does not have enough currency to pay interest", bankID)
 	}
 
-	// 更新银行的货币量
+	// 更新代理本金与银行的货币量
+	for i, value := range updated {
+		set(i, value)
+	}
 	bank.SetCurrency(bankCurrency - totalInterest)
 
-	return totalInterest, updatedCurrencies, nil
+	return totalInterest, updated, nil
 }
 
-// GetFirmIDs 获取所有企业ID
-func (e *EconomySim) GetFirmIDs() []int32 {
+// Deposit 代理将可支配的货币存入银行储蓄
+// 参数：bankID-银行ID，agentID-代理ID，amount-存入金额（>0，不超过代理的货币量）
+func (e *EconomySim) Deposit(bankID, agentID int32, amount float32) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	var ids []int32
-	for id := range e.firms {
-		ids = append(ids, id)
+	bank, agent, err := e.getBankAndAgent(bankID, agentID, amount)
+	if err != nil {
+		return err
 	}
-	return ids
+	if agent.GetCurrency() < amount {
+		return fmt.Errorf("agent %d does not have enough currency to deposit", agentID)
+	}
+	agent.SetCurrency(agent.GetCurrency() - amount)
+	bank.SetSavings(agentID, bank.GetSavings(agentID)+amount)
+	return nil
 }
 
-// GetNBSIDs 获取所有国家统计局ID
-func (e *EconomySim) GetNBSIDs() []int32 {
+// Withdraw 代理从银行储蓄中取出货币
+// 参数：bankID-银行ID，agentID-代理ID，amount-取出金额（>0，不超过代理的储蓄余额）
+func (e *EconomySim) Withdraw(bankID, agentID int32, amount float32) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	var ids []int32
-	for id := range e.nbs {
-		ids = append(ids, id)
+	bank, agent, err := e.getBankAndAgent(bankID, agentID, amount)
+	if err != nil {
+		return err
+	}
+	if bank.GetSavings(agentID) < amount {
+		return fmt.Errorf("agent %d does not have enough savings in bank %d to withdraw", agentID, bankID)
 	}
-	return ids
+	bank.SetSavings(agentID, bank.GetSavings(agentID)-amount)
+	agent.SetCurrency(agent.GetCurrency() + amount)
+	return nil
 }
 
-// GetGovernmentIDs 获取所有政府ID
-func (e *EconomySim) GetGovernmentIDs() []int32 {
+// getBankAndAgent 获取存取款涉及的银行与代理并检查金额（调用方需持有锁）
+func (e *EconomySim) getBankAndAgent(bankID, agentID int32, amount float32) (*Bank, *Agent, error) {
+	bank, exists := e.banks[bankID]
+	if !exists {
+		return nil, nil, fmt.Errorf("bank %d not found", bankID)
+	}
+	agent, exists := e.agents[agentID]
+	if !exists {
+		return nil, nil, fmt.Errorf("agent %d not found", agentID)
+	}
+	if amount <= 0 {
+		return nil, nil, fmt.Errorf("invalid amount %f", amount)
+	}
+	return bank, agent, nil
+}
+
+// InitializeEconomy 批量添加经济实体
+// 功能：一次性添加一组企业、代理、国家统计局、政府与银行，不读取文件也不清空已有状态
+// 参数：entities-待添加的经济实体
+// 说明：任一实体ID与同类已有实体或同批实体重复时返回错误，且不添加任何实体
+func (e *EconomySim) InitializeEconomy(entities *economyv2.EconomyEntities) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	var ids []int32
-	for id := range e.govs {
-		ids = append(ids, id)
+	// 先检查全部ID，保证添加操作要么全部生效要么全部不生效
+	if err := checkNewIDs(e.firms, entities.GetFirms(), "firm"); err != nil {
+		return err
+	}
+	if err := checkNewIDs(e.agents, entities.GetAgents(), "agent"); err != nil {
+		return err
+	}
+	if err := checkNewIDs(e.nbs, entities.GetNbs(), "NBS"); err != nil {
+		return err
+	}
+	if err := checkNewIDs(e.govs, entities.GetGovernments(), "government"); err != nil {
+		return err
+	}
+	if err := checkNewIDs(e.banks, entities.GetBanks(), "bank"); err != nil {
+		return err
+	}
+
+	for _, firm := range entities.GetFirms() {
+		e.firms[firm.Id] = NewFirm(firm)
+	}
+	for _, agent := range entities.GetAgents() {
+		e.agents[agent.Id] = NewAgent(agent)
+	}
+	for _, nbs := range entities.GetNbs() {
+		e.nbs[nbs.Id] = NewNBS(nbs)
+	}
+	for _, gov := range entities.GetGovernments() {
+		e.govs[gov.Id] = NewGovernment(gov)
+	}
+	for _, bank := range entities.GetBanks() {
+		e.banks[bank.Id] = NewBank(bank)
 	}
-	return ids
+	return nil
+}
+
+// GetFirmIDs 获取所有企业ID（按ID升序）
+func (e *EconomySim) GetFirmIDs() []int32 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return sortedIDs(e.firms)
 }
 
-// GetBankIDs 获取所有银行ID
+// GetNBSIDs 获取所有国家统计局ID（按ID升序）
+func (e *EconomySim) GetNBSIDs() []int32 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return sortedIDs(e.nbs)
+}
+
+// GetGovernmentIDs 获取所有政府ID（按ID升序）
+func (e *EconomySim) GetGovernmentIDs() []int32 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return sortedIDs(e.govs)
+}
+
+// GetBankIDs 获取所有银行ID（按ID升序）
 func (e *EconomySim) GetBankIDs() []int32 {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	var ids []int32
-	for id := range e.banks {
-		ids = append(ids, id)
+	return sortedIDs(e.banks)
+}
+
+// GetEmploymentStats 获取就业统计
+// 功能：合并所有企业的雇员列表，统计就业与失业的代理数量
+// 返回：就业人数、失业人数
+// 说明：同时受雇于多家企业的代理只计一次，雇员列表中不存在的代理不计入
+func (e *EconomySim) GetEmploymentStats() (employed, unemployed int32) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.employmentStats()
+}
+
+// employmentStats 统计就业与失业的代理数量（调用方需持有锁）
+func (e *EconomySim) employmentStats() (employed, unemployed int32) {
+	employedSet := make(map[int32]struct{})
+	for _, firm := range e.firms {
+		for _, agentID := range firm.GetEmployees() {
+			if _, exists := e.agents[agentID]; exists {
+				employedSet[agentID] = struct{}{}
+			}
+		}
 	}
-	return ids
+	employed = int32(len(employedSet))
+	unemployed = int32(len(e.agents)) - employed
+	return employed, unemployed
 }
 
 // SaveEntities 保存经济实体状态
@@ -594,7 +789,8 @@ func (e *EconomySim) SaveEntities(filePath string) error {
 		return fmt.Errorf("failed to write file: %v", err)
 	}
 
-	return nil
+	// 储蓄不在proto中，另存到同目录的文件
+	return e.saveSavings(savingsFilePath(filePath))
 }
 
 // LoadEntities 加载经济实体状态
@@ -640,6 +836,54 @@ func (e *EconomySim) LoadEntities(filePath string) error {
 		e.agents[agent.Id] = NewAgent(agent)
 	}
 
+	return e.loadSavings(savingsFilePath(filePath))
+}
+
+// savingsFilePath 获取与实体状态文件一同保存的储蓄文件路径
+func savingsFilePath(filePath string) string {
+	return filePath + ".savings.json"
+}
+
+// saveSavings 将各银行的储蓄余额（银行ID->代理ID->余额）以JSON格式写入文件（调用方需持有锁）
+func (e *EconomySim) saveSavings(filePath string) error {
+	savings := make(map[int32]map[int32]float32, len(e.banks))
+	for id, bank := range e.banks {
+		if len(bank.savings) > 0 {
+			savings[id] = bank.savings
+		}
+	}
+	data, err := json.Marshal(savings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal savings: %v", err)
+	}
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write savings file: %v", err)
+	}
+	return nil
+}
+
+// loadSavings 从文件恢复各银行的储蓄余额（调用方需持有锁）
+// 文件不存在时（没有储蓄功能之前保存的状态）所有储蓄为空，不存在的银行的储蓄被忽略
+func (e *EconomySim) loadSavings(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read savings file: %v", err)
+	}
+	var savings map[int32]map[int32]float32
+	if err := json.Unmarshal(data, &savings); err != nil {
+		return fmt.Errorf("failed to unmarshal savings: %v", err)
+	}
+	for id, balances := range savings {
+		bank, exists := e.banks[id]
+		if !exists {
+			log.Warnf("load savings: bank %d not found", id)
+			continue
+		}
+		bank.savings = balances
+	}
 	return nil
 }
 
@@ -692,15 +936,52 @@ func (e *EconomySim) GetBank(bankID int32) (*Bank, error) {
 }
 
 // DeltaUpdateFirm 增量更新企业
-func (e *EconomySim) DeltaUpdateFirm(firmID int32, deltaInventory *int32, deltaPrice, deltaCurrency *float32, deltaDemand, deltaSales *float32, addEmployees, removeEmployees []int32) error {
+// checkBankruptcy为true时，更新后企业货币量为负则执行破产处理（见ProcessBankruptcy）
+// expectedVersion不为nil且与企业当前版本号不一致时返回ErrVersionConflict，且不修改任何状态
+// 返回：更新后的版本号
+func (e *EconomySim) DeltaUpdateFirm(firmID int32, deltaInventory *int32, deltaPrice, deltaCurrency *float32, deltaDemand, deltaSales *float32, addEmployees, removeEmployees []int32, checkBankruptcy bool, expectedVersion *int64) (int64, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	firm, exists := e.firms[firmID]
 	if !exists {
-		return fmt.Errorf("firm %d not found", firmID)
+		return 0, fmt.Errorf("firm %d not found", firmID)
+	}
+	if err := checkVersion("firm", firmID, firm.GetVersion(), expectedVersion); err != nil {
+		return 0, err
+	}
+	e.deltaUpdateFirm(firm, deltaInventory, deltaPrice, deltaCurrency, deltaDemand, deltaSales, addEmployees, removeEmployees, checkBankruptcy)
+	return firm.GetVersion(), nil
+}
+
+// DeltaUpdateFirms 批量增量更新企业（全部成功或全部不生效）
+// 功能：在一次加锁操作中先检查全部企业存在且版本号与期望一致，再依次执行更新；不执行破产检查
+// 参数：expectedVersions-企业ID->期望的版本号（更新前），不在其中的企业不检查版本
+// 返回：企业ID->更新后的版本号；检查失败时返回错误（版本号不一致时为ErrVersionConflict），且不修改任何状态
+func (e *EconomySim) DeltaUpdateFirms(req *economyv2.DeltaUpdateFirmRequest, expectedVersions map[int32]int64) (map[int32]int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, update := range req.Updates {
+		firm, exists := e.firms[update.FirmId]
+		if !exists {
+			return nil, fmt.Errorf("firm %d not found", update.FirmId)
+		}
+		if err := checkVersion("firm", update.FirmId, firm.GetVersion(), expectedVersionOf(expectedVersions, update.FirmId)); err != nil {
+			return nil, err
+		}
+	}
+	versions := make(map[int32]int64, len(req.Updates))
+	for _, update := range req.Updates {
+		firm := e.firms[update.FirmId]
+		e.deltaUpdateFirm(firm, update.DeltaInventory, update.DeltaPrice, update.DeltaCurrency, update.DeltaDemand, update.DeltaSales, update.AddEmployees, update.RemoveEmployees, false)
+		versions[update.FirmId] = firm.GetVersion()
 	}
+	return versions, nil
+}
 
+// deltaUpdateFirm 执行企业的增量更新（调用方需持有锁）
+func (e *EconomySim) deltaUpdateFirm(firm *Firm, deltaInventory *int32, deltaPrice, deltaCurrency *float32, deltaDemand, deltaSales *float32, addEmployees, removeEmployees []int32, checkBankruptcy bool) {
 	if deltaInventory != nil {
 		firm.SetInventory(firm.GetInventory() + *deltaInventory)
 	}
@@ -751,17 +1032,95 @@ func (e *EconomySim) DeltaUpdateFirm(firmID int32, deltaInventory *int32, deltaP
 		firm.SetEmployees(newEmployees)
 	}
 
+	if checkBankruptcy && firm.GetCurrency() < 0 {
+		e.processBankruptcy(firm)
+	}
+}
+
+// PayWages 企业向代理支付工资
+// 功能：在一次加锁操作中从企业扣除工资总额，并向每个代理支付对应工资
+// 参数：firmID-企业ID，wages-代理ID->工资（不可为负）
+// 返回：企业货币不足、代理不存在或工资为负时返回错误，且不修改任何状态
+func (e *EconomySim) PayWages(firmID int32, wages map[int32]float32) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	firm, exists := e.firms[firmID]
+	if !exists {
+		return fmt.Errorf("firm %d not found", firmID)
+	}
+
+	// 先检查全部代理与工资，再统一修改状态
+	var total float32
+	for _, agentID := range sortedIDs(wages) {
+		if _, exists := e.agents[agentID]; !exists {
+			return fmt.Errorf("agent %d not found", agentID)
+		}
+		if wages[agentID] < 0 {
+			return fmt.Errorf("invalid wage %f for agent %d", wages[agentID], agentID)
+		}
+		total += wages[agentID]
+	}
+	if firm.GetCurrency() < total {
+		return fmt.Errorf("firm %d does not have enough currency to pay wages", firmID)
+	}
+
+	firm.SetCurrency(firm.GetCurrency() - total)
+	for agentID, wage := range wages {
+		agent := e.agents[agentID]
+		agent.SetCurrency(agent.GetCurrency() + wage)
+	}
 	return nil
 }
 
+// ProcessBankruptcy 企业破产
+// 功能：解雇企业的全部员工（员工的所属企业置空）、清空库存，并移除该企业
+// 返回：被解雇的员工ID
+func (e *EconomySim) ProcessBankruptcy(firmID int32) ([]int32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	firm, exists := e.firms[firmID]
+	if !exists {
+		return nil, fmt.Errorf("firm %d not found", firmID)
+	}
+	return e.processBankruptcy(firm), nil
+}
+
+// processBankruptcy 企业破产处理（调用方需持有锁）
+func (e *EconomySim) processBankruptcy(firm *Firm) []int32 {
+	firmID := firm.GetID()
+	employees := firm.GetEmployees()
+	for _, empID := range employees {
+		agent, exists := e.agents[empID]
+		if !exists {
+			continue
+		}
+		// 只解除仍属于该企业的员工，避免覆盖已跳槽员工的所属企业
+		if id := agent.GetFirmID(); id != nil && *id == firmID {
+			agent.SetFirmID(nil)
+		}
+	}
+	firm.SetEmployees([]int32{})
+	firm.SetInventory(0)
+	delete(e.firms, firmID)
+	log.Infof("firm %d went bankrupt, %d employees laid off", firmID, len(employees))
+	return employees
+}
+
 // DeltaUpdateNBS 增量更新国家统计局
-func (e *EconomySim) DeltaUpdateNBS(nbsID int32, deltaNominalGDP, deltaRealGDP, deltaUnemployment, deltaWages, deltaPrices, deltaWorkingHours, deltaDepression, deltaConsumptionCurrency, deltaIncomeCurrency, deltaLocusControl map[string]float32, deltaCurrency *float32, addCitizenIDs, removeCitizenIDs []int32) error {
+// expectedVersion不为nil且与当前版本号不一致时返回ErrVersionConflict，且不修改任何状态
+// 返回：更新后的版本号
+func (e *EconomySim) DeltaUpdateNBS(nbsID int32, deltaNominalGDP, deltaRealGDP, deltaUnemployment, deltaWages, deltaPrices, deltaWorkingHours, deltaDepression, deltaConsumptionCurrency, deltaIncomeCurrency, deltaLocusControl map[string]float32, deltaCurrency *float32, addCitizenIDs, removeCitizenIDs []int32, expectedVersion *int64) (int64, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	nbs, exists := e.nbs[nbsID]
 	if !exists {
-		return fmt.Errorf("NBS %d not found", nbsID)
+		return 0, fmt.Errorf("NBS %d not found", nbsID)
+	}
+	if err := checkVersion("NBS", nbsID, nbs.GetVersion(), expectedVersion); err != nil {
+		return 0, err
 	}
 
 	// 更新时间序列数据
@@ -869,19 +1228,25 @@ func (e *EconomySim) DeltaUpdateNBS(nbsID int32, deltaNominalGDP, deltaRealGDP,
 		}
 
 		nbs.GetBase().CitizenIds = newCitizenIDs
+		nbs.version++
 	}
 
-	return nil
+	return nbs.GetVersion(), nil
 }
 
 // DeltaUpdateGovernment 增量更新政府
-func (e *EconomySim) DeltaUpdateGovernment(govID int32, deltaBracketCutoffs, deltaBracketRates []float32, deltaCurrency *float32, addCitizenIDs, removeCitizenIDs []int32) error {
+// expectedVersion不为nil且与当前版本号不一致时返回ErrVersionConflict，且不修改任何状态
+// 返回：更新后的版本号
+func (e *EconomySim) DeltaUpdateGovernment(govID int32, deltaBracketCutoffs, deltaBracketRates []float32, deltaCurrency *float32, addCitizenIDs, removeCitizenIDs []int32, expectedVersion *int64) (int64, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	gov, exists := e.govs[govID]
 	if !exists {
-		return fmt.Errorf("government %d not found", govID)
+		return 0, fmt.Errorf("government %d not found", govID)
+	}
+	if err := checkVersion("government", govID, gov.GetVersion(), expectedVersion); err != nil {
+		return 0, err
 	}
 
 	if deltaBracketCutoffs != nil {
@@ -937,19 +1302,25 @@ func (e *EconomySim) DeltaUpdateGovernment(govID int32, deltaBracketCutoffs, del
 		}
 
 		gov.GetBase().CitizenIds = newCitizenIDs
+		gov.version++
 	}
 
-	return nil
+	return gov.GetVersion(), nil
 }
 
 // DeltaUpdateBank 增量更新银行
-func (e *EconomySim) DeltaUpdateBank(bankID int32, deltaInterestRate, deltaCurrency *float32, addCitizenIDs, removeCitizenIDs []int32) error {
+// expectedVersion不为nil且与当前版本号不一致时返回ErrVersionConflict，且不修改任何状态
+// 返回：更新后的版本号
+func (e *EconomySim) DeltaUpdateBank(bankID int32, deltaInterestRate, deltaCurrency *float32, addCitizenIDs, removeCitizenIDs []int32, expectedVersion *int64) (int64, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	bank, exists := e.banks[bankID]
 	if !exists {
-		return fmt.Errorf("bank %d not found", bankID)
+		return 0, fmt.Errorf("bank %d not found", bankID)
+	}
+	if err := checkVersion("bank", bankID, bank.GetVersion(), expectedVersion); err != nil {
+		return 0, err
 	}
 
 	if deltaInterestRate != nil {
@@ -989,21 +1360,58 @@ func (e *EconomySim) DeltaUpdateBank(bankID int32, deltaInterestRate, deltaCurre
 		}
 
 		bank.GetBase().CitizenIds = newCitizenIDs
+		bank.version++
 	}
 
-	return nil
+	return bank.GetVersion(), nil
 }
 
 // DeltaUpdateAgent 增量更新代理
-func (e *EconomySim) DeltaUpdateAgent(update *economyv2.AgentDeltaUpdate) error {
+// expectedVersion不为nil且与代理当前版本号不一致时返回ErrVersionConflict，且不修改任何状态
+// 返回：更新后的版本号
+func (e *EconomySim) DeltaUpdateAgent(update *economyv2.AgentDeltaUpdate, expectedVersion *int64) (int64, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	agent, exists := e.agents[update.AgentId]
 	if !exists {
-		return fmt.Errorf("agent %d not found", update.AgentId)
+		return 0, fmt.Errorf("agent %d not found", update.AgentId)
+	}
+	if err := checkVersion("agent", update.AgentId, agent.GetVersion(), expectedVersion); err != nil {
+		return 0, err
+	}
+	deltaUpdateAgent(agent, update)
+	return agent.GetVersion(), nil
+}
+
+// DeltaUpdateAgents 批量增量更新代理（全部成功或全部不生效）
+// 功能：在一次加锁操作中先检查全部代理存在且版本号与期望一致，再依次执行更新
+// 参数：expectedVersions-代理ID->期望的版本号（更新前），不在其中的代理不检查版本
+// 返回：代理ID->更新后的版本号；检查失败时返回错误（版本号不一致时为ErrVersionConflict），且不修改任何状态
+func (e *EconomySim) DeltaUpdateAgents(req *economyv2.DeltaUpdateAgentRequest, expectedVersions map[int32]int64) (map[int32]int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, update := range req.Updates {
+		agent, exists := e.agents[update.AgentId]
+		if !exists {
+			return nil, fmt.Errorf("agent %d not found", update.AgentId)
+		}
+		if err := checkVersion("agent", update.AgentId, agent.GetVersion(), expectedVersionOf(expectedVersions, update.AgentId)); err != nil {
+			return nil, err
+		}
+	}
+	versions := make(map[int32]int64, len(req.Updates))
+	for _, update := range req.Updates {
+		agent := e.agents[update.AgentId]
+		deltaUpdateAgent(agent, update)
+		versions[update.AgentId] = agent.GetVersion()
 	}
+	return versions, nil
+}
 
+// deltaUpdateAgent 执行代理的增量更新（调用方需持有锁）
+func deltaUpdateAgent(agent *Agent, update *economyv2.AgentDeltaUpdate) {
 	if update.DeltaCurrency != nil {
 		agent.SetCurrency(agent.GetCurrency() + *update.DeltaCurrency)
 	}
@@ -1038,8 +1446,76 @@ func (e *EconomySim) DeltaUpdateAgent(update *economyv2.AgentDeltaUpdate) error
 		newIncome := currentIncome + *update.DeltaIncome
 		agent.SetIncome(&newIncome)
 	}
+}
 
-	return nil
+// EntityIDs 各类经济实体的ID
+type EntityIDs struct {
+	Firms       []int32
+	Agents      []int32
+	NBS         []int32
+	Governments []int32
+	Banks       []int32
+}
+
+// EntityVersions 各类经济实体的版本号（实体ID->版本号）
+type EntityVersions struct {
+	Firms       map[int32]int64
+	Agents      map[int32]int64
+	NBS         map[int32]int64
+	Governments map[int32]int64
+	Banks       map[int32]int64
+}
+
+// GetVersions 获取各类经济实体的当前版本号（在一次加锁操作中读取）
+// 返回：任一实体不存在时返回错误
+func (e *EconomySim) GetVersions(ids EntityIDs) (EntityVersions, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var versions EntityVersions
+	var err error
+	if versions.Firms, err = versionsOf("firm", e.firms, ids.Firms); err != nil {
+		return EntityVersions{}, err
+	}
+	if versions.Agents, err = versionsOf("agent", e.agents, ids.Agents); err != nil {
+		return EntityVersions{}, err
+	}
+	if versions.NBS, err = versionsOf("NBS", e.nbs, ids.NBS); err != nil {
+		return EntityVersions{}, err
+	}
+	if versions.Governments, err = versionsOf("government", e.govs, ids.Governments); err != nil {
+		return EntityVersions{}, err
+	}
+	if versions.Banks, err = versionsOf("bank", e.banks, ids.Banks); err != nil {
+		return EntityVersions{}, err
+	}
+	return versions, nil
+}
+
+// CalculateInflation 计算两个时间点之间的通胀率
+// 返回：prices[toT]/prices[fromT]-1，任一时间点不存在或fromT的价格为0时返回错误
+func (e *EconomySim) CalculateInflation(nbsID int32, fromT, toT string) (float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	nbs, exists := e.nbs[nbsID]
+	if !exists {
+		return 0, fmt.Errorf("NBS %d not found", nbsID)
+	}
+
+	prices := nbs.GetPrices()
+	fromPrice, ok := prices[fromT]
+	if !ok {
+		return 0, fmt.Errorf("price at %s not found in NBS %d", fromT, nbsID)
+	}
+	toPrice, ok := prices[toT]
+	if !ok {
+		return 0, fmt.Errorf("price at %s not found in NBS %d", toT, nbsID)
+	}
+	if fromPrice == 0 {
+		return 0, fmt.Errorf("price at %s is zero in NBS %d", fromT, nbsID)
+	}
+	return toPrice/fromPrice - 1, nil
 }
 
 // CalculateRealGDP 计算实际GDP
@@ -0,0 +1,616 @@
+package ecosim
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"connectrpc.com/connect"
+	economyv2 "git.fiblab.net/sim/protos/v2/go/city/economy/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
+)
+
+// newTestServer 创建包含给定ID企业的服务器
+func newTestServer(t *testing.T, firmIDs ...int32) *Server {
+	s := NewServer()
+	for _, id := range firmIDs {
+		assert.NoError(t, s.econ.AddFirm(&economyv2.Firm{Id: id}))
+	}
+	return s
+}
+
+func TestGetFirmIDsSorted(t *testing.T) {
+	s := newTestServer(t, 5, 3, 9, 1, 7)
+	assert.Equal(t, []int32{1, 3, 5, 7, 9}, s.econ.GetFirmIDs())
+	assert.Empty(t, NewEconomySim().GetFirmIDs())
+}
+
+func TestListFirmsStable(t *testing.T) {
+	ids := []int32{42, 7, 19, 3, 88, 61, 25}
+	firmIDs := func(s *Server) []int32 {
+		res, err := s.ListFirms(context.Background(), connect.NewRequest(&economyv2.ListFirmsRequest{}))
+		assert.NoError(t, err)
+		var ids []int32
+		for _, f := range res.Msg.Firms {
+			ids = append(ids, f.Id)
+		}
+		return ids
+	}
+	want := []int32{3, 7, 19, 25, 42, 61, 88}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, want, firmIDs(newTestServer(t, ids...)))
+	}
+}
+
+// newTestBankEconomy 创建包含一家银行与一个代理的经济系统
+func newTestBankEconomy(t *testing.T, bankCurrency, agentCurrency, rate float32) *EconomySim {
+	e := NewEconomySim()
+	assert.NoError(t, e.AddBank(&economyv2.Bank{Id: 1, Currency: bankCurrency, InterestRate: rate}))
+	assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: 10, Currency: &agentCurrency}))
+	return e
+}
+
+func TestCompoundInterest(t *testing.T) {
+	simple, _, err := newTestBankEconomy(t, 1e6, 1000, 0.12).CalculateInterest(1, []int32{10}, 1)
+	assert.NoError(t, err)
+	assert.InDelta(t, 120, simple, 1e-3)
+
+	e := newTestBankEconomy(t, 1e6, 1000, 0.12)
+	compound, currencies, err := e.CalculateInterest(1, []int32{10}, 12)
+	assert.NoError(t, err)
+	assert.Greater(t, compound, simple)
+	assert.InDelta(t, 126.825, compound, 1e-2)
+	assert.InDelta(t, 1000+compound, currencies[0], 1e-3)
+	bank, _ := e.GetBank(1)
+	assert.InDelta(t, 1e6-compound, bank.GetCurrency(), 1e-1)
+
+	// 服务器按调用方给出的子周期数计算复利
+	s := &Server{econ: newTestBankEconomy(t, 1e6, 1000, 0.12)}
+	viaServer, _, err := s.CalculateCompoundInterest(context.Background(), 1, []int32{10}, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, compound, viaServer)
+}
+
+func TestCompoundInterestInsolvent(t *testing.T) {
+	// 银行货币足以支付单利但不足以支付复利
+	e := newTestBankEconomy(t, 125, 1000, 0.12)
+	_, _, err := e.CalculateInterest(1, []int32{10}, 12)
+	assert.Error(t, err)
+	agent, _ := e.GetAgent(10)
+	assert.Equal(t, float32(1000), agent.GetCurrency())
+	_, _, err = e.CalculateInterest(1, []int32{10}, 1)
+	assert.NoError(t, err)
+}
+
+func TestSavingsInterest(t *testing.T) {
+	e := newTestBankEconomy(t, 1e4, 500, 0.1)
+	agent, _ := e.GetAgent(10)
+	bank, _ := e.GetBank(1)
+
+	assert.Error(t, e.Deposit(1, 10, 600))
+	assert.Error(t, e.Deposit(1, 10, -1))
+	assert.NoError(t, e.Deposit(1, 10, 300))
+	assert.Equal(t, float32(200), agent.GetCurrency())
+
+	// 只有储蓄计息
+	interest, savings, err := e.CalculateSavingsInterest(1, []int32{10}, 1)
+	assert.NoError(t, err)
+	assert.InDelta(t, 30, interest, 1e-4)
+	assert.InDelta(t, 330, savings[0], 1e-4)
+	assert.Equal(t, float32(200), agent.GetCurrency())
+	assert.InDelta(t, 330, bank.GetSavings(10), 1e-4)
+
+	// CalculateInterest保持按货币计息的语义，不涉及储蓄
+	interest, _, err = e.CalculateInterest(1, []int32{10}, 1)
+	assert.NoError(t, err)
+	assert.InDelta(t, 20, interest, 1e-4)
+	assert.InDelta(t, 220, agent.GetCurrency(), 1e-4)
+	assert.InDelta(t, 330, bank.GetSavings(10), 1e-4)
+
+	assert.Error(t, e.Withdraw(1, 10, 400))
+	assert.NoError(t, e.Withdraw(1, 10, 330))
+	assert.InDelta(t, 550, agent.GetCurrency(), 1e-4)
+	assert.InDelta(t, 0, bank.GetSavings(10), 1e-4)
+}
+
+func TestSaveLoadSavings(t *testing.T) {
+	e := newTestBankEconomy(t, 1e4, 500, 0.1)
+	assert.NoError(t, e.Deposit(1, 10, 300))
+	path := filepath.Join(t.TempDir(), "entities.pb")
+	assert.NoError(t, e.SaveEntities(path))
+
+	loaded := NewEconomySim()
+	assert.NoError(t, loaded.LoadEntities(path))
+	bank, _ := loaded.GetBank(1)
+	assert.Equal(t, float32(300), bank.GetSavings(10))
+	agent, _ := loaded.GetAgent(10)
+	assert.Equal(t, float32(200), agent.GetCurrency())
+
+	// 没有储蓄文件的旧状态文件仍可加载
+	assert.NoError(t, os.Remove(savingsFilePath(path)))
+	assert.NoError(t, loaded.LoadEntities(path))
+	bank, _ = loaded.GetBank(1)
+	assert.Zero(t, bank.GetSavings(10))
+}
+
+// newTestFirmEconomy 创建一个雇佣了代理1、2、3的企业，其中代理3已跳槽到企业2
+func newTestFirmEconomy(t *testing.T) *EconomySim {
+	e := NewEconomySim()
+	firmID, otherID := int32(1), int32(2)
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: firmID, Currency: 100, Inventory: 50, Employees: []int32{1, 2, 3}}))
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: otherID}))
+	for _, id := range []int32{1, 2} {
+		assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: id, FirmId: &firmID}))
+	}
+	assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: 3, FirmId: &otherID}))
+	return e
+}
+
+func TestProcessBankruptcy(t *testing.T) {
+	e := newTestFirmEconomy(t)
+	firm, _ := e.GetFirm(1)
+	laidOff, err := e.ProcessBankruptcy(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{1, 2, 3}, laidOff)
+	assert.Empty(t, firm.GetEmployees())
+	assert.Zero(t, firm.GetInventory())
+	assert.Equal(t, []int32{2}, e.GetFirmIDs())
+	for _, id := range []int32{1, 2} {
+		agent, _ := e.GetAgent(id)
+		assert.Nil(t, agent.GetFirmID())
+	}
+	agent, _ := e.GetAgent(3)
+	assert.Equal(t, int32(2), *agent.GetFirmID())
+
+	_, err = e.ProcessBankruptcy(1)
+	assert.Error(t, err)
+}
+
+func TestDeltaUpdateFirmBankruptcy(t *testing.T) {
+	e := newTestFirmEconomy(t)
+	wages := float32(-50)
+	// 未开启破产检查时货币量可以为负
+	for i := 0; i < 3; i++ {
+		_, err := e.DeltaUpdateFirm(1, nil, nil, &wages, nil, nil, nil, nil, false, nil)
+		assert.NoError(t, err)
+	}
+	firm, err := e.GetFirm(1)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(-50), firm.GetCurrency())
+
+	// 开启破产检查后企业被移除
+	zero := float32(0)
+	_, err = e.DeltaUpdateFirm(1, nil, nil, &zero, nil, nil, nil, nil, true, nil)
+	assert.NoError(t, err)
+	_, err = e.GetFirm(1)
+	assert.Error(t, err)
+	agent, _ := e.GetAgent(1)
+	assert.Nil(t, agent.GetFirmID())
+}
+
+func TestServerProcessBankruptcy(t *testing.T) {
+	s := newTestServer(t, 1, 2)
+	laidOff, err := s.ProcessBankruptcy(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Empty(t, laidOff)
+	assert.Equal(t, []int32{2}, s.econ.GetFirmIDs())
+
+	_, err = s.ProcessBankruptcy(context.Background(), 1)
+	assert.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+}
+
+func TestComputeMarketClearingPrice(t *testing.T) {
+	e := NewEconomySim()
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 1, Price: 10, Inventory: 100}))
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 2, Price: 20, Inventory: 100}))
+	// 参考价格为15，需求D(p)=1500/p；p<=20时供给S(p)=100*min(p/10,1)+5p
+	for _, c := range []struct {
+		demand    float32
+		wantPrice float64
+	}{
+		{100, 10},           // 1500/p=100+5p
+		{60, math.Sqrt(60)}, // 900/p=10p+5p
+		{300, 22.5},         // 供不应求，4500/p=200
+	} {
+		price, err := e.ComputeMarketClearingPrice([]int32{1, 2}, c.demand)
+		assert.NoError(t, err)
+		assert.InDelta(t, c.wantPrice, price, 1e-3)
+		p := float64(price)
+		supply := marketSupply(100, 10, p) + marketSupply(100, 20, p)
+		assert.InDelta(t, float64(c.demand)*15/p, supply, 1e-2)
+	}
+
+	// 不修改企业状态
+	firm, _ := e.GetFirm(1)
+	assert.Equal(t, float32(10), firm.GetPrice())
+	assert.Equal(t, int32(100), firm.GetInventory())
+
+	_, err := e.ComputeMarketClearingPrice([]int32{1, 3}, 100)
+	assert.Error(t, err)
+	_, err = e.ComputeMarketClearingPrice(nil, 100)
+	assert.Error(t, err)
+}
+
+func TestCalculateInflation(t *testing.T) {
+	s := NewServer()
+	assert.NoError(t, s.econ.AddNBS(&economyv2.NBS{
+		Id:     1,
+		Prices: map[string]float32{"2024-01": 100, "2024-02": 110, "2024-03": 0},
+	}))
+	inflation, err := s.CalculateInflation(context.Background(), 1, "2024-01", "2024-02")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.1, inflation, 1e-6)
+
+	_, err = s.CalculateInflation(context.Background(), 1, "2024-01", "2024-04")
+	assert.Error(t, err)
+	_, err = s.CalculateInflation(context.Background(), 1, "2024-03", "2024-02")
+	assert.Error(t, err)
+	_, err = s.CalculateInflation(context.Background(), 2, "2024-01", "2024-02")
+	assert.Error(t, err)
+}
+
+func TestPayWages(t *testing.T) {
+	s := newTestServer(t)
+	e := s.econ
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 1, Currency: 100}))
+	for _, id := range []int32{10, 11} {
+		currency := float32(5)
+		assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: id, Currency: &currency}))
+	}
+	firm, _ := e.GetFirm(1)
+	agentCurrency := func(id int32) float32 {
+		agent, _ := e.GetAgent(id)
+		return agent.GetCurrency()
+	}
+	ctx := context.Background()
+
+	// 代理不存在或企业货币不足时不修改任何状态
+	assert.Error(t, s.PayWages(ctx, 1, map[int32]float32{10: 30, 12: 30}))
+	assert.Error(t, s.PayWages(ctx, 1, map[int32]float32{10: 60, 11: 60}))
+	assert.Error(t, s.PayWages(ctx, 1, map[int32]float32{10: -10}))
+	assert.Equal(t, float32(100), firm.GetCurrency())
+	assert.Equal(t, float32(5), agentCurrency(10))
+	assert.Equal(t, float32(5), agentCurrency(11))
+
+	assert.NoError(t, s.PayWages(ctx, 1, map[int32]float32{10: 30, 11: 50}))
+	assert.Equal(t, float32(20), firm.GetCurrency())
+	assert.Equal(t, float32(35), agentCurrency(10))
+	assert.Equal(t, float32(55), agentCurrency(11))
+}
+
+func TestPreviewConsumption(t *testing.T) {
+	s := newTestServer(t)
+	e := s.econ
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 1, Price: 2, Inventory: 10}))
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 2, Price: 5, Inventory: 3}))
+	currency := float32(30)
+	assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: 10, Currency: &currency}))
+
+	// 企业2库存不足，只能售出3件
+	total, success, breakdown, err := s.PreviewConsumption(context.Background(), []int32{1, 2}, 10, []int32{4, 5})
+	assert.NoError(t, err)
+	assert.False(t, success)
+	assert.Equal(t, float32(23), total)
+	assert.Equal(t, []FirmSale{{FirmID: 1, Sales: 4, Cost: 8}, {FirmID: 2, Sales: 3, Cost: 15}}, breakdown)
+
+	// 试算不修改库存与货币
+	firm1, _ := e.GetFirm(1)
+	firm2, _ := e.GetFirm(2)
+	agent, _ := e.GetAgent(10)
+	assert.Equal(t, int32(10), firm1.GetInventory())
+	assert.Equal(t, int32(3), firm2.GetInventory())
+	assert.Equal(t, float32(30), agent.GetCurrency())
+
+	// 实际消费的销售明细与试算一致
+	_, _, sales, err := e.CalculateConsumption([]int32{1, 2}, 10, []int32{4, 5}, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, breakdown, sales)
+	assert.Equal(t, int32(6), firm1.GetInventory())
+	assert.Equal(t, int32(0), firm2.GetInventory())
+	assert.Equal(t, float32(7), agent.GetCurrency())
+}
+
+func TestGetEmploymentStats(t *testing.T) {
+	s := newTestServer(t)
+	e := s.econ
+	// 代理2同时受雇于两家企业，代理99不存在
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 1, Employees: []int32{1, 2}}))
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 2, Employees: []int32{2, 3, 99}}))
+	for id := int32(1); id <= 5; id++ {
+		assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: id}))
+	}
+	employed, unemployed, err := s.GetEmploymentStats(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), employed)
+	assert.Equal(t, int32(2), unemployed)
+}
+
+func TestInitializeEconomy(t *testing.T) {
+	s := newTestServer(t, 1)
+	ctx := context.Background()
+	entities := &economyv2.EconomyEntities{
+		Firms:       []*economyv2.Firm{{Id: 2}, {Id: 3}},
+		Agents:      []*economyv2.Agent{{Id: 10}, {Id: 11}},
+		Nbs:         []*economyv2.NBS{{Id: 20}},
+		Governments: []*economyv2.Government{{Id: 30}},
+		Banks:       []*economyv2.Bank{{Id: 40}},
+	}
+
+	// 与已有企业ID重复时不添加任何实体
+	entities.Firms = append(entities.Firms, &economyv2.Firm{Id: 1})
+	assert.Error(t, s.InitializeEconomy(ctx, entities))
+	assert.Equal(t, []int32{1}, s.econ.GetFirmIDs())
+	_, err := s.econ.GetAgent(10)
+	assert.Error(t, err)
+
+	// 同批实体之间ID重复
+	entities.Firms[2] = &economyv2.Firm{Id: 2}
+	assert.Error(t, s.InitializeEconomy(ctx, entities))
+
+	entities.Firms = entities.Firms[:2]
+	assert.NoError(t, s.InitializeEconomy(ctx, entities))
+	assert.Equal(t, []int32{1, 2, 3}, s.econ.GetFirmIDs())
+	assert.Equal(t, []int32{20}, s.econ.GetNBSIDs())
+	assert.Equal(t, []int32{30}, s.econ.GetGovernmentIDs())
+	assert.Equal(t, []int32{40}, s.econ.GetBankIDs())
+	for _, id := range []int32{10, 11} {
+		_, err := s.econ.GetAgent(id)
+		assert.NoError(t, err)
+	}
+}
+
+func TestProduceGoods(t *testing.T) {
+	s := newTestServer(t)
+	e := s.econ
+	// 代理99不存在，不计入员工
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 1, Currency: 100, Inventory: 5, Employees: []int32{10, 11, 99}}))
+	for _, id := range []int32{10, 11} {
+		skill := float32(2)
+		assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: id, Skill: &skill}))
+	}
+	firm, _ := e.GetFirm(1)
+	agent, _ := e.GetAgent(10)
+	ctx := context.Background()
+
+	// 无劳动投入时不生产
+	produced, err := s.ProduceGoods(ctx, 1, 0)
+	assert.NoError(t, err)
+	assert.Zero(t, produced)
+	assert.Equal(t, int32(5), firm.GetInventory())
+	assert.Equal(t, float32(100), firm.GetCurrency())
+
+	// 2名员工各投入8单位劳动，产量16，工资共32
+	produced, err = s.ProduceGoods(ctx, 1, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(16), produced)
+	assert.Equal(t, int32(21), firm.GetInventory())
+	assert.Equal(t, float32(68), firm.GetCurrency())
+	assert.Equal(t, float32(16), agent.GetCurrency())
+
+	// 企业货币不足或劳动投入为负时不修改任何状态
+	_, err = s.ProduceGoods(ctx, 1, 20)
+	assert.Error(t, err)
+	_, err = s.ProduceGoods(ctx, 1, -1)
+	assert.Error(t, err)
+	_, err = s.ProduceGoods(ctx, 2, 1)
+	assert.Error(t, err)
+	assert.Equal(t, int32(21), firm.GetInventory())
+	assert.Equal(t, float32(68), firm.GetCurrency())
+}
+
+func TestComputeCPI(t *testing.T) {
+	s := newTestServer(t)
+	e := s.econ
+	for id := int32(1); id <= 3; id++ {
+		assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: id, Price: 10}))
+	}
+	assert.NoError(t, e.AddNBS(&economyv2.NBS{Id: 20}))
+	ctx := context.Background()
+	basket, weights := []int32{1, 2, 3}, []float32{0.5, 0.3, 0.2}
+
+	// 未设置基期
+	_, err := s.ComputeCPI(ctx, 20, basket, weights)
+	assert.Error(t, err)
+	assert.NoError(t, s.SetCPIBase(ctx, 20, basket))
+	cpi, err := s.ComputeCPI(ctx, 20, basket, weights)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1, cpi, 1e-6)
+
+	// 企业2价格翻倍，CPI上升其权重0.3
+	firm, _ := e.GetFirm(2)
+	firm.SetPrice(20)
+	cpi, err = s.ComputeCPI(ctx, 20, basket, weights)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.3, cpi, 1e-6)
+
+	// 更新统计局时保留基期
+	assert.NoError(t, e.UpdateNBS(&economyv2.NBS{Id: 20}))
+	_, err = s.ComputeCPI(ctx, 20, basket, weights)
+	assert.NoError(t, err)
+
+	// 权重之和不为1、长度不匹配或NBS不存在
+	_, err = s.ComputeCPI(ctx, 20, basket, []float32{0.5, 0.3, 0.3})
+	assert.Error(t, err)
+	_, err = s.ComputeCPI(ctx, 20, basket, []float32{0.5, 0.5})
+	assert.Error(t, err)
+	_, err = s.ComputeCPI(ctx, 21, basket, weights)
+	assert.Error(t, err)
+}
+
+func TestDeltaUpdateVersion(t *testing.T) {
+	s := newTestServer(t, 1)
+	e := s.econ
+	currency := float32(10)
+	assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: 10, Currency: &currency}))
+	ctx := context.Background()
+
+	// 两个客户端读取到相同版本号后先后更新企业，后到的更新被拒绝
+	delta := float32(5)
+	initial, err := s.GetVersions(ctx, EntityIDs{Firms: []int32{1}, Agents: []int32{10}})
+	assert.NoError(t, err)
+	stale := initial.Firms[1]
+	agentVersions := initial.Agents
+	v, err := e.DeltaUpdateFirm(1, nil, nil, &delta, nil, nil, nil, nil, false, &stale)
+	assert.NoError(t, err)
+	assert.Greater(t, v, stale)
+	_, err = e.DeltaUpdateFirm(1, nil, nil, &delta, nil, nil, nil, nil, false, &stale)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	firm, _ := e.GetFirm(1)
+	assert.Equal(t, float32(5), firm.GetCurrency())
+	v2, err := e.DeltaUpdateFirm(1, nil, nil, &delta, nil, nil, nil, nil, false, &v)
+	assert.NoError(t, err)
+	assert.Greater(t, v2, v)
+
+	// 其他操作修改实体后版本号同样递增
+	assert.NoError(t, e.UpdateFirm(&economyv2.Firm{Id: 1}))
+	_, err = e.DeltaUpdateFirm(1, nil, nil, &delta, nil, nil, nil, nil, false, &v2)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	// 代理：过期版本返回CodeAborted，当前版本更新成功并返回新版本号
+	req := &economyv2.DeltaUpdateAgentRequest{Updates: []*economyv2.AgentDeltaUpdate{{AgentId: 10, DeltaCurrency: &delta}}}
+	versions, err := s.DeltaUpdateAgentWithVersion(ctx, req, agentVersions)
+	assert.NoError(t, err)
+	assert.Greater(t, versions[10], agentVersions[10])
+	_, err = s.DeltaUpdateAgentWithVersion(ctx, req, agentVersions)
+	assert.Equal(t, connect.CodeAborted, connect.CodeOf(err))
+	agent, _ := e.GetAgent(10)
+	assert.Equal(t, float32(15), agent.GetCurrency())
+	_, err = s.DeltaUpdateAgentWithVersion(ctx, req, versions)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(20), agent.GetCurrency())
+}
+
+func TestDeltaUpdateVersionAtomic(t *testing.T) {
+	s := newTestServer(t, 1, 2)
+	e := s.econ
+	assert.NoError(t, e.AddBank(&economyv2.Bank{Id: 20}))
+	ctx := context.Background()
+	versions, err := s.GetVersions(ctx, EntityIDs{Firms: []int32{1, 2}, Banks: []int32{20}})
+	assert.NoError(t, err)
+
+	// 企业2的版本号已过期：整批更新被拒绝，企业1也不被更新
+	delta := float32(5)
+	_, err = e.DeltaUpdateFirm(2, nil, nil, &delta, nil, nil, nil, nil, false, nil)
+	assert.NoError(t, err)
+	req := &economyv2.DeltaUpdateFirmRequest{Updates: []*economyv2.FirmDeltaUpdate{
+		{FirmId: 1, DeltaCurrency: &delta},
+		{FirmId: 2, DeltaCurrency: &delta},
+	}}
+	_, err = s.DeltaUpdateFirmWithVersion(ctx, req, versions.Firms)
+	assert.Equal(t, connect.CodeAborted, connect.CodeOf(err))
+	firm1, _ := e.GetFirm(1)
+	assert.Zero(t, firm1.GetCurrency())
+	assert.Equal(t, versions.Firms[1], firm1.GetVersion())
+
+	// 银行同样支持版本检查
+	bankReq := &economyv2.DeltaUpdateBankRequest{BankId: 20, DeltaCurrency: &delta}
+	v, err := s.DeltaUpdateBankWithVersion(ctx, bankReq, versions.Banks[20])
+	assert.NoError(t, err)
+	assert.Greater(t, v, versions.Banks[20])
+	_, err = s.DeltaUpdateBankWithVersion(ctx, bankReq, versions.Banks[20])
+	assert.Equal(t, connect.CodeAborted, connect.CodeOf(err))
+
+	_, err = s.GetVersions(ctx, EntityIDs{NBS: []int32{30}})
+	assert.Error(t, err)
+}
+
+func TestDeltaUpdateAgentPartial(t *testing.T) {
+	s := newTestServer(t)
+	e := s.econ
+	for _, id := range []int32{10, 11} {
+		assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: id}))
+	}
+	ctx := context.Background()
+	delta := float32(5)
+	req := &economyv2.DeltaUpdateAgentRequest{Updates: []*economyv2.AgentDeltaUpdate{
+		{AgentId: 10, DeltaCurrency: &delta},
+		{AgentId: 99, DeltaCurrency: &delta},
+		{AgentId: 11, DeltaCurrency: &delta},
+	}}
+	currency := func(id int32) float32 {
+		agent, _ := e.GetAgent(id)
+		return agent.GetCurrency()
+	}
+
+	// 非partial模式在代理99处中止，代理11未更新
+	_, err := s.DeltaUpdateAgentPartial(ctx, req, false)
+	assert.Error(t, err)
+	assert.Equal(t, float32(5), currency(10))
+	assert.Zero(t, currency(11))
+
+	// partial模式更新其余代理并只报告代理99失败
+	statuses, err := s.DeltaUpdateAgentPartial(ctx, req, true)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 3)
+	assert.Equal(t, []int32{10, 99, 11}, []int32{statuses[0].ID, statuses[1].ID, statuses[2].ID})
+	assert.Empty(t, statuses[0].Error)
+	assert.Contains(t, statuses[1].Error, "agent 99 not found")
+	assert.Empty(t, statuses[2].Error)
+	assert.Equal(t, float32(10), currency(10))
+	assert.Equal(t, float32(5), currency(11))
+}
+
+func TestTick(t *testing.T) {
+	e := NewEconomySim()
+	// 企业1供不应求，企业2供过于求
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 1, Price: 10, Demand: 100, Inventory: 10, Employees: []int32{10}}))
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 2, Price: 10, Demand: 10, Inventory: 100}))
+	assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: 10}))
+	assert.NoError(t, e.AddAgent(&economyv2.Agent{Id: 11}))
+	assert.NoError(t, e.AddBank(&economyv2.Bank{Id: 20, Currency: 1000, InterestRate: 0.1}))
+	assert.NoError(t, e.AddNBS(&economyv2.NBS{Id: 30}))
+	savings := float32(100)
+	agent, _ := e.GetAgent(11)
+	agent.SetCurrency(savings)
+	assert.NoError(t, e.Deposit(20, 11, savings))
+	firm1, _ := e.GetFirm(1)
+	firm2, _ := e.GetFirm(2)
+	bank, _ := e.GetBank(20)
+
+	// 未启用时不推进
+	e.Tick(1)
+	assert.Equal(t, float32(10), firm1.GetPrice())
+
+	e.SetTickConfig(config.EconomyTick{Enable: true, PriceAdjustment: 0.1, InterestInterval: 2})
+	last1, last2 := firm1.GetPrice(), firm2.GetPrice()
+	for step := 1; step <= 4; step++ {
+		if step > 1 {
+			// 需求量为累计值，每步按新增的需求量调整价格
+			firm1.SetDemand(firm1.GetDemand() + 100)
+			firm2.SetDemand(firm2.GetDemand() + 10)
+		}
+		e.Tick(float64(step))
+		assert.Greater(t, firm1.GetPrice(), last1)
+		assert.Less(t, firm2.GetPrice(), last2)
+		last1, last2 = firm1.GetPrice(), firm2.GetPrice()
+	}
+	// 每2步计息一次
+	assert.InDelta(t, 121, bank.GetSavings(11), 1e-3)
+
+	// 统计局按时间记录平均价格与失业率
+	nbs, _ := e.GetNBS(30)
+	assert.Len(t, nbs.GetPrices(), 4)
+	assert.InDelta(t, (last1+last2)/2, nbs.GetPrices()["4"], 1e-4)
+	assert.Equal(t, float32(0.5), nbs.GetUnemployment()["4"])
+
+	// 没有新增需求时供过于求，价格下降
+	e.Tick(5)
+	assert.Less(t, firm1.GetPrice(), last1)
+}
+
+func TestTickRecordWindow(t *testing.T) {
+	e := NewEconomySim()
+	assert.NoError(t, e.AddFirm(&economyv2.Firm{Id: 1, Price: 10}))
+	assert.NoError(t, e.AddNBS(&economyv2.NBS{Id: 30, Prices: map[string]float32{"base": 10}}))
+	e.SetTickConfig(config.EconomyTick{Enable: true, RecordInterval: 2, RecordWindow: 3})
+	for step := 1; step <= 10; step++ {
+		e.Tick(float64(step))
+	}
+	// 每2步记录一次，只保留最近3条，其他来源的记录不受影响
+	nbs, _ := e.GetNBS(30)
+	assert.Equal(t, map[string]float32{"base": 10, "6": 10, "8": 10, "10": 10}, nbs.GetPrices())
+	assert.Len(t, nbs.GetUnemployment(), 3)
+}
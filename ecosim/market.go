@@ -0,0 +1,83 @@
+package ecosim
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	marketClearingIterations = 100  // 二分法的最大迭代次数
+	marketClearingTolerance  = 1e-6 // 二分法的相对价格精度
+)
+
+// marketSupply 企业在给定价格下的供给
+// 企业以当前价格为保留价格：市场价格不低于保留价格时供给全部库存，低于时按价格比例减少供给
+func marketSupply(inventory, reservationPrice, price float64) float64 {
+	return inventory * math.Min(price/reservationPrice, 1)
+}
+
+// ComputeMarketClearingPrice 计算多家企业商品市场的出清价格
+// 功能：在不修改任何状态的情况下，求使总供给等于总需求的价格
+// 参数：firmIDs-参与市场的企业ID，totalDemand-以企业当前价格的库存加权平均值为价格时的总需求量
+// 返回：出清价格
+// 算法说明：
+// 1. 供给：各企业供给之和（见marketSupply），随价格单调递增
+// 2. 需求：单位弹性的向下倾斜需求曲线D(p)=totalDemand*pRef/p，pRef为企业当前价格的库存加权平均值
+// 3. 在[最低企业价格的千分之一, 最高企业价格]上二分求解超额需求的零点，
+// 最高企业价格下仍供不应求时倍增上界
+func (e *EconomySim) ComputeMarketClearingPrice(firmIDs []int32, totalDemand float32) (float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(firmIDs) == 0 {
+		return 0, fmt.Errorf("no firm in the market")
+	}
+	if totalDemand <= 0 {
+		return 0, fmt.Errorf("invalid total demand %f", totalDemand)
+	}
+	inventories := make([]float64, len(firmIDs))
+	prices := make([]float64, len(firmIDs))
+	var totalInventory, weightedPrice float64
+	minPrice, maxPrice := math.Inf(1), 0.
+	for i, firmID := range firmIDs {
+		firm, exists := e.firms[firmID]
+		if !exists {
+			return 0, fmt.Errorf("firm %d not found", firmID)
+		}
+		inventories[i] = math.Max(float64(firm.GetInventory()), 0)
+		prices[i] = float64(firm.GetPrice())
+		if prices[i] <= 0 {
+			return 0, fmt.Errorf("invalid price %f of firm %d", prices[i], firmID)
+		}
+		totalInventory += inventories[i]
+		weightedPrice += inventories[i] * prices[i]
+		minPrice = math.Min(minPrice, prices[i])
+		maxPrice = math.Max(maxPrice, prices[i])
+	}
+	if totalInventory == 0 {
+		return 0, fmt.Errorf("no inventory in the market")
+	}
+	refPrice := weightedPrice / totalInventory
+
+	// 超额需求，随价格单调递减
+	excessDemand := func(price float64) float64 {
+		supply := 0.
+		for i := range inventories {
+			supply += marketSupply(inventories[i], prices[i], price)
+		}
+		return float64(totalDemand)*refPrice/price - supply
+	}
+	lo, hi := minPrice*1e-3, maxPrice
+	for i := 0; i < marketClearingIterations && excessDemand(hi) > 0; i++ {
+		lo, hi = hi, hi*2
+	}
+	for i := 0; i < marketClearingIterations && hi-lo > marketClearingTolerance*hi; i++ {
+		mid := (lo + hi) / 2
+		if excessDemand(mid) > 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return float32((lo + hi) / 2), nil
+}
@@ -8,8 +8,9 @@ import (
 
 // Firm 代表企业实体
 type Firm struct {
-	mu   sync.RWMutex
-	base *economyv2.Firm
+	mu      sync.RWMutex
+	base    *economyv2.Firm
+	version int64 // 版本号，每次修改后递增，用于乐观并发控制
 }
 
 // NewFirm 创建新的企业实例
@@ -37,6 +38,7 @@ func (f *Firm) GetCurrency() float32 {
 func (f *Firm) SetCurrency(value float32) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	f.version++
 	f.base.Currency = value
 }
 
@@ -47,6 +49,13 @@ func (f *Firm) GetBase() *economyv2.Firm {
 	return f.base
 }
 
+// GetVersion 获取版本号
+func (f *Firm) GetVersion() int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.version
+}
+
 // GetPrice 获取价格
 func (f *Firm) GetPrice() float32 {
 	f.mu.RLock()
@@ -58,6 +67,7 @@ func (f *Firm) GetPrice() float32 {
 func (f *Firm) SetPrice(value float32) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	f.version++
 	f.base.Price = value
 }
 
@@ -72,6 +82,7 @@ func (f *Firm) GetInventory() int32 {
 func (f *Firm) SetInventory(value int32) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	f.version++
 	f.base.Inventory = value
 }
 
@@ -86,6 +97,7 @@ func (f *Firm) GetDemand() float32 {
 func (f *Firm) SetDemand(value float32) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	f.version++
 	f.base.Demand = value
 }
 
@@ -100,6 +112,7 @@ func (f *Firm) GetSales() float32 {
 func (f *Firm) SetSales(value float32) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	f.version++
 	f.base.Sales = value
 }
 
@@ -114,19 +127,24 @@ func (f *Firm) GetEmployees() []int32 {
 func (f *Firm) SetEmployees(value []int32) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	f.version++
 	f.base.Employees = value
 }
 
 // NBS 代表国家统计局实体
 type NBS struct {
-	mu   sync.RWMutex
-	base *economyv2.NBS
+	mu      sync.RWMutex
+	base    *economyv2.NBS
+	version int64 // 版本号，每次修改后递增，用于乐观并发控制
+	// 企业ID->CPI基期价格（economyv2.NBS中暂无对应字段，不随实体状态保存）
+	cpiBasePrices map[int32]float32
 }
 
 // NewNBS 创建新的国家统计局实例
 func NewNBS(nbs *economyv2.NBS) *NBS {
 	return &NBS{
-		base: nbs,
+		base:          nbs,
+		cpiBasePrices: make(map[int32]float32),
 	}
 }
 
@@ -148,6 +166,7 @@ func (n *NBS) GetCurrency() float32 {
 func (n *NBS) SetCurrency(value float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.Currency = value
 }
 
@@ -158,6 +177,13 @@ func (n *NBS) GetBase() *economyv2.NBS {
 	return n.base
 }
 
+// GetVersion 获取版本号
+func (n *NBS) GetVersion() int64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.version
+}
+
 // GetNominalGDP 获取名义GDP
 func (n *NBS) GetNominalGDP() map[string]float32 {
 	n.mu.RLock()
@@ -169,6 +195,7 @@ func (n *NBS) GetNominalGDP() map[string]float32 {
 func (n *NBS) SetNominalGDP(value map[string]float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.NominalGdp = value
 }
 
@@ -183,6 +210,7 @@ func (n *NBS) GetRealGDP() map[string]float32 {
 func (n *NBS) SetRealGDP(value map[string]float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.RealGdp = value
 }
 
@@ -197,6 +225,7 @@ func (n *NBS) GetUnemployment() map[string]float32 {
 func (n *NBS) SetUnemployment(value map[string]float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.Unemployment = value
 }
 
@@ -211,6 +240,7 @@ func (n *NBS) GetWages() map[string]float32 {
 func (n *NBS) SetWages(value map[string]float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.Wages = value
 }
 
@@ -225,6 +255,7 @@ func (n *NBS) GetPrices() map[string]float32 {
 func (n *NBS) SetPrices(value map[string]float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.Prices = value
 }
 
@@ -239,6 +270,7 @@ func (n *NBS) GetWorkingHours() map[string]float32 {
 func (n *NBS) SetWorkingHours(value map[string]float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.WorkingHours = value
 }
 
@@ -253,6 +285,7 @@ func (n *NBS) GetDepression() map[string]float32 {
 func (n *NBS) SetDepression(value map[string]float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.Depression = value
 }
 
@@ -267,6 +300,7 @@ func (n *NBS) GetConsumptionCurrency() map[string]float32 {
 func (n *NBS) SetConsumptionCurrency(value map[string]float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.ConsumptionCurrency = value
 }
 
@@ -281,6 +315,7 @@ func (n *NBS) GetIncomeCurrency() map[string]float32 {
 func (n *NBS) SetIncomeCurrency(value map[string]float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.IncomeCurrency = value
 }
 
@@ -295,13 +330,30 @@ func (n *NBS) GetLocusControl() map[string]float32 {
 func (n *NBS) SetLocusControl(value map[string]float32) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.version++
 	n.base.LocusControl = value
 }
 
+// GetCPIBasePrices 获取CPI基期价格
+func (n *NBS) GetCPIBasePrices() map[int32]float32 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.cpiBasePrices
+}
+
+// SetCPIBasePrices 设置CPI基期价格
+func (n *NBS) SetCPIBasePrices(value map[int32]float32) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.version++
+	n.cpiBasePrices = value
+}
+
 // Government 代表政府实体
 type Government struct {
-	mu   sync.RWMutex
-	base *economyv2.Government
+	mu      sync.RWMutex
+	base    *economyv2.Government
+	version int64 // 版本号，每次修改后递增，用于乐观并发控制
 }
 
 // NewGovernment 创建新的政府实例
@@ -329,6 +381,7 @@ func (g *Government) GetCurrency() float32 {
 func (g *Government) SetCurrency(value float32) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	g.version++
 	g.base.Currency = value
 }
 
@@ -339,6 +392,13 @@ func (g *Government) GetBase() *economyv2.Government {
 	return g.base
 }
 
+// GetVersion 获取版本号
+func (g *Government) GetVersion() int64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.version
+}
+
 // GetBracketRates 获取税率
 func (g *Government) GetBracketRates() []float32 {
 	g.mu.RLock()
@@ -350,6 +410,7 @@ func (g *Government) GetBracketRates() []float32 {
 func (g *Government) SetBracketRates(value []float32) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	g.version++
 	g.base.BracketRates = value
 }
 
@@ -364,19 +425,25 @@ func (g *Government) GetBracketCutoffs() []float32 {
 func (g *Government) SetBracketCutoffs(value []float32) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	g.version++
 	g.base.BracketCutoffs = value
 }
 
 // Bank 代表银行实体
 type Bank struct {
-	mu   sync.RWMutex
-	base *economyv2.Bank
+	mu      sync.RWMutex
+	base    *economyv2.Bank
+	version int64 // 版本号，每次修改后递增，用于乐观并发控制
+	// 代理ID->储蓄余额（economyv2.Bank中暂无对应字段，由SaveEntities另存到单独的文件）
+	// 储蓄与代理可支配的货币分开记录，由CalculateSavingsInterest计息
+	savings map[int32]float32
 }
 
 // NewBank 创建新的银行实例
 func NewBank(bank *economyv2.Bank) *Bank {
 	return &Bank{
-		base: bank,
+		base:    bank,
+		savings: make(map[int32]float32),
 	}
 }
 
@@ -398,6 +465,7 @@ func (b *Bank) GetCurrency() float32 {
 func (b *Bank) SetCurrency(value float32) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.version++
 	b.base.Currency = value
 }
 
@@ -408,6 +476,13 @@ func (b *Bank) GetBase() *economyv2.Bank {
 	return b.base
 }
 
+// GetVersion 获取版本号
+func (b *Bank) GetVersion() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.version
+}
+
 // GetInterestRate 获取利率
 func (b *Bank) GetInterestRate() float32 {
 	b.mu.RLock()
@@ -419,5 +494,21 @@ func (b *Bank) GetInterestRate() float32 {
 func (b *Bank) SetInterestRate(value float32) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.version++
 	b.base.InterestRate = value
 }
+
+// GetSavings 获取代理的储蓄余额
+func (b *Bank) GetSavings(agentID int32) float32 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.savings[agentID]
+}
+
+// SetSavings 设置代理的储蓄余额
+func (b *Bank) SetSavings(agentID int32, value float32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.version++
+	b.savings[agentID] = value
+}
@@ -0,0 +1,57 @@
+package ecosim
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultLaborProductivity 每名员工每单位劳动投入的产出量
+const DefaultLaborProductivity = 1.0
+
+// ProduceGoods 企业生产
+// 功能：按企业员工的劳动投入生产商品计入库存，并向员工支付工资
+// 参数：firmID-企业ID，laborInput-每名员工本次投入的劳动量（如工作小时数，不可为负）
+// 返回：产量
+// 算法说明：
+// 1. 生产函数：产量=floor(DefaultLaborProductivity*员工数*laborInput)，员工数只计入存在的代理
+// 2. 工资：每名员工获得skill*laborInput（代理技能水平视为单位劳动的工资，未设置时为0）
+// 3. 企业货币不足以支付工资总额时返回错误，且不修改任何状态
+func (e *EconomySim) ProduceGoods(firmID int32, laborInput float32) (int32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	firm, exists := e.firms[firmID]
+	if !exists {
+		return 0, fmt.Errorf("firm %d not found", firmID)
+	}
+	if laborInput < 0 {
+		return 0, fmt.Errorf("invalid labor input %f", laborInput)
+	}
+
+	// 先计算工资总额，再统一修改状态
+	var workers []*Agent
+	var wageCost float32
+	for _, empID := range firm.GetEmployees() {
+		agent, exists := e.agents[empID]
+		if !exists {
+			continue
+		}
+		workers = append(workers, agent)
+		if skill := agent.GetSkill(); skill != nil {
+			wageCost += *skill * laborInput
+		}
+	}
+	if firm.GetCurrency() < wageCost {
+		return 0, fmt.Errorf("firm %d does not have enough currency to pay wages", firmID)
+	}
+
+	produced := int32(math.Floor(DefaultLaborProductivity * float64(len(workers)) * float64(laborInput)))
+	firm.SetInventory(firm.GetInventory() + produced)
+	firm.SetCurrency(firm.GetCurrency() - wageCost)
+	for _, agent := range workers {
+		if skill := agent.GetSkill(); skill != nil {
+			agent.SetCurrency(agent.GetCurrency() + *skill*laborInput)
+		}
+	}
+	return produced, nil
+}
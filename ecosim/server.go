@@ -1,13 +1,19 @@
+// Package ecosim 经济模拟系统，管理代理与各类经济组织，并通过economyv2 RPC对外提供服务。
+//
+// economyv2中暂无对应请求消息（或缺少所需字段）的功能以Server上的导出方法形式提供，
+// 由外部服务直接调用，不经过RPC。
 package ecosim
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"connectrpc.com/connect"
 	economyv2 "git.fiblab.net/sim/protos/v2/go/city/economy/v2"
 	economyv2connect "git.fiblab.net/sim/protos/v2/go/city/economy/v2/economyv2connect"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
 )
 
 // Server 实现gRPC服务器
@@ -33,6 +39,16 @@ func RunServer(address string) error {
 	return http.ListenAndServe(address, mux)
 }
 
+// SetTickConfig 设置经济系统内置动态的配置
+func (s *Server) SetTickConfig(c config.EconomyTick) {
+	s.econ.SetTickConfig(c)
+}
+
+// Tick 推进一步经济系统的内置动态（见EconomySim.Tick），由仿真主循环在每步更新阶段之后调用
+func (s *Server) Tick(t float64) {
+	s.econ.Tick(t)
+}
+
 // AddFirm 添加企业
 func (s *Server) AddFirm(ctx context.Context, req *connect.Request[economyv2.AddFirmRequest]) (*connect.Response[economyv2.AddFirmResponse], error) {
 	// 处理批量添加
@@ -84,11 +100,11 @@ func (s *Server) UpdateFirm(ctx context.Context, req *connect.Request[economyv2.
 	return connect.NewResponse(&economyv2.UpdateFirmResponse{}), nil
 }
 
-// ListFirms 列出所有企业
+// ListFirms 列出所有企业（按ID升序）
 func (s *Server) ListFirms(ctx context.Context, req *connect.Request[economyv2.ListFirmsRequest]) (*connect.Response[economyv2.ListFirmsResponse], error) {
 	var firmList []*economyv2.Firm
-	for _, firm := range s.econ.firms {
-		firmList = append(firmList, firm.GetBase())
+	for _, id := range sortedIDs(s.econ.firms) {
+		firmList = append(firmList, s.econ.firms[id].GetBase())
 	}
 	return connect.NewResponse(&economyv2.ListFirmsResponse{
 		Firms: firmList,
@@ -97,23 +113,30 @@ func (s *Server) ListFirms(ctx context.Context, req *connect.Request[economyv2.L
 
 // DeltaUpdateFirm 增量更新企业
 func (s *Server) DeltaUpdateFirm(ctx context.Context, req *connect.Request[economyv2.DeltaUpdateFirmRequest]) (*connect.Response[economyv2.DeltaUpdateFirmResponse], error) {
-	for _, update := range req.Msg.Updates {
-		if err := s.econ.DeltaUpdateFirm(
-			update.FirmId,
-			update.DeltaInventory,
-			update.DeltaPrice,
-			update.DeltaCurrency,
-			update.DeltaDemand,
-			update.DeltaSales,
-			update.AddEmployees,
-			update.RemoveEmployees,
-		); err != nil {
-			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to delta update firm: %v", err))
-		}
+	if _, err := s.deltaUpdateFirms(req.Msg, nil); err != nil {
+		return nil, err
 	}
 	return connect.NewResponse(&economyv2.DeltaUpdateFirmResponse{}), nil
 }
 
+// DeltaUpdateFirmWithVersion 带版本检查的增量更新企业（全部成功或全部不生效）
+// 参数：expectedVersions-企业ID->期望的版本号，不在其中的企业不检查版本
+// 返回：企业ID->更新后的版本号；版本号不一致时返回CodeAborted错误，且不执行任何更新
+// 说明：DeltaUpdateFirmRequest与DeltaUpdateFirmResponse中暂无版本号字段
+func (s *Server) DeltaUpdateFirmWithVersion(ctx context.Context, req *economyv2.DeltaUpdateFirmRequest, expectedVersions map[int32]int64) (map[int32]int64, error) {
+	return s.deltaUpdateFirms(req, expectedVersions)
+}
+
+// deltaUpdateFirms 批量执行增量更新企业，expectedVersions为nil时不检查版本
+// 说明：请求中暂无破产检查选项，破产处理由调用方通过Server.ProcessBankruptcy触发
+func (s *Server) deltaUpdateFirms(req *economyv2.DeltaUpdateFirmRequest, expectedVersions map[int32]int64) (map[int32]int64, error) {
+	versions, err := s.econ.DeltaUpdateFirms(req, expectedVersions)
+	if err != nil {
+		return nil, connect.NewError(versionErrorCode(err), fmt.Errorf("failed to delta update firm: %w", err))
+	}
+	return versions, nil
+}
+
 // AddAgent 添加新代理
 func (s *Server) AddAgent(ctx context.Context, req *connect.Request[economyv2.AddAgentRequest]) (*connect.Response[economyv2.AddAgentResponse], error) {
 	var agentIDs []int32
@@ -165,19 +188,75 @@ func (s *Server) UpdateAgent(ctx context.Context, req *connect.Request[economyv2
 
 // DeltaUpdateAgent 增量更新代理
 func (s *Server) DeltaUpdateAgent(ctx context.Context, req *connect.Request[economyv2.DeltaUpdateAgentRequest]) (*connect.Response[economyv2.DeltaUpdateAgentResponse], error) {
-	for _, update := range req.Msg.Updates {
-		if err := s.econ.DeltaUpdateAgent(update); err != nil {
-			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to delta update agent: %v", err))
-		}
+	if _, err := s.deltaUpdateAgents(req.Msg, nil); err != nil {
+		return nil, err
 	}
 	return connect.NewResponse(&economyv2.DeltaUpdateAgentResponse{}), nil
 }
 
-// ListAgents 列出所有代理
+// DeltaUpdateAgentWithVersion 带版本检查的增量更新代理（全部成功或全部不生效）
+// 参数：expectedVersions-代理ID->期望的版本号，不在其中的代理不检查版本
+// 返回：代理ID->更新后的版本号；版本号不一致时返回CodeAborted错误，且不执行任何更新
+// 说明：DeltaUpdateAgentRequest与DeltaUpdateAgentResponse中暂无版本号字段
+func (s *Server) DeltaUpdateAgentWithVersion(ctx context.Context, req *economyv2.DeltaUpdateAgentRequest, expectedVersions map[int32]int64) (map[int32]int64, error) {
+	return s.deltaUpdateAgents(req, expectedVersions)
+}
+
+// deltaUpdateAgents 批量执行增量更新代理，expectedVersions为nil时不检查版本
+func (s *Server) deltaUpdateAgents(req *economyv2.DeltaUpdateAgentRequest, expectedVersions map[int32]int64) (map[int32]int64, error) {
+	versions, err := s.econ.DeltaUpdateAgents(req, expectedVersions)
+	if err != nil {
+		return nil, connect.NewError(versionErrorCode(err), fmt.Errorf("failed to delta update agent: %w", err))
+	}
+	return versions, nil
+}
+
+// DeltaUpdateStatus 批量增量更新中单条更新的结果
+type DeltaUpdateStatus struct {
+	ID    int32  // 实体ID
+	Error string // 失败原因，成功时为空
+}
+
+// DeltaUpdateAgentPartial 批量增量更新代理并返回每条更新的结果
+// 参数：partial-为true时单条更新失败（如代理不存在）不影响其余更新，为false时与DeltaUpdateAgent相同，遇到第一条失败的更新即返回错误
+// 返回：与req.Updates一一对应的更新结果
+// 说明：DeltaUpdateAgentRequest中暂无partial字段、DeltaUpdateAgentResponse中暂无逐条结果字段
+func (s *Server) DeltaUpdateAgentPartial(ctx context.Context, req *economyv2.DeltaUpdateAgentRequest, partial bool) ([]DeltaUpdateStatus, error) {
+	statuses := make([]DeltaUpdateStatus, len(req.Updates))
+	for i, update := range req.Updates {
+		statuses[i].ID = update.AgentId
+		if _, err := s.econ.DeltaUpdateAgent(update, nil); err != nil {
+			if !partial {
+				return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to delta update agent: %v", err))
+			}
+			statuses[i].Error = err.Error()
+		}
+	}
+	return statuses, nil
+}
+
+// GetVersions 获取各类经济实体的当前版本号，用于之后带版本检查的增量更新
+func (s *Server) GetVersions(ctx context.Context, ids EntityIDs) (EntityVersions, error) {
+	versions, err := s.econ.GetVersions(ids)
+	if err != nil {
+		return EntityVersions{}, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to get version: %v", err))
+	}
+	return versions, nil
+}
+
+// versionErrorCode 版本冲突返回CodeAborted，其他错误返回CodeInternal
+func versionErrorCode(err error) connect.Code {
+	if errors.Is(err, ErrVersionConflict) {
+		return connect.CodeAborted
+	}
+	return connect.CodeInternal
+}
+
+// ListAgents 列出所有代理（按ID升序）
 func (s *Server) ListAgents(ctx context.Context, req *connect.Request[economyv2.ListAgentsRequest]) (*connect.Response[economyv2.ListAgentsResponse], error) {
 	agents := make([]*economyv2.Agent, 0)
-	for _, agent := range s.econ.agents {
-		agents = append(agents, agent.base)
+	for _, id := range sortedIDs(s.econ.agents) {
+		agents = append(agents, s.econ.agents[id].base)
 	}
 	return connect.NewResponse(&economyv2.ListAgentsResponse{
 		Agents: agents,
@@ -207,11 +286,13 @@ func (s *Server) CalculateConsumption(ctx context.Context, req *connect.Request[
 	if req.Msg.ConsumptionAccumulation != nil {
 		accumulation = *req.Msg.ConsumptionAccumulation
 	}
-	actualConsumption, success, err := s.econ.CalculateConsumption(
+	// CalculateConsumptionResponse中暂无销售明细字段，试算通过PreviewConsumption提供
+	actualConsumption, success, _, err := s.econ.CalculateConsumption(
 		req.Msg.FirmIds,
 		req.Msg.AgentId,
 		req.Msg.Demands,
 		accumulation,
+		false,
 	)
 	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to calculate consumption: %v", err))
@@ -222,11 +303,22 @@ func (s *Server) CalculateConsumption(ctx context.Context, req *connect.Request[
 	}), nil
 }
 
+// PreviewConsumption 试算消费，返回各企业的实际销售明细，不修改任何状态
+func (s *Server) PreviewConsumption(ctx context.Context, firmIDs []int32, agentID int32, demands []int32) (float32, bool, []FirmSale, error) {
+	actualConsumption, success, breakdown, err := s.econ.CalculateConsumption(firmIDs, agentID, demands, false, true)
+	if err != nil {
+		return 0, false, nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to preview consumption: %v", err))
+	}
+	return actualConsumption, success, breakdown, nil
+}
+
 // CalculateInterest 计算利息
 func (s *Server) CalculateInterest(ctx context.Context, req *connect.Request[economyv2.CalculateInterestRequest]) (*connect.Response[economyv2.CalculateInterestResponse], error) {
+	// CalculateInterestRequest中暂无复利子周期数字段，按单利计算，复利通过CalculateCompoundInterest提供
 	totalInterest, updatedCurrencies, err := s.econ.CalculateInterest(
 		req.Msg.BankId,
 		req.Msg.AgentIds,
+		1,
 	)
 	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to calculate interest: %v", err))
@@ -237,6 +329,17 @@ func (s *Server) CalculateInterest(ctx context.Context, req *connect.Request[eco
 	}), nil
 }
 
+// CalculateCompoundInterest 按复利计算利息，一次计息分为periods个子周期（见EconomySim.CalculateInterest）
+// 返回：利息总额与各代理计息后的货币量
+// 说明：CalculateInterestRequest中暂无复利子周期数字段
+func (s *Server) CalculateCompoundInterest(ctx context.Context, bankID int32, agentIDs []int32, periods int32) (float32, []float32, error) {
+	totalInterest, updatedCurrencies, err := s.econ.CalculateInterest(bankID, agentIDs, periods)
+	if err != nil {
+		return 0, nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to calculate interest: %v", err))
+	}
+	return totalInterest, updatedCurrencies, nil
+}
+
 // CalculateRealGDP 计算实际GDP
 func (s *Server) CalculateRealGDP(ctx context.Context, req *connect.Request[economyv2.CalculateRealGDPRequest]) (*connect.Response[economyv2.CalculateRealGDPResponse], error) {
 	realGDP, err := s.econ.CalculateRealGDP(req.Msg.NbsId)
@@ -299,11 +402,11 @@ func (s *Server) UpdateNBS(ctx context.Context, req *connect.Request[economyv2.U
 	return connect.NewResponse(&economyv2.UpdateNBSResponse{}), nil
 }
 
-// ListNBS 列出所有国家统计局
+// ListNBS 列出所有国家统计局（按ID升序）
 func (s *Server) ListNBS(ctx context.Context, req *connect.Request[economyv2.ListNBSRequest]) (*connect.Response[economyv2.ListNBSResponse], error) {
 	var nbsList []*economyv2.NBS
-	for _, nbs := range s.econ.nbs {
-		nbsList = append(nbsList, nbs.GetBase())
+	for _, id := range sortedIDs(s.econ.nbs) {
+		nbsList = append(nbsList, s.econ.nbs[id].GetBase())
 	}
 	return connect.NewResponse(&economyv2.ListNBSResponse{
 		NbsList: nbsList,
@@ -312,27 +415,44 @@ func (s *Server) ListNBS(ctx context.Context, req *connect.Request[economyv2.Lis
 
 // DeltaUpdateNBS 增量更新国家统计局
 func (s *Server) DeltaUpdateNBS(ctx context.Context, req *connect.Request[economyv2.DeltaUpdateNBSRequest]) (*connect.Response[economyv2.DeltaUpdateNBSResponse], error) {
-	if err := s.econ.DeltaUpdateNBS(
-		req.Msg.NbsId,
-		req.Msg.DeltaNominalGdp,
-		req.Msg.DeltaRealGdp,
-		req.Msg.DeltaUnemployment,
-		req.Msg.DeltaWages,
-		req.Msg.DeltaPrices,
-		req.Msg.DeltaWorkingHours,
-		req.Msg.DeltaDepression,
-		req.Msg.DeltaConsumptionCurrency,
-		req.Msg.DeltaIncomeCurrency,
-		req.Msg.DeltaLocusControl,
-		req.Msg.DeltaCurrency,
-		req.Msg.AddCitizenIds,
-		req.Msg.RemoveCitizenIds,
-	); err != nil {
-		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to delta update NBS: %v", err))
+	if _, err := s.deltaUpdateNBS(req.Msg, nil); err != nil {
+		return nil, err
 	}
 	return connect.NewResponse(&economyv2.DeltaUpdateNBSResponse{}), nil
 }
 
+// DeltaUpdateNBSWithVersion 带版本检查的增量更新国家统计局
+// 返回：更新后的版本号；版本号不一致时返回CodeAborted错误，且不执行更新
+// 说明：DeltaUpdateNBSRequest与DeltaUpdateNBSResponse中暂无版本号字段
+func (s *Server) DeltaUpdateNBSWithVersion(ctx context.Context, req *economyv2.DeltaUpdateNBSRequest, expectedVersion int64) (int64, error) {
+	return s.deltaUpdateNBS(req, &expectedVersion)
+}
+
+// deltaUpdateNBS 增量更新国家统计局，expectedVersion为nil时不检查版本
+func (s *Server) deltaUpdateNBS(req *economyv2.DeltaUpdateNBSRequest, expectedVersion *int64) (int64, error) {
+	version, err := s.econ.DeltaUpdateNBS(
+		req.NbsId,
+		req.DeltaNominalGdp,
+		req.DeltaRealGdp,
+		req.DeltaUnemployment,
+		req.DeltaWages,
+		req.DeltaPrices,
+		req.DeltaWorkingHours,
+		req.DeltaDepression,
+		req.DeltaConsumptionCurrency,
+		req.DeltaIncomeCurrency,
+		req.DeltaLocusControl,
+		req.DeltaCurrency,
+		req.AddCitizenIds,
+		req.RemoveCitizenIds,
+		expectedVersion,
+	)
+	if err != nil {
+		return 0, connect.NewError(versionErrorCode(err), fmt.Errorf("failed to delta update NBS: %w", err))
+	}
+	return version, nil
+}
+
 // AddGovernment 添加政府
 func (s *Server) AddGovernment(ctx context.Context, req *connect.Request[economyv2.AddGovernmentRequest]) (*connect.Response[economyv2.AddGovernmentResponse], error) {
 	if err := s.econ.AddGovernment(req.Msg.Government); err != nil {
@@ -368,11 +488,11 @@ func (s *Server) UpdateGovernment(ctx context.Context, req *connect.Request[econ
 	return connect.NewResponse(&economyv2.UpdateGovernmentResponse{}), nil
 }
 
-// ListGovernments 列出所有政府
+// ListGovernments 列出所有政府（按ID升序）
 func (s *Server) ListGovernments(ctx context.Context, req *connect.Request[economyv2.ListGovernmentsRequest]) (*connect.Response[economyv2.ListGovernmentsResponse], error) {
 	var govList []*economyv2.Government
-	for _, gov := range s.econ.govs {
-		govList = append(govList, gov.GetBase())
+	for _, id := range sortedIDs(s.econ.govs) {
+		govList = append(govList, s.econ.govs[id].GetBase())
 	}
 	return connect.NewResponse(&economyv2.ListGovernmentsResponse{
 		Governments: govList,
@@ -381,19 +501,36 @@ func (s *Server) ListGovernments(ctx context.Context, req *connect.Request[econo
 
 // DeltaUpdateGovernment 增量更新政府
 func (s *Server) DeltaUpdateGovernment(ctx context.Context, req *connect.Request[economyv2.DeltaUpdateGovernmentRequest]) (*connect.Response[economyv2.DeltaUpdateGovernmentResponse], error) {
-	if err := s.econ.DeltaUpdateGovernment(
-		req.Msg.GovernmentId,
-		req.Msg.DeltaBracketCutoffs,
-		req.Msg.DeltaBracketRates,
-		req.Msg.DeltaCurrency,
-		req.Msg.AddCitizenIds,
-		req.Msg.RemoveCitizenIds,
-	); err != nil {
-		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to delta update government: %v", err))
+	if _, err := s.deltaUpdateGovernment(req.Msg, nil); err != nil {
+		return nil, err
 	}
 	return connect.NewResponse(&economyv2.DeltaUpdateGovernmentResponse{}), nil
 }
 
+// DeltaUpdateGovernmentWithVersion 带版本检查的增量更新政府
+// 返回：更新后的版本号；版本号不一致时返回CodeAborted错误，且不执行更新
+// 说明：DeltaUpdateGovernmentRequest与DeltaUpdateGovernmentResponse中暂无版本号字段
+func (s *Server) DeltaUpdateGovernmentWithVersion(ctx context.Context, req *economyv2.DeltaUpdateGovernmentRequest, expectedVersion int64) (int64, error) {
+	return s.deltaUpdateGovernment(req, &expectedVersion)
+}
+
+// deltaUpdateGovernment 增量更新政府，expectedVersion为nil时不检查版本
+func (s *Server) deltaUpdateGovernment(req *economyv2.DeltaUpdateGovernmentRequest, expectedVersion *int64) (int64, error) {
+	version, err := s.econ.DeltaUpdateGovernment(
+		req.GovernmentId,
+		req.DeltaBracketCutoffs,
+		req.DeltaBracketRates,
+		req.DeltaCurrency,
+		req.AddCitizenIds,
+		req.RemoveCitizenIds,
+		expectedVersion,
+	)
+	if err != nil {
+		return 0, connect.NewError(versionErrorCode(err), fmt.Errorf("failed to delta update government: %w", err))
+	}
+	return version, nil
+}
+
 // AddBank 添加银行
 func (s *Server) AddBank(ctx context.Context, req *connect.Request[economyv2.AddBankRequest]) (*connect.Response[economyv2.AddBankResponse], error) {
 	if err := s.econ.AddBank(req.Msg.Bank); err != nil {
@@ -429,11 +566,11 @@ func (s *Server) UpdateBank(ctx context.Context, req *connect.Request[economyv2.
 	return connect.NewResponse(&economyv2.UpdateBankResponse{}), nil
 }
 
-// ListBanks 列出所有银行
+// ListBanks 列出所有银行（按ID升序）
 func (s *Server) ListBanks(ctx context.Context, req *connect.Request[economyv2.ListBanksRequest]) (*connect.Response[economyv2.ListBanksResponse], error) {
 	var bankList []*economyv2.Bank
-	for _, bank := range s.econ.banks {
-		bankList = append(bankList, bank.GetBase())
+	for _, id := range sortedIDs(s.econ.banks) {
+		bankList = append(bankList, s.econ.banks[id].GetBase())
 	}
 	return connect.NewResponse(&economyv2.ListBanksResponse{
 		Banks: bankList,
@@ -442,14 +579,126 @@ func (s *Server) ListBanks(ctx context.Context, req *connect.Request[economyv2.L
 
 // DeltaUpdateBank 增量更新银行
 func (s *Server) DeltaUpdateBank(ctx context.Context, req *connect.Request[economyv2.DeltaUpdateBankRequest]) (*connect.Response[economyv2.DeltaUpdateBankResponse], error) {
-	if err := s.econ.DeltaUpdateBank(
-		req.Msg.BankId,
-		req.Msg.DeltaInterestRate,
-		req.Msg.DeltaCurrency,
-		req.Msg.AddCitizenIds,
-		req.Msg.RemoveCitizenIds,
-	); err != nil {
-		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to delta update bank: %v", err))
+	if _, err := s.deltaUpdateBank(req.Msg, nil); err != nil {
+		return nil, err
 	}
 	return connect.NewResponse(&economyv2.DeltaUpdateBankResponse{}), nil
 }
+
+// DeltaUpdateBankWithVersion 带版本检查的增量更新银行
+// 返回：更新后的版本号；版本号不一致时返回CodeAborted错误，且不执行更新
+// 说明：DeltaUpdateBankRequest与DeltaUpdateBankResponse中暂无版本号字段
+func (s *Server) DeltaUpdateBankWithVersion(ctx context.Context, req *economyv2.DeltaUpdateBankRequest, expectedVersion int64) (int64, error) {
+	return s.deltaUpdateBank(req, &expectedVersion)
+}
+
+// deltaUpdateBank 增量更新银行，expectedVersion为nil时不检查版本
+func (s *Server) deltaUpdateBank(req *economyv2.DeltaUpdateBankRequest, expectedVersion *int64) (int64, error) {
+	version, err := s.econ.DeltaUpdateBank(
+		req.BankId,
+		req.DeltaInterestRate,
+		req.DeltaCurrency,
+		req.AddCitizenIds,
+		req.RemoveCitizenIds,
+		expectedVersion,
+	)
+	if err != nil {
+		return 0, connect.NewError(versionErrorCode(err), fmt.Errorf("failed to delta update bank: %w", err))
+	}
+	return version, nil
+}
+
+// CalculateInflation 计算国家统计局价格序列中两个时间点之间的通胀率
+func (s *Server) CalculateInflation(ctx context.Context, nbsID int32, fromT, toT string) (float32, error) {
+	inflation, err := s.econ.CalculateInflation(nbsID, fromT, toT)
+	if err != nil {
+		return 0, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to calculate inflation: %v", err))
+	}
+	return inflation, nil
+}
+
+// InitializeEconomy 批量添加经济实体（全部成功或全部不生效）
+func (s *Server) InitializeEconomy(ctx context.Context, entities *economyv2.EconomyEntities) error {
+	if err := s.econ.InitializeEconomy(entities); err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to initialize economy: %v", err))
+	}
+	return nil
+}
+
+// GetEmploymentStats 获取就业与失业的代理数量
+func (s *Server) GetEmploymentStats(ctx context.Context) (employed, unemployed int32, err error) {
+	employed, unemployed = s.econ.GetEmploymentStats()
+	return employed, unemployed, nil
+}
+
+// PayWages 企业向代理支付工资（全部成功或全部不生效）
+func (s *Server) PayWages(ctx context.Context, firmID int32, wages map[int32]float32) error {
+	if err := s.econ.PayWages(firmID, wages); err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to pay wages: %v", err))
+	}
+	return nil
+}
+
+// Deposit 代理将可支配的货币存入银行储蓄
+func (s *Server) Deposit(ctx context.Context, bankID, agentID int32, amount float32) error {
+	if err := s.econ.Deposit(bankID, agentID, amount); err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to deposit: %v", err))
+	}
+	return nil
+}
+
+// Withdraw 代理从银行储蓄中取出货币
+func (s *Server) Withdraw(ctx context.Context, bankID, agentID int32, amount float32) error {
+	if err := s.econ.Withdraw(bankID, agentID, amount); err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to withdraw: %v", err))
+	}
+	return nil
+}
+
+// CalculateSavingsInterest 按储蓄余额计算利息并计入储蓄
+// 返回：利息总额与各代理计息后的储蓄余额
+// 说明：CalculateInterest RPC仍按货币计息
+func (s *Server) CalculateSavingsInterest(ctx context.Context, bankID int32, agentIDs []int32, periods int32) (float32, []float32, error) {
+	totalInterest, updatedSavings, err := s.econ.CalculateSavingsInterest(bankID, agentIDs, periods)
+	if err != nil {
+		return 0, nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to calculate savings interest: %v", err))
+	}
+	return totalInterest, updatedSavings, nil
+}
+
+// ProduceGoods 企业按员工的劳动投入生产商品并支付工资
+func (s *Server) ProduceGoods(ctx context.Context, firmID int32, laborInput float32) (produced int32, err error) {
+	produced, err = s.econ.ProduceGoods(firmID, laborInput)
+	if err != nil {
+		return 0, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to produce goods: %v", err))
+	}
+	return produced, nil
+}
+
+// SetCPIBase 以篮子中各企业的当前价格设置国家统计局的CPI基期
+func (s *Server) SetCPIBase(ctx context.Context, nbsID int32, firmIDs []int32) error {
+	if err := s.econ.SetCPIBase(nbsID, firmIDs); err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to set CPI base: %v", err))
+	}
+	return nil
+}
+
+// ComputeCPI 计算消费篮子相对国家统计局基期的消费者价格指数
+func (s *Server) ComputeCPI(ctx context.Context, nbsID int32, firmIDs []int32, weights []float32) (float32, error) {
+	cpi, err := s.econ.ComputeCPI(nbsID, firmIDs, weights)
+	if err != nil {
+		return 0, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to compute CPI: %v", err))
+	}
+	return cpi, nil
+}
+
+// ProcessBankruptcy 企业破产，解雇全部员工并移除该企业
+// 返回：被解雇的员工ID
+// 说明：economyv2中暂无破产请求，DeltaUpdateFirmRequest中也暂无破产检查选项
+func (s *Server) ProcessBankruptcy(ctx context.Context, firmID int32) ([]int32, error) {
+	laidOff, err := s.econ.ProcessBankruptcy(firmID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to process bankruptcy: %v", err))
+	}
+	return laidOff, nil
+}
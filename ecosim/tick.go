@@ -0,0 +1,121 @@
+package ecosim
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
+)
+
+const (
+	defaultPriceAdjustment = 0.05 // 未配置时的企业价格调整系数
+	defaultRecordWindow    = 1000 // 未配置时统计局保留的最近记录数
+)
+
+// SetTickConfig 设置内置动态的配置
+func (e *EconomySim) SetTickConfig(c config.EconomyTick) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tick = c
+}
+
+// Tick 推进一步经济系统的内置动态
+// 功能：未启用内置动态（config.EconomyTick.Enable）时不做任何事
+// 参数：t-当前仿真时间（秒），作为统计局时间序列的键
+// 算法说明：
+// 1. 企业价格调整：以企业自上一步以来新增的需求量（企业需求量为累计值）与库存比较，价格乘以1+PriceAdjustment*(需求-库存)/max(需求,库存)，供不应求时涨价，供过于求时降价
+// 2. 银行计息：每InterestInterval步对各银行全部储蓄计一次单利（见CalculateSavingsInterest），银行货币不足时跳过本次计息
+// 3. 统计局记录：每RecordInterval步以t为键记录全部企业的平均价格与失业率，只保留最近RecordWindow条由此写入的记录
+func (e *EconomySim) Tick(t float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.tick.Enable {
+		return
+	}
+	e.tickCount++
+
+	// 1. 企业价格调整
+	alpha := e.tick.PriceAdjustment
+	if alpha == 0 {
+		alpha = defaultPriceAdjustment
+	}
+	lastDemand := make(map[int32]float32, len(e.firms))
+	for _, id := range sortedIDs(e.firms) {
+		firm := e.firms[id]
+		lastDemand[id] = firm.GetDemand()
+		demand := math.Max(float64(firm.GetDemand()-e.lastDemand[id]), 0)
+		supply := math.Max(float64(firm.GetInventory()), 0)
+		if scale := math.Max(demand, supply); scale > 0 {
+			firm.SetPrice(firm.GetPrice() * float32(1+alpha*(demand-supply)/scale))
+		}
+	}
+	e.lastDemand = lastDemand
+
+	// 2. 银行计息
+	if e.tick.InterestInterval > 0 && e.tickCount%int64(e.tick.InterestInterval) == 0 {
+		for _, id := range sortedIDs(e.banks) {
+			bank := e.banks[id]
+			if bank.GetInterestRate() <= 0 {
+				continue
+			}
+			var agentIDs []int32
+			for _, agentID := range sortedIDs(bank.savings) {
+				if _, exists := e.agents[agentID]; exists {
+					agentIDs = append(agentIDs, agentID)
+				}
+			}
+			if _, _, err := e.calculateSavingsInterest(id, agentIDs, 1); err != nil {
+				log.Warnf("tick: skip interest of bank %d: %v", id, err)
+			}
+		}
+	}
+
+	// 3. 统计局记录
+	if len(e.nbs) == 0 {
+		return
+	}
+	if e.tick.RecordInterval > 1 && e.tickCount%int64(e.tick.RecordInterval) != 0 {
+		return
+	}
+	key := strconv.FormatFloat(t, 'f', -1, 64)
+	window := int(e.tick.RecordWindow)
+	if window <= 0 {
+		window = defaultRecordWindow
+	}
+	var expired []string
+	if len(e.recordKeys) >= window {
+		n := len(e.recordKeys) - window + 1
+		expired = e.recordKeys[:n]
+		e.recordKeys = append([]string(nil), e.recordKeys[n:]...)
+	}
+	e.recordKeys = append(e.recordKeys, key)
+	var meanPrice, unemployment float32
+	if len(e.firms) > 0 {
+		for _, firm := range e.firms {
+			meanPrice += firm.GetPrice()
+		}
+		meanPrice /= float32(len(e.firms))
+	}
+	if employed, unemployed := e.employmentStats(); employed+unemployed > 0 {
+		unemployment = float32(unemployed) / float32(employed+unemployed)
+	}
+	for _, nbs := range e.nbs {
+		prices := nbs.GetPrices()
+		if prices == nil {
+			prices = make(map[string]float32)
+		}
+		rates := nbs.GetUnemployment()
+		if rates == nil {
+			rates = make(map[string]float32)
+		}
+		for _, k := range expired {
+			delete(prices, k)
+			delete(rates, k)
+		}
+		prices[key] = meanPrice
+		nbs.SetPrices(prices)
+		rates[key] = unemployment
+		nbs.SetUnemployment(rates)
+	}
+}
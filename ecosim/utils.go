@@ -1,5 +1,62 @@
 package ecosim
 
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// ErrVersionConflict 增量更新时期望版本号与实体当前版本号不一致
+var ErrVersionConflict = errors.New("version conflict")
+
+// checkVersion 检查实体当前版本号是否与期望版本号一致，expected为nil时不检查
+func checkVersion(kind string, id int32, current int64, expected *int64) error {
+	if expected != nil && *expected != current {
+		return fmt.Errorf("%w: %s %d is at version %d, expected %d", ErrVersionConflict, kind, id, current, *expected)
+	}
+	return nil
+}
+
+// expectedVersionOf 返回实体的期望版本号，不在expectedVersions中时返回nil（不检查版本）
+func expectedVersionOf(expectedVersions map[int32]int64, id int32) *int64 {
+	if v, ok := expectedVersions[id]; ok {
+		return &v
+	}
+	return nil
+}
+
+// versionsOf 返回给定ID实体的当前版本号，任一实体不存在时返回错误
+func versionsOf[T interface{ GetVersion() int64 }](kind string, entities map[int32]T, ids []int32) (map[int32]int64, error) {
+	versions := make(map[int32]int64, len(ids))
+	for _, id := range ids {
+		entity, exists := entities[id]
+		if !exists {
+			return nil, fmt.Errorf("%s %d not found", kind, id)
+		}
+		versions[id] = entity.GetVersion()
+	}
+	return versions, nil
+}
+
+// sortedIDs 返回按升序排列的实体ID，使遍历实体的结果与map的遍历顺序无关
+func sortedIDs[T any](m map[int32]T) []int32 {
+	return slices.Sorted(maps.Keys(m))
+}
+
+// checkNewIDs 检查待添加实体的ID既不与已有实体重复，也不在待添加实体之间重复
+func checkNewIDs[T any, E interface{ GetId() int32 }](existing map[int32]T, items []E, kind string) error {
+	seen := make(map[int32]bool, len(items))
+	for _, item := range items {
+		id := item.GetId()
+		if _, exists := existing[id]; exists || seen[id] {
+			return fmt.Errorf("%s %d already exists", kind, id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
 // taxesDue 计算指定收入水平的应缴税额
 func taxesDue(income float32, bracketCutoffs []float32, bracketRates []float32) float32 {
 	if len(bracketCutoffs) != len(bracketRates) {
@@ -1,6 +1,10 @@
 package aoi
 
 import (
+	"cmp"
+	"math"
+	"slices"
+	"sort"
 	"sync"
 
 	"git.fiblab.net/general/common/v2/geometry"
@@ -8,9 +12,12 @@ import (
 	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
 	"github.com/samber/lo"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
 )
 
+const secondsPerDay = 24 * 3600 // 吸引力曲线的循环周期（秒）
+
 type aoiBufferItem struct {
 	P entity.IPerson
 }
@@ -30,11 +37,20 @@ type Aoi struct {
 
 	generator *randengine.Engine // 随机数生成器
 
+	capacity       int32                    // 容纳人数上限（<=0表示不限制）
+	capacityBuffer int32                    // 容纳人数上限buffer
+	waiting        map[entity.IPerson]int64 // 因AOI已满在外等待的人及其排队序号，先开始等待的人先被接纳
+	waitingSeq     int64                    // 下一个开始等待的人的排队序号
+
+	attraction []config.AttractionPoint // 吸引力曲线，按时刻排序（为空表示权重恒为1）
+
 	persons               map[entity.IPerson]struct{} // 所有的人
 	addPersonBuffer       []aoiBufferItem             // 缓存上一时刻进入AOI的人或进入室内行走的人
 	addPersonBufferMtx    sync.Mutex
 	removePersonBuffer    []aoiBufferItem // 缓存上一时刻离开AOI的人或离开室内行走的人
 	removePersonBufferMtx sync.Mutex
+
+	events []AoiEvent // 本步应用缓冲区时产生的进出事件（aoi.max_events）
 }
 
 // newAoi 创建并初始化一个新的AOI实例
@@ -56,6 +72,10 @@ func newAoi(ctx entity.ITaskContext, base *mapv2.Aoi, _ *AoiManager, laneManager
 		persons:      make(map[entity.IPerson]struct{}),
 		generator:    randengine.New(uint64(base.Id)),
 	}
+	a.capacity = ctx.RuntimeConfig().C.AoiCapacities[a.id]
+	a.capacityBuffer = a.capacity
+	a.attraction = slices.Clone(ctx.RuntimeConfig().C.AoiAttractions[a.id])
+	slices.SortFunc(a.attraction, func(x, y config.AttractionPoint) int { return cmp.Compare(x.T, y.T) })
 	a.centroid = geometry.GetPolygonCentroid2D(a.boundary)
 	var sumZ float64
 	for _, point := range a.boundary {
@@ -81,26 +101,58 @@ func newAoi(ctx entity.ITaskContext, base *mapv2.Aoi, _ *AoiManager, laneManager
 // prepare 准备阶段，处理缓冲区的数据更新
 // 功能：根据缓冲区数据更新AOI内的人员和车辆状态，包括添加/移除人员和停靠车辆
 // 说明：处理上一时刻的缓冲区操作，更新内部数据结构，为输出准备数据列表
+// 设置了容量时按先到先得的顺序接纳进入的人：已在等待的人按开始等待的先后优先，本步新到达的人按ID排序，
+// AOI已满时拒绝并通知该人自行处理；等待的人需每步重新请求进入，不再请求的人退出排队
 func (a *Aoi) prepare() {
+	a.capacity = a.capacityBuffer
 	// 根据buffer更新人的情况
 	for _, item := range a.removePersonBuffer {
 		// 存在性检查
 		if _, ok := a.persons[item.P]; !ok {
 			log.Errorf("remove person %d not in aoi %d", item.P.ID(), a.id)
+		} else {
+			a.recordEvent(item.P.ID(), AoiEventLeave)
 		}
 		delete(a.persons, item.P)
 	}
 	a.removePersonBuffer = a.removePersonBuffer[:0]
+	if a.capacity > 0 {
+		// 保证多人同时到达时的接纳结果与并发顺序无关
+		slices.SortFunc(a.addPersonBuffer, func(x, y aoiBufferItem) int {
+			return cmp.Or(cmp.Compare(a.waitingOrder(x.P), a.waitingOrder(y.P)), cmp.Compare(x.P.ID(), y.P.ID()))
+		})
+	}
+	waiting := make(map[entity.IPerson]int64)
 	for _, item := range a.addPersonBuffer {
 		// 存在性检查
 		if _, ok := a.persons[item.P]; ok {
 			log.Warnf("add person %d already in aoi %d", item.P.ID(), a.id)
+		} else if a.capacity > 0 && int32(len(a.persons)) >= a.capacity {
+			if seq, ok := a.waiting[item.P]; ok {
+				waiting[item.P] = seq
+			} else {
+				waiting[item.P] = a.waitingSeq
+				a.waitingSeq++
+			}
+			item.P.RejectByAoi(a)
+			continue
+		} else {
+			a.recordEvent(item.P.ID(), AoiEventEnter)
 		}
 		a.persons[item.P] = struct{}{}
 	}
+	a.waiting = waiting
 	a.addPersonBuffer = a.addPersonBuffer[:0]
 }
 
+// waitingOrder 获取人在等待队列中的排队序号，未在等待的人排在所有等待的人之后
+func (a *Aoi) waitingOrder(p entity.IPerson) int64 {
+	if seq, ok := a.waiting[p]; ok {
+		return seq
+	}
+	return math.MaxInt64
+}
+
 // update 更新阶段，执行AOI的模拟逻辑
 // 功能：执行AOI的模拟更新逻辑，目前为空实现，预留扩展接口
 // 参数：dt-时间步长
@@ -182,6 +234,54 @@ func (a *Aoi) AddPerson(p entity.IPerson) {
 	a.addPersonBufferMtx.Unlock()
 }
 
+// Capacity 获取AOI的容纳人数上限
+// 返回：容纳人数上限，<=0表示不限制
+func (a *Aoi) Capacity() int32 {
+	return a.capacity
+}
+
+// SetCapacity 设置AOI的容纳人数上限
+// 功能：写入buffer，在下一时刻的prepare阶段生效，之后进入的人超出上限时被拒绝
+// 参数：capacity-容纳人数上限，<=0表示不限制
+// 说明：已在AOI内的人不受影响
+func (a *Aoi) SetCapacity(capacity int32) {
+	a.capacityBuffer = capacity
+}
+
+// Attraction 获取AOI在t时刻作为出行目的地的吸引力权重
+// 功能：在吸引力曲线的相邻控制点之间线性插值，曲线按天循环（最后一个控制点与次日第一个控制点之间插值）
+// 参数：t-仿真时间（秒）
+// 返回：吸引力权重，未配置曲线时为1
+// 说明：只读，不影响模拟
+func (a *Aoi) Attraction(t float64) float64 {
+	curve := a.attraction
+	if len(curve) == 0 {
+		return 1
+	}
+	t = math.Mod(t, secondsPerDay)
+	if t < 0 {
+		t += secondsPerDay
+	}
+	i := sort.Search(len(curve), func(i int) bool { return curve[i].T > t })
+	var prev, next config.AttractionPoint
+	if i == 0 {
+		prev = curve[len(curve)-1]
+		prev.T -= secondsPerDay
+	} else {
+		prev = curve[i-1]
+	}
+	if i == len(curve) {
+		next = curve[0]
+		next.T += secondsPerDay
+	} else {
+		next = curve[i]
+	}
+	if next.T <= prev.T {
+		return prev.Weight
+	}
+	return prev.Weight + (next.Weight-prev.Weight)*(t-prev.T)/(next.T-prev.T)
+}
+
 // RemovePerson 从AOI移除人员到缓冲区
 // 功能：将人员添加到AOI的移除缓冲区，在下一时刻的prepare阶段处理
 // 参数：p-要移除的人员，isCrowd-是否为室内行人
@@ -0,0 +1,79 @@
+package aoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
+)
+
+func TestAttraction(t *testing.T) {
+	assert.Equal(t, 1., (&Aoi{}).Attraction(1000))
+
+	a := &Aoi{attraction: []config.AttractionPoint{
+		{T: 8 * 3600, Weight: 1},
+		{T: 12 * 3600, Weight: 3},
+		{T: 20 * 3600, Weight: 2},
+	}}
+	// 控制点之间线性插值
+	assert.InDelta(t, 3, a.Attraction(12*3600), 1e-9)
+	assert.InDelta(t, 2, a.Attraction(10*3600), 1e-9)
+	assert.InDelta(t, 2.5, a.Attraction(16*3600), 1e-9)
+	// 跨越0点时在最后一个控制点与次日第一个控制点之间插值，曲线按天循环
+	assert.InDelta(t, 1.5, a.Attraction(2*3600), 1e-9)
+	assert.InDelta(t, 1.5, a.Attraction(26*3600), 1e-9)
+
+	m := &AoiManager{
+		aois: []*Aoi{{id: 1}, a},
+		data: map[int32]*Aoi{1: {id: 1}, 2: a},
+	}
+	a.id = 2
+	weights, err := m.GetAoiWeights(10*3600, []int32{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]float64{1: 1, 2: 2}, weights)
+	_, err = m.GetAoiWeights(0, []int32{3})
+	assert.Error(t, err)
+}
+
+// testPerson 只实现AOI容量测试所需方法的人
+type testPerson struct {
+	entity.IPerson
+	id       int32
+	rejected bool
+}
+
+func (p *testPerson) ID() int32                 { return p.id }
+func (p *testPerson) RejectByAoi(_ entity.IAoi) { p.rejected = true }
+
+func TestCapacityArrivalOrder(t *testing.T) {
+	a := &Aoi{id: 1, capacityBuffer: 1, persons: make(map[entity.IPerson]struct{})}
+	occupant, early, late := &testPerson{id: 9}, &testPerson{id: 3}, &testPerson{id: 1}
+	// 每步等待的人重新请求进入
+	step := func(arrivals ...*testPerson) {
+		for _, p := range arrivals {
+			p.rejected = false
+			a.AddPerson(p)
+		}
+		a.prepare()
+	}
+	step(occupant)
+	step(early)
+	assert.True(t, early.rejected)
+	step(early, late)
+	assert.True(t, early.rejected)
+	assert.True(t, late.rejected)
+
+	// 空出位置后先开始等待的人先进入，与ID无关
+	a.RemovePerson(occupant)
+	step(late, early)
+	assert.False(t, early.rejected)
+	assert.True(t, late.rejected)
+	assert.Contains(t, a.persons, entity.IPerson(early))
+	assert.NotContains(t, a.persons, entity.IPerson(late))
+
+	// 不再请求进入的人退出排队
+	assert.Len(t, a.waiting, 1)
+	step()
+	assert.Empty(t, a.waiting)
+}
@@ -0,0 +1,66 @@
+package aoi
+
+import (
+	"flag"
+)
+
+var (
+	maxEvents = flag.Int("aoi.max_events", 0, "AOI进出事件缓冲区的最大长度，超出时丢弃最早的事件（<=0表示不记录进出事件）")
+)
+
+// AoiEventType AOI进出事件类型
+type AoiEventType int
+
+const (
+	AoiEventEnter AoiEventType = iota // 进入AOI
+	AoiEventLeave                     // 离开AOI
+)
+
+// AoiEvent AOI进出事件
+// 功能：记录人进入或离开AOI（包括进入或离开室内）的时刻，在AOI的准备阶段应用缓冲区时产生
+type AoiEvent struct {
+	T        float64      // 事件生效的仿真时间（秒）
+	PersonID int32        // 人的ID
+	AoiID    int32        // AOI ID
+	Type     AoiEventType // 事件类型
+}
+
+// recordEvent 记录本AOI的进出事件，未启用时不记录
+func (a *Aoi) recordEvent(personID int32, typ AoiEventType) {
+	if *maxEvents <= 0 {
+		return
+	}
+	a.events = append(a.events, AoiEvent{
+		T:        a.ctx.Clock().T,
+		PersonID: personID,
+		AoiID:    a.id,
+		Type:     typ,
+	})
+}
+
+// collectEvents 按AOI顺序收集本步各AOI产生的进出事件，缓冲区超出上限时丢弃最早的事件
+func (m *AoiManager) collectEvents() {
+	if *maxEvents <= 0 {
+		return
+	}
+	m.eventsMtx.Lock()
+	defer m.eventsMtx.Unlock()
+	for _, a := range m.aois {
+		m.events = append(m.events, a.events...)
+		a.events = a.events[:0]
+	}
+	if over := len(m.events) - *maxEvents; over > 0 {
+		m.events = append(m.events[:0], m.events[over:]...)
+	}
+}
+
+// GetAoiEvents 获取自上次调用以来的AOI进出事件
+// 功能：返回并清空事件缓冲区，需启用aoi.max_events
+// 返回：按发生时间排序的进出事件，同一步内按AOI顺序排列
+func (m *AoiManager) GetAoiEvents() []AoiEvent {
+	m.eventsMtx.Lock()
+	defer m.eventsMtx.Unlock()
+	events := m.events
+	m.events = nil
+	return events
+}
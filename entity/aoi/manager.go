@@ -1,9 +1,18 @@
+// Package aoi AOI（兴趣区域）的管理与模拟。
+//
+// mapv2中暂无对应请求消息（或缺少所需字段）的功能以管理器上的导出方法形式提供，
+// 由外部服务直接调用，不经过RPC。
 package aoi
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+
+	"connectrpc.com/connect"
 
 	"git.fiblab.net/general/common/v2/parallel"
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
 	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
 	"git.fiblab.net/sim/protos/v2/go/city/map/v2/mapv2connect"
 	"github.com/samber/lo"
@@ -18,6 +27,12 @@ type AoiManager struct {
 
 	data map[int32]*Aoi
 	aois []*Aoi
+
+	pending    []*mapv2.Aoi // 待在下一次Prepare中加入的AOI
+	pendingMtx sync.Mutex
+
+	events    []AoiEvent // 自上次读取以来的AOI进出事件（aoi.max_events）
+	eventsMtx sync.Mutex
 }
 
 // NewManager 创建AOI管理器实例
@@ -72,11 +87,111 @@ func (m *AoiManager) GetOrError(id int32) (entity.IAoi, error) {
 	}
 }
 
+// AddAoi 运行时添加AOI
+// 功能：校验并缓存新的AOI，在下一步的准备阶段创建AOI、连接车道并加入导航服务，之后即可作为出行目的地
+// 参数：pb-AOI数据，其车道连接必须指向已有的对应类型车道
+// 返回：错误信息，AOI ID已存在或车道连接无效时返回错误
+func (m *AoiManager) AddAoi(pb *mapv2.Aoi) error {
+	if len(pb.Positions) == 0 {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("aoi has no boundary positions"))
+	}
+	if len(pb.DrivingPositions) == 0 && len(pb.WalkingPositions) == 0 {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("aoi is not connected to any lane"))
+	}
+	laneManager := m.ctx.LaneManager()
+	check := func(positions []*geov2.LanePosition, typ mapv2.LaneType) error {
+		for _, p := range positions {
+			lane, err := laneManager.GetOrError(p.LaneId)
+			if err != nil {
+				return connect.NewError(connect.CodeInvalidArgument, err)
+			}
+			if lane.Type() != typ || !lane.InRoad() {
+				return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("lane %d is not a %v lane in road", p.LaneId, typ))
+			}
+			if p.S < 0 || p.S > lane.Length() {
+				return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("s %f out of lane %d", p.S, p.LaneId))
+			}
+		}
+		return nil
+	}
+	if err := check(pb.DrivingPositions, mapv2.LaneType_LANE_TYPE_DRIVING); err != nil {
+		return err
+	}
+	if err := check(pb.WalkingPositions, mapv2.LaneType_LANE_TYPE_WALKING); err != nil {
+		return err
+	}
+	m.pendingMtx.Lock()
+	defer m.pendingMtx.Unlock()
+	if _, ok := m.data[pb.Id]; ok {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("aoi id already exists"))
+	}
+	for _, p := range m.pending {
+		if p.Id == pb.Id {
+			return connect.NewError(connect.CodeInvalidArgument, errors.New("aoi id already exists"))
+		}
+	}
+	m.pending = append(m.pending, pb)
+	return nil
+}
+
+// SetAoiCapacity 设置AOI的容纳人数上限
+// 功能：在下一步的准备阶段生效，AOI已满时到达的人在所连接的车道上等待
+// 参数：id-AOI ID，capacity-容纳人数上限，<=0表示不限制
+// 返回：错误信息，AOI不存在时返回错误
+func (m *AoiManager) SetAoiCapacity(id int32, capacity int32) error {
+	a, ok := m.data[id]
+	if !ok {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("aoi id does not exist"))
+	}
+	a.SetCapacity(capacity)
+	return nil
+}
+
+// GetAoiWeights 获取AOI在t时刻作为出行目的地的吸引力权重
+// 功能：供外部需求生成器按时段选择目的地，通常以当前仿真时间ctx.Clock().T调用
+// 参数：t-仿真时间（秒），ids-AOI ID列表，为空时返回所有AOI
+// 返回：AOI ID到权重的映射，错误信息，AOI不存在时返回错误
+func (m *AoiManager) GetAoiWeights(t float64, ids []int32) (map[int32]float64, error) {
+	if len(ids) == 0 {
+		return lo.SliceToMap(m.aois, func(a *Aoi) (int32, float64) {
+			return a.id, a.Attraction(t)
+		}), nil
+	}
+	weights := make(map[int32]float64, len(ids))
+	for _, id := range ids {
+		a, ok := m.data[id]
+		if !ok {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("aoi %d does not exist", id))
+		}
+		weights[id] = a.Attraction(t)
+	}
+	return weights, nil
+}
+
+// PrepareNode 准备阶段：将运行时添加的AOI加入管理器与导航服务
+// 说明：会修改AOI索引与车道的AOI列表，需在其他管理器的准备阶段之前串行调用
+func (m *AoiManager) PrepareNode() {
+	m.pendingMtx.Lock()
+	defer m.pendingMtx.Unlock()
+	if len(m.pending) == 0 {
+		return
+	}
+	for _, pb := range m.pending {
+		a := newAoi(m.ctx, pb, m, m.ctx.LaneManager())
+		m.aois = append(m.aois, a)
+		m.data[a.id] = a
+	}
+	m.ctx.Router().AddAois(m.pending)
+	log.Infof("add %d aois at runtime", len(m.pending))
+	m.pending = nil
+}
+
 // Prepare 准备阶段，处理所有AOI的缓冲区数据
 // 功能：对所有AOI执行准备阶段，处理人员进出和车辆停靠的缓冲区操作
 // 说明：使用并行处理提高性能，为输出准备数据
 func (m *AoiManager) Prepare() {
 	parallel.GoFor(m.aois, func(a *Aoi) { a.prepare() })
+	m.collectEvents()
 }
 
 // Update 更新阶段，执行所有AOI的模拟逻辑
@@ -1,17 +1,38 @@
 package entity
 
 import (
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
 	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/clock"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
 )
 
 // 导航模块接口
+// 导航选项（GetRouteRequest中无对应字段，由调用方额外指定）
+type RouteOptions struct {
+	AvoidTolls   bool   // 是否避开收费道路（仅对驾车导航生效）
+	VehicleClass string // 车辆类别，非空时避开不允许该类别通行的道路（仅对驾车导航生效）
+}
+
 type IRouter interface {
 	// 路径规划（回调版本）
 	GetRoute(in *routingv2.GetRouteRequest, process func(res *routingv2.GetRouteResponse)) chan struct{}
+	// 路径规划（回调版本，带导航选项）
+	GetRouteWithOptions(in *routingv2.GetRouteRequest, opts RouteOptions, process func(res *routingv2.GetRouteResponse)) chan struct{}
 	// 路径规划（同步版本）
 	GetRouteSync(in *routingv2.GetRouteRequest) *routingv2.GetRouteResponse
+	// 批量路径规划（同步版本，并行处理，结果与请求顺序一致）
+	GetRoutesBatch(reqs []*routingv2.GetRouteRequest) []*routingv2.GetRouteResponse
+	// 修改道路的时间代价，t为nil时修改所有时间片，否则只修改t所在的时间片
+	SetRoadCost(roadID int32, cost float64, t *float64) error
+	// 批量修改道路的时间代价（道路ID->代价），全部修改完成后统一生效
+	SetRoadCosts(costs map[int32]float64, t *float64) error
+	// 设置道路的额外时间代价（道路ID->代价，如临时事件），替换此前设置的全部额外代价
+	SetRoadPenalties(penalties map[int32]float64)
+	// 设置道路允许通行的车辆类别（道路ID->类别列表），未包含的道路不限制
+	SetRoadAllowedClasses(classes map[int32][]string)
+	// 运行时添加AOI，使其可以作为导航的起终点
+	AddAois(aois []*mapv2.Aoi)
 }
 
 type ITaskContext interface {
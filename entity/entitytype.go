@@ -44,6 +44,8 @@ type IPerson interface {
 
 	ParentID() int32                 // 获取人的空间父对象ID
 	PersonType() personv2.PersonType // Person类型
+	VehicleClass() string            // 获取人开车时的车辆类别
+	RouteOptions() RouteOptions      // 获取人驾车导航的选项（车辆类别与收费偏好）
 	Aoi() IAoi                       // 获取人所在的Aoi
 	Lane() ILane                     // 获取人所在的Lane
 	S() float64                      // 获取人在Lane上的位置S坐标
@@ -59,6 +61,7 @@ type IPerson interface {
 	DebugTripIndex() int32 // 获取调试用的trip index
 
 	GetLabel(key string) (string, bool) // 获取指定键的标签值
+	RejectByAoi(a IAoi)                 // Aoi已满时通知人未能进入（在Aoi的准备阶段调用）
 	// print
 
 	String() string
@@ -83,6 +86,13 @@ type Overlap struct {
 	SelfFirst bool    // 是否本Lane优先
 }
 
+// 车道上的临时事件（如交通事故），事件点前后一定范围内限速降低，到期后自动解除
+type Incident struct {
+	S    float64 // 事件点的S坐标
+	MaxV float64 // 事件范围内的限速（米/秒）
+	EndT float64 // 事件解除的时刻（秒）
+}
+
 // 车辆链表支链，记录左右车道的前后车辆
 type VehicleSideLink struct {
 	// [LEFT/RIGHT][BACK/FRONT]
@@ -195,7 +205,12 @@ type ILane interface {
 	// 车道状态
 
 	MaxV() float64                                                             // 获取车道限速
+	AvgV() float64                                                             // 获取平滑后的车辆平均速度
 	Light() (state mapv2.LightState, totalTime float64, remainingTime float64) // 获取信号灯状态
+	StopSign() bool                                                            // 车道末端是否有停车让行标志
+	AllowsClass(class string) bool                                             // 车道是否允许该类别的车辆通行
+	AllowedClasses() []string                                                  // 获取允许通行的车辆类别（nil表示不限制）
+	Incident() *Incident                                                       // 获取车道上的临时事件（没有时为nil）
 
 	// 所在道路/路口
 
@@ -206,12 +221,17 @@ type ILane interface {
 
 	AddVehicle(node *VehicleNode)          // 向Lane链表中添加车辆（Prepare后生效）
 	RemoveVehicle(node *VehicleNode)       // 从Lane链表中移除车辆（Prepare后生效）
+	AddShadowVehicle(node *VehicleNode)    // 向Lane链表中添加变道影子车辆（Prepare后生效）
+	RemoveShadowVehicle(node *VehicleNode) // 从Lane链表中移除变道影子车辆（Prepare后生效）
 	AddPedestrian(node *PedestrianNode)    // 向Lane链表中添加行人（Prepare后生效）
 	RemovePedestrian(node *PedestrianNode) // 从Lane链表中移除行人（Prepare后生效）
 
 	// setter
 
-	SetMaxV(v float64) // 设置车道限速
+	SetMaxV(v float64)                  // 设置车道限速
+	SetStopSign(stopSign bool)          // 设置车道末端的停车让行标志
+	SetAllowedClasses(classes []string) // 设置允许通行的车辆类别（空表示不限制）
+	SetIncident(incident *Incident)     // 设置车道上的临时事件（nil表示解除）
 }
 
 // 车道的信控接口
@@ -236,20 +256,35 @@ type IRoad interface {
 	ProjectToNearestDrivingLane(walkingLane ILane, s float64) (drivingLane ILane, newS float64) // 从步行道投影到最近的行车道
 	ProjectToNearestWalkingLane(drivingLane ILane, s float64) (walkingLane ILane, newS float64) // 从行车道投影到最近的步行道
 
-	MaxV() float64 // 获取道路限速
+	MaxV() float64            // 获取道路限速（同MeanMaxV，已弃用）
+	MeanMaxV() float64        // 获取行车道设计限速的平均值
+	MaxMaxV() float64         // 获取行车道设计限速的最大值
+	MaxVAt(s float64) float64 // 获取道路上指定位置的代表性限速（覆盖该位置的行车道当前限速的平均值）
 	GetAvgDrivingL() float64
+	Toll() float64 // 获取道路通行费（0表示不收费）
+
+	ObservedTravelTime() float64 // 获取根据行车道平滑车速估计的通行时间
 }
 
 // entity/junction/junction.go的依赖倒置
 type IJunction interface {
-	ID() int32              // 获取Junction ID
-	Lanes() map[int32]ILane // 获取Junction内的所有车道（Lane ID -> Lane）
-	HasTrafficLight() bool  // 判断是否有信号灯
+	ID() int32                 // 获取Junction ID
+	Lanes() map[int32]ILane    // 获取Junction内的所有车道（Lane ID -> Lane）
+	HasTrafficLight() bool     // 判断是否有信号灯
+	Reservation() IReservation // 获取路口的预约管理（非预约控制的路口为nil）
 
 	// 根据(入道路, 出道路) 获取Junction内的行车道组与角度
 	DrivingLaneGroup(inRoad, outRoad IRoad) (lanes []ILane, inAngle, outAngle float64, ok bool)
 }
 
+// 预约控制路口的依赖倒置（entity/junction/reservation.go）
+type IReservation interface {
+	// 申请在[arrival, exit]时间段内占用路口车道lane，下一步准备阶段统一处理；
+	// committed表示车辆已在路口内或无法在停车线前停车，申请总被接受
+	Request(personID int32, lane ILane, arrival, exit float64, committed bool)
+	Granted(personID int32, lane ILane) bool // 判断车辆是否已获得路口车道lane的通行许可
+}
+
 // entity/aoi/aoi.go的依赖倒置
 type IAoi interface {
 	// 自身属性
@@ -267,4 +302,7 @@ type IAoi interface {
 
 	AddPerson(p IPerson)    // 添加人到Aoi
 	RemovePerson(p IPerson) // 从Aoi中移除人
+
+	Capacity() int32            // 获取Aoi的容纳人数上限（<=0表示不限制）
+	SetCapacity(capacity int32) // 设置Aoi的容纳人数上限
 }
@@ -0,0 +1,45 @@
+package junction
+
+import (
+	"cmp"
+	"slices"
+)
+
+// ConflictPoint 路口内两条车道的冲突点
+// 功能：记录两条车道在路口内的重叠位置，LaneA的ID总是小于LaneB
+type ConflictPoint struct {
+	LaneA int32   // 车道A的ID
+	SA    float64 // 冲突点在车道A上的S坐标
+	LaneB int32   // 车道B的ID
+	SB    float64 // 冲突点在车道B上的S坐标
+}
+
+// ConflictPoints 获取路口内的冲突点
+// 功能：汇总路口内各车道的冲突点数据（Overlaps），两条车道互相记录的同一冲突点只保留一次
+// 返回：按(LaneA, LaneB, SA, SB)排序的冲突点列表
+// 说明：可用于外部的安全替代指标分析
+func (j *Junction) ConflictPoints() []ConflictPoint {
+	set := make(map[ConflictPoint]struct{})
+	for _, laneID := range j.laneIDs {
+		for s, o := range j.lanes[laneID].Overlaps() {
+			p := ConflictPoint{LaneA: laneID, SA: s, LaneB: o.Other.ID(), SB: o.OtherS}
+			if p.LaneA > p.LaneB {
+				p = ConflictPoint{LaneA: p.LaneB, SA: p.SB, LaneB: p.LaneA, SB: p.SA}
+			}
+			set[p] = struct{}{}
+		}
+	}
+	res := make([]ConflictPoint, 0, len(set))
+	for p := range set {
+		res = append(res, p)
+	}
+	slices.SortFunc(res, func(a, b ConflictPoint) int {
+		return cmp.Or(
+			cmp.Compare(a.LaneA, b.LaneA),
+			cmp.Compare(a.LaneB, b.LaneB),
+			cmp.Compare(a.SA, b.SA),
+			cmp.Compare(a.SB, b.SB),
+		)
+	})
+	return res
+}
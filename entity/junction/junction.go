@@ -2,11 +2,13 @@ package junction
 
 import (
 	"errors"
+	"flag"
 
 	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
 	"github.com/samber/lo"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/junction/trafficlight"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
 )
 
@@ -14,6 +16,20 @@ var (
 	ErrDisabledTrafficLight = errors.New("traffic light is disabled for the junction")
 )
 
+var (
+	disableAllTrafficLights = flag.Bool("tl.disable_all", false, "初始化时关闭所有路口的信控（全绿灯），可通过SetTrafficLightStatus对单个路口重新开启")
+)
+
+// JunctionControl 路口的信控类型
+type JunctionControl int32
+
+const (
+	JunctionControlNone        JunctionControl = iota // 无信控
+	JunctionControlFixed                              // 固定相位信控
+	JunctionControlMaxPressure                        // 最大压力信控
+	JunctionControlReservation                        // 预约控制（无信号灯）
+)
+
 type laneGroupKey struct {
 	InRoad  entity.IRoad
 	OutRoad entity.IRoad
@@ -31,12 +47,16 @@ type Junction struct {
 	id                int32
 	laneIDs           []int32
 	trafficLight      ITrafficLight          // 信号灯模块
+	control           JunctionControl        // 信号灯模块的信控类型
+	reservation       *reservationManager    // 预约控制模块（仅预约控制的路口）
 	lanes             map[int32]entity.ILane // 车道id->车道指针映射表
 	drivingLanes      []entity.ILane         // 行车道
 	drivingLaneGroups map[laneGroupKey]*laneGroupValue
 	preDrivingLanes   []entity.ILane       // 前驱行车道
 	phases            [][]mapv2.LightState // 最大压力信控的可用相位
 	fixedProgram      *mapv2.TrafficLight
+	turnCounter       *turnCounter   // 转向流量计数器
+	approachQueue     *approachQueue // 进口道排队统计
 
 	generator *randengine.Engine
 }
@@ -97,6 +117,8 @@ func newJunction(
 		j.drivingLaneGroups[key] = value
 	}
 
+	j.turnCounter = newTurnCounter(j.drivingLaneGroups)
+
 	// 初始化前驱行车道
 	for _, l := range j.drivingLanes {
 		pre, err := l.UniquePredecessor()
@@ -106,6 +128,7 @@ func newJunction(
 		j.preDrivingLanes = append(j.preDrivingLanes, pre)
 	}
 	j.preDrivingLanes = lo.Uniq(j.preDrivingLanes)
+	j.approachQueue = newApproachQueue(j.preDrivingLanes, *queueWindow)
 
 	// 转换可用相位数据
 	j.phases = lo.Map(base.Phases, func(p *mapv2.AvailablePhase, _ int) []mapv2.LightState {
@@ -113,37 +136,105 @@ func newJunction(
 	})
 
 	// 信号灯初始化逻辑
-	if ctx.RuntimeConfig().C.PreferFixedLight && j.fixedProgram != nil && len(j.fixedProgram.Phases) > 0 {
+	if lo.Contains(ctx.RuntimeConfig().C.ReservationJunctions, j.id) {
+		// 使用预约控制，不设置信号灯
+		j.reservation = newReservationManager(j.drivingLanes)
+		j.control = JunctionControlReservation
+	} else if ctx.RuntimeConfig().C.PreferFixedLight && j.fixedProgram != nil && len(j.fixedProgram.Phases) > 0 {
 		// 使用固定信号灯程序
 		j.trafficLight = trafficlight.NewLocalTrafficLight(ctx, j.id, lanes)
+		j.control = JunctionControlFixed
 		if err := j.trafficLight.Set(j.fixedProgram); err != nil {
 			log.Panicf("set fixed program error: %v", err)
 		}
 	} else {
 		// 使用最大压力信号灯
 		if len(j.phases) > 0 {
-			j.trafficLight = trafficlight.NewMaxPressureTrafficLight(j.id, lanes, j.phases)
+			times := clearanceTimes(ctx.RuntimeConfig().C.JunctionClearanceTimes, j.id)
+			j.trafficLight = trafficlight.NewMaxPressureTrafficLight(j.id, lanes, j.phases, times)
+			j.control = JunctionControlMaxPressure
 		}
 	}
+	// 无信控基准场景：关闭信控但保留信号灯模块，以便后续单独开启
+	if *disableAllTrafficLights && j.trafficLight != nil {
+		j.trafficLight.SetOk(false)
+	}
 
 	return j
 }
 
+// overrideProgram 以固定信控程序覆盖路口的信控
+// 功能：无论地图中的信控类型（包括预约控制），均改为执行给定程序的固定相位信控
+// 参数：tl-信控程序
+// 返回：程序无效时返回错误
+// 说明：程序在下一个更新阶段生效，tl.disable_all时仍保持关闭
+func (j *Junction) overrideProgram(tl *mapv2.TrafficLight) error {
+	lanes := lo.Map(j.laneIDs, func(id int32, _ int) entity.ILaneTrafficLightSetter {
+		return j.lanes[id]
+	})
+	j.trafficLight = trafficlight.NewLocalTrafficLight(j.ctx, j.id, lanes)
+	j.control = JunctionControlFixed
+	j.reservation = nil
+	if *disableAllTrafficLights {
+		j.trafficLight.SetOk(false)
+	}
+	if err := j.SetTrafficLight(tl); err != nil {
+		return err
+	}
+	j.fixedProgram = tl
+	return nil
+}
+
+// clearanceTimes 获取指定路口的过渡相位时长
+// 功能：以全局flag为默认值，使用配置中该路口的覆盖项替换对应字段
+// 参数：overrides-按路口ID配置的过渡相位时长，id-路口ID
+// 返回：该路口的过渡相位时长
+func clearanceTimes(overrides map[int32]config.ClearanceTime, id int32) trafficlight.ClearanceTimes {
+	times := trafficlight.DefaultClearanceTimes()
+	o, ok := overrides[id]
+	if !ok {
+		return times
+	}
+	if o.Yellow != nil {
+		times.Yellow = *o.Yellow
+	}
+	if o.AllRed != nil {
+		times.AllRed = *o.AllRed
+	}
+	if o.PedestrianClear != nil {
+		times.PedestrianClear = *o.PedestrianClear
+	}
+	return times
+}
+
 // prepare 准备阶段，处理信号灯的准备工作
-// 功能：执行信号灯的准备工作，处理各种写入缓冲区操作，更新排队情况等统计信息
+// 功能：执行信号灯的准备工作，处理各种写入缓冲区操作，更新排队情况等统计信息；预约控制的路口处理车辆的通行申请
 func (j *Junction) prepare() {
 	if j.trafficLight != nil {
 		j.trafficLight.Prepare()
 	}
+	if j.reservation != nil {
+		j.reservation.prepare(j.ctx.Clock().T)
+	}
 }
 
 // update 更新阶段，执行Junction的模拟逻辑
-// 功能：执行信号灯的更新逻辑，更新信号灯状态
+// 功能：执行信号灯的更新逻辑，更新信号灯状态，并统计转向流量与进口道排队
 // 参数：dt-时间步长
 func (j *Junction) update(dt float64) {
 	if j.trafficLight != nil {
 		j.trafficLight.Update(dt)
 	}
+	j.turnCounter.update(j.drivingLanes)
+	j.approachQueue.update()
+}
+
+// TurnCounts 获取转向流量统计
+// 功能：返回自上次重置以来各(入道路, 出道路)车道组驶入路口的车辆数
+// 参数：reset-是否在读取后清零计数
+// 返回：按(入道路ID, 出道路ID)排序的转向流量统计结果
+func (j *Junction) TurnCounts(reset bool) []TurnCount {
+	return j.turnCounter.get(j.drivingLaneGroups, reset)
 }
 
 // ID 获取Junction的唯一标识符
@@ -179,6 +270,41 @@ func (j *Junction) DrivingLaneGroup(inRoad, outRoad entity.IRoad) (lanes []entit
 	return value.Lanes, value.InAngle, value.OutAngle, true
 }
 
+// ApproachQueues 获取各进口道的平均排队长度
+// 功能：返回各前驱道路上停止车辆数在时间窗口内的平均值
+// 返回：前驱道路ID->平均排队车辆数
+// 说明：速度低于junction.queue_speed_threshold的车辆视为停止，窗口长度由junction.queue_window指定
+func (j *Junction) ApproachQueues() map[int32]float64 {
+	return j.approachQueue.get()
+}
+
+// LaneLightState 路口车道的信号灯状态
+// 功能：记录路口内单条车道当前的信号灯状态与剩余时间
+type LaneLightState struct {
+	LaneID        int32            // 车道ID
+	State         mapv2.LightState // 当前信号灯状态
+	TotalTime     float64          // 当前状态总时长
+	RemainingTime float64          // 当前状态剩余时间
+}
+
+// LightStates 获取路口内各车道的信号灯状态
+// 功能：直接读取车道上的信号灯状态，不依赖具体的信控算法
+// 返回：按地图中车道顺序排列的各车道信号灯状态
+// 说明：车道状态在prepare阶段由信控写入，无信控的路口全部为绿灯
+func (j *Junction) LightStates() []LaneLightState {
+	states := make([]LaneLightState, len(j.laneIDs))
+	for i, laneID := range j.laneIDs {
+		state, totalTime, remainingTime := j.lanes[laneID].Light()
+		states[i] = LaneLightState{
+			LaneID:        laneID,
+			State:         state,
+			TotalTime:     totalTime,
+			RemainingTime: remainingTime,
+		}
+	}
+	return states
+}
+
 // HasTrafficLight 判断是否有信号灯
 // 功能：检查当前Junction是否有可用的信号灯
 // 返回：true表示有信号灯且正常工作，false表示没有信号灯或信号灯失效
@@ -186,6 +312,16 @@ func (j *Junction) HasTrafficLight() bool {
 	return j.trafficLight != nil && j.trafficLight.Ok()
 }
 
+// Reservation 获取路口的预约管理
+// 功能：返回预约控制路口的通行许可管理，供车辆申请与查询通行许可
+// 返回：预约管理，非预约控制的路口返回nil
+func (j *Junction) Reservation() entity.IReservation {
+	if j.reservation == nil {
+		return nil
+	}
+	return j.reservation
+}
+
 // SetTrafficLight 设置信号灯程序
 // 功能：为Junction设置新的信号灯程序
 // 参数：tl-信号灯程序数据
@@ -223,6 +359,19 @@ func (j *Junction) setPhase(offset int32, remainingTime float64) error {
 	return nil
 }
 
+// flush 全红清空
+// 功能：将路口所有车道置为红灯持续指定时长，之后恢复原信控
+// 参数：seconds-全红时长
+// 返回：设置结果，如果信号灯被禁用则返回错误
+func (j *Junction) flush(seconds float64) error {
+	if j.trafficLight == nil {
+		// 信控被禁用，无法设置信号灯
+		return ErrDisabledTrafficLight
+	}
+	j.trafficLight.Flush(seconds)
+	return nil
+}
+
 // setStatus 设置信号灯状态
 // 功能：设置信号灯的开关状态
 // 参数：ok-信号灯状态，true表示正常工作，false表示失效（全绿灯）
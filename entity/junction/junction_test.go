@@ -0,0 +1,482 @@
+package junction
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/junction/trafficlight"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/input"
+	"google.golang.org/protobuf/proto"
+)
+
+// 测试用道路，仅实现ID
+type testRoad struct {
+	entity.IRoad
+	id int32
+}
+
+func (r *testRoad) ID() int32 { return r.id }
+
+// 测试用车道，仅实现类型、转向、所属道路与车辆链表
+type testLane struct {
+	entity.ILane
+	id       int32
+	parentID int32
+	turn     mapv2.LaneTurn
+	vehicles entity.VehicleList
+	nodes    map[int32]*entity.VehicleNode
+
+	overlaps                           map[float64]entity.Overlap
+	pressure                           float64
+	light                              mapv2.LightState
+	lightTotalTime, lightRemainingTime float64
+}
+
+func newTestLane(id int32, turn mapv2.LaneTurn) *testLane {
+	return &testLane{id: id, turn: turn, nodes: make(map[int32]*entity.VehicleNode)}
+}
+
+func (l *testLane) ID() int32                     { return l.id }
+func (l *testLane) ParentID() int32               { return l.parentID }
+func (l *testLane) Type() mapv2.LaneType          { return mapv2.LaneType_LANE_TYPE_DRIVING }
+func (l *testLane) Turn() mapv2.LaneTurn          { return l.turn }
+func (l *testLane) Vehicles() *entity.VehicleList { return &l.vehicles }
+func (l *testLane) GetPressure() float64          { return l.pressure }
+func (l *testLane) IsWalkLane() bool              { return false }
+func (l *testLane) IsRightTurnDrivingLane() bool  { return false }
+func (l *testLane) SetLight(state mapv2.LightState, totalTime, remainingTime float64) {
+	l.light, l.lightTotalTime, l.lightRemainingTime = state, totalTime, remainingTime
+}
+func (l *testLane) Light() (mapv2.LightState, float64, float64) {
+	return l.light, l.lightTotalTime, l.lightRemainingTime
+}
+func (l *testLane) SetParentJunctionWhenInit(entity.IJunction) {}
+func (l *testLane) Overlaps() map[float64]entity.Overlap       { return l.overlaps }
+
+// 设置车道上的车辆，模拟车辆驶入与驶离
+func (l *testLane) set(persons ...*testPerson) {
+	for _, node := range l.nodes {
+		l.vehicles.Remove(node)
+	}
+	clear(l.nodes)
+	for _, p := range persons {
+		node := &entity.VehicleNode{Value: p}
+		l.vehicles.PushBack(node)
+		l.nodes[p.id] = node
+	}
+}
+
+// 测试用车辆，仅实现ID、速度与长度
+type testPerson struct {
+	entity.IPerson
+	id int32
+	v  float64
+}
+
+func (p *testPerson) ID() int32       { return p.id }
+func (p *testPerson) V() float64      { return p.v }
+func (p *testPerson) Length() float64 { return 5 }
+
+// newTestJunction 创建包含左转与直行两个车道组的测试路口
+// 路口另有两条分别属于道路1与道路4的前驱行车道，用于排队统计
+func newTestJunction() (j *Junction, left, through *testLane) {
+	in, leftOut, throughOut := &testRoad{id: 1}, &testRoad{id: 2}, &testRoad{id: 3}
+	left = newTestLane(10, mapv2.LaneTurn_LANE_TURN_LEFT)
+	through = newTestLane(11, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	pre1 := newTestLane(20, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	pre1.parentID = 1
+	pre4 := newTestLane(21, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	pre4.parentID = 4
+	j = &Junction{
+		id:           100,
+		drivingLanes: []entity.ILane{left, through},
+		drivingLaneGroups: map[laneGroupKey]*laneGroupValue{
+			{InRoad: in, OutRoad: leftOut}:    {Lanes: []entity.ILane{left}},
+			{InRoad: in, OutRoad: throughOut}: {Lanes: []entity.ILane{through}},
+		},
+		preDrivingLanes: []entity.ILane{pre1, pre4},
+	}
+	j.turnCounter = newTurnCounter(j.drivingLaneGroups)
+	j.approachQueue = newApproachQueue(j.preDrivingLanes, 10)
+	return
+}
+
+func TestTurnCounts(t *testing.T) {
+	j, left, through := newTestJunction()
+	ps := make([]*testPerson, 6)
+	for i := range ps {
+		ps[i] = &testPerson{id: int32(i)}
+	}
+	// 按脚本驶入路口：0、1、2左转，3、4、5直行，车辆在路口内停留多步
+	steps := []struct {
+		left, through []*testPerson
+	}{
+		{left: ps[0:1], through: ps[3:4]},
+		{left: ps[0:2], through: ps[3:5]},
+		{left: ps[1:2], through: ps[4:6]},
+		{left: ps[1:3], through: ps[5:6]},
+		{left: ps[2:3], through: nil},
+		{left: nil, through: nil},
+	}
+	for _, s := range steps {
+		left.set(s.left...)
+		through.set(s.through...)
+		j.update(1)
+	}
+	counts := j.TurnCounts(false)
+	assert.Equal(t, []TurnCount{
+		{InRoadID: 1, OutRoadID: 2, Turn: mapv2.LaneTurn_LANE_TURN_LEFT, Count: 3},
+		{InRoadID: 1, OutRoadID: 3, Turn: mapv2.LaneTurn_LANE_TURN_STRAIGHT, Count: 3},
+	}, counts)
+
+	// 读取并重置后计数清零
+	assert.Equal(t, counts, j.TurnCounts(true))
+	for _, c := range j.TurnCounts(false) {
+		assert.Zero(t, c.Count)
+	}
+}
+
+func TestTurnCountsSplit(t *testing.T) {
+	j, left, through := newTestJunction()
+	// 每步有一辆新车驶入，每4辆中1辆左转、3辆直行
+	for i := int32(0); i < 40; i++ {
+		p := &testPerson{id: i}
+		if i%4 == 0 {
+			left.set(p)
+			through.set()
+		} else {
+			left.set()
+			through.set(p)
+		}
+		j.update(1)
+	}
+	counts := j.TurnCounts(true)
+	assert.Equal(t, int32(10), counts[0].Count)
+	assert.Equal(t, int32(30), counts[1].Count)
+}
+
+func TestApproachQueues(t *testing.T) {
+	j, _, _ := newTestJunction()
+	red := j.preDrivingLanes[0].(*testLane)
+	green := j.preDrivingLanes[1].(*testLane)
+	assert.Equal(t, map[int32]float64{1: 0, 4: 0}, j.ApproachQueues())
+
+	// 红灯进口道每步新增一辆停止车辆，绿灯进口道车辆持续通过
+	queued := make([]*testPerson, 0)
+	last := 0.
+	for i := int32(0); i < 20; i++ {
+		queued = append(queued, &testPerson{id: i})
+		red.set(queued...)
+		green.set(&testPerson{id: 100 + i, v: 10})
+		j.update(1)
+		queues := j.ApproachQueues()
+		assert.Greater(t, queues[1], last)
+		assert.Zero(t, queues[4])
+		last = queues[1]
+	}
+	// 窗口为10步，平均值为最近10步排队车辆数(11..20)的均值
+	assert.InDelta(t, 15.5, last, 1e-9)
+}
+
+func TestClearanceTimesOverride(t *testing.T) {
+	yellow := 5.
+	overrides := map[int32]config.ClearanceTime{1: {Yellow: &yellow}}
+	def := trafficlight.DefaultClearanceTimes()
+
+	// 被覆盖的路口只替换黄灯时间，其余字段与未配置的路口使用默认值
+	custom := clearanceTimes(overrides, 1)
+	assert.Equal(t, 5., custom.Yellow)
+	assert.Equal(t, def.AllRed, custom.AllRed)
+	assert.Equal(t, def.PedestrianClear, custom.PedestrianClear)
+	assert.Equal(t, 3., clearanceTimes(overrides, 2).Yellow)
+	assert.Equal(t, def, clearanceTimes(nil, 1))
+}
+
+// newTestSignalJunction 创建包含两条车道的信控测试路口，lanes[i]仅在第i相位放行
+func newTestSignalJunction() (*Junction, []*testLane, [][]mapv2.LightState) {
+	lanes := []*testLane{
+		newTestLane(10, mapv2.LaneTurn_LANE_TURN_STRAIGHT),
+		newTestLane(11, mapv2.LaneTurn_LANE_TURN_LEFT),
+	}
+	j := &Junction{
+		id:      100,
+		laneIDs: []int32{10, 11},
+		lanes:   map[int32]entity.ILane{10: lanes[0], 11: lanes[1]},
+	}
+	phases := [][]mapv2.LightState{
+		{mapv2.LightState_LIGHT_STATE_GREEN, mapv2.LightState_LIGHT_STATE_RED},
+		{mapv2.LightState_LIGHT_STATE_RED, mapv2.LightState_LIGHT_STATE_GREEN},
+	}
+	return j, lanes, phases
+}
+
+func lightStates(states []LaneLightState) []mapv2.LightState {
+	res := make([]mapv2.LightState, len(states))
+	for i, s := range states {
+		res[i] = s.State
+	}
+	return res
+}
+
+func TestLightStatesFixed(t *testing.T) {
+	j, lanes, phases := newTestSignalJunction()
+	j.trafficLight = trafficlight.NewLocalTrafficLight(nil, j.id, []entity.ILaneTrafficLightSetter{lanes[0], lanes[1]})
+	err := j.SetTrafficLight(&mapv2.TrafficLight{
+		JunctionId: j.id,
+		Phases: []*mapv2.Phase{
+			{Duration: 30, States: phases[0]},
+			{Duration: 30, States: phases[1]},
+		},
+	})
+	assert.NoError(t, err)
+
+	// 路口ID为100，初始相位为100%2=0
+	j.trafficLight.Update(1)
+	j.prepare()
+	states := j.LightStates()
+	assert.Equal(t, []int32{10, 11}, []int32{states[0].LaneID, states[1].LaneID})
+	assert.Equal(t, phases[0], lightStates(states))
+
+	for i := 0; i < 30; i++ {
+		j.trafficLight.Update(1)
+	}
+	j.prepare()
+	assert.Equal(t, phases[1], lightStates(j.LightStates()))
+}
+
+func TestLightStatesMaxPressure(t *testing.T) {
+	j, lanes, phases := newTestSignalJunction()
+	setters := []entity.ILaneTrafficLightSetter{lanes[0], lanes[1]}
+	j.trafficLight = trafficlight.NewMaxPressureTrafficLight(j.id, setters, phases, trafficlight.DefaultClearanceTimes())
+	// 最大压力信控不提供信控程序，但车道状态仍可读取
+	assert.Nil(t, j.trafficLight.Get())
+
+	// 第0车道压力更大，保持第0相位
+	lanes[0].pressure = 10
+	j.prepare()
+	j.trafficLight.Update(1)
+	j.prepare()
+	states := j.LightStates()
+	assert.Equal(t, phases[0], lightStates(states))
+	assert.Equal(t, 14., states[0].RemainingTime)
+	assert.Equal(t, 14., states[1].RemainingTime)
+
+	// 未启用信控时全部为绿灯
+	assert.NoError(t, j.setStatus(false))
+	j.prepare()
+	for _, s := range j.LightStates() {
+		assert.Equal(t, mapv2.LightState_LIGHT_STATE_GREEN, s.State)
+	}
+}
+
+// 测试用上下文，仅实现运行时配置
+type testContext struct {
+	entity.ITaskContext
+	runtimeConfig *config.RuntimeConfig
+}
+
+func (ctx *testContext) RuntimeConfig() *config.RuntimeConfig { return ctx.runtimeConfig }
+
+// 测试用车道管理器，仅实现按ID获取车道
+type testLaneManager struct {
+	entity.ILaneManager
+	lanes map[int32]*testLane
+}
+
+func (m *testLaneManager) Get(id int32) entity.ILane { return m.lanes[id] }
+
+func TestDisableAllTrafficLights(t *testing.T) {
+	defer flag.Set("tl.disable_all", "false")
+	flag.Set("tl.disable_all", "true")
+	_, lanes, phases := newTestSignalJunction()
+	ctx := &testContext{runtimeConfig: config.NewRuntimeConfig(config.Config{})}
+	lm := &testLaneManager{lanes: map[int32]*testLane{10: lanes[0], 11: lanes[1]}}
+	j := newJunction(ctx, &mapv2.Junction{
+		Id:      100,
+		LaneIds: []int32{10, 11},
+		Phases: []*mapv2.AvailablePhase{
+			{States: phases[0]},
+			{States: phases[1]},
+		},
+	}, lm, nil)
+
+	// 所有车道均为绿灯，车辆不会在路口停车
+	for i := 0; i < 60; i++ {
+		j.prepare()
+		j.trafficLight.Update(1)
+		assert.False(t, j.HasTrafficLight())
+		for _, s := range j.LightStates() {
+			assert.Equal(t, mapv2.LightState_LIGHT_STATE_GREEN, s.State)
+		}
+	}
+
+	// 单独开启该路口的信控后恢复红灯
+	assert.NoError(t, j.setStatus(true))
+	j.prepare()
+	j.trafficLight.Update(1)
+	j.prepare()
+	assert.True(t, j.HasTrafficLight())
+	assert.Contains(t, lightStates(j.LightStates()), mapv2.LightState_LIGHT_STATE_RED)
+}
+
+func TestFlushJunction(t *testing.T) {
+	allRed := []mapv2.LightState{mapv2.LightState_LIGHT_STATE_RED, mapv2.LightState_LIGHT_STATE_RED}
+	// flush 全红清空3秒，期间所有车道为红灯
+	flush := func(t *testing.T, j *Junction) {
+		m := &JunctionManager{data: map[int32]*Junction{j.id: j}}
+		assert.Error(t, m.FlushJunction(1, 3))
+		assert.Error(t, m.FlushJunction(j.id, 0))
+		assert.NoError(t, m.FlushJunction(j.id, 3))
+		for i := 0; i < 3; i++ {
+			j.prepare()
+			assert.Equal(t, allRed, lightStates(j.LightStates()))
+			assert.Equal(t, 3.-float64(i), j.LightStates()[0].RemainingTime)
+			j.trafficLight.Update(1)
+		}
+	}
+
+	t.Run("fixed", func(t *testing.T) {
+		j, lanes, phases := newTestSignalJunction()
+		j.trafficLight = trafficlight.NewLocalTrafficLight(nil, j.id, []entity.ILaneTrafficLightSetter{lanes[0], lanes[1]})
+		assert.NoError(t, j.SetTrafficLight(&mapv2.TrafficLight{
+			JunctionId: j.id,
+			Phases: []*mapv2.Phase{
+				{Duration: 30, States: phases[0]},
+				{Duration: 30, States: phases[1]},
+			},
+		}))
+		j.trafficLight.Update(1)
+		j.prepare()
+		assert.Equal(t, phases[0], lightStates(j.LightStates()))
+
+		flush(t, j)
+		// 全红结束后从原相位继续，剩余时间不变
+		j.prepare()
+		assert.Equal(t, phases[0], lightStates(j.LightStates()))
+		assert.Equal(t, 29., j.trafficLight.RemainingTime())
+		for i := 0; i < 29; i++ {
+			j.trafficLight.Update(1)
+		}
+		j.prepare()
+		assert.Equal(t, phases[1], lightStates(j.LightStates()))
+	})
+
+	t.Run("max pressure", func(t *testing.T) {
+		j, lanes, phases := newTestSignalJunction()
+		setters := []entity.ILaneTrafficLightSetter{lanes[0], lanes[1]}
+		j.trafficLight = trafficlight.NewMaxPressureTrafficLight(j.id, setters, phases, trafficlight.DefaultClearanceTimes())
+		lanes[0].pressure = 10
+		j.prepare()
+		j.trafficLight.Update(1)
+		j.prepare()
+		assert.Equal(t, phases[0], lightStates(j.LightStates()))
+
+		flush(t, j)
+		// 全红结束后恢复最大压力控制
+		j.prepare()
+		states := j.LightStates()
+		assert.Equal(t, phases[0], lightStates(states))
+		assert.Equal(t, 14., states[0].RemainingTime)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		// 全红覆盖不受信控开关影响
+		j, lanes, phases := newTestSignalJunction()
+		setters := []entity.ILaneTrafficLightSetter{lanes[0], lanes[1]}
+		j.trafficLight = trafficlight.NewMaxPressureTrafficLight(j.id, setters, phases, trafficlight.DefaultClearanceTimes())
+		assert.NoError(t, j.setStatus(false))
+		flush(t, j)
+		j.prepare()
+		assert.Equal(t, mapv2.LightState_LIGHT_STATE_GREEN, j.LightStates()[0].State)
+	})
+}
+
+func TestConflictPoints(t *testing.T) {
+	// 直行车道10与左转车道11交叉，两条车道各自记录同一个冲突点
+	j, lanes, _ := newTestSignalJunction()
+	lanes[0].overlaps = map[float64]entity.Overlap{5: {Other: lanes[1], OtherS: 7, SelfFirst: true}}
+	lanes[1].overlaps = map[float64]entity.Overlap{7: {Other: lanes[0], OtherS: 5}}
+	m := &JunctionManager{data: map[int32]*Junction{j.id: j}}
+
+	points, err := m.GetJunctionConflictPoints(j.id)
+	assert.NoError(t, err)
+	assert.Equal(t, []ConflictPoint{{LaneA: 10, SA: 5, LaneB: 11, SB: 7}}, points)
+	_, err = m.GetJunctionConflictPoints(1)
+	assert.Error(t, err)
+}
+
+func TestTrafficLightProgramOverride(t *testing.T) {
+	j, lanes, phases := newTestSignalJunction()
+	// 地图中的默认程序：两个相位各30秒
+	j.fixedProgram = &mapv2.TrafficLight{
+		JunctionId: j.id,
+		Phases: []*mapv2.Phase{
+			{Duration: 30, States: phases[0]},
+			{Duration: 30, States: phases[1]},
+		},
+	}
+	j.trafficLight = trafficlight.NewLocalTrafficLight(nil, j.id, []entity.ILaneTrafficLightSetter{lanes[0], lanes[1]})
+	assert.NoError(t, j.SetTrafficLight(j.fixedProgram))
+	j.trafficLight.Update(1)
+	j.prepare()
+
+	// 覆盖文件中的程序：第0相位10秒、第1相位50秒
+	path := filepath.Join(t.TempDir(), "tl.json")
+	content := `{"100": {"phases": [
+		{"duration": 10, "states": ["LIGHT_STATE_GREEN", "LIGHT_STATE_RED"]},
+		{"duration": 50, "states": ["LIGHT_STATE_RED", "LIGHT_STATE_GREEN"]}
+	]}}`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	tls, err := input.LoadTrafficLights(path)
+	assert.NoError(t, err)
+	m := &JunctionManager{data: map[int32]*Junction{j.id: j}}
+	assert.NoError(t, m.SetTrafficLightPrograms(tls))
+	j.trafficLight.Update(1)
+	j.prepare()
+	assert.True(t, proto.Equal(tls[100], j.trafficLight.Get()))
+	assert.Equal(t, int32(100), j.trafficLight.Get().JunctionId)
+	assert.Equal(t, 10., j.trafficLight.Get().Phases[0].Duration)
+
+	// 最大压力信控的路口同样被覆盖
+	setters := []entity.ILaneTrafficLightSetter{lanes[0], lanes[1]}
+	j.trafficLight = trafficlight.NewMaxPressureTrafficLight(j.id, setters, phases, trafficlight.DefaultClearanceTimes())
+	assert.NoError(t, m.SetTrafficLightPrograms(tls))
+	j.trafficLight.Update(1)
+	j.prepare()
+	assert.True(t, proto.Equal(tls[100], j.trafficLight.Get()))
+
+	// 路口不存在或状态数与车道数不符时报错
+	assert.Error(t, m.SetTrafficLightPrograms(map[int32]*mapv2.TrafficLight{1: {JunctionId: 1}}))
+	tls[100].Phases[0].States = phases[0][:1]
+	assert.Error(t, m.SetTrafficLightPrograms(tls))
+}
+
+func TestListJunctions(t *testing.T) {
+	_, lanes, phases := newTestSignalJunction()
+	lm := &testLaneManager{lanes: map[int32]*testLane{10: lanes[0], 11: lanes[1]}}
+	fixedCtx := &testContext{runtimeConfig: config.NewRuntimeConfig(config.Config{
+		Control: config.Control{PreferFixedLight: true},
+	})}
+	ctx := &testContext{runtimeConfig: config.NewRuntimeConfig(config.Config{})}
+	available := []*mapv2.AvailablePhase{{States: phases[0]}, {States: phases[1]}}
+	program := &mapv2.TrafficLight{
+		JunctionId: 3,
+		Phases:     []*mapv2.Phase{{Duration: 30, States: phases[0]}, {Duration: 30, States: phases[1]}},
+	}
+	m := &JunctionManager{junctions: []*Junction{
+		newJunction(fixedCtx, &mapv2.Junction{Id: 3, LaneIds: []int32{10, 11}, Phases: available, FixedProgram: program}, lm, nil),
+		newJunction(ctx, &mapv2.Junction{Id: 2, LaneIds: []int32{10, 11}, Phases: available}, lm, nil),
+		newJunction(ctx, &mapv2.Junction{Id: 1, LaneIds: []int32{10}}, lm, nil),
+	}}
+	assert.Equal(t, []JunctionInfo{
+		{ID: 1, HasTrafficLight: false, Control: JunctionControlNone, LaneCount: 1},
+		{ID: 2, HasTrafficLight: true, Control: JunctionControlMaxPressure, LaneCount: 2},
+		{ID: 3, HasTrafficLight: true, Control: JunctionControlFixed, LaneCount: 2},
+	}, m.ListJunctions())
+}
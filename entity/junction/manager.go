@@ -53,6 +53,24 @@ func (m *JunctionManager) Init(pbs []*mapv2.Junction, laneManager entity.ILaneMa
 	}
 }
 
+// SetTrafficLightPrograms 以固定信控程序覆盖指定路口的信控
+// 功能：将给定路口改为执行覆盖文件中的固定相位程序，取代地图中的固定程序或最大压力信控
+// 参数：programs-路口ID->信控程序
+// 返回：路口不存在或程序无效时返回错误
+// 说明：应在Init之后、模拟开始之前调用
+func (m *JunctionManager) SetTrafficLightPrograms(programs map[int32]*mapv2.TrafficLight) error {
+	for id, tl := range programs {
+		j, ok := m.data[id]
+		if !ok {
+			return fmt.Errorf("no id %d in junction data", id)
+		}
+		if err := j.overrideProgram(tl); err != nil {
+			return fmt.Errorf("override traffic light of junction %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
 // Get 根据ID获取Junction实例
 // 功能：通过Junction ID查找对应的Junction对象，如果不存在则panic
 // 参数：id-Junction的唯一标识符
@@ -1,9 +1,15 @@
+// Package junction 路口的管理与模拟。
+//
+// mapv2中暂无对应请求消息（或缺少所需字段）的功能以管理器上的导出方法形式提供，
+// 由外部服务直接调用，不经过RPC。
 package junction
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"net/http"
+	"slices"
 
 	"connectrpc.com/connect"
 	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
@@ -54,6 +60,18 @@ func (m *JunctionManager) GetTrafficLight(
 	}
 }
 
+// GetTrafficLightState 获取指定Junction内各车道的信号灯状态
+// 功能：对任意信控算法（固定相位、最大压力或无信控）统一返回各车道的当前信号灯状态与剩余时间
+// 参数：id-Junction ID
+// 返回：按地图中车道顺序排列的各车道信号灯状态，Junction不存在时返回错误
+func (m *JunctionManager) GetTrafficLightState(id int32) ([]LaneLightState, error) {
+	j, ok := m.data[id]
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("junction id does not exist"))
+	}
+	return j.LightStates(), nil
+}
+
 // SetTrafficLight RPC接口：设置指定Junction的信号灯程序
 // 功能：处理SetTrafficLight RPC请求，为指定Junction设置新的信号灯程序
 // 参数：ctx-上下文，in-包含信号灯程序和相位信息的请求
@@ -123,3 +141,82 @@ func (m *JunctionManager) SetTrafficLightStatus(
 	}
 	return connect.NewResponse(&mapv2.SetTrafficLightStatusResponse{}), nil
 }
+
+// GetJunctionCounts 获取指定Junction的转向流量统计
+// 功能：返回自上次重置以来，各(入道路, 出道路)车道组驶入路口的车辆数及其转向类型
+// 参数：id-Junction ID，reset-是否在读取后清零计数
+// 返回：按(入道路ID, 出道路ID)排序的转向流量统计结果，Junction不存在时返回错误
+func (m *JunctionManager) GetJunctionCounts(id int32, reset bool) ([]TurnCount, error) {
+	j, ok := m.data[id]
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("junction id does not exist"))
+	}
+	return j.TurnCounts(reset), nil
+}
+
+// FlushJunction 全红清空指定Junction
+// 功能：不论使用何种信控算法，将路口所有车道置为红灯持续seconds秒，之后恢复正常控制
+// 参数：id-Junction ID，seconds-全红时长（秒）
+// 返回：设置结果，Junction不存在、时长不为正或信控被禁用时返回错误
+func (m *JunctionManager) FlushJunction(id int32, seconds float64) error {
+	j, ok := m.data[id]
+	if !ok {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("junction id does not exist"))
+	}
+	if seconds <= 0 {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("flush duration must be positive"))
+	}
+	if err := j.flush(seconds); err != nil {
+		return connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	return nil
+}
+
+// GetJunctionApproachQueues 获取指定Junction各进口道的平均排队长度
+// 功能：返回各前驱道路上停止车辆数在时间窗口内的平均值
+// 参数：id-Junction ID
+// 返回：前驱道路ID->平均排队车辆数，Junction不存在时返回错误
+func (m *JunctionManager) GetJunctionApproachQueues(id int32) (map[int32]float64, error) {
+	j, ok := m.data[id]
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("junction id does not exist"))
+	}
+	return j.ApproachQueues(), nil
+}
+
+// GetJunctionConflictPoints 获取指定Junction内的冲突点
+// 功能：返回路口内各车道两两之间去重后的冲突点（车道ID与各自的S坐标）
+// 参数：id-Junction ID
+// 返回：按车道ID排序的冲突点列表，Junction不存在时返回错误
+func (m *JunctionManager) GetJunctionConflictPoints(id int32) ([]ConflictPoint, error) {
+	j, ok := m.data[id]
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("junction id does not exist"))
+	}
+	return j.ConflictPoints(), nil
+}
+
+// JunctionInfo 路口概况
+type JunctionInfo struct {
+	ID              int32           // 路口ID
+	HasTrafficLight bool            // 是否有正常工作的信号灯
+	Control         JunctionControl // 信控类型
+	LaneCount       int32           // 路口内车道数
+}
+
+// ListJunctions 列出所有Junction的概况
+// 功能：返回每个路口的ID、信号灯是否工作、信控类型（固定相位或最大压力）与路口内车道数，供外部控制器发现路网
+// 返回：按路口ID排序的路口概况列表
+func (m *JunctionManager) ListJunctions() []JunctionInfo {
+	infos := make([]JunctionInfo, len(m.junctions))
+	for i, j := range m.junctions {
+		infos[i] = JunctionInfo{
+			ID:              j.id,
+			HasTrafficLight: j.HasTrafficLight(),
+			Control:         j.control,
+			LaneCount:       int32(len(j.laneIDs)),
+		}
+	}
+	slices.SortFunc(infos, func(a, b JunctionInfo) int { return cmp.Compare(a.ID, b.ID) })
+	return infos
+}
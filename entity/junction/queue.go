@@ -0,0 +1,80 @@
+package junction
+
+import (
+	"flag"
+
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+var (
+	queueSpeedThreshold = flag.Float64("junction.queue_speed_threshold", 0.1, "排队统计中判定车辆停止的速度阈值（米/秒）")
+	queueWindow         = flag.Int("junction.queue_window", 60, "排队长度平滑的时间窗口（步数）")
+)
+
+// approachQueue 路口进口道排队统计
+// 功能：按前驱道路统计进口道上停止车辆的数量，并在滑动窗口内取平均
+type approachQueue struct {
+	lanes   map[int32][]entity.ILane // 前驱道路ID->该道路上通往路口的行车道
+	history map[int32][]int32        // 前驱道路ID->窗口内各步的停止车辆数（环形缓冲区）
+	sums    map[int32]int32          // 前驱道路ID->窗口内停止车辆数之和
+	index   int                      // 环形缓冲区的下一个写入位置
+	size    int                      // 环形缓冲区中已写入的步数
+	window  int                      // 窗口长度（步数）
+}
+
+// newApproachQueue 创建进口道排队统计
+// 功能：按所属道路对前驱行车道分组，初始化滑动窗口
+// 参数：preDrivingLanes-路口的前驱行车道，window-窗口长度（步数，不足1时按1处理）
+// 返回：初始化完成的排队统计
+func newApproachQueue(preDrivingLanes []entity.ILane, window int) *approachQueue {
+	window = max(window, 1)
+	q := &approachQueue{
+		lanes:   make(map[int32][]entity.ILane),
+		history: make(map[int32][]int32),
+		sums:    make(map[int32]int32),
+		window:  window,
+	}
+	for _, l := range preDrivingLanes {
+		roadID := l.ParentID()
+		q.lanes[roadID] = append(q.lanes[roadID], l)
+		if _, ok := q.history[roadID]; !ok {
+			q.history[roadID] = make([]int32, window)
+		}
+	}
+	return q
+}
+
+// update 更新排队统计
+// 功能：统计本步各进口道上速度低于阈值的车辆数，写入滑动窗口
+func (q *approachQueue) update() {
+	for roadID, lanes := range q.lanes {
+		count := int32(0)
+		for _, l := range lanes {
+			for node := l.Vehicles().First(); node != nil; node = node.Next() {
+				if node.V() < *queueSpeedThreshold {
+					count++
+				}
+			}
+		}
+		history := q.history[roadID]
+		q.sums[roadID] += count - history[q.index]
+		history[q.index] = count
+	}
+	q.index = (q.index + 1) % q.window
+	q.size = min(q.size+1, q.window)
+}
+
+// get 获取平均排队长度
+// 功能：返回各进口道在窗口内的平均停止车辆数
+// 返回：前驱道路ID->平均排队车辆数，尚未开始统计时均为0
+func (q *approachQueue) get() map[int32]float64 {
+	res := make(map[int32]float64, len(q.lanes))
+	for roadID := range q.lanes {
+		if q.size == 0 {
+			res[roadID] = 0
+		} else {
+			res[roadID] = float64(q.sums[roadID]) / float64(q.size)
+		}
+	}
+	return res
+}
@@ -0,0 +1,155 @@
+package junction
+
+import (
+	"cmp"
+	"flag"
+	"slices"
+	"sync"
+
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+var (
+	reservationBuffer = flag.Float64("junction.reservation_buffer", 1, "预约控制路口中冲突车道的占用时间窗之间的最小间隔（秒）")
+)
+
+// reservationRequest 车辆对路口车道的占用申请（通过后即为通行许可）
+type reservationRequest struct {
+	personID  int32
+	laneID    int32
+	arrival   float64 // 预计驶入时间
+	exit      float64 // 预计驶离时间
+	committed bool    // 车辆已在路口内或无法停车，申请无法拒绝
+}
+
+// reservationManager 预约控制路口的通行许可管理
+// 功能：取代信号灯，按车辆申请的时空窗口分配路口车道的通行许可，冲突车道上的许可时间窗互不重叠
+// 说明：车辆在更新阶段提交申请，路口在下一步准备阶段统一处理，更新阶段只读取许可
+type reservationManager struct {
+	conflicts map[int32][]int32 // 车道ID->冲突车道ID（路口内交叉或汇入同一后继车道）
+
+	mtx      sync.Mutex
+	requests map[int32]reservationRequest // 本步收到的申请，person ID->申请
+
+	grants map[int32]reservationRequest // 当前的通行许可，person ID->许可
+}
+
+// newReservationManager 创建预约控制路口的通行许可管理
+// 参数：drivingLanes-路口内的行车道
+// 返回：根据车道的冲突点与后继车道建立冲突关系的通行许可管理
+func newReservationManager(drivingLanes []entity.ILane) *reservationManager {
+	r := &reservationManager{
+		conflicts: make(map[int32][]int32),
+		requests:  make(map[int32]reservationRequest),
+		grants:    make(map[int32]reservationRequest),
+	}
+	for i, a := range drivingLanes {
+		for _, b := range drivingLanes[i+1:] {
+			if lanesConflict(a, b) {
+				r.conflicts[a.ID()] = append(r.conflicts[a.ID()], b.ID())
+				r.conflicts[b.ID()] = append(r.conflicts[b.ID()], a.ID())
+			}
+		}
+	}
+	return r
+}
+
+// lanesConflict 判断两条路口车道是否冲突：存在冲突点或汇入同一后继车道
+func lanesConflict(a, b entity.ILane) bool {
+	for _, o := range a.Overlaps() {
+		if o.Other == b {
+			return true
+		}
+	}
+	for _, o := range b.Overlaps() {
+		if o.Other == a {
+			return true
+		}
+	}
+	for id := range a.Successors() {
+		if _, ok := b.Successors()[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Request 申请在[arrival, exit]时间段内占用路口车道lane
+// 说明：每辆车每步只保留最后一次申请，持有许可的车辆需要每步续约
+func (r *reservationManager) Request(personID int32, lane entity.ILane, arrival, exit float64, committed bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.requests[personID] = reservationRequest{
+		personID:  personID,
+		laneID:    lane.ID(),
+		arrival:   arrival,
+		exit:      exit,
+		committed: committed,
+	}
+}
+
+// Granted 判断车辆是否已获得路口车道lane的通行许可
+func (r *reservationManager) Granted(personID int32, lane entity.ILane) bool {
+	g, ok := r.grants[personID]
+	return ok && g.laneID == lane.ID()
+}
+
+// prepare 处理上一步收到的申请，更新通行许可
+// 参数：now-当前时间
+// 算法说明：
+// 1. 未续约的许可保留到时间窗结束（车辆已驶离或放弃申请），避免估计误差导致冲突车辆提前进入
+// 2. 依次处理无法拒绝的申请、已持有同一车道许可的续约、新申请，同类按预计驶入时间与person ID排序
+// 3. 与冲突车道上已有许可的时间窗（含junction.reservation_buffer间隔）不重叠的申请获得许可
+func (r *reservationManager) prepare(now float64) {
+	r.mtx.Lock()
+	requests := r.requests
+	r.requests = make(map[int32]reservationRequest)
+	r.mtx.Unlock()
+
+	grants := make(map[int32]reservationRequest, len(r.grants))
+	for id, g := range r.grants {
+		if _, ok := requests[id]; !ok && g.exit+*reservationBuffer >= now {
+			grants[id] = g
+		}
+	}
+	rank := func(req reservationRequest) int {
+		if req.committed {
+			return 0
+		}
+		if g, ok := r.grants[req.personID]; ok && g.laneID == req.laneID {
+			return 1
+		}
+		return 2
+	}
+	order := make([]reservationRequest, 0, len(requests))
+	for _, req := range requests {
+		order = append(order, req)
+	}
+	slices.SortFunc(order, func(a, b reservationRequest) int {
+		return cmp.Or(
+			cmp.Compare(rank(a), rank(b)),
+			cmp.Compare(a.arrival, b.arrival),
+			cmp.Compare(a.personID, b.personID),
+		)
+	})
+	for _, req := range order {
+		if req.committed || !r.conflicting(grants, req) {
+			grants[req.personID] = req
+		}
+	}
+	r.grants = grants
+}
+
+// conflicting 判断申请与冲突车道上已有许可的时间窗是否重叠
+func (r *reservationManager) conflicting(grants map[int32]reservationRequest, req reservationRequest) bool {
+	buffer := *reservationBuffer
+	for _, g := range grants {
+		if !slices.Contains(r.conflicts[req.laneID], g.laneID) {
+			continue
+		}
+		if req.arrival < g.exit+buffer && g.arrival < req.exit+buffer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package trafficlight
+
+import (
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+// allRedFlush 全红清空的临时覆盖
+// 功能：在指定时长内将路口所有车道置为红灯，期间暂停底层信控算法，结束后从暂停处恢复
+// 说明：由固定相位与最大压力信号灯共用，与信控开关无关
+type allRedFlush struct {
+	remainingT float64 // 全红剩余时间
+	buffer     float64 // 待生效的全红时长，用于交互式接口写入（<=0表示没有）
+}
+
+// set 请求全红清空，下一个准备阶段生效，覆盖尚未结束的全红
+func (f *allRedFlush) set(seconds float64) {
+	f.buffer = seconds
+}
+
+// prepare 处理全红请求，全红期间将所有车道置为红灯
+// 返回：true表示处于全红期间，调用方不再写入正常的信控结果
+func (f *allRedFlush) prepare(lanes []entity.ILaneTrafficLightSetter) bool {
+	if f.buffer > 0 {
+		f.remainingT = f.buffer
+		f.buffer = 0
+	}
+	if f.remainingT <= 0 {
+		return false
+	}
+	for _, lane := range lanes {
+		lane.SetLight(mapv2.LightState_LIGHT_STATE_RED, f.remainingT, f.remainingT)
+	}
+	return true
+}
+
+// update 推进全红时间
+// 返回：true表示处于全红期间，调用方跳过本步的信控更新
+func (f *allRedFlush) update(dt float64) bool {
+	if f.remainingT <= 0 {
+		return false
+	}
+	f.remainingT -= dt
+	return true
+}
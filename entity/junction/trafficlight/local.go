@@ -31,6 +31,7 @@ type localTrafficLight struct {
 	buffer           *localTlRuntime // 数据buffer，用于交互式接口写入(optional)
 	ok               bool            // 信号灯状态，true为开启，false为关闭
 	okBuffer         bool            // 信号灯状态buffer，用于交互式接口写入
+	flush            allRedFlush     // 全红清空覆盖
 }
 
 // NewLocalTrafficLight 创建固定相位信号灯控制器
@@ -58,6 +59,9 @@ func (l *localTrafficLight) Prepare() {
 	// 写入snapshot
 	l.snapshot = l.runtime
 	// 写入lane中数据
+	if l.flush.prepare(l.lanes) {
+		return
+	}
 	if l.snapshot.tl == nil || !l.ok {
 		for _, lane := range l.lanes {
 			lane.SetLight(mapv2.LightState_LIGHT_STATE_GREEN, mathutil.INF, mathutil.INF)
@@ -135,7 +139,7 @@ func (l *localTrafficLight) Update(dt float64) {
 			}
 		}
 	}
-	if l.runtime.tl == nil || !l.ok {
+	if l.flush.update(dt) || l.runtime.tl == nil || !l.ok {
 		return
 	}
 
@@ -223,6 +227,14 @@ func (l *localTrafficLight) SetOk(ok bool) {
 	l.okBuffer = ok
 }
 
+// Flush 全红清空
+// 功能：在seconds秒内将所有车道置为红灯，结束后从原相位继续
+// 参数：seconds-全红时长
+// 说明：全红设置会延迟到下一个准备阶段生效
+func (l *localTrafficLight) Flush(seconds float64) {
+	l.flush.set(seconds)
+}
+
 // Step 获取当前相位索引
 // 功能：返回当前相位索引
 // 返回：当前相位索引
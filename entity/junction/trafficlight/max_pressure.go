@@ -19,8 +19,31 @@ var (
 	allRedTime          = flag.Float64("tl.mp_all_red_time", 3, "最大压力法全红时间")
 	phaseTime           = flag.Float64("tl.mp_phase_time", 15, "最大压力法相位时间")
 	maxRepeatCount      = flag.Int("tl.mp_max_repeat_count", 6, "最大压力法每个相位最多重复的次数")
+	thrashSwitchCount   = flag.Int("tl.mp_thrash_switch_count", 0, "最大压力法在时间窗口内相位切换次数超过该值时退化为固定轮转（<=0表示不启用）")
+	thrashWindow        = flag.Float64("tl.mp_thrash_window", 300, "最大压力法相位切换次数统计的时间窗口（秒）")
+	fallbackCooldown    = flag.Float64("tl.mp_fallback_cooldown", 600, "最大压力法退化为固定轮转后的持续时间（秒）")
 )
 
+// ClearanceTimes 最大压力法过渡相位时长
+// 功能：定义相位切换时行人清空、黄灯与全红相位的持续时间（秒）
+// 说明：不同规模的路口需要不同的清空时间，未单独配置时使用全局flag
+type ClearanceTimes struct {
+	Yellow          float64 // 黄灯时间
+	PedestrianClear float64 // 行人清空时间
+	AllRed          float64 // 全红时间
+}
+
+// DefaultClearanceTimes 获取全局默认的过渡相位时长
+// 功能：从tl.mp_yellow_time、tl.mp_pedestrian_clear_time、tl.mp_all_red_time读取默认值
+// 返回：默认过渡相位时长
+func DefaultClearanceTimes() ClearanceTimes {
+	return ClearanceTimes{
+		Yellow:          *yellowTime,
+		PedestrianClear: *pedestrianClearTime,
+		AllRed:          *allRedTime,
+	}
+}
+
 var (
 	ErrMaxPressure = errors.New("mp: cannot set traffic light with traffic light algorithm")
 )
@@ -37,6 +60,10 @@ type mpTlRuntime struct {
 	transitionTimes  []float64            // 过渡相位持续时长
 
 	nextIndex int // 黄灯状态后的下一个相位
+
+	elapsed       float64   // 信控累计运行时间
+	switchTimes   []float64 // 时间窗口内各次相位切换的时刻
+	fallbackUntil float64   // 固定轮转的结束时刻（早于该时刻时不使用最大压力算法选择相位）
 }
 
 // mpTrafficLight 最大压力信号灯控制器
@@ -44,23 +71,31 @@ type mpTlRuntime struct {
 type mpTrafficLight struct {
 	junctionID         int32                            // 所属junction ID
 	lanes              []entity.ILaneTrafficLightSetter // 车道数据
+	clearanceTimes     ClearanceTimes                   // 过渡相位时长
 	snapshotRemainingT float64                          // 上一次的剩余时间
 	runtime            mpTlRuntime                      // 运行时数据
 	ok                 bool                             // 信号灯状态，true为开启，false为关闭
 	okBuffer           bool                             // 信号灯状态buffer，用于交互式接口写入
+	flush              allRedFlush                      // 全红清空覆盖
 }
 
 // NewMaxPressureTrafficLight 创建Max Pressure算法信号灯控制器
 // 功能：初始化最大压力信号灯控制器，设置基础参数和可用相位
-// 参数：junctionID-路口ID，lanes-车道列表，phases-可用相位列表
+// 参数：junctionID-路口ID，lanes-车道列表，phases-可用相位列表，clearanceTimes-过渡相位时长
 // 返回：初始化完成的最大压力信号灯控制器实例
-func NewMaxPressureTrafficLight(junctionID int32, lanes []entity.ILaneTrafficLightSetter, phases [][]mapv2.LightState) *mpTrafficLight {
+func NewMaxPressureTrafficLight(
+	junctionID int32,
+	lanes []entity.ILaneTrafficLightSetter,
+	phases [][]mapv2.LightState,
+	clearanceTimes ClearanceTimes,
+) *mpTrafficLight {
 	return &mpTrafficLight{
-		junctionID: junctionID,
-		lanes:      lanes,
-		runtime:    mpTlRuntime{phases: phases},
-		ok:         true,
-		okBuffer:   true,
+		junctionID:     junctionID,
+		lanes:          lanes,
+		clearanceTimes: clearanceTimes,
+		runtime:        mpTlRuntime{phases: phases},
+		ok:             true,
+		okBuffer:       true,
 	}
 }
 
@@ -72,6 +107,9 @@ func (l *mpTrafficLight) Prepare() {
 	l.ok = l.okBuffer
 	l.snapshotRemainingT = l.runtime.remainingT
 	// 写入lane中数据
+	if l.flush.prepare(l.lanes) {
+		return
+	}
 	// 至少两个相位才有信控
 	if len(l.runtime.phases) < 2 || !l.ok {
 		// 无信控，全绿
@@ -113,11 +151,13 @@ func (l *mpTrafficLight) Prepare() {
 // 3. 选择压力最大的相位作为下一个相位
 // 4. 如果最大压力相位未变化且未达到最大重复次数，则延长当前相位
 // 5. 生成过渡相位（行人清空、黄灯、全红）
+// 6. 若时间窗口内相位切换过于频繁，则在冷却期内退化为按顺序轮转的固定周期
 func (l *mpTrafficLight) Update(dt float64) {
-	if len(l.runtime.phases) < 2 || !l.ok {
+	if l.flush.update(dt) || len(l.runtime.phases) < 2 || !l.ok {
 		return
 	}
 
+	l.runtime.elapsed += dt
 	l.runtime.remainingT -= dt
 	if l.runtime.remainingT > 0 {
 		// 当前相位没走完，啥事都不干
@@ -135,36 +175,18 @@ func (l *mpTrafficLight) Update(dt float64) {
 		l.runtime.remainingT += l.runtime.transitionTimes[0]
 	} else {
 		// 切换相位（正常灯->根据最大压力计算下一相位并生成黄灯相位）
-		// 找到最大压力的相位
-		lanePressure := lo.Map(l.lanes, func(l entity.ILaneTrafficLightSetter, _ int) float64 {
-			return l.GetPressure()
-		})
-		pressureHeap := container.NewPriorityQueue[int]()
-		for i, phase := range l.runtime.phases {
-			// 统计所有绿灯junction lane的压力和
-			pressure := 0.
-			for j, state := range phase {
-				if state == mapv2.LightState_LIGHT_STATE_GREEN {
-					pressure += lanePressure[j]
-				}
-			}
-			pressureHeap.Push(i, -pressure) // 小顶堆，压力越大越靠前
-		}
-		pressureHeap.Heapify()
-		// 如果最大压力的相位没有变化，延时直至达到最长时间（并切换到第二大压力的相位）
-		// 如果有变化，进入黄灯状态
-		maxIndex, _ := pressureHeap.HeapPop()
-		if maxIndex == l.runtime.index {
-			// 没变化，先检查是否达到最大延时次数
-			if l.runtime.repeatCount >= *maxRepeatCount {
-				// 达到最大延时次数，切换到第二大压力的相位
-				maxIndex, _ = pressureHeap.HeapPop()
-			} else {
-				l.runtime.remainingT += *phaseTime
-				l.runtime.repeatCount++
-			}
+		var maxIndex int
+		fallback := l.inFallback()
+		if fallback {
+			// 固定轮转，依次切换到下一相位
+			maxIndex = (l.runtime.index + 1) % len(l.runtime.phases)
+		} else {
+			maxIndex = l.selectPhase()
 		}
 		if maxIndex != l.runtime.index {
+			if !fallback {
+				l.recordSwitch()
+			}
 			// 有变化
 			l.runtime.nextIndex = maxIndex
 			l.runtime.repeatCount = 1
@@ -198,13 +220,13 @@ func (l *mpTrafficLight) Update(dt float64) {
 			l.runtime.transitionTimes = make([]float64, 0)
 			if hasClearPhase {
 				l.runtime.transitionPhases = append(l.runtime.transitionPhases, clearPhase)
-				l.runtime.transitionTimes = append(l.runtime.transitionTimes, *pedestrianClearTime)
+				l.runtime.transitionTimes = append(l.runtime.transitionTimes, l.clearanceTimes.PedestrianClear)
 			}
 			l.runtime.transitionPhases = append(l.runtime.transitionPhases, yellowPhase)
-			l.runtime.transitionTimes = append(l.runtime.transitionTimes, *yellowTime)
+			l.runtime.transitionTimes = append(l.runtime.transitionTimes, l.clearanceTimes.Yellow)
 			if hasAllRedPhase {
 				l.runtime.transitionPhases = append(l.runtime.transitionPhases, allRedPhase)
-				l.runtime.transitionTimes = append(l.runtime.transitionTimes, *allRedTime)
+				l.runtime.transitionTimes = append(l.runtime.transitionTimes, l.clearanceTimes.AllRed)
 			}
 			l.runtime.remainingT += l.runtime.transitionTimes[0]
 		}
@@ -216,6 +238,78 @@ func (l *mpTrafficLight) Update(dt float64) {
 	l.runtime.totalTime = l.runtime.remainingT
 }
 
+// selectPhase 使用最大压力算法选择下一相位
+// 功能：计算各相位绿灯车道的压力和，选择压力最大的相位
+// 返回：下一相位的索引，与当前相位相同时表示延长当前相位
+// 说明：当前相位达到最大重复次数时选择压力第二大的相位
+func (l *mpTrafficLight) selectPhase() int {
+	// 找到最大压力的相位
+	lanePressure := lo.Map(l.lanes, func(l entity.ILaneTrafficLightSetter, _ int) float64 {
+		return l.GetPressure()
+	})
+	pressureHeap := container.NewPriorityQueue[int]()
+	for i, phase := range l.runtime.phases {
+		// 统计所有绿灯junction lane的压力和
+		pressure := 0.
+		for j, state := range phase {
+			if state == mapv2.LightState_LIGHT_STATE_GREEN {
+				pressure += lanePressure[j]
+			}
+		}
+		pressureHeap.Push(i, -pressure) // 小顶堆，压力越大越靠前
+	}
+	pressureHeap.Heapify()
+	// 如果最大压力的相位没有变化，延时直至达到最长时间（并切换到第二大压力的相位）
+	// 如果有变化，进入黄灯状态
+	maxIndex, _ := pressureHeap.HeapPop()
+	if maxIndex == l.runtime.index {
+		// 没变化，先检查是否达到最大延时次数
+		if l.runtime.repeatCount >= *maxRepeatCount {
+			// 达到最大延时次数，切换到第二大压力的相位
+			maxIndex, _ = pressureHeap.HeapPop()
+		} else {
+			l.runtime.remainingT += *phaseTime
+			l.runtime.repeatCount++
+		}
+	}
+	return maxIndex
+}
+
+// inFallback 判断当前是否处于固定轮转状态
+// 功能：检查退化为固定轮转的冷却期是否仍未结束，结束后清空切换记录并恢复最大压力算法
+// 返回：true表示处于固定轮转状态
+func (l *mpTrafficLight) inFallback() bool {
+	if l.runtime.fallbackUntil <= 0 {
+		return false
+	}
+	if l.runtime.elapsed < l.runtime.fallbackUntil {
+		return true
+	}
+	l.runtime.fallbackUntil = 0
+	l.runtime.switchTimes = nil
+	return false
+}
+
+// recordSwitch 记录一次由最大压力算法触发的相位切换
+// 功能：维护时间窗口内的切换时刻，切换过于频繁时退化为固定轮转
+// 算法说明：
+// 1. 丢弃早于时间窗口的切换记录，加入本次切换时刻
+// 2. 窗口内切换次数超过tl.mp_thrash_switch_count时，在tl.mp_fallback_cooldown时间内使用固定轮转
+func (l *mpTrafficLight) recordSwitch() {
+	if *thrashSwitchCount <= 0 {
+		return
+	}
+	times := lo.Filter(l.runtime.switchTimes, func(t float64, _ int) bool {
+		return l.runtime.elapsed-t < *thrashWindow
+	})
+	l.runtime.switchTimes = append(times, l.runtime.elapsed)
+	if len(l.runtime.switchTimes) > *thrashSwitchCount {
+		log.Warnf("traffic light %d switches %d times in %vs, fallback to fixed cycle for %vs",
+			l.junctionID, len(l.runtime.switchTimes), *thrashWindow, *fallbackCooldown)
+		l.runtime.fallbackUntil = l.runtime.elapsed + *fallbackCooldown
+	}
+}
+
 // Get 获取当前信号灯程序
 // 功能：返回当前信号灯程序，最大压力算法不支持外部程序设置
 // 返回：始终返回nil，因为最大压力算法不保存外部程序
@@ -247,6 +341,14 @@ func (l *mpTrafficLight) SetOk(ok bool) {
 	l.okBuffer = ok
 }
 
+// Flush 全红清空
+// 功能：在seconds秒内将所有车道置为红灯，结束后从原相位继续最大压力控制
+// 参数：seconds-全红时长
+// 说明：全红设置会延迟到下一个准备阶段生效
+func (l *mpTrafficLight) Flush(seconds float64) {
+	l.flush.set(seconds)
+}
+
 // Step 获取当前相位索引
 // 功能：返回当前相位索引，最大压力算法返回-1表示动态相位
 // 返回：当前相位索引，最大压力算法返回-1
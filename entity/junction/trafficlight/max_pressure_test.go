@@ -0,0 +1,113 @@
+package trafficlight
+
+import (
+	"testing"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+// 测试用信控车道，压力固定
+type testLane struct {
+	pressure float64
+	state    mapv2.LightState
+}
+
+func (l *testLane) GetPressure() float64 { return l.pressure }
+func (l *testLane) SetLight(state mapv2.LightState, totalTime float64, remainingTime float64) {
+	l.state = state
+}
+func (l *testLane) IsWalkLane() bool             { return false }
+func (l *testLane) IsRightTurnDrivingLane() bool { return false }
+
+// newTestMaxPressure 创建两车道两相位的最大压力信控，第二相位压力更大
+func newTestMaxPressure(times ClearanceTimes) (*mpTrafficLight, []*testLane) {
+	lanes := []*testLane{{pressure: 1}, {pressure: 10}}
+	phases := [][]mapv2.LightState{
+		{mapv2.LightState_LIGHT_STATE_GREEN, mapv2.LightState_LIGHT_STATE_RED},
+		{mapv2.LightState_LIGHT_STATE_RED, mapv2.LightState_LIGHT_STATE_GREEN},
+	}
+	setters := []entity.ILaneTrafficLightSetter{lanes[0], lanes[1]}
+	return NewMaxPressureTrafficLight(1, setters, phases, times), lanes
+}
+
+// yellowDuration 统计第一相位切换到第二相位时黄灯持续的步数（步长1秒）
+func yellowDuration(l *mpTrafficLight, lanes []*testLane) int {
+	l.runtime.remainingT = 1
+	duration := 0
+	for step := 0; step < 100; step++ {
+		l.Prepare()
+		if lanes[0].state == mapv2.LightState_LIGHT_STATE_YELLOW {
+			duration++
+		} else if duration > 0 {
+			break
+		}
+		l.Update(1)
+	}
+	return duration
+}
+
+func TestClearanceTimes(t *testing.T) {
+	def := DefaultClearanceTimes()
+	l, lanes := newTestMaxPressure(def)
+	assert.Equal(t, int(def.Yellow), yellowDuration(l, lanes))
+
+	custom := def
+	custom.Yellow = 5
+	l, lanes = newTestMaxPressure(custom)
+	assert.Equal(t, 5, yellowDuration(l, lanes))
+}
+
+func TestThrashFallback(t *testing.T) {
+	defer func(count int, window, cooldown float64) {
+		*thrashSwitchCount, *thrashWindow, *fallbackCooldown = count, window, cooldown
+	}(*thrashSwitchCount, *thrashWindow, *fallbackCooldown)
+	*thrashSwitchCount, *thrashWindow, *fallbackCooldown = 3, 200, 300
+
+	// 三车道三相位，第i相位只放行第i车道
+	lanes := []*testLane{{}, {}, {}}
+	phases := make([][]mapv2.LightState, len(lanes))
+	for i := range phases {
+		phases[i] = make([]mapv2.LightState, len(lanes))
+		for j := range phases[i] {
+			phases[i][j] = mapv2.LightState_LIGHT_STATE_RED
+		}
+		phases[i][i] = mapv2.LightState_LIGHT_STATE_GREEN
+	}
+	setters := []entity.ILaneTrafficLightSetter{lanes[0], lanes[1], lanes[2]}
+	l := NewMaxPressureTrafficLight(1, setters, phases, DefaultClearanceTimes())
+
+	fallbackStart, fallbackEnd := -1., -1.
+	visited := make(map[int]bool)
+	resumed := false
+	for step := 0; step < 1000; step++ {
+		// 噪声需求：当前放行车道压力归零，第0、1车道交替成为压力最大的车道，第2车道始终无车
+		for _, lane := range lanes {
+			lane.pressure = 0
+		}
+		if l.runtime.index == 0 {
+			lanes[1].pressure = 10
+		} else {
+			lanes[0].pressure = 10
+		}
+		l.Prepare()
+		l.Update(1)
+		visited[l.runtime.index] = true
+		if fallbackStart < 0 && l.runtime.fallbackUntil > 0 {
+			fallbackStart = l.runtime.elapsed
+			fallbackEnd = l.runtime.fallbackUntil
+		}
+		if fallbackEnd > 0 && l.runtime.elapsed > fallbackEnd && len(l.runtime.switchTimes) > 0 {
+			// 冷却期结束后重新由最大压力算法触发相位切换
+			resumed = true
+			break
+		}
+	}
+	assert.Positive(t, fallbackStart)
+	assert.Less(t, fallbackStart, *thrashWindow)
+	assert.Equal(t, fallbackStart+*fallbackCooldown, fallbackEnd)
+	// 最大压力算法不会选择无车的第2相位，只有固定轮转会经过它
+	assert.True(t, visited[2])
+	assert.True(t, resumed)
+}
@@ -0,0 +1,101 @@
+package junction
+
+import (
+	"cmp"
+	"slices"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+// TurnCount 路口转向流量统计结果
+// 功能：记录自上次重置以来，从入道路驶向出道路的车辆数
+type TurnCount struct {
+	InRoadID  int32          // 入道路ID
+	OutRoadID int32          // 出道路ID
+	Turn      mapv2.LaneTurn // 转向类型（左转/直行/右转/掉头）
+	Count     int32          // 车辆数
+}
+
+// turnCounter 路口转向流量计数器
+// 功能：通过比较相邻两步路口行车道上的车辆集合，统计新驶入路口的车辆所属的车道组
+type turnCounter struct {
+	laneGroups map[entity.ILane]laneGroupKey // 行车道->所属车道组
+	counts     map[laneGroupKey]int32        // 车道组->自上次重置以来驶入的车辆数
+	vehicles   map[entity.IPerson]struct{}   // 上一步位于路口行车道上的车辆
+}
+
+// newTurnCounter 创建转向流量计数器
+// 功能：根据路口的行车道组建立车道到车道组的映射
+// 参数：groups-路口的行车道组
+// 返回：初始化完成的计数器
+func newTurnCounter(groups map[laneGroupKey]*laneGroupValue) *turnCounter {
+	c := &turnCounter{
+		laneGroups: make(map[entity.ILane]laneGroupKey),
+		counts:     make(map[laneGroupKey]int32),
+		vehicles:   make(map[entity.IPerson]struct{}),
+	}
+	for key, value := range groups {
+		for _, l := range value.Lanes {
+			if l.Type() == mapv2.LaneType_LANE_TYPE_DRIVING {
+				c.laneGroups[l] = key
+			}
+		}
+	}
+	return c
+}
+
+// update 更新转向流量计数
+// 功能：扫描路口行车道上的车辆，对上一步不在路口内的车辆按所在车道组计数
+// 参数：lanes-路口的行车道
+// 说明：车辆在路口内行驶期间只会被计数一次
+func (c *turnCounter) update(lanes []entity.ILane) {
+	vehicles := make(map[entity.IPerson]struct{}, len(c.vehicles))
+	for _, l := range lanes {
+		key, ok := c.laneGroups[l]
+		if !ok {
+			continue
+		}
+		for node := l.Vehicles().First(); node != nil; node = node.Next() {
+			p := node.Value
+			if _, ok := vehicles[p]; ok {
+				continue
+			}
+			vehicles[p] = struct{}{}
+			if _, ok := c.vehicles[p]; !ok {
+				c.counts[key]++
+			}
+		}
+	}
+	c.vehicles = vehicles
+}
+
+// get 获取转向流量计数
+// 功能：按(入道路ID, 出道路ID)排序输出各车道组的计数
+// 参数：groups-路口的行车道组（用于确定转向类型），reset-是否在读取后清零计数
+// 返回：转向流量统计结果列表，包含计数为0的车道组
+func (c *turnCounter) get(groups map[laneGroupKey]*laneGroupValue, reset bool) []TurnCount {
+	res := make([]TurnCount, 0, len(groups))
+	for key, value := range groups {
+		turn := mapv2.LaneTurn_LANE_TURN_UNSPECIFIED
+		if len(value.Lanes) > 0 {
+			turn = value.Lanes[0].Turn()
+		}
+		res = append(res, TurnCount{
+			InRoadID:  key.InRoad.ID(),
+			OutRoadID: key.OutRoad.ID(),
+			Turn:      turn,
+			Count:     c.counts[key],
+		})
+	}
+	slices.SortFunc(res, func(a, b TurnCount) int {
+		if a.InRoadID != b.InRoadID {
+			return cmp.Compare(a.InRoadID, b.InRoadID)
+		}
+		return cmp.Compare(a.OutRoadID, b.OutRoadID)
+	})
+	if reset {
+		clear(c.counts)
+	}
+	return res
+}
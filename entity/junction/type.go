@@ -24,4 +24,5 @@ type ITrafficLight interface {
 	Unset()                                       // 删除信控程序（全绿）
 	SetPhase(offset int32, remainingTime float64) // 修改信控相位到指定值
 	SetOk(ok bool)                                // 设置信控开关情况（true信控工作|false信控失效-全绿）
+	Flush(seconds float64)                        // 全红清空：所有车道红灯持续seconds秒后恢复原信控
 }
@@ -0,0 +1,95 @@
+package lane
+
+import (
+	"flag"
+)
+
+var (
+	maxJamEvents       = flag.Int("lane.max_jam_events", 0, "车道拥堵事件缓冲区的最大长度，超出时丢弃最早的事件（<=0表示不检测拥堵）")
+	jamStopSpeed       = flag.Float64("lane.jam_stop_speed", 0.5, "拥堵检测中视为停止的车速阈值（米/秒）")
+	jamStoppedFraction = flag.Float64("lane.jam_stopped_fraction", 0.5, "拥堵检测的停止车辆占比阈值，车道上停止车辆的占比超过该值视为拥堵")
+	jamDuration        = flag.Float64("lane.jam_duration", 60, "停止车辆占比持续超过阈值多长时间后产生拥堵事件（秒）")
+)
+
+// JamEventType 车道拥堵事件类型
+type JamEventType int
+
+const (
+	JamEventStart JamEventType = iota // 开始拥堵
+	JamEventEnd                       // 拥堵消散
+)
+
+// JamEvent 车道拥堵事件
+// 功能：记录行车道上停止车辆的占比持续超过阈值（开始拥堵）或回落到阈值以下（拥堵消散）的时刻，在车道的更新阶段产生
+type JamEvent struct {
+	T               float64      // 事件产生的仿真时间（秒）
+	LaneID          int32        // 车道ID
+	Type            JamEventType // 事件类型
+	StoppedFraction float64      // 事件产生时的停止车辆占比
+}
+
+// updateJam 根据本步停止车辆的占比更新拥堵状态，未启用时不检测
+// 参数：stopped-停止车辆数，count-车辆数
+func (l *Lane) updateJam(stopped, count int) {
+	if *maxJamEvents <= 0 {
+		return
+	}
+	now := l.ctx.Clock().T
+	fraction := 0.
+	if count > 0 {
+		fraction = float64(stopped) / float64(count)
+	}
+	if fraction <= *jamStoppedFraction {
+		l.jamAbove = false
+		if l.jammed {
+			l.jammed = false
+			l.recordJamEvent(JamEventEnd, fraction)
+		}
+		return
+	}
+	if !l.jamAbove {
+		l.jamAbove = true
+		l.jamStart = now
+	}
+	if !l.jammed && now-l.jamStart >= *jamDuration {
+		l.jammed = true
+		l.recordJamEvent(JamEventStart, fraction)
+	}
+}
+
+// recordJamEvent 记录本车道的拥堵事件
+func (l *Lane) recordJamEvent(typ JamEventType, fraction float64) {
+	l.jamEvents = append(l.jamEvents, JamEvent{
+		T:               l.ctx.Clock().T,
+		LaneID:          l.id,
+		Type:            typ,
+		StoppedFraction: fraction,
+	})
+}
+
+// collectJamEvents 按车道顺序收集本步各车道产生的拥堵事件，缓冲区超出上限时丢弃最早的事件
+func (m *LaneManager) collectJamEvents() {
+	if *maxJamEvents <= 0 {
+		return
+	}
+	m.jamEventsMtx.Lock()
+	defer m.jamEventsMtx.Unlock()
+	for _, l := range m.lanes {
+		m.jamEvents = append(m.jamEvents, l.jamEvents...)
+		l.jamEvents = l.jamEvents[:0]
+	}
+	if over := len(m.jamEvents) - *maxJamEvents; over > 0 {
+		m.jamEvents = append(m.jamEvents[:0], m.jamEvents[over:]...)
+	}
+}
+
+// GetJamEvents 获取自上次调用以来的车道拥堵事件
+// 功能：返回并清空事件缓冲区，需启用lane.max_jam_events
+// 返回：按发生时间排序的拥堵事件，同一步内按车道顺序排列
+func (m *LaneManager) GetJamEvents() []JamEvent {
+	m.jamEventsMtx.Lock()
+	defer m.jamEventsMtx.Unlock()
+	events := m.jamEvents
+	m.jamEvents = nil
+	return events
+}
@@ -5,6 +5,7 @@ import (
 	"math"
 	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 
@@ -16,7 +17,9 @@ import (
 )
 
 const (
-	winLength = 600 // 统计路况的时间窗长度(s)
+	winLength     = 600  // 统计路况的时间窗长度(s)
+	minLaneLength = 0.01 // 车道最小长度(m)，零长度车道按此长度处理，避免除零
+	timeGapMinV   = 0.1  // 计算车头时距时视为停止的速度阈值(m/s)
 )
 
 // Lane 车道实体
@@ -55,11 +58,28 @@ type Lane struct {
 	lineDirections    []geometry.PolylineDirection // 中心线折线段每一段的方向（atan2）
 	line              []geometry.Point             // 转成Point的中心线折线
 
-	maxVBuffer float64 // 限速buffer
-	k          float64 // 平滑系数
+	maxVBuffer           float64          // 限速buffer
+	stopSign             bool             // 车道末端是否为停车让行标志控制的进口道
+	stopSignBuffer       bool             // 停车让行标志buffer
+	allowedClasses       map[string]bool  // 允许通行的车辆类别（nil表示不限制）
+	allowedClassesBuffer map[string]bool  // 允许通行的车辆类别buffer
+	incident             *entity.Incident // 临时事件（nil表示没有）
+	incidentBuffer       *entity.Incident // 临时事件buffer
+	incidentChanged      bool             // 临时事件在本次prepare中是否变化（生效或解除），需要同步给导航服务
+	k                    float64          // 平滑系数
+	avgV                 float64          // 指数平滑后的车辆平均速度
+
+	jamAbove  bool       // 停止车辆占比是否超过阈值（lane.jam_stopped_fraction）
+	jamStart  float64    // 停止车辆占比开始超过阈值的时间
+	jammed    bool       // 是否处于拥堵状态
+	jamEvents []JamEvent // 本步更新阶段产生的拥堵事件（lane.max_jam_events）
 
 	pedestrians laneList[entity.IPerson, struct{}]
 	vehicles    laneList[entity.IPerson, entity.VehicleSideLink]
+	vehicleIn   atomic.Int64 // 累计驶入车辆数（在prepare中应用添加缓冲区时计数）
+	vehicleOut  atomic.Int64 // 累计驶离车辆数（在prepare中应用删除缓冲区时计数）
+	shadowIn    atomic.Int64 // 本步缓冲区中待添加的影子车辆数（不计入驶入数）
+	shadowOut   atomic.Int64 // 本步缓冲区中待删除的影子车辆数（不计入驶离数）
 
 	lightState              mapv2.LightState // 车道信号灯状态
 	lightStateTotalTime     float64          // 车道信号灯本相位总时长
@@ -98,13 +118,30 @@ func newLane(ctx entity.ITaskContext, base *mapv2.Lane) *Lane {
 		lightStateTotalTime:     mathutil.INF,
 		lightStateRemainingTime: mathutil.INF,
 		maxVBuffer:              base.MaxSpeed,
+		avgV:                    base.MaxSpeed,
 	}
-	l.line = lo.Map(base.CenterLine.Nodes, func(node *geov2.XYPosition, _ int) geometry.Point {
+	if classes, ok := ctx.RuntimeConfig().C.LaneAllowedClasses[l.id]; ok {
+		l.allowedClasses = newClassSet(classes)
+		l.allowedClassesBuffer = l.allowedClasses
+	}
+	l.line = lo.Map(base.CenterLine.GetNodes(), func(node *geov2.XYPosition, _ int) geometry.Point {
 		return geometry.NewPointFromPb(node)
 	})
+	if len(l.line) == 0 {
+		log.Panicf("lane %d has no center line", l.id)
+	}
+	if len(l.line) == 1 {
+		// 只有一个点的中心线补齐为两个重合点，保证至少有一段折线方向
+		l.line = append(l.line, l.line[0])
+	}
 	l.lineLengths = geometry.GetPolylineLengths2D(l.line)
 	l.length = l.lineLengths[len(l.lineLengths)-1]
 	l.lineDirections = geometry.GetPolylineDirections(l.line)
+	if l.length < minLaneLength {
+		// 零长度车道：几何上仍为一个点，长度取最小值，使按长度计算的密度与比例保持有限
+		log.Warnf("lane %d has zero length (%v), use minimum length %v", l.id, l.length, minLaneLength)
+		l.length = minLaneLength
+	}
 
 	switch l.typ {
 	case mapv2.LaneType_LANE_TYPE_DRIVING:
@@ -176,9 +213,20 @@ func (l *Lane) initWithManager(laneManager entity.ILaneManager) {
 func (l *Lane) prepare() {
 	// 限速buffer写入
 	l.maxV = l.maxVBuffer
+	l.stopSign = l.stopSignBuffer
+	l.allowedClasses = l.allowedClassesBuffer
+	// 临时事件到期后自动解除
+	if l.incidentBuffer != nil && l.ctx.Clock().T >= l.incidentBuffer.EndT {
+		l.incidentBuffer = nil
+	}
+	l.incidentChanged = l.incident != l.incidentBuffer
+	l.incident = l.incidentBuffer
 	// 维护本车道链表
 	l.pedestrians.prepare()
-	l.vehicles.prepare()
+	in, out := l.vehicles.prepare()
+	// 在缓冲区实际生效时计数，避免同一车辆在缓冲期间被重复统计；变道中的影子车辆不计数
+	l.vehicleIn.Add(int64(in) - l.shadowIn.Swap(0))
+	l.vehicleOut.Add(int64(out) - l.shadowOut.Swap(0))
 }
 
 // prepare2 第二阶段准备，处理车道间的侧链关系和行人占用计算
@@ -219,8 +267,30 @@ func (l *Lane) prepare2() {
 
 // update 更新阶段，执行Lane的模拟逻辑
 // 功能：更新行车道的车辆统计、路况计算、能耗排放统计等
-// 说明：只对行车道进行统计更新，使用指数平滑算法计算平均车速
+// 说明：只对行车道进行统计更新，使用指数平滑算法计算平均车速，车道上没有车辆时按限速（自由流）计算；
+// 启用lane.max_jam_events时根据停止车辆的占比检测拥堵
 func (l *Lane) update() {
+	if l.typ != mapv2.LaneType_LANE_TYPE_DRIVING {
+		return
+	}
+	sumV := .0
+	count, stopped := 0, 0
+	for node := l.vehicles.list.First(); node != nil; node = node.Next() {
+		if node.Value.ShadowLane() != l {
+			v := node.V()
+			sumV += v
+			count++
+			if v < *jamStopSpeed {
+				stopped++
+			}
+		}
+	}
+	v := l.maxV
+	if count > 0 {
+		v = sumV / float64(count)
+	}
+	l.avgV = l.k*l.avgV + (1-l.k)*v
+	l.updateJam(stopped, count)
 }
 
 // 数据初始化
@@ -366,7 +436,7 @@ func (l *Lane) GetPressure() float64 {
 	incoming := .0
 	// 车辆数/长度
 	if pre.Length() > 10 {
-		incoming = float64(pre.Vehicles().Len()) / pre.Length()
+		incoming = density(pre.Vehicles().Len(), pre.Length())
 	} else {
 		// 如果前驱车道长度小于10米，则向前多考虑一个路口内的车道，把堵在路口的车也考虑进来
 		totalLength := pre.Length()
@@ -375,7 +445,7 @@ func (l *Lane) GetPressure() float64 {
 			totalLength += conn.Lane.Length()
 			totalCount += conn.Lane.Vehicles().Len()
 		}
-		incoming = float64(totalCount) / totalLength
+		incoming = density(totalCount, totalLength)
 	}
 	// 按后继数均分
 	incoming /= float64(len(pre.Successors()))
@@ -384,7 +454,7 @@ func (l *Lane) GetPressure() float64 {
 	// 车辆数/长度
 	outgoing := .0
 	if suc.Length() > 10 {
-		outgoing = float64(suc.Vehicles().Len()) / suc.Length()
+		outgoing = density(suc.Vehicles().Len(), suc.Length())
 	} else {
 		// 如果后继车道长度小于10米，则向后多考虑一个路口内的车道，把堵在路口的车也考虑进来
 		totalLength := suc.Length()
@@ -393,13 +463,39 @@ func (l *Lane) GetPressure() float64 {
 			totalLength += conn.Lane.Length()
 			totalCount += conn.Lane.Vehicles().Len()
 		}
-		outgoing = float64(totalCount) / totalLength
+		outgoing = density(totalCount, totalLength)
 	}
 	// 按前驱数均分
 	outgoing /= float64(len(suc.Predecessors()))
 	return incoming - outgoing
 }
 
+// density 计算车辆密度（车辆数/长度），长度不小于车道最小长度
+func density(count int, length float64) float64 {
+	return float64(count) / math.Max(length, minLaneLength)
+}
+
+// MeanTimeGap 计算车道上相邻车辆的平均车头时距
+// 功能：沿车辆链表计算每辆车与前车的净间距除以本车速度，取平均值，用于跟驰模型标定
+// 返回：平均车头时距（秒），没有可计算的车辆对时返回NaN
+// 说明：速度低于timeGapMinV的停止车辆不参与计算
+func (l *Lane) MeanTimeGap() float64 {
+	sum := .0
+	count := 0
+	for node := l.Vehicles().First(); node != nil; node = node.Next() {
+		ahead := node.Next()
+		if ahead == nil || node.V() < timeGapMinV {
+			continue
+		}
+		sum += (ahead.S - ahead.L() - node.S) / node.V()
+		count++
+	}
+	if count == 0 {
+		return math.NaN()
+	}
+	return sum / float64(count)
+}
+
 // VehicleCount 统计非影子车辆数
 // 功能：统计车道上的非影子车辆数量，用于交通流分析
 // 返回：非影子车辆数量
@@ -523,11 +619,62 @@ func (l *Lane) MaxV() float64 {
 	return l.maxV
 }
 
+// 获取平滑后的车辆平均速度（米/秒）
+func (l *Lane) AvgV() float64 {
+	return l.avgV
+}
+
 // 设置车道限速
 func (l *Lane) SetMaxV(v float64) {
 	l.maxVBuffer = v
 }
 
+// 车道末端是否有停车让行标志（车辆须在驶入路口前停车）
+func (l *Lane) StopSign() bool {
+	return l.stopSign
+}
+
+// 设置车道末端的停车让行标志
+func (l *Lane) SetStopSign(stopSign bool) {
+	l.stopSignBuffer = stopSign
+}
+
+// 车道是否允许该类别的车辆通行
+func (l *Lane) AllowsClass(class string) bool {
+	return l.allowedClasses == nil || l.allowedClasses[class]
+}
+
+// 获取允许通行的车辆类别，不限制时返回nil
+func (l *Lane) AllowedClasses() []string {
+	if l.allowedClasses == nil {
+		return nil
+	}
+	return lo.Keys(l.allowedClasses)
+}
+
+// 设置允许通行的车辆类别，classes为空时不限制
+func (l *Lane) SetAllowedClasses(classes []string) {
+	l.allowedClassesBuffer = newClassSet(classes)
+}
+
+// 获取车道上的临时事件，没有时为nil
+func (l *Lane) Incident() *entity.Incident {
+	return l.incident
+}
+
+// 设置车道上的临时事件，nil表示解除
+func (l *Lane) SetIncident(incident *entity.Incident) {
+	l.incidentBuffer = incident
+}
+
+// 将车辆类别列表转换为集合，空列表返回nil（不限制）
+func newClassSet(classes []string) map[string]bool {
+	if len(classes) == 0 {
+		return nil
+	}
+	return lo.SliceToMap(classes, func(c string) (string, bool) { return c, true })
+}
+
 // 人车更新相关函数
 
 // 获取车道上的车辆
@@ -540,6 +687,16 @@ func (l *Lane) Pedestrians() *entity.PedestrianList {
 	return l.pedestrians.list
 }
 
+// 获取累计驶入、驶离车辆数（不含变道中的影子车辆）
+func (l *Lane) InOutCounts() (in, out int64) {
+	return l.vehicleIn.Load(), l.vehicleOut.Load()
+}
+
+// 获取累计驶入、驶离车辆数并清零
+func (l *Lane) ResetInOutCounts() (in, out int64) {
+	return l.vehicleIn.Swap(0), l.vehicleOut.Swap(0)
+}
+
 // 向Lane链表中添加行人（Prepare后生效）
 func (l *Lane) AddPedestrian(node *entity.PedestrianNode) {
 	l.pedestrians.add(node)
@@ -560,6 +717,18 @@ func (l *Lane) RemoveVehicle(node *entity.VehicleNode) {
 	l.vehicles.remove(node)
 }
 
+// 向Lane链表中添加变道中的影子车辆（Prepare后生效，不计入驶入车辆数）
+func (l *Lane) AddShadowVehicle(node *entity.VehicleNode) {
+	l.vehicles.add(node)
+	l.shadowIn.Add(1)
+}
+
+// 从Lane链表中移除变道中的影子车辆（Prepare后生效，不计入驶离车辆数）
+func (l *Lane) RemoveShadowVehicle(node *entity.VehicleNode) {
+	l.vehicles.remove(node)
+	l.shadowOut.Add(1)
+}
+
 // 获取第一辆车
 func (l *Lane) FirstVehicle() *entity.VehicleNode {
 	return l.vehicles.list.First()
@@ -594,6 +763,7 @@ func (l *Lane) GetRelativePosition(lane entity.ILane) int32 {
 }
 
 // 从候选集中选出与本车道最近的车道，要求候选集与本车道都在同一道路中
+// 左右两侧距离相同时选择ID最小的车道，结果与候选集的顺序无关
 func (l *Lane) GetClosestLane(candidates []entity.ILane) entity.ILane {
 	lanePos := map[entity.ILane]int{l: 0}
 	i := 0
@@ -609,7 +779,7 @@ func (l *Lane) GetClosestLane(candidates []entity.ILane) entity.ILane {
 	i = len(lanePos)
 	var minLane entity.ILane
 	for _, lane := range candidates {
-		if j := lanePos[lane]; j < i {
+		if j := lanePos[lane]; j < i || (j == i && lane.ID() < minLane.ID()) {
 			i = j
 			minLane = lane
 		}
@@ -623,7 +793,7 @@ func (l *Lane) ProjectFromLane(other entity.ILane, otherS float64) float64 {
 		log.Panic("project from lane in different road")
 		return 0
 	} else {
-		return lo.Clamp(otherS/other.Length()*l.length, 0, l.length)
+		return lo.Clamp(otherS/math.Max(other.Length(), minLaneLength)*l.length, 0, l.length)
 	}
 }
 
@@ -671,6 +841,10 @@ func (l *Lane) GetOffsetPositionByS(s, offset float64) (pos geometry.Point) {
 
 // 将xyz坐标投影到车道折线上，计算出对应的s坐标
 func (l *Lane) ProjectToLane(pos geometry.Point) float64 {
+	if l.lineLengths[len(l.lineLengths)-1] < minLaneLength {
+		// 零长度车道上的投影无法定义方向，统一投影到起点
+		return 0
+	}
 	s := geometry.GetClosestPolylineSToPoint2D(l.line, l.lineLengths, pos)
 	return lo.Clamp(s, 0, l.length)
 }
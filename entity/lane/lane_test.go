@@ -0,0 +1,264 @@
+package lane
+
+import (
+	"flag"
+	"math"
+	"testing"
+
+	"git.fiblab.net/general/common/v2/geometry"
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/clock"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
+)
+
+// testContext 只包含时钟、运行时配置、车道管理器与导航服务的测试用任务上下文
+type testContext struct {
+	clock         *clock.Clock
+	runtimeConfig *config.RuntimeConfig
+	laneManager   *LaneManager
+	router        *testRouter
+}
+
+func newTestContext(lanes []*mapv2.Lane) *testContext {
+	ctx := &testContext{
+		clock:         clock.New(config.ControlStep{Total: 100, Interval: 1}),
+		runtimeConfig: config.NewRuntimeConfig(config.Config{}),
+		router:        &testRouter{},
+	}
+	ctx.laneManager = NewManager(ctx)
+	ctx.laneManager.Init(lanes)
+	return ctx
+}
+
+func (ctx *testContext) Clock() *clock.Clock                      { return ctx.clock }
+func (ctx *testContext) LaneManager() entity.ILaneManager         { return ctx.laneManager }
+func (ctx *testContext) AoiManager() entity.IAoiManager           { return nil }
+func (ctx *testContext) RoadManager() entity.IRoadManager         { return nil }
+func (ctx *testContext) JunctionManager() entity.IJunctionManager { return nil }
+func (ctx *testContext) PersonManager() entity.IPersonManager     { return nil }
+func (ctx *testContext) RuntimeConfig() *config.RuntimeConfig     { return ctx.runtimeConfig }
+func (ctx *testContext) Router() entity.IRouter                   { return ctx.router }
+
+// testRouter 测试用导航服务，记录设置的道路额外代价
+type testRouter struct {
+	entity.IRouter
+	penalties []map[int32]float64
+}
+
+func (r *testRouter) SetRoadPenalties(penalties map[int32]float64) {
+	r.penalties = append(r.penalties, penalties)
+}
+
+// testRoad 测试用道路，仅实现ID
+type testRoad struct {
+	entity.IRoad
+	id int32
+}
+
+func (r *testRoad) ID() int32 { return r.id }
+
+// newTestLanePb 创建从(x, 0)出发、沿x轴的行车道
+func newTestLanePb(id int32, x, length float64, nodes int) *mapv2.Lane {
+	pb := &mapv2.Lane{
+		Id:         id,
+		Type:       mapv2.LaneType_LANE_TYPE_DRIVING,
+		MaxSpeed:   10,
+		Width:      3.2,
+		CenterLine: &geov2.Polyline{},
+	}
+	for i := 0; i < nodes; i++ {
+		pb.CenterLine.Nodes = append(pb.CenterLine.Nodes, &geov2.XYPosition{
+			X: x + length*float64(i)/math.Max(float64(nodes-1), 1),
+		})
+	}
+	return pb
+}
+
+// testPerson 测试用车辆，仅实现ID、速度、长度与影子车道
+type testPerson struct {
+	entity.IPerson
+	id int32
+	v  float64
+}
+
+func (p *testPerson) ID() int32                { return p.id }
+func (p *testPerson) V() float64               { return p.v }
+func (p *testPerson) Length() float64          { return 5 }
+func (p *testPerson) ShadowLane() entity.ILane { return nil }
+
+func connectTestLanes(from, to *mapv2.Lane) {
+	from.Successors = append(from.Successors, &mapv2.LaneConnection{Id: to.Id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD})
+	to.Predecessors = append(to.Predecessors, &mapv2.LaneConnection{Id: from.Id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL})
+}
+
+func TestZeroLengthLane(t *testing.T) {
+	// 零长度车道1 -> 车道2（100米）-> 只有一个点的车道3
+	l1, l2, l3 := newTestLanePb(1, 0, 0, 2), newTestLanePb(2, 0, 100, 2), newTestLanePb(3, 100, 0, 1)
+	connectTestLanes(l1, l2)
+	connectTestLanes(l2, l3)
+	ctx := newTestContext([]*mapv2.Lane{l1, l2, l3})
+	zero, normal, point := ctx.laneManager.Get(1), ctx.laneManager.Get(2), ctx.laneManager.Get(3)
+
+	// 零长度车道按最小长度处理，几何上仍为一个点
+	assert.Equal(t, minLaneLength, zero.Length())
+	assert.Equal(t, minLaneLength, point.Length())
+	assert.Equal(t, 100., normal.Length())
+	assert.Equal(t, geometry.Point{X: 0}, zero.GetPositionByS(zero.Length()))
+	assert.Equal(t, geometry.Point{X: 100}, point.GetPositionByS(0))
+	assert.NotPanics(t, func() { point.GetDirectionByS(0) })
+
+	// 投影结果有限
+	assert.Equal(t, 0., zero.ProjectToLane(geometry.Point{X: 50, Y: 10}))
+	assert.Equal(t, 0., point.ProjectToLane(geometry.Point{X: 50, Y: 10}))
+	assert.Equal(t, 0., normal.ProjectFromLane(zero, 0))
+	assert.Equal(t, 100., normal.ProjectFromLane(zero, zero.Length()))
+	assert.InDelta(t, minLaneLength/2, zero.ProjectFromLane(normal, 50), 1e-9)
+
+	// 前驱与后继均为零长度车道时压力有限
+	pressure := normal.GetPressure()
+	assert.False(t, math.IsNaN(pressure) || math.IsInf(pressure, 0))
+	assert.Equal(t, 0., pressure)
+}
+
+func TestMeanTimeGap(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, 0, 100, 2)})
+	l, m := ctx.laneManager.Get(1), ctx.laneManager
+	gap, err := m.GetLaneMeanTimeGap(1)
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(gap))
+	_, err = m.GetLaneMeanTimeGap(2)
+	assert.Error(t, err)
+
+	// 车头间距20米、车长5米、速度10米/秒的车队，车头时距为1.5秒；队尾停止的车辆不参与计算
+	l.AddVehicle(&entity.VehicleNode{S: 0, Value: &testPerson{v: 0}})
+	for _, s := range []float64{10, 30, 50, 70} {
+		l.AddVehicle(&entity.VehicleNode{S: s, Value: &testPerson{v: 10}})
+	}
+	m.Prepare()
+	gap, err = m.GetLaneMeanTimeGap(1)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.5, gap, 1e-9)
+
+	// 只有一辆车时无法计算
+	single := newTestContext([]*mapv2.Lane{newTestLanePb(1, 0, 100, 2)})
+	single.laneManager.Get(1).AddVehicle(&entity.VehicleNode{S: 10, Value: &testPerson{v: 10}})
+	single.laneManager.Prepare()
+	gap, err = single.laneManager.GetLaneMeanTimeGap(1)
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(gap))
+}
+
+func TestLaneInOutCounts(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, 0, 100, 2), newTestLanePb(2, 0, 100, 2)})
+	l1, l2, m := ctx.laneManager.Get(1), ctx.laneManager.Get(2), ctx.laneManager
+	counts := func(id int32) []int64 {
+		in, out, err := m.GetLaneInOutCounts(id, false)
+		assert.NoError(t, err)
+		return []int64{in, out}
+	}
+
+	// 添加在prepare生效前不计数
+	p := &testPerson{id: 1, v: 10}
+	node := &entity.VehicleNode{S: 10, Value: p}
+	l1.AddVehicle(node)
+	assert.Equal(t, []int64{0, 0}, counts(1))
+	m.Prepare()
+	assert.Equal(t, []int64{1, 0}, counts(1))
+
+	// 变道中的影子车辆不计数
+	shadow := &entity.VehicleNode{S: 10, Value: p}
+	l2.AddShadowVehicle(shadow)
+	m.Prepare()
+	assert.Equal(t, []int64{0, 0}, counts(2))
+	l2.RemoveShadowVehicle(shadow)
+	m.Prepare()
+	assert.Equal(t, []int64{0, 0}, counts(2))
+
+	// 车辆从车道1驶入车道2，重复prepare不重复计数
+	l1.RemoveVehicle(node)
+	l2.AddVehicle(&entity.VehicleNode{S: 0, Value: p})
+	m.Prepare()
+	m.Prepare()
+	assert.Equal(t, []int64{1, 1}, counts(1))
+	assert.Equal(t, []int64{1, 0}, counts(2))
+
+	in, out, err := m.GetLaneInOutCounts(1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 1}, []int64{in, out})
+	assert.Equal(t, []int64{0, 0}, counts(1))
+	_, _, err = m.GetLaneInOutCounts(3, false)
+	assert.Error(t, err)
+}
+
+func TestJamEvents(t *testing.T) {
+	defer flag.Set("lane.max_jam_events", "0")
+	flag.Set("lane.max_jam_events", "100")
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, 0, 100, 2)})
+	l, m := ctx.laneManager.Get(1), ctx.laneManager
+	// 红灯前排队停止的3辆车，另有1辆车正在驶近
+	l.SetLight(mapv2.LightState_LIGHT_STATE_RED, 90, 90)
+	queue := []*testPerson{{v: 0}, {v: 0}, {v: 0}, {v: 8}}
+	for i, p := range queue {
+		l.AddVehicle(&entity.VehicleNode{S: 95 - 10*float64(i), Value: p})
+	}
+	m.Prepare()
+	step := func(from, to int) (events []JamEvent) {
+		for i := from; i < to; i++ {
+			ctx.clock.T = float64(i)
+			m.Update()
+			events = append(events, m.GetJamEvents()...)
+		}
+		return
+	}
+
+	// 停止车辆占比0.75持续超过60秒后产生一次拥堵事件
+	assert.Empty(t, step(0, 60))
+	events := step(60, 90)
+	assert.Equal(t, []JamEvent{{T: 60, LaneID: 1, Type: JamEventStart, StoppedFraction: 0.75}}, events)
+
+	// 绿灯后队列消散，停止车辆占比回落到阈值以下时拥堵解除
+	l.SetLight(mapv2.LightState_LIGHT_STATE_GREEN, 30, 30)
+	queue[0].v, queue[1].v = 5, 3
+	events = step(90, 100)
+	assert.Equal(t, []JamEvent{{T: 90, LaneID: 1, Type: JamEventEnd, StoppedFraction: 0.25}}, events)
+	assert.Empty(t, step(100, 200))
+}
+
+func TestIncidentRoutePenalty(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, 0, 100, 2), newTestLanePb(2, 0, 100, 2)})
+	m := ctx.laneManager
+	road := &testRoad{id: 7}
+	for i, l := range m.lanes {
+		l.SetParentRoadWhenInit(road, i)
+	}
+	m.Prepare()
+	assert.Empty(t, ctx.router.penalties)
+
+	// 两条行车道之一有临时事件，道路代价增加一半的惩罚
+	assert.NoError(t, m.CreateIncident(1, 50, .8, 30))
+	m.Prepare()
+	assert.Equal(t, []map[int32]float64{{7: *incidentRoutePenalty / 2}}, ctx.router.penalties)
+	m.Prepare()
+	assert.Len(t, ctx.router.penalties, 1)
+
+	// 事件解除后恢复道路代价
+	ctx.clock.T = 30
+	m.Prepare()
+	assert.Equal(t, map[int32]float64{}, ctx.router.penalties[1])
+}
+
+func TestGetClosestLaneTieBreak(t *testing.T) {
+	// 车道2左侧为车道3、右侧为车道1，两者与车道2的距离相同
+	l1, l2, l3 := newTestLanePb(1, 0, 100, 2), newTestLanePb(2, 0, 100, 2), newTestLanePb(3, 0, 100, 2)
+	l2.LeftLaneIds, l2.RightLaneIds = []int32{3}, []int32{1}
+	ctx := newTestContext([]*mapv2.Lane{l1, l2, l3})
+	m := ctx.laneManager
+	cur := m.Get(2)
+	// 无论候选集的顺序如何，总是选择ID最小的车道
+	assert.Equal(t, m.Get(1), cur.GetClosestLane([]entity.ILane{m.Get(3), m.Get(1)}))
+	assert.Equal(t, m.Get(1), cur.GetClosestLane([]entity.ILane{m.Get(1), m.Get(3)}))
+	assert.Equal(t, cur, cur.GetClosestLane([]entity.ILane{m.Get(3), cur, m.Get(1)}))
+}
@@ -2,6 +2,7 @@ package lane
 
 import (
 	"fmt"
+	"sync"
 
 	"git.fiblab.net/general/common/v2/parallel"
 	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
@@ -19,6 +20,11 @@ type LaneManager struct {
 
 	data  map[int32]*Lane
 	lanes []*Lane
+
+	classesChanged bool // 车道允许通行的车辆类别是否变化，需要在prepare后同步给导航服务
+
+	jamEvents    []JamEvent // 自上次读取以来的车道拥堵事件（lane.max_jam_events）
+	jamEventsMtx sync.Mutex
 }
 
 // NewManager 创建Lane管理器实例
@@ -45,6 +51,7 @@ func (m *LaneManager) Init(pbs []*mapv2.Lane) {
 		return l.id, l
 	})
 	parallel.GoFor(m.lanes, func(l *Lane) { l.initWithManager(m) })
+	m.classesChanged = len(m.ctx.RuntimeConfig().C.LaneAllowedClasses) > 0
 }
 
 // Get 根据ID获取Lane实例
@@ -72,12 +79,90 @@ func (m *LaneManager) GetOrError(id int32) (entity.ILane, error) {
 	}
 }
 
+// MeanSpeed 获取地图的平均车速
+// 功能：计算所有行车道当前限速的平均值，用于导航失败时估计直线瞬移的用时等粗略估计
+// 返回：平均车速（米/秒），没有行车道时为0
+func (m *LaneManager) MeanSpeed() float64 {
+	sum, n := 0., 0
+	for _, l := range m.lanes {
+		if l.Type() == mapv2.LaneType_LANE_TYPE_DRIVING {
+			sum += l.MaxV()
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
 // Prepare 准备阶段，处理所有Lane的准备工作
 // 功能：对所有Lane执行准备阶段，处理车辆/行人列表的缓冲区操作
 // 说明：使用并行处理提高性能，分两个阶段：prepare和prepare2
 func (m *LaneManager) Prepare() {
 	parallel.GoFor(m.lanes, func(l *Lane) { l.prepare() })
 	parallel.GoFor(m.lanes, func(l *Lane) { l.prepare2() })
+	if m.classesChanged {
+		m.classesChanged = false
+		m.ctx.Router().SetRoadAllowedClasses(m.RoadAllowedClasses())
+	}
+	if lo.ContainsBy(m.lanes, func(l *Lane) bool { return l.incidentChanged }) {
+		m.ctx.Router().SetRoadPenalties(m.RoadIncidentPenalties())
+	}
+}
+
+// RoadIncidentPenalties 统计各道路因临时事件增加的导航时间代价
+// 功能：有临时事件的行车道计lane.incident_route_penalty，道路取其所有行车道的平均值
+// 返回：道路ID->时间代价（秒），只包含有临时事件的道路
+func (m *LaneManager) RoadIncidentPenalties() map[int32]float64 {
+	lanes := make(map[int32]int)
+	incidents := make(map[int32]int)
+	for _, l := range m.lanes {
+		if l.typ != mapv2.LaneType_LANE_TYPE_DRIVING || l.parentRoad == nil {
+			continue
+		}
+		roadID := l.parentRoad.ID()
+		lanes[roadID]++
+		if l.incident != nil {
+			incidents[roadID]++
+		}
+	}
+	res := make(map[int32]float64, len(incidents))
+	for roadID, n := range incidents {
+		res[roadID] = *incidentRoutePenalty * float64(n) / float64(lanes[roadID])
+	}
+	return res
+}
+
+// RoadAllowedClasses 统计各道路允许通行的车辆类别
+// 功能：道路允许通行的车辆类别为其所有行车道允许类别的并集，任一行车道不限制时道路不限制
+// 返回：道路ID->允许通行的车辆类别，只包含有限制的道路
+func (m *LaneManager) RoadAllowedClasses() map[int32][]string {
+	classes := make(map[int32]map[string]bool)
+	unrestricted := make(map[int32]bool)
+	for _, l := range m.lanes {
+		if l.typ != mapv2.LaneType_LANE_TYPE_DRIVING || l.parentRoad == nil {
+			continue
+		}
+		roadID := l.parentRoad.ID()
+		if l.allowedClasses == nil {
+			unrestricted[roadID] = true
+			continue
+		}
+		if classes[roadID] == nil {
+			classes[roadID] = make(map[string]bool)
+		}
+		for c := range l.allowedClasses {
+			classes[roadID][c] = true
+		}
+	}
+	res := make(map[int32][]string, len(classes))
+	for roadID, cs := range classes {
+		if !unrestricted[roadID] {
+			res[roadID] = lo.Keys(cs)
+		}
+	}
+	return res
 }
 
 // Update 更新阶段，执行所有Lane的模拟逻辑
@@ -85,4 +170,5 @@ func (m *LaneManager) Prepare() {
 // 说明：使用并行处理提高性能
 func (m *LaneManager) Update() {
 	parallel.GoFor(m.lanes, func(l *Lane) { l.update() })
+	m.collectJamEvents()
 }
@@ -0,0 +1,112 @@
+// Package lane 车道的管理与模拟。
+//
+// mapv2中暂无对应请求消息（或缺少所需字段）的功能以管理器上的导出方法形式提供，
+// 由外部服务直接调用，不经过RPC。
+package lane
+
+import (
+	"errors"
+	"flag"
+	"math"
+
+	"connectrpc.com/connect"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+const (
+	incidentMinV = 1 // 临时事件范围内的最低限速（米/秒）
+)
+
+var (
+	incidentRoutePenalty = flag.Float64("lane.incident_route_penalty", 300, "有临时事件的行车道在导航中增加的时间代价（秒），道路的代价增加其各行车道的平均值")
+)
+
+// GetLaneInOutCounts 获取指定Lane的驶入、驶离车辆数
+// 功能：返回自上次重置以来驶入与驶离车道的车辆数，用于OD校验
+// 参数：id-Lane ID，reset-是否在读取后清零计数
+// 返回：驶入车辆数、驶离车辆数，Lane不存在时返回错误
+func (m *LaneManager) GetLaneInOutCounts(id int32, reset bool) (in, out int64, err error) {
+	l, ok := m.data[id]
+	if !ok {
+		return 0, 0, connect.NewError(connect.CodeInvalidArgument, errors.New("lane id does not exist"))
+	}
+	if reset {
+		in, out = l.ResetInOutCounts()
+	} else {
+		in, out = l.InOutCounts()
+	}
+	return in, out, nil
+}
+
+// GetLaneMeanTimeGap 获取指定Lane上相邻车辆的平均车头时距
+// 功能：返回车道上运动车辆与前车的净间距除以本车速度的平均值，用于跟驰模型标定
+// 参数：id-Lane ID
+// 返回：平均车头时距（秒，没有可计算的车辆对时为NaN），Lane不存在时返回错误
+func (m *LaneManager) GetLaneMeanTimeGap(id int32) (float64, error) {
+	l, ok := m.data[id]
+	if !ok {
+		return 0, connect.NewError(connect.CodeInvalidArgument, errors.New("lane id does not exist"))
+	}
+	return l.MeanTimeGap(), nil
+}
+
+// SetLaneStopSign 设置指定Lane末端的停车让行标志
+// 功能：将车道标记为停车让行控制的进口道，车辆须在车道末端完全停车、确认路口内冲突车道无来车后再驶入路口
+// 参数：id-Lane ID，stopSign-是否设置停车让行标志
+// 返回：Lane不存在或不是驶入路口的道路行车道时返回错误
+// 说明：设置在下一次prepare后生效
+func (m *LaneManager) SetLaneStopSign(id int32, stopSign bool) error {
+	l, ok := m.data[id]
+	if !ok {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("lane id does not exist"))
+	}
+	if l.Type() != mapv2.LaneType_LANE_TYPE_DRIVING || l.InJunction() {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("stop sign can only be set on road driving lanes"))
+	}
+	l.SetStopSign(stopSign)
+	return nil
+}
+
+// SetLaneAllowedClasses 设置指定Lane允许通行的车辆类别
+// 功能：限制车道只允许指定类别的车辆通行（如公交专用道、禁止货车），导航会避开不允许本车类别通行的道路，车辆也不会主动变道进入该车道
+// 参数：id-Lane ID，classes-允许通行的车辆类别（car/truck/bus），为空时取消限制
+// 返回：Lane不存在或不是行车道时返回错误
+// 说明：设置在下一次prepare后生效
+func (m *LaneManager) SetLaneAllowedClasses(id int32, classes []string) error {
+	l, ok := m.data[id]
+	if !ok {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("lane id does not exist"))
+	}
+	if l.Type() != mapv2.LaneType_LANE_TYPE_DRIVING {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("allowed classes can only be set on driving lanes"))
+	}
+	l.SetAllowedClasses(classes)
+	m.classesChanged = true
+	return nil
+}
+
+// CreateIncident 在指定Lane上创建临时事件
+// 功能：模拟交通事故等临时事件，事件点前后一定范围内限速降为原限速的(1-severity)倍（不低于incidentMinV），
+// 车辆不会主动变道进入有事件的车道，事件期间所在道路的导航代价增加（lane.incident_route_penalty），事件在持续时间结束后自动解除
+// 参数：id-Lane ID，s-事件点位置，severity-严重程度（0~1），duration-持续时间（秒）
+// 返回：Lane不存在、不是行车道或参数不合法时返回错误
+// 说明：事件在下一次prepare后生效，每条车道同时只有一个事件
+func (m *LaneManager) CreateIncident(id int32, s, severity, duration float64) error {
+	l, ok := m.data[id]
+	if !ok {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("lane id does not exist"))
+	}
+	if l.Type() != mapv2.LaneType_LANE_TYPE_DRIVING {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("incident can only be created on driving lanes"))
+	}
+	if s < 0 || s > l.Length() || severity <= 0 || severity > 1 || duration <= 0 {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("invalid incident position, severity or duration"))
+	}
+	l.SetIncident(&entity.Incident{
+		S:    s,
+		MaxV: math.Max(l.maxVBuffer*(1-severity), incidentMinV),
+		EndT: m.ctx.Clock().T + duration,
+	})
+	return nil
+}
@@ -1,15 +1,23 @@
 package lane
 
 import (
+	"cmp"
+	"slices"
 	"sync"
 
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/container"
 )
 
+// laneListItem 车道列表元素需要满足的接口
+type laneListItem interface {
+	container.IHasVAndLength
+	ID() int32
+}
+
 // laneList 车道列表数据结构，用于管理车道上的车辆或行人
 // 功能：提供线程安全的车辆/行人列表管理，支持缓冲式添加和删除操作
 // 泛型参数：T-列表元素类型（必须实现IHasVAndLength接口），E-侧链数据类型
-type laneList[T container.IHasVAndLength, E any] struct {
+type laneList[T laneListItem, E any] struct {
 	list              *container.List[T, E]
 	addBuffer         []*container.ListNode[T, E]
 	addBufferMutex    sync.Mutex
@@ -21,7 +29,7 @@ type laneList[T container.IHasVAndLength, E any] struct {
 // 功能：初始化车道列表，设置基础数据结构和互斥锁
 // 参数：id-列表标识符，用于调试和日志
 // 返回：初始化完成的车道列表实例
-func newLaneList[T container.IHasVAndLength, E any](id string) laneList[T, E] {
+func newLaneList[T laneListItem, E any](id string) laneList[T, E] {
 	return laneList[T, E]{
 		list: &container.List[T, E]{
 			ID: id,
@@ -35,18 +43,25 @@ func newLaneList[T container.IHasVAndLength, E any](id string) laneList[T, E] {
 
 // prepare 准备阶段，处理缓冲区的添加和删除操作
 // 功能：将缓冲区中的操作应用到主列表，清空缓冲区
-// 说明：已处理为nil的情况，使用缓冲机制提高并发性能
-func (l *laneList[T, E]) prepare() {
+// 返回：本次实际加入与移除的节点数
+// 说明：已处理为nil的情况，使用缓冲机制提高并发性能；
+// 添加缓冲区的写入顺序取决于并行调度，合并前按ID排序，保证S相同的节点插入顺序固定
+func (l *laneList[T, E]) prepare() (added, removed int) {
 	if l == nil || l.list == nil {
-		return
+		return 0, 0
 	}
 	for _, v := range l.removeBuffer {
 		l.list.Remove(v)
 	}
+	slices.SortFunc(l.addBuffer, func(a, b *container.ListNode[T, E]) int {
+		return cmp.Compare(a.Value.ID(), b.Value.ID())
+	})
 	unsorted := l.list.PopUnsorted()
 	l.list.Merge(append(l.addBuffer, unsorted...))
+	added, removed = len(l.addBuffer), len(l.removeBuffer)
 	l.removeBuffer = l.removeBuffer[:0]
 	l.addBuffer = l.addBuffer[:0]
+	return added, removed
 }
 
 // add 添加节点到缓冲区
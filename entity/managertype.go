@@ -16,6 +16,8 @@ type ILaneManager interface {
 	Get(id int32) ILane
 	// 输入Lane ID，查找Lane，如果不存在则返回error
 	GetOrError(id int32) (ILane, error)
+	// 所有行车道限速的平均值（米/秒），没有行车道时为0
+	MeanSpeed() float64
 
 	Prepare() // 准备阶段
 	Update()  // 更新阶段
@@ -33,6 +35,7 @@ type IAoiManager interface {
 	// 输入Aoi ID，查找Aoi，如果不存在则返回error
 	GetOrError(id int32) (IAoi, error)
 
+	PrepareNode()      // 准备阶段：加入运行时添加的AOI
 	Prepare()          // 准备阶段
 	Update(dt float64) // 更新阶段
 }
@@ -46,6 +49,8 @@ type IRoadManager interface {
 	Get(id int32) IRoad
 	// 输入Road ID，查找Road，如果不存在则返回error
 	GetOrError(id int32) (IRoad, error)
+
+	Update() // 更新阶段，按配置将道路的观测通行时间写入导航服务
 }
 
 // entity/junction/manager.go的依赖倒置
@@ -58,6 +63,9 @@ type IJunctionManager interface {
 	// 输入Junction ID，查找Junction，如果不存在则返回error
 	GetOrError(id int32) (IJunction, error)
 
+	// 以固定信控程序覆盖指定路口的信控（路口ID->信控程序），在初始化后、模拟开始前调用
+	SetTrafficLightPrograms(programs map[int32]*mapv2.TrafficLight) error
+
 	Prepare()          // 准备阶段
 	Update(dt float64) // 更新阶段                                         // 产生所有Junction的simple输出
 }
@@ -82,4 +90,7 @@ type IPersonManager interface {
 	PrepareNode()      // 准备阶段：链表节点更新
 	Prepare()          // 准备阶段：snapshot更新
 	Update(dt float64) // 更新阶段
+	Close()            // 结束仿真时关闭输出文件
+
+	ActivePersons() int32 // 仍有出行任务的人数
 }
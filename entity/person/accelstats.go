@@ -0,0 +1,61 @@
+package person
+
+import (
+	"errors"
+	"flag"
+	"math"
+
+	"connectrpc.com/connect"
+	"git.fiblab.net/general/common/v2/parallel"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+)
+
+var (
+	accelHistogram = flag.Bool("stats.accel_histogram", false, "是否在每个输出步收集所有驾车的人的加速度，用于GetAccelerationHistogram")
+	accelRangeMin  = flag.Float64("stats.accel_range_min", -6, "加速度直方图的下界（米/秒²），小于下界的值计入第一个区间")
+	accelRangeMax  = flag.Float64("stats.accel_range_max", 4, "加速度直方图的上界（米/秒²），大于上界的值计入最后一个区间")
+)
+
+// AccelerationHistogram 加速度分布直方图
+type AccelerationHistogram struct {
+	Min, Max float64 // 直方图范围（米/秒²），等分为len(Counts)个区间
+	Counts   []int32 // 各区间的车辆数
+}
+
+// collectAccelerations 收集驾车的人在上一步的加速度
+// 功能：并行读取快照中的车辆行为，作为最近一个输出步的加速度样本
+func (m *PersonManager) collectAccelerations() {
+	m.accelerations = parallel.GoMapFilter(m.persons.Data(), func(p *Person) (float64, bool) {
+		if p.snapshot.Status != personv2.Status_STATUS_DRIVING {
+			return 0, false
+		}
+		a := p.snapshot.Action.A
+		// 过滤异常值
+		return a, !math.IsInf(a, 0) && !math.IsNaN(a)
+	})
+}
+
+// GetAccelerationHistogram 获取最近一个输出步所有驾车的人的加速度分布
+// 功能：将加速度样本按stats.accel_range_min/max等分为bins个区间计数，用于跟驰模型的验证
+// 参数：bins-区间数，必须为正数
+// 返回：加速度直方图，错误信息
+// 说明：需要开启stats.accel_histogram，样本在每个输出步（output.interval_steps）更新
+func (m *PersonManager) GetAccelerationHistogram(bins int) (AccelerationHistogram, error) {
+	if !*accelHistogram {
+		return AccelerationHistogram{}, connect.NewError(connect.CodeFailedPrecondition, errors.New("acceleration histogram is disabled"))
+	}
+	if bins <= 0 {
+		return AccelerationHistogram{}, connect.NewError(connect.CodeInvalidArgument, errors.New("bins must be positive"))
+	}
+	lo, hi := *accelRangeMin, *accelRangeMax
+	if hi <= lo {
+		return AccelerationHistogram{}, connect.NewError(connect.CodeFailedPrecondition, errors.New("bad acceleration histogram range"))
+	}
+	h := AccelerationHistogram{Min: lo, Max: hi, Counts: make([]int32, bins)}
+	width := (hi - lo) / float64(bins)
+	for _, a := range m.accelerations {
+		i := int(math.Floor((a - lo) / width))
+		h.Counts[max(0, min(bins-1, i))]++
+	}
+	return h, nil
+}
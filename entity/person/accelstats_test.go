@@ -0,0 +1,49 @@
+package person
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccelerationHistogram(t *testing.T) {
+	defer flag.Set("stats.accel_histogram", "false")
+	defer flag.Set("sim.disable_noise", "false")
+	flag.Set("sim.disable_noise", "true")
+	ctx, m := newTestTrafficScene(2, 5)
+	_, err := m.GetAccelerationHistogram(10)
+	assert.Error(t, err)
+	flag.Set("stats.accel_histogram", "true")
+	_, err = m.GetAccelerationHistogram(0)
+	assert.Error(t, err)
+
+	// 所有车辆从静止起步
+	for _, p := range m.persons.Data() {
+		p.runtime.V, p.snapshot.V = 0, 0
+	}
+	for i := 0; i < 3; i++ {
+		m.PrepareNode()
+		ctx.laneManager.Prepare()
+		m.Prepare()
+		m.Update(1)
+	}
+	m.PrepareNode()
+	ctx.laneManager.Prepare()
+	m.Prepare()
+	// 默认范围[-6, 4]，每个区间1米/秒²，后4个区间为正加速度
+	h, err := m.GetAccelerationHistogram(10)
+	assert.NoError(t, err)
+	assert.Equal(t, -6., h.Min)
+	assert.Equal(t, 4., h.Max)
+	assert.Len(t, h.Counts, 10)
+	total, positive := int32(0), int32(0)
+	for i, c := range h.Counts {
+		total += c
+		if i >= 6 {
+			positive += c
+		}
+	}
+	assert.Equal(t, int32(10), total)
+	assert.Equal(t, total, positive)
+}
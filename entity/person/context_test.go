@@ -0,0 +1,301 @@
+package person
+
+import (
+	"sync"
+
+	"git.fiblab.net/general/common/v2/geometry"
+	"git.fiblab.net/general/common/v2/protoutil"
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
+	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
+	"github.com/samber/lo"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/clock"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/aoi"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/lane"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/person/route"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/person/schedule"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/road"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/container"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
+)
+
+// testContext 测试用的最小任务上下文，包含时钟、车道与AOI管理器以及记录请求的导航服务
+type testContext struct {
+	clock         *clock.Clock
+	laneManager   *lane.LaneManager
+	aoiManager    *aoi.AoiManager
+	roadManager   entity.IRoadManager // 需要道路的测试自行设置
+	personManager *PersonManager
+	runtimeConfig *config.RuntimeConfig
+	router        *testRouter
+}
+
+func newTestContext(lanes []*mapv2.Lane, aois []*mapv2.Aoi) *testContext {
+	ctx := &testContext{
+		clock:         clock.New(config.ControlStep{Total: 100, Interval: 1}),
+		runtimeConfig: config.NewRuntimeConfig(config.Config{}),
+		router:        &testRouter{},
+	}
+	ctx.laneManager = lane.NewManager(ctx)
+	ctx.laneManager.Init(lanes)
+	ctx.aoiManager = aoi.NewManager(ctx)
+	ctx.aoiManager.Init(aois, ctx.laneManager)
+	return ctx
+}
+
+func (ctx *testContext) Clock() *clock.Clock                      { return ctx.clock }
+func (ctx *testContext) LaneManager() entity.ILaneManager         { return ctx.laneManager }
+func (ctx *testContext) AoiManager() entity.IAoiManager           { return ctx.aoiManager }
+func (ctx *testContext) RoadManager() entity.IRoadManager         { return ctx.roadManager }
+func (ctx *testContext) JunctionManager() entity.IJunctionManager { return nil }
+func (ctx *testContext) PersonManager() entity.IPersonManager     { return ctx.personManager }
+func (ctx *testContext) RuntimeConfig() *config.RuntimeConfig     { return ctx.runtimeConfig }
+func (ctx *testContext) Router() entity.IRouter                   { return ctx.router }
+
+// testRouter 记录所有请求的导航服务
+// 步行请求返回walking（未设置时为空结果），其余请求总是返回空结果（导航失败）
+type testRouter struct {
+	mtx      sync.Mutex
+	requests []*routingv2.GetRouteRequest
+	walking  *routingv2.GetRouteResponse
+	aois     []*mapv2.Aoi // 运行时添加的AOI
+}
+
+func (r *testRouter) GetRoute(in *routingv2.GetRouteRequest, process func(res *routingv2.GetRouteResponse)) chan struct{} {
+	res := r.GetRouteSync(in)
+	process(res)
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+func (r *testRouter) GetRouteWithOptions(in *routingv2.GetRouteRequest, opts entity.RouteOptions, process func(res *routingv2.GetRouteResponse)) chan struct{} {
+	return r.GetRoute(in, process)
+}
+
+func (r *testRouter) GetRouteSync(in *routingv2.GetRouteRequest) *routingv2.GetRouteResponse {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.requests = append(r.requests, in)
+	if in.Type == routingv2.RouteType_ROUTE_TYPE_WALKING && r.walking != nil {
+		return protoutil.Clone(r.walking)
+	}
+	return &routingv2.GetRouteResponse{}
+}
+
+func (r *testRouter) GetRoutesBatch(reqs []*routingv2.GetRouteRequest) []*routingv2.GetRouteResponse {
+	res := make([]*routingv2.GetRouteResponse, len(reqs))
+	for i, req := range reqs {
+		res[i] = r.GetRouteSync(req)
+	}
+	return res
+}
+
+func (r *testRouter) SetRoadCost(roadID int32, cost float64, t *float64) error {
+	return nil
+}
+
+func (r *testRouter) SetRoadCosts(costs map[int32]float64, t *float64) error {
+	return nil
+}
+
+func (r *testRouter) SetRoadPenalties(penalties map[int32]float64)     {}
+func (r *testRouter) SetRoadAllowedClasses(classes map[int32][]string) {}
+
+func (r *testRouter) AddAois(aois []*mapv2.Aoi) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.aois = append(r.aois, aois...)
+}
+
+// newTestLanePb 创建沿x轴方向的直线车道
+func newTestLanePb(id int32, typ mapv2.LaneType, length float64) *mapv2.Lane {
+	return &mapv2.Lane{
+		Id:       id,
+		Type:     typ,
+		MaxSpeed: 10,
+		Width:    3.2,
+		CenterLine: &geov2.Polyline{Nodes: []*geov2.XYPosition{
+			{X: 0, Y: 0},
+			{X: length, Y: 0},
+		}},
+	}
+}
+
+// newTestAoiPb 创建以(x, 10)为中心、连接到给定行车道s位置的方形AOI
+func newTestAoiPb(id int32, laneID int32, s float64) *mapv2.Aoi {
+	return &mapv2.Aoi{
+		Id: id,
+		Positions: []*geov2.XYPosition{
+			{X: s - 5, Y: 5}, {X: s + 5, Y: 5}, {X: s + 5, Y: 15}, {X: s - 5, Y: 15}, {X: s - 5, Y: 5},
+		},
+		DrivingPositions: []*geov2.LanePosition{{LaneId: laneID, S: s}},
+	}
+}
+
+// newTestDrivingPerson 创建位于车道上行驶中的人，并将车辆节点加入车道
+func newTestDrivingPerson(ctx *testContext, id int32, l entity.ILane, s float64) *Person {
+	p := newTestPerson(id, s, 0)
+	p.ctx = ctx
+	p.vehicleAttr = &personv2.VehicleAttribute{Length: 5}
+	p.vehicle = &vehicle{length: 5}
+	p.pedestrian = &pedestrian{}
+	p.multiModalRoute = route.NewMultiModalRoute(ctx, p)
+	p.schedule = schedule.NewSchedule(ctx, nil)
+	p.runtime = runtime{
+		Status: personv2.Status_STATUS_DRIVING,
+		XYZ:    l.GetPositionByS(s),
+		Lane:   l,
+		S:      s,
+	}
+	p.vehicle.node = newVehicleNode(s, p)
+	l.AddVehicle(p.vehicle.node)
+	p.snapshot = p.runtime
+	return p
+}
+
+// newTestSleepingPerson 创建位于AOI内处于睡眠状态的人
+func newTestSleepingPerson(ctx *testContext, id int32, a entity.IAoi) *Person {
+	p := newTestPerson(id, a.Centroid().X, a.Centroid().Y)
+	p.ctx = ctx
+	p.vehicleAttr = &personv2.VehicleAttribute{Length: 5}
+	p.vehicle = &vehicle{length: 5}
+	p.pedestrian = &pedestrian{}
+	p.multiModalRoute = route.NewMultiModalRoute(ctx, p)
+	p.schedule = schedule.NewSchedule(ctx, nil)
+	p.runtime = runtime{
+		Status:    personv2.Status_STATUS_SLEEP,
+		IsTripEnd: true,
+		XYZ:       a.Centroid(),
+		Aoi:       a,
+	}
+	a.AddPerson(p)
+	p.snapshot = p.runtime
+	return p
+}
+
+func newTestManager(persons ...*Person) *PersonManager {
+	m := &PersonManager{
+		data:    make(map[int32]*Person),
+		persons: container.NewIncrementalArray[*Person](),
+	}
+	for _, p := range persons {
+		p.m = m
+		m.data[p.id] = p
+		m.persons.Add(p)
+	}
+	m.persons.Prepare()
+	return m
+}
+
+func newTestPerson(id int32, x, y float64) *Person {
+	return &Person{id: id, snapshot: runtime{XYZ: geometry.Point{X: x, Y: y}}}
+}
+
+func ids(persons []*Person) []int32 {
+	return lo.Map(persons, func(p *Person, _ int) int32 { return p.ID() })
+}
+
+// newTestController 创建位于车道上的车辆的控制器
+func newTestController(ctx *testContext, l entity.ILane, s float64) *controller {
+	p := newTestDrivingPerson(ctx, 1, l, s)
+	p.vehicleAttr = &personv2.VehicleAttribute{
+		Length:                           5,
+		MaxSpeed:                         30,
+		MaxAcceleration:                  3,
+		UsualBrakingAcceleration:         -4.5,
+		MaxBrakingAcceleration:           -10,
+		MinGap:                           1,
+		Headway:                          1.5,
+		LaneMaxSpeedRecognitionDeviation: 1,
+	}
+	p.generator = randengine.New(1)
+	c := newController(p)
+	c.dt = 1
+	return c
+}
+
+// newTestTrafficScene 创建多条单车道道路、每条道路上有多辆车的场景
+func newTestTrafficScene(roadCount, vehiclesPerRoad int) (*testContext, *PersonManager) {
+	lanes := make([]*mapv2.Lane, roadCount)
+	roads := make([]*mapv2.Road, roadCount)
+	for i := range lanes {
+		id := int32(i + 1)
+		lanes[i] = newTestLanePb(id, mapv2.LaneType_LANE_TYPE_DRIVING, 10000)
+		lanes[i].ParentId = id
+		roads[i] = &mapv2.Road{Id: id, LaneIds: []int32{id}}
+	}
+	ctx := newTestContext(lanes, nil)
+	rm := road.NewManager(ctx)
+	rm.Init(roads, ctx.laneManager)
+	persons := make([]*Person, 0, roadCount*vehiclesPerRoad)
+	for i := 0; i < roadCount; i++ {
+		l := ctx.laneManager.Get(int32(i + 1))
+		for j := 0; j < vehiclesPerRoad; j++ {
+			id := int32(i*vehiclesPerRoad + j + 1)
+			c := newTestController(ctx, l, float64(j)*20)
+			p := c.self
+			p.id = id
+			p.generator = randengine.New(uint64(id))
+			p.vehicle.controller = c
+			p.runtime.V, p.snapshot.V = 10, 10
+			p.multiModalRoute.VehicleRoute.AtRoad = true
+			p.multiModalRoute.VehicleRoute.Roads = []entity.IRoad{rm.Get(int32(i + 1))}
+			p.multiModalRoute.VehicleRoute.End = entity.RoutePosition{Lane: l, S: 9000}
+			persons = append(persons, p)
+		}
+	}
+	m := newTestManager(persons...)
+	m.ctx = ctx
+	return ctx, m
+}
+
+// runTestTrafficScene 运行场景，返回每个人最终的位置
+func runTestTrafficScene(roadCount, vehiclesPerRoad, steps int) map[int32]float64 {
+	ctx, m := newTestTrafficScene(roadCount, vehiclesPerRoad)
+	for i := 0; i < steps; i++ {
+		m.PrepareNode()
+		ctx.laneManager.Prepare()
+		m.Prepare()
+		m.Update(1)
+	}
+	return lo.SliceToMap(m.persons.Data(), func(p *Person) (int32, float64) {
+		return p.id, p.runtime.S
+	})
+}
+
+// newTestWalkingScene 创建AOI 10与AOI 20之间步行出行的场景
+func newTestWalkingScene() (*testContext, *PersonManager, *Person) {
+	aois := []*mapv2.Aoi{newTestAoiPb(10, 1, 30), newTestAoiPb(20, 1, 80)}
+	for _, a := range aois {
+		a.WalkingPositions = []*geov2.LanePosition{{LaneId: 2, S: a.DrivingPositions[0].S}}
+	}
+	ctx := newTestContext([]*mapv2.Lane{
+		newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+		newTestLanePb(2, mapv2.LaneType_LANE_TYPE_WALKING, 100),
+	}, aois)
+	ctx.router.walking = &routingv2.GetRouteResponse{Journeys: []*routingv2.Journey{{
+		Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
+		Walking: &routingv2.WalkingJourneyBody{
+			Route: []*routingv2.WalkingRouteSegment{{LaneId: 2, MovingDirection: routingv2.MovingDirection_MOVING_DIRECTION_FORWARD}},
+			Eta:   50,
+		},
+	}}}
+	p := newTestSleepingPerson(ctx, 1, ctx.aoiManager.Get(10))
+	p.pedestrian.walkingV = 10
+	p.schedule.Set([]*tripv2.Schedule{{
+		Trips: []*tripv2.Trip{{
+			Mode: tripv2.TripMode_TRIP_MODE_WALK_ONLY,
+			End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 20}},
+		}},
+		LoopCount: 1,
+	}}, 0)
+	m := newTestManager(p)
+	m.ctx = ctx
+	ctx.personManager = m
+	return ctx, m, p
+}
@@ -13,13 +13,9 @@ import (
 )
 
 const (
-	idmTheta           = 4   // IDM模型参数（智能驾驶模型参数）
 	platoonMaxDistance = 10  // 编队判定距离（间距小于该值表示完成编队，形成编队的后车将无视信控与车道限速）
 	laneMaxVBiasStd    = 0.1 // 车道限速偏差比例的标准差
 
-	// https://jtgl.beijing.gov.cn/jgj/94220/aqcs/139634/index.html
-	viewDistanceFactor   = 12 // 在一般情况下，观察距离应等于汽车在12秒内所通过的路程。如果车速为每小时60公里，则观察距离应为200米。
-	minViewDistance      = 50 // 最小观察距离（米）
 	behindViewDistance   = 3  // 后方观察距离（米）
 	decelerationDuration = 20 // 停车提前开始的时间（秒）
 
@@ -42,17 +38,23 @@ type controller struct {
 	maxBrakingA   float64            // 最大制动加速度
 	maxA          float64            // 最大加速度
 	maxV          float64            // 最大速度
+	speedCap      float64            // 外部设置的速度上限（未设置时为无穷大）
 	laneMaxVRatio float64            // 本车对车道限速认知的偏差百分比，正态分布N(1,0.1)，例如车道限速为50，偏差为10%，则本车认为车道限速为55，限制不超过20%
 	length        float64            // 车辆长度
 	minGap        float64            // 最小车距
 	lcLength      float64            // 变道长度
-	headway       float64            // 安全车头时距
+	headway       float64            // 安全车头时距（本步使用，编队跟车时减小）
+	baseHeadway   float64            // 车辆自身的安全车头时距（IDM期望车头时距）
+	idmDelta      float64            // IDM加速度指数
+	idmComfortB   float64            // IDM舒适减速度（负数）
 	generator     *randengine.Engine // 随机数生成器
+	view          route.ViewRange    // 观察距离参数
 
 	// 状态
 
-	forceLC    bool    // 强制变道标志
-	lastLCTime float64 // 上次变道时间
+	forceLC    bool         // 强制变道标志
+	lastLCTime float64      // 上次变道时间
+	yieldLane  entity.ILane // 已在停车点停车、正在让行的路口车道
 
 	// 每次update时更新
 
@@ -60,6 +62,29 @@ type controller struct {
 	node  *entity.VehicleNode // 当前节点
 	v     float64             // 当前速度
 	dt    float64             // 时间步长
+	// 本步内已经过的时间（sim.substeps>1时为此前各子步的时长之和），用于外推周围车辆的位置
+	elapsed float64
+}
+
+// 车辆类别，由人的vehicle_class标签指定，未指定时为小汽车
+const (
+	vehicleClassLabel = "vehicle_class"
+	vehicleClassCar   = "car"
+	vehicleClassTruck = "truck"
+	vehicleClassBus   = "bus"
+)
+
+// vehicleClass 车辆类别对车辆性能的修正
+type vehicleClass struct {
+	aFactor float64 // 最大加速度与常用制动加速度的缩放系数
+	maxV    float64 // 最大速度上限（米/秒）
+}
+
+// 各车辆类别的性能修正，重型车辆加减速更慢、最大速度更低
+var vehicleClasses = map[string]vehicleClass{
+	vehicleClassCar:   {aFactor: 1, maxV: mathutil.INF},
+	vehicleClassTruck: {aFactor: .5, maxV: 25},
+	vehicleClassBus:   {aFactor: .7, maxV: 22},
 }
 
 // newController 创建新的车辆控制器
@@ -69,18 +94,20 @@ type controller struct {
 // 算法说明：
 // 1. 验证和修正车辆属性参数
 // 2. 从分布中采样缺失的参数
-// 3. 设置控制器的所有参数
+// 3. 设置控制器的所有参数（按车辆类别修正加速度与最大速度）
 // 4. 初始化状态变量
 func newController(self *Person) *controller {
 	// 数据预读
 	vehicleAttr := self.vehicleAttr
 	e := self.generator
+	class := vehicleClasses[self.VehicleClass()]
 	c := &controller{
 		self:          self,
-		usualBrakingA: vehicleAttr.UsualBrakingAcceleration,
+		usualBrakingA: vehicleAttr.UsualBrakingAcceleration * class.aFactor,
 		maxBrakingA:   vehicleAttr.MaxBrakingAcceleration,
-		maxA:          vehicleAttr.MaxAcceleration,
-		maxV:          vehicleAttr.MaxSpeed,
+		maxA:          vehicleAttr.MaxAcceleration * class.aFactor,
+		maxV:          math.Min(vehicleAttr.MaxSpeed, class.maxV),
+		speedCap:      mathutil.INF,
 		laneMaxVRatio: vehicleAttr.LaneMaxSpeedRecognitionDeviation,
 		length:        vehicleAttr.Length,
 		minGap:        vehicleAttr.MinGap,
@@ -88,7 +115,9 @@ func newController(self *Person) *controller {
 		headway:       vehicleAttr.Headway,
 		generator:     e,
 		lastLCTime:    -mathutil.INF,
+		view:          route.NewViewRange(),
 	}
+	c.initIDM()
 	return c
 }
 
@@ -148,7 +177,7 @@ func (l *controller) getEnv(
 	curLane entity.ILane,
 	s float64,
 ) (e env) {
-	viewDistance := math.Max(l.v*viewDistanceFactor, minViewDistance)
+	viewDistance := l.view.Distance(l.v)
 	e.curLane = curLane
 	e.s = s
 	e.nextStopDistance = math.Inf(0)
@@ -180,7 +209,7 @@ func (l *controller) getEnv(
 	if aheadHint != nil {
 		e.aheadVeh = &envVehicle{
 			node:     aheadHint,
-			distance: aheadHint.S - e.s - aheadHint.L(),
+			distance: l.nodeS(aheadHint) - e.s - aheadHint.L(),
 		}
 	}
 	// 感知障碍物
@@ -191,7 +220,7 @@ func (l *controller) getEnv(
 			if aheadHint != nil {
 				e.aheadVeh = &envVehicle{
 					node:     aheadHint,
-					distance: envLane.distance + aheadHint.S - aheadHint.L(),
+					distance: envLane.distance + l.nodeS(aheadHint) - aheadHint.L(),
 				}
 			}
 			if e.aheadVeh != nil {
@@ -202,6 +231,13 @@ func (l *controller) getEnv(
 	return
 }
 
+// nodeS 车辆节点在当前子步的位置
+// 说明：车道链表中的位置与速度为本步开始时的快照，sim.substeps>1时按快照速度匀速外推本步内已经过的时间，
+// 避免本车前进而周围车辆静止导致间距被低估
+func (l *controller) nodeS(node *entity.VehicleNode) float64 {
+	return node.S + node.V()*l.elapsed
+}
+
 func (l *controller) getSideEnvs(
 	curLane entity.ILane,
 	s float64,
@@ -228,7 +264,9 @@ func (l *controller) getSideEnvs(
 	return envs
 }
 
-func (l *controller) update(dt float64) (ac Action) {
+// update 感知周围环境并计算本车在当前子步的动作
+// 参数：dt-子步时长，elapsed-本步内已经过的时间
+func (l *controller) update(dt, elapsed float64) (ac Action) {
 	ac.A = mathutil.INF
 	ac.AheadVDistance = -1
 	// 更新参数
@@ -236,6 +274,7 @@ func (l *controller) update(dt float64) (ac Action) {
 	l.node = l.self.vehicle.node
 	l.v = l.self.runtime.V
 	l.dt = dt
+	l.elapsed = elapsed
 
 	var (
 		e        env
@@ -268,7 +307,7 @@ func (l *controller) update(dt float64) (ac Action) {
 	updateEnvs()
 
 	// ---------------------------------------------
-	l.headway = l.self.vehicleAttr.Headway
+	l.headway = l.getHeadway(e.aheadVeh)
 
 	// 执行纵向决策（加速度）
 	if e.aheadVeh != nil {
@@ -276,7 +315,12 @@ func (l *controller) update(dt float64) (ac Action) {
 	} else {
 		ac.Update(l.policyCarFollow(e.curLane, nil, mathutil.INF))
 	}
+	l.requestReservation(e.curLane, e.aheadLanes, e.s)
 	ac.Update(l.policyLane(e.curLane, e.aheadLanes, e.s))
+	ac.Update(l.policyIncident(e.curLane, e.aheadLanes, e.s))
+	if !l.self.IsLC() {
+		ac.Update(l.policyZipper(e.curLane, e.s))
+	}
 	// 执行变道时的额外纵向决策（加速度），看原车道的前车
 	if l.self.IsLC() {
 		if shadowE.aheadVeh != nil {
@@ -296,7 +340,7 @@ func (l *controller) update(dt float64) (ac Action) {
 	// 后处理
 	ac.A = lo.Clamp(ac.A, l.maxBrakingA, l.maxA)
 	// 加速度添加随机扰动
-	noise_acc := maxNoiseA * lo.Clamp(.5*l.generator.NormFloat64(), -1, 1)
+	noise_acc := noise(l.generator, maxNoiseA)
 	// 过小的加速度不扰动 扰动不改变加速度符号
 	if math.Abs(ac.A) >= zeroAThreshold && math.Signbit(ac.A) == math.Signbit(ac.A+noise_acc) {
 		ac.A += noise_acc
@@ -0,0 +1,236 @@
+package person
+
+import (
+	"errors"
+	"flag"
+	"math"
+	"slices"
+	"testing"
+
+	"git.fiblab.net/general/common/v2/mathutil"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
+)
+
+// 测试用路口车道，仅实现路口内判定、转向、信号灯与冲突点
+type testJunctionLane struct {
+	entity.ILane
+	turn     mapv2.LaneTurn
+	light    mapv2.LightState
+	overlaps map[float64]entity.Overlap
+	junction testJunction
+	pre      entity.ILane
+}
+
+// 测试用路口，仅实现信控判定
+type testJunction struct {
+	entity.IJunction
+	hasTrafficLight bool
+}
+
+func (j testJunction) HasTrafficLight() bool                            { return j.hasTrafficLight }
+func (j testJunction) Reservation() entity.IReservation                 { return nil }
+func (l *testJunctionLane) InJunction() bool                            { return true }
+func (l *testJunctionLane) Turn() mapv2.LaneTurn                        { return l.turn }
+func (l *testJunctionLane) Overlaps() map[float64]entity.Overlap        { return l.overlaps }
+func (l *testJunctionLane) Light() (mapv2.LightState, float64, float64) { return l.light, 30, 30 }
+func (l *testJunctionLane) ParentJunction() entity.IJunction            { return l.junction }
+
+func (l *testJunctionLane) UniquePredecessor() (entity.ILane, error) {
+	if l.pre == nil {
+		return nil, errors.New("no predecessor")
+	}
+	return l.pre, nil
+}
+
+func TestVehicleClass(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 1000)}, nil)
+	l := ctx.laneManager.Get(1)
+	// 从静止起步加速10秒后的速度
+	speedAfter := func(labels map[string]string) float64 {
+		c := newTestController(ctx, l, 0)
+		for key, value := range labels {
+			c.self.SetLabel(key, value)
+		}
+		c.self.applyLabels()
+		c = newController(c.self)
+		c.v, c.dt = 0, .5
+		for i := 0; i < 20; i++ {
+			c.v, _ = computeVAndDistance(c.v, c.selfFollow(0, mathutil.INF, 30), c.dt)
+		}
+		return c.v
+	}
+	car := speedAfter(nil)
+	assert.Equal(t, car, speedAfter(map[string]string{"vehicle_class": "car"}))
+	assert.Equal(t, car, speedAfter(map[string]string{"vehicle_class": "unknown"}))
+	truck := speedAfter(map[string]string{"vehicle_class": "truck"})
+	assert.Less(t, truck, car)
+	assert.LessOrEqual(t, truck, vehicleClasses[vehicleClassTruck].maxV)
+
+	// 运行时修改标签后重新解析车辆类别
+	p := newTestController(ctx, l, 0).self
+	p.SetLabel(vehicleClassLabel, vehicleClassBus)
+	assert.Equal(t, vehicleClassCar, p.VehicleClass())
+	p.applyLabels()
+	assert.Equal(t, vehicleClassBus, p.VehicleClass())
+	p.DeleteLabel(vehicleClassLabel)
+	p.applyLabels()
+	assert.Equal(t, vehicleClassCar, p.VehicleClass())
+}
+
+func TestIncident(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{
+		newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 1000),
+		newTestLanePb(2, mapv2.LaneType_LANE_TYPE_WALKING, 1000),
+	}, nil)
+	l := ctx.laneManager.Get(1)
+	assert.Error(t, ctx.laneManager.CreateIncident(3, 500, .8, 30))
+	assert.Error(t, ctx.laneManager.CreateIncident(2, 500, .8, 30))
+	assert.Error(t, ctx.laneManager.CreateIncident(1, 2000, .8, 30))
+	assert.Error(t, ctx.laneManager.CreateIncident(1, 500, 0, 30))
+	assert.Error(t, ctx.laneManager.CreateIncident(1, 500, .8, 0))
+	assert.NoError(t, ctx.laneManager.CreateIncident(1, 500, .8, 30))
+	assert.Nil(t, l.Incident())
+	ctx.laneManager.Prepare()
+	assert.NotNil(t, l.Incident())
+	assert.InDelta(t, 2, l.Incident().MaxV, 1e-9)
+
+	c := newTestController(ctx, l, 0)
+	// 车辆以10m/s通过事件点，统计通过事件范围的用时
+	passTime := func() float64 {
+		c.v = 10
+		s, elapsed := 0., 0.
+		for s < 1000 {
+			ac := c.policyCarFollow(l, nil, mathutil.INF)
+			ac.Update(c.policyIncident(l, nil, s))
+			var d float64
+			c.v, d = computeVAndDistance(c.v, ac.A, c.dt)
+			if s >= 500-incidentRange && s <= 500+incidentRange {
+				elapsed += c.dt
+			}
+			s += d
+		}
+		return elapsed
+	}
+	// 事件范围内按2m/s的限速行驶
+	slow := passTime()
+	assert.GreaterOrEqual(t, slow, 2*incidentRange/2.-2)
+
+	// 事件在持续时间结束后解除
+	ctx.clock.T = 29
+	ctx.laneManager.Prepare()
+	assert.NotNil(t, l.Incident())
+	ctx.clock.T = 30
+	ctx.laneManager.Prepare()
+	assert.Nil(t, l.Incident())
+	free := passTime()
+	assert.Less(t, free, slow/2)
+	assert.Equal(t, mathutil.INF, c.policyIncident(l, nil, 480).A)
+}
+
+func TestIDMParameters(t *testing.T) {
+	defer flag.Set("person.idm_time_gap", "0")
+	defer flag.Set("sim.disable_noise", "false")
+	flag.Set("sim.disable_noise", "true")
+	// 车队稳定后相邻车辆的平均间距
+	spacing := func(timeGap string) float64 {
+		flag.Set("person.idm_time_gap", timeGap)
+		ctx, m := newTestTrafficScene(1, 5)
+		for i := 0; i < 300; i++ {
+			m.PrepareNode()
+			ctx.laneManager.Prepare()
+			m.Prepare()
+			m.Update(1)
+		}
+		ss := lo.Map(m.persons.Data(), func(p *Person, _ int) float64 { return p.runtime.S })
+		slices.Sort(ss)
+		return (ss[len(ss)-1] - ss[0]) / float64(len(ss)-1)
+	}
+	// 默认使用车辆属性中的车头时距（1.5秒）
+	base := spacing("0")
+	assert.InDelta(t, base, spacing("1.5"), 1e-6)
+	assert.Greater(t, spacing("3"), base+10)
+
+	// 驾驶员画像覆盖全局参数
+	delta, gap := 2., 2.5
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)}, nil)
+	ctx.runtimeConfig = config.NewRuntimeConfig(config.Config{Control: config.Control{
+		DriverProfiles: map[string]config.IDMProfile{"cautious": {Delta: &delta, TimeGap: &gap}},
+	}})
+	c := newTestController(ctx, ctx.laneManager.Get(1), 0)
+	assert.Equal(t, 4., c.idmDelta)
+	assert.Equal(t, 1.5, c.baseHeadway)
+	assert.Equal(t, -4.5, c.idmComfortB)
+	c.self.labels = map[string]string{driverProfileLabel: "cautious"}
+	c = newController(c.self)
+	assert.Equal(t, 2., c.idmDelta)
+	assert.Equal(t, 2.5, c.baseHeadway)
+	assert.Equal(t, 2.5, c.headway)
+	assert.Equal(t, -4.5, c.idmComfortB)
+}
+
+// 测试用路口内车道链，仅实现长度、唯一后继与首车
+type testChainLane struct {
+	entity.ILane
+	length float64
+	next   *testChainLane
+	first  *entity.VehicleNode
+}
+
+func (l *testChainLane) InJunction() bool                  { return true }
+func (l *testChainLane) Length() float64                   { return l.length }
+func (l *testChainLane) FirstVehicle() *entity.VehicleNode { return l.first }
+
+func (l *testChainLane) UniqueSuccessor() (entity.ILane, error) {
+	if l.next == nil {
+		return nil, nil
+	}
+	return l.next, nil
+}
+
+func TestViewDistance(t *testing.T) {
+	defer flag.Set("veh.view_distance_factor", "12")
+	pb := newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)
+	pb.MaxSpeed = 30
+	ctx := newTestContext([]*mapv2.Lane{pb}, nil)
+	l := ctx.laneManager.Get(1)
+	// 三条50米的车道相连，第三条车道s=10处有停止的车辆（车长5米）
+	obstacle := newTestDrivingPerson(ctx, 2, l, 0)
+	obstacle.snapshot.V = 0
+	l3 := &testChainLane{length: 50, first: &entity.VehicleNode{S: 10, Value: obstacle}}
+	l2 := &testChainLane{length: 50, next: l3}
+	l1 := &testChainLane{length: 50, next: l2}
+
+	// 以20m/s行驶的车辆在第一条车道上前进，返回首次感知到障碍物时的距离与起点处的加速度
+	sense := func(factor string) (distance, a float64) {
+		flag.Set("veh.view_distance_factor", factor)
+		c := newTestController(ctx, l, 0)
+		c.v = 20
+		distance = math.NaN()
+		for s := 0.; s < 50; s++ {
+			e := c.getEnv(nil, l1, s)
+			if s == 0 {
+				if e.aheadVeh != nil {
+					a = c.policyCarFollow(l, e.aheadVeh.node, e.aheadVeh.distance).A
+				} else {
+					a = c.policyCarFollow(l, nil, mathutil.INF).A
+				}
+			}
+			if e.aheadVeh != nil {
+				distance = e.aheadVeh.distance
+				break
+			}
+		}
+		return
+	}
+	// 默认观察距离240米，起点处即感知到105米外的障碍物并提前减小加速度
+	farDistance, farA := sense("12")
+	assert.Equal(t, 105., farDistance)
+	// 观察距离60米时，障碍物进入观察范围时已更近
+	nearDistance, nearA := sense("3")
+	assert.Less(t, nearDistance, 65.)
+	assert.Less(t, farA, nearA)
+}
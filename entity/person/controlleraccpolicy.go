@@ -1,11 +1,17 @@
 package person
 
 import (
+	"math"
+
 	"git.fiblab.net/general/common/v2/mathutil"
 	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 )
 
+const (
+	incidentRange = 20 // 临时事件的影响范围（事件点前后，米）
+)
+
 // policyCarFollow 策略1：前车跟车策略
 // 功能：根据前车信息计算跟车加速度
 // 参数：curLane-当前车道，ahead-前车节点，distance-与前车距离
@@ -36,7 +42,7 @@ func (l *controller) policyCarFollow(
 // 2. 红灯停车检查：如果未完全进入车道且遇到红灯则停车
 // 3. 路口人行道处理：检查人行道占用情况，决定停车或减速
 // 4. 前方车道检查：检查前方车道的各种限制条件
-// 5. 信号灯处理：根据信号灯状态决定是否停车
+// 5. 信号灯处理：根据信号灯状态决定是否停车（预约控制的路口根据通行许可决定）
 // 说明：处理车道上的各种交通规则和约束条件
 func (l *controller) policyLane(curLane entity.ILane, aheadLanes []envLane, s float64) (ac Action) {
 	ac.A = mathutil.INF
@@ -50,10 +56,29 @@ func (l *controller) policyLane(curLane entity.ILane, aheadLanes []envLane, s fl
 		// ATTENTION: 增加2米的空间
 		stopA := l.stop(envLane.distance, l.getLaneMaxV(curLane), l.minGap+2)
 		if envLane.lane.InJunction() {
+			// 预约控制的路口没有信号灯，未获得通行许可时停车等待
+			if r := envLane.lane.ParentJunction().Reservation(); r != nil {
+				if !r.Granted(l.self.id, envLane.lane) {
+					ac.Update(Action{
+						A: stopA,
+					})
+				}
+				continue
+			}
+			// 停车让行标志控制的进口道，先停车再让行；人行横道上有优先通行的行人时停车礼让
+			if l.mustStopAtSign(envLane) || l.mustYieldToPedestrians(envLane) {
+				ac.Update(Action{
+					A: stopA,
+				})
+				continue
+			}
 			// 需要开始判断路口信控情况
 			switch state, _, remainingTime := envLane.lane.Light(); state {
 			case mapv2.LightState_LIGHT_STATE_RED:
-				// 红灯减速停车
+				// 红灯减速停车（允许红灯右转时，右转车道停车让行后通过）
+				if l.canTurnRightOnRed(envLane) {
+					break
+				}
 				ac.Update(Action{
 					A: stopA,
 				})
@@ -65,9 +90,45 @@ func (l *controller) policyLane(curLane entity.ILane, aheadLanes []envLane, s fl
 					})
 				}
 			default:
-				// 绿灯或没灯，跳过
+				// 绿灯或没灯，无信控路口按间隙接受模型让行
+				if l.mustYield(envLane) {
+					ac.Update(Action{
+						A: stopA,
+					})
+				}
 			}
 		}
 	}
 	return
 }
+
+// policyIncident 策略3：临时事件限速策略
+// 功能：车道上有临时事件时，在事件范围内按事件限速行驶，接近事件范围时提前减速
+// 参数：curLane-当前车道，aheadLanes-前方车道环境，s-当前位置
+// 返回：ac-计算得到的加速度动作
+// 说明：接近事件范围时按匀减速运动计算减速到事件限速所需的加速度
+func (l *controller) policyIncident(curLane entity.ILane, aheadLanes []envLane, s float64) (ac Action) {
+	ac.A = mathutil.INF
+	if inc := curLane.Incident(); inc != nil && s <= inc.S+incidentRange {
+		ac.Update(Action{A: l.slowDownTo(inc.MaxV, inc.S-incidentRange-s)})
+	}
+	for _, envLane := range aheadLanes {
+		if inc := envLane.lane.Incident(); inc != nil {
+			ac.Update(Action{A: l.slowDownTo(inc.MaxV, envLane.distance+inc.S-incidentRange)})
+		}
+	}
+	return
+}
+
+// slowDownTo 在指定距离内减速到目标速度
+// 参数：v-目标速度，distance-距离（<=0表示已经到达）
+// 返回：计算得到的加速度（米/秒²），无需减速时为无穷大
+func (l *controller) slowDownTo(v, distance float64) float64 {
+	if l.v <= v {
+		return mathutil.INF
+	}
+	if distance <= 0 {
+		return l.selfFollow(mathutil.INF, mathutil.INF, v)
+	}
+	return math.Max((v*v-l.v*l.v)/2/distance, l.maxBrakingA)
+}
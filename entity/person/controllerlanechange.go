@@ -1,6 +1,7 @@
 package person
 
 import (
+	"flag"
 	"math"
 
 	"git.fiblab.net/general/common/v2/mathutil"
@@ -14,6 +15,31 @@ const (
 	lcLaneEnd          = 20 // 车道最末端禁止主动变道的距离
 )
 
+// 变道时长模型
+const (
+	lcModelSpeed    = "speed"    // 变道长度与车速成正比（lcLengthFactor秒）
+	lcModelTime     = "time"     // 固定变道用时（veh.lc_time秒）
+	lcModelDistance = "distance" // 固定变道长度（veh.lc_distance米）
+)
+
+var (
+	lcModel    = flag.String("veh.lc_model", lcModelSpeed, "变道时长模型：speed（变道长度与车速成正比，前轮转角随车速变化）、time（固定用时）、distance（固定纵向长度）")
+	lcTime     = flag.Float64("veh.lc_time", 3, "veh.lc_model=time时的变道用时（秒），必须为正数")
+	lcDistance = flag.Float64("veh.lc_distance", 50, "veh.lc_model=distance时的变道纵向长度（米），必须为正数")
+)
+
+// getLCLength 计算车辆以速度v完成一次变道所需的纵向长度（米）
+func getLCLength(v float64) float64 {
+	switch *lcModel {
+	case lcModelTime:
+		return v * *lcTime
+	case lcModelDistance:
+		return *lcDistance
+	default:
+		return v * lcLengthFactor
+	}
+}
+
 // planLaneChange 变道规划主函数
 // 功能：根据当前环境和策略决定是否进行变道
 // 参数：curLane-当前车道，s-当前位置，ahead-前方车辆，sideEnvs-侧方环境，enableProactiveLaneChange-是否启用主动变道
@@ -29,13 +55,14 @@ func (l *controller) planLaneChange(
 	sideEnvs [2]*env,
 ) (ac Action) {
 	ac.A = mathutil.INF
+	l.self.runtime.ZipperTarget = nil
 	reverseS := curLane.Length() - s
 	links := l.node.Extra.Links
 	envs := sideEnvs
 	maxV := l.getLaneMaxV(curLane)
 	// 变道目标
-	lcLength := math.Max(l.v*lcLengthFactor, l.length) // 变道距离至少保留2个车长
-	lc := l.route.GetLCScan(curLane, l.self.snapshot.S, l.self.snapshot.V)
+	lcLength := math.Max(getLCLength(l.v), l.length) // 变道距离至少保留2个车长
+	lc := l.route.GetLCScan(curLane, l.self.runtime.S, l.self.runtime.V)
 	if !lc.InCandidate && (reverseS-lc.DeltaLCDistance <= lcLength*float64(lc.Count)) {
 		// 如果距离不足，进入强制变道模式（且无法从路由上延迟变道）
 		l.forceLC = true
@@ -49,6 +76,10 @@ func (l *controller) planLaneChange(
 		if e == nil {
 			log.Panicf("VehicleRoute: bad force lc target %+v, %v, %+v", lc, curLane, l.route)
 		}
+		// 消失车道末端按拉链式合流轮流汇入
+		if *zipperMerge && len(curLane.Successors()) == 0 {
+			return l.planZipperMerge(curLane, reverseS, ahead, e, links[lc.Side][entity.BEFORE])
+		}
 		target := e.curLane
 		l.lastLCTime = l.self.ctx.Clock().T
 		// 执行纵向控制策略
@@ -61,21 +92,21 @@ func (l *controller) planLaneChange(
 		// 强制变道，必须过去，所以越慢越好
 		if back := links[lc.Side][entity.BEFORE]; back != nil {
 			v3 := back.V()
-			s3 := back.S
+			s3 := l.nodeS(back)
 			an3 := l.follow(v3, maxV, l.v, sn-l.length-s3)
 			// 判决规则: 如果后车会追尾本车，本车刹车停下来等后车过去
 			// TODO: 不太合理
 			if an3 < math.Min(l.usualBrakingA+lcSafeBrakingABias, -1) {
 				ac.Update(Action{A: l.maxBrakingA})
 				// 变道，但不旋转车身
-				ac.startLaneChange(target, 0)
+				ac.startLaneChange(target, 0, true)
 				return
 			}
 		}
 		// 正常强制变道，减速慢行
 		if ac.LCTarget == nil {
 			ac.Update(Action{A: l.usualBrakingA})
-			ac.startLaneChange(target, 0)
+			ac.startLaneChange(target, 0, true)
 		}
 		return
 	}
@@ -88,6 +119,13 @@ func (l *controller) planLaneChange(
 	if l.self.ctx.Clock().T-l.lastLCTime < l.generator.Float64()*2+4 {
 		return
 	}
+	// 不主动变道进入不允许本车类别通行或有临时事件的车道
+	class := l.self.VehicleClass()
+	for side, e := range envs {
+		if e != nil && e.curLane != nil && (!e.curLane.AllowsClass(class) || e.curLane.Incident() != nil) {
+			envs[side] = nil
+		}
+	}
 	// 没有变道的可能
 	if envs[entity.LEFT] == nil && envs[entity.RIGHT] == nil {
 		return
@@ -109,14 +147,14 @@ func (l *controller) planLaneChange(
 	v1, s1 := mathutil.INF, mathutil.INF
 	if ahead != nil && ahead.node != nil {
 		v1 = ahead.node.V()
-		s1 = ahead.node.S - ahead.node.L()
+		s1 = l.nodeS(ahead.node) - ahead.node.L()
 	}
 	a0 := l.selfFollow(v1, s1-s, maxV)
 	deltaA2 := 0.0
 	if vehNode2 := l.node.Prev(); vehNode2 != nil {
 		// 如果2号车存在，计算2号车的预期加速度变化值
 		v2 := vehNode2.V()
-		s2 := vehNode2.S
+		s2 := l.nodeS(vehNode2)
 		deltaA2 = l.follow(v2, maxV, v1, s1-s2) - l.follow(v2, maxV, l.v, s-l.length-s2)
 	}
 	deltas := [2]float64{}
@@ -146,7 +184,7 @@ func (l *controller) planLaneChange(
 		v4, s4 := mathutil.INF, mathutil.INF
 		if node := links[side][entity.AFTER]; node != nil {
 			v4 = node.V()
-			s4 = node.S - node.L()
+			s4 = l.nodeS(node) - node.L()
 		}
 		sn0 := e.s
 		an0 := l.selfFollow(v4, s4-sn0, maxV)
@@ -156,7 +194,7 @@ func (l *controller) planLaneChange(
 		deltaA3 := 0.0
 		if vehNode3 := links[side][entity.BEFORE]; vehNode3 != nil {
 			v3 := vehNode3.V()
-			s3 := vehNode3.S
+			s3 := l.nodeS(vehNode3)
 			an3 := l.follow(v3, maxV, l.v, sn0-l.length-s3)
 			// 判决规则1: 如果3号车会追尾target，那么不变道
 			if an3 < l.usualBrakingA+lcSafeBrakingABias {
@@ -196,7 +234,7 @@ func (l *controller) planLaneChange(
 		ac = Action{A: an0s[side]}
 		ac.Update(l.policyLane(e.curLane, e.aheadLanes, e.s))
 		l.lastLCTime = l.self.ctx.Clock().T
-		ac.startLaneChange(target, 0)
+		ac.startLaneChange(target, 0, false)
 	}
 	return
 }
@@ -0,0 +1,47 @@
+package person
+
+import (
+	"flag"
+	"slices"
+	"testing"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLaneChangeTimeModel(t *testing.T) {
+	defer flag.Set("veh.lc_model", "speed")
+	flag.Set("veh.lc_model", "time")
+	right := newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 1000)
+	right.LeftLaneIds = []int32{2}
+	left := newTestLanePb(2, mapv2.LaneType_LANE_TYPE_DRIVING, 1000)
+	left.RightLaneIds = []int32{1}
+	for _, node := range left.CenterLine.Nodes {
+		node.Y = 3.2
+	}
+	ctx := newTestContext([]*mapv2.Lane{right, left}, nil)
+	l1, l2 := ctx.laneManager.Get(1), ctx.laneManager.Get(2)
+
+	// 固定用时模式下，不同车速的变道用时均约为veh.lc_time（3秒）
+	const dt = .1
+	var durations []float64
+	for _, v := range []float64{8, 15, 25} {
+		c := newTestController(ctx, l1, 10)
+		c.dt = dt
+		p := c.self
+		newTestManager(p)
+		p.runtime.V = v
+		ac := Action{LCTarget: l2}
+		steps := 0
+		for ; steps < 1000 && (steps == 0 || p.runtime.LC.IsLC); steps++ {
+			p.refreshRuntime(ac, dt)
+			ac = Action{LCPhi: c.getLCPhi(p.runtime.V)}
+		}
+		assert.Equal(t, l2, p.runtime.Lane)
+		durations = append(durations, float64(steps)*dt)
+	}
+	for _, d := range durations {
+		assert.InDelta(t, 3, d, .5)
+	}
+	assert.Less(t, slices.Max(durations)-slices.Min(durations), .5)
+}
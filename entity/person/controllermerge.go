@@ -0,0 +1,95 @@
+package person
+
+import (
+	"flag"
+
+	"git.fiblab.net/general/common/v2/mathutil"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+var (
+	zipperMerge = flag.Bool("person.zipper_merge", false, "是否在消失车道（没有后继车道）末端启用拉链式合流（相邻车道的每辆车让行一辆消失车道上的车，两条车道的车辆轮流通过合流点）")
+)
+
+const (
+	zipperRange = 50 // 拉链式合流的范围（距消失车道末端，米）
+)
+
+// planZipperMerge 拉链式合流的变道规划
+// 功能：消失车道上需要强制变道的车辆沿本车道行驶到合流范围内，作为本车道的头车轮到本车时变道汇入，否则在车道末端前停车等待
+// 参数：curLane-当前车道，reverseS-距车道末端的距离，ahead-前方车辆，e-目标车道环境，back-目标车道上的后车
+// 返回：ac-变道动作
+// 说明：等待期间在运行时记录目标车道，供目标车道上的车辆让行（见policyZipper）
+func (l *controller) planZipperMerge(
+	curLane entity.ILane, reverseS float64, ahead *envVehicle,
+	e *env, back *entity.VehicleNode,
+) (ac Action) {
+	ac.A = mathutil.INF
+	if reverseS > zipperRange {
+		// 尚未到达合流范围，继续利用本车道行驶
+		return
+	}
+	maxV := l.getLaneMaxV(curLane)
+	target := e.curLane
+	if (ahead == nil || ahead.node == nil) && l.zipperTurn(back, e.s, maxV) {
+		l.lastLCTime = l.self.ctx.Clock().T
+		if e.aheadVeh != nil {
+			ac.Update(l.policyCarFollow(target, e.aheadVeh.node, e.aheadVeh.distance))
+		}
+		ac.Update(l.policyLane(target, e.aheadLanes, e.s))
+		ac.startLaneChange(target, 0, true)
+		return
+	}
+	l.self.runtime.ZipperTarget = target
+	ac.Update(Action{A: l.stop(reverseS, maxV, l.minGap)})
+	return
+}
+
+// zipperTurn 判断是否轮到消失车道上的车辆汇入
+// 功能：目标车道上没有后车、后车与本车间隙足够，或后车尚未让行过其他车辆（或正在让行本车）且能够刹停时返回true
+// 参数：back-目标车道上的后车，sn-本车在目标车道上的投影位置，maxV-车道限速
+// 说明：只依赖快照数据，后车已经让行过其他车辆时本车等待下一辆车让行，保证两条车道的车辆交替通过
+func (l *controller) zipperTurn(back *entity.VehicleNode, sn, maxV float64) bool {
+	if back == nil {
+		return true
+	}
+	an3 := l.follow(back.V(), maxV, l.v, sn-l.length-l.nodeS(back))
+	if an3 >= l.usualBrakingA+lcSafeBrakingABias {
+		return true
+	}
+	yieldTo := back.Value.(*Person).snapshot.ZipperYieldTo
+	return (yieldTo == nil || yieldTo == l.self) && an3 >= l.maxBrakingA
+}
+
+// policyZipper 策略：拉链式合流让行
+// 功能：相邻消失车道上有前方等待汇入本车道的车辆时，若本车在当前车道上尚未让行过其他车辆，则将该车视为前车跟驰，为其留出汇入空间
+// 参数：curLane-当前车道，s-当前位置
+// 返回：ac-计算得到的加速度动作
+// 说明：让行的车辆记录在运行时中，每辆车在每条车道上只让行一次
+func (l *controller) policyZipper(curLane entity.ILane, s float64) (ac Action) {
+	ac.A = mathutil.INF
+	if !*zipperMerge {
+		return
+	}
+	for _, links := range l.node.Extra.Links {
+		m := links[entity.AFTER]
+		if m == nil {
+			continue
+		}
+		p, ok := m.Value.(*Person)
+		if !ok || p.snapshot.ZipperTarget != curLane {
+			continue
+		}
+		if yieldTo := l.self.runtime.ZipperYieldTo; yieldTo != nil && yieldTo != p {
+			continue
+		}
+		distance := curLane.ProjectFromLane(p.snapshot.Lane, l.nodeS(m)) - m.L() - s
+		if distance < 0 {
+			// 已经并排，来不及让行
+			continue
+		}
+		l.self.runtime.ZipperYieldTo = p
+		ac.Update(l.policyCarFollow(curLane, m, distance))
+	}
+	return
+}
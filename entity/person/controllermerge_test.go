@@ -0,0 +1,94 @@
+package person
+
+import (
+	"flag"
+	"testing"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/road"
+)
+
+func TestZipperMerge(t *testing.T) {
+	defer flag.Set("person.zipper_merge", "false")
+	defer flag.Set("sim.disable_noise", "false")
+	flag.Set("person.zipper_merge", "true")
+	flag.Set("sim.disable_noise", "true")
+	// 右侧直行车道1（400米）与左侧消失车道2（200米），车辆的终点在车道1末端
+	through := newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 400)
+	through.LeftLaneIds = []int32{2}
+	merging := newTestLanePb(2, mapv2.LaneType_LANE_TYPE_DRIVING, 200)
+	merging.RightLaneIds = []int32{1}
+	for _, node := range merging.CenterLine.Nodes {
+		node.Y = 3.2
+	}
+	through.ParentId, merging.ParentId = 1, 1
+	ctx := newTestContext([]*mapv2.Lane{through, merging}, nil)
+	rm := road.NewManager(ctx)
+	rm.Init([]*mapv2.Road{{Id: 1, LaneIds: []int32{2, 1}}}, ctx.laneManager)
+	ctx.roadManager = rm
+
+	// 两条车道都排满车辆
+	const n = 8
+	origin := make(map[int32]int32)
+	var persons []*Person
+	for _, laneID := range []int32{1, 2} {
+		l := ctx.laneManager.Get(laneID)
+		for j := 0; j < n; j++ {
+			s := float64(j) * 15
+			if laneID == 1 {
+				// 按长度比例投影后两条车道的车队同时到达合流点
+				s += 200
+			}
+			c := newTestController(ctx, l, s)
+			p := c.self
+			p.id = int32(len(persons) + 1)
+			p.vehicle.controller = c
+			p.runtime.V, p.snapshot.V = 8, 8
+			p.multiModalRoute.VehicleRoute.AtRoad = true
+			p.multiModalRoute.VehicleRoute.Roads = []entity.IRoad{rm.Get(1)}
+			p.multiModalRoute.VehicleRoute.End = entity.RoutePosition{Lane: ctx.laneManager.Get(1), S: 400}
+			origin[p.id] = laneID
+			persons = append(persons, p)
+		}
+	}
+	m := newTestManager(persons...)
+	m.ctx = ctx
+
+	// 按到达终点的顺序记录车辆的出发车道
+	var arrivals []int32
+	arrived := make(map[int32]bool)
+	for i := 0; i < 300 && len(arrivals) < len(persons); i++ {
+		m.PrepareNode()
+		ctx.laneManager.Prepare()
+		m.Prepare()
+		m.Update(1)
+		for _, p := range persons {
+			if !arrived[p.id] && p.runtime.Status == personv2.Status_STATUS_SLEEP {
+				arrived[p.id] = true
+				arrivals = append(arrivals, origin[p.id])
+			}
+		}
+	}
+	assert.Len(t, arrivals, len(persons))
+	// 合流期间两条车道的车辆交替通过，没有一条车道连续通过多辆车
+	maxRun, run := 1, 1
+	for i := 1; i < len(arrivals); i++ {
+		if arrivals[i] == arrivals[i-1] {
+			run++
+		} else {
+			run = 1
+		}
+		maxRun = max(maxRun, run)
+	}
+	assert.LessOrEqual(t, maxRun, 3)
+	switches := 0
+	for i := 1; i < len(arrivals); i++ {
+		if arrivals[i] != arrivals[i-1] {
+			switches++
+		}
+	}
+	assert.GreaterOrEqual(t, switches, n)
+}
@@ -1,20 +1,67 @@
 package person
 
 import (
+	"flag"
 	"math"
 
 	"git.fiblab.net/general/common/v2/mathutil"
 	"github.com/samber/lo"
 )
 
+var (
+	idmDelta            = flag.Float64("person.idm_delta", 4, "IDM跟驰模型的加速度指数")
+	idmComfortableDecel = flag.Float64("person.idm_comfortable_decel", 0, "IDM跟驰模型的舒适减速度（米/秒²，正数），<=0表示使用车辆属性中的常用制动加速度")
+	idmTimeGap          = flag.Float64("person.idm_time_gap", 0, "IDM跟驰模型的期望车头时距（秒），<=0表示使用车辆属性中的安全车头时距")
+)
+
+const (
+	driverProfileLabel = "driver_profile" // 驾驶员画像标签，按配置中的driver_profiles覆盖IDM参数
+)
+
+// initIDM 初始化IDM跟驰参数
+// 功能：依次使用车辆属性、全局flag（person.idm_*）、驾驶员画像（driver_profile标签）的配置，后者覆盖前者
+// 说明：在创建控制器时读取一次，默认参数与车辆属性一致
+func (l *controller) initIDM() {
+	l.idmDelta = *idmDelta
+	l.idmComfortB = l.usualBrakingA
+	l.baseHeadway = l.headway
+	if *idmComfortableDecel > 0 {
+		l.idmComfortB = -*idmComfortableDecel
+	}
+	if *idmTimeGap > 0 {
+		l.baseHeadway = *idmTimeGap
+	}
+	name, ok := l.self.GetLabel(driverProfileLabel)
+	if !ok {
+		l.headway = l.baseHeadway
+		return
+	}
+	profile, ok := l.self.ctx.RuntimeConfig().C.DriverProfiles[name]
+	if !ok {
+		log.Warnf("person %d: unknown driver profile %q", l.self.ID(), name)
+		l.headway = l.baseHeadway
+		return
+	}
+	if profile.Delta != nil {
+		l.idmDelta = *profile.Delta
+	}
+	if profile.ComfortableDecel != nil && *profile.ComfortableDecel > 0 {
+		l.idmComfortB = -*profile.ComfortableDecel
+	}
+	if profile.TimeGap != nil && *profile.TimeGap > 0 {
+		l.baseHeadway = *profile.TimeGap
+	}
+	l.headway = l.baseHeadway
+}
+
 // followImpl 跟车模型核心实现
 // 功能：实现智能驾驶模型(IDM)的跟车逻辑
 // 参数：selfV-本车速度，targetV-目标速度，aheadV-前车速度，distance-车距，minGap-最小车距，headway-安全车头时距
 // 返回：计算得到的加速度（米/秒²）
 // 算法说明：
 // 1. 检查是否发生碰撞（距离小于等于0）
-// 2. 使用IDM模型计算期望车距：s_star = minGap + max(0, v*headway + v*(v-v_ahead)/(2*sqrt(a*b)))
-// 3. 计算加速度：a = maxA * (1 - (v/targetV)^4 - (s_star/distance)^2)
+// 2. 使用IDM模型计算期望车距：s_star = minGap + max(0, v*headway + v*(v-v_ahead)/(2*sqrt(a*b)))，b为舒适减速度
+// 3. 计算加速度：a = maxA * (1 - (v/targetV)^delta - (s_star/distance)^2)
 // 4. 限制加速度在制动和加速范围内
 // 说明：IDM模型是经典的跟车模型，能够模拟真实驾驶行为
 func (l *controller) followImpl(
@@ -29,10 +76,10 @@ func (l *controller) followImpl(
 		// 计算期望车距：s_star = minGap + max(0, v*headway + v*(v-v_ahead)/(2*sqrt(a*b)))
 		s_star := minGap + math.Max(
 			0,
-			selfV*headway+selfV*(selfV-aheadV)/2/math.Sqrt(-l.usualBrakingA*l.maxA),
+			selfV*headway+selfV*(selfV-aheadV)/2/math.Sqrt(-l.idmComfortB*l.maxA),
 		)
-		// IDM加速度公式：a = maxA * (1 - (v/targetV)^4 - (s_star/distance)^2)
-		acc = l.maxA * (1 - math.Pow(selfV/targetV, idmTheta) - math.Pow(s_star/distance, 2))
+		// IDM加速度公式：a = maxA * (1 - (v/targetV)^delta - (s_star/distance)^2)
+		acc = l.maxA * (1 - math.Pow(selfV/targetV, l.idmDelta) - math.Pow(s_star/distance, 2))
 	}
 	return lo.Clamp(acc, l.maxBrakingA, l.maxA) // 限制加速度在合理范围内
 }
@@ -52,9 +99,9 @@ func (l *controller) follow(
 // 功能：使用控制器自身的速度和目标速度进行跟车计算
 // 参数：aheadV-前车速度，distance-车距，laneMaxV-车道最大速度
 // 返回：计算得到的加速度（米/秒²）
-// 说明：目标速度为车道限速和车辆最大速度的较小值，确保安全
+// 说明：目标速度为车道限速和车辆最大速度（含外部速度上限）的较小值，确保安全
 func (l *controller) selfFollow(aheadV, distance, laneMaxV float64) float64 {
-	return l.follow(l.v, math.Min(l.maxV, laneMaxV), aheadV, distance)
+	return l.follow(l.v, math.Min(l.getMaxV(), laneMaxV), aheadV, distance)
 }
 
 // stop 在指定距离内刹停
@@ -68,5 +115,5 @@ func (l *controller) selfFollow(aheadV, distance, laneMaxV float64) float64 {
 // 说明：用于计算停车所需的制动加速度，确保在指定距离内安全停车
 func (l *controller) stop(distance, laneMaxV, minGap float64) float64 {
 	// 停车的话，要先预判dt时间，而不需要按照跟车的headway进行计算
-	return l.followImpl(l.v, math.Min(l.maxV, laneMaxV), 0, distance, minGap, l.dt)
+	return l.followImpl(l.v, math.Min(l.getMaxV(), laneMaxV), 0, distance, minGap, l.dt)
 }
@@ -0,0 +1,54 @@
+package person
+
+import (
+	"math"
+
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+// requestReservation 向预约控制路口申请通行许可
+// 功能：车辆位于预约控制路口内时续约当前路口车道（无法放弃），否则为前方第一个路口车道申请，
+// 已持有许可且无法舒适停车的车辆的申请同样无法放弃
+// 参数：curLane-当前车道，aheadLanes-前方车道环境，s-当前位置
+// 说明：申请在下一步准备阶段处理，预计驶入、驶离时间按最大加速度加速到限速后匀速行驶估计
+func (l *controller) requestReservation(curLane entity.ILane, aheadLanes []envLane, s float64) {
+	now := l.self.ctx.Clock().T
+	if curLane.InJunction() {
+		if r := curLane.ParentJunction().Reservation(); r != nil {
+			exit := now + l.travelTime(curLane.Length()-s+l.length, l.getLaneMaxV(curLane))
+			r.Request(l.self.id, curLane, now, exit, true)
+		}
+		return
+	}
+	for _, envLane := range aheadLanes {
+		if !envLane.lane.InJunction() {
+			continue
+		}
+		r := envLane.lane.ParentJunction().Reservation()
+		if r == nil {
+			return
+		}
+		maxV := l.getLaneMaxV(envLane.lane)
+		arrival := now + l.travelTime(envLane.distance, maxV)
+		exit := now + l.travelTime(envLane.distance+envLane.lane.Length()+l.length, maxV)
+		committed := r.Granted(l.self.id, envLane.lane) && l.v*l.v/2/-l.usualBrakingA >= envLane.distance
+		r.Request(l.self.id, envLane.lane, arrival, exit, committed)
+		return
+	}
+}
+
+// travelTime 估计行驶指定距离所需的时间
+// 参数：distance-距离（米），laneMaxV-车道限速
+// 返回：以最大加速度加速到限速后匀速行驶所需的时间（秒）
+func (l *controller) travelTime(distance, laneMaxV float64) float64 {
+	if distance <= 0 {
+		return 0
+	}
+	maxV := math.Min(l.getMaxV(), laneMaxV)
+	v := math.Min(l.v, maxV)
+	accD := (maxV*maxV - v*v) / 2 / l.maxA
+	if distance <= accD {
+		return (math.Sqrt(v*v+2*l.maxA*distance) - v) / l.maxA
+	}
+	return (maxV-v)/l.maxA + (distance-accD)/maxV
+}
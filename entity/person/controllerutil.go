@@ -1,11 +1,16 @@
 package person
 
 import (
+	"flag"
 	"math"
 
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 )
 
+var (
+	caccHeadway = flag.Float64("person.cacc_headway", 0, "编队（协同自适应巡航）跟车时的安全车头时距（秒），<=0表示不启用编队跟车")
+)
+
 // getLaneMaxV 获取车道最大速度
 // 功能：根据车道限速和车辆对限速的认知偏差计算实际限速
 // 参数：lane-车道对象
@@ -19,6 +24,13 @@ func (l *controller) getLaneMaxV(lane entity.ILane) float64 {
 	return lane.MaxV() * l.laneMaxVRatio
 }
 
+// getMaxV 获取车辆当前的最大速度
+// 功能：综合车辆自身最大速度与外部设置的速度上限
+// 返回：车辆当前的最大速度（米/秒）
+func (l *controller) getMaxV() float64 {
+	return math.Min(l.maxV, l.speedCap)
+}
+
 // getLCPhi 计算车辆前轮转角
 // 功能：根据车速计算变道时的前轮转角
 // 参数：v-车速（米/秒）
@@ -29,10 +41,52 @@ func (l *controller) getLaneMaxV(lane entity.ILane) float64 {
 // 3. 当v=80km/h≈25m/s时，转角为5度
 // 4. 最小转角限制为5度
 // 5. 将角度转换为弧度
-// 说明：车速越快，变道时前轮转角越小，确保变道稳定性
+// 说明：车速越快，变道时前轮转角越小，确保变道稳定性；
+// veh.lc_model为time或distance时，改为使车身在本步内转到匀速完成变道所需的偏转角，
+// 即以getLCLength的纵向长度完成一个车道宽度的横向位移
 func (l *controller) getLCPhi(v float64) float64 {
+	if *lcModel != lcModelSpeed {
+		return l.getLCPhiForLength(v, getLCLength(v))
+	}
 	// 车轮最大转角φ: v=0km/h时，为30度, v=80km/h≈25m/s时，为5度
 	const K = (5.0 - 25.0) / (25.0 - 0.0)     // 线性插值斜率
 	const B = 30.0                            // 线性插值截距
 	return math.Max(K*v+B, 5) * math.Pi / 180 // 限制最小转角为5度并转换为弧度
 }
+
+// getLCPhiForLength 计算使车身偏转到目标偏转角的前轮转角
+// 参数：v-车速（米/秒），lcLength-完成变道的纵向长度（米）
+// 返回：前轮转角（弧度），已达到目标偏转角或车辆静止时为0
+func (l *controller) getLCPhiForLength(v, lcLength float64) float64 {
+	rt := l.self.runtime
+	d := v * l.dt
+	if d <= 0 || lcLength <= 0 {
+		return 0
+	}
+	width := (rt.Lane.Width() + rt.LC.ShadowLane.Width()) / 2
+	// 目标偏转角不超过refreshRuntime允许的最大偏转角
+	maxYaw := math.Min(math.Pi/6, math.Asin(math.Min(width/l.self.vehicleAttr.Length, 1)))
+	target := math.Min(math.Asin(math.Min(width/lcLength, 1)), maxYaw)
+	dYaw := target - rt.LC.Yaw
+	if dYaw <= 0 {
+		return 0
+	}
+	// 与refreshRuntime中的转向动力学一致：dYaw = d / (L/2) * tan(φ)
+	return math.Atan(dYaw * l.self.vehicleAttr.Length / 2 / d)
+}
+
+// getHeadway 计算本步使用的安全车头时距
+// 功能：与前车间距小于platoonMaxDistance且车辆类别相同时视为编队跟车，使用person.cacc_headway减小车头时距
+// 参数：ahead-前车（可以为nil）
+// 返回：安全车头时距（秒）
+// 说明：每步重新判断，编队解散（间距变大或前车改变）后自动恢复车辆自身的车头时距
+func (l *controller) getHeadway(ahead *envVehicle) float64 {
+	headway := l.baseHeadway
+	if *caccHeadway <= 0 || ahead == nil || ahead.distance >= platoonMaxDistance {
+		return headway
+	}
+	if ahead.node.Value.VehicleClass() != l.self.VehicleClass() {
+		return headway
+	}
+	return math.Min(headway, *caccHeadway)
+}
@@ -0,0 +1,56 @@
+package person
+
+import (
+	"flag"
+	"testing"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlatoonHeadway(t *testing.T) {
+	defer flag.Set("person.cacc_headway", "0")
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 1000)}, nil)
+	l := ctx.laneManager.Get(1)
+	c := newTestController(ctx, l, 0)
+	c.self.base = &personv2.Person{}
+	leader := newTestDrivingPerson(ctx, 2, l, 100)
+	leader.base = &personv2.Person{}
+	c.dt = .5
+
+	// 跟随10m/s匀速行驶的前车，从8米的间距开始，返回稳定后的间距
+	steadyGap := func() float64 {
+		c.v = 10
+		gap := 8.
+		for i := 0; i < 400; i++ {
+			c.headway = c.getHeadway(&envVehicle{node: leader.vehicle.node, distance: gap})
+			var d float64
+			c.v, d = computeVAndDistance(c.v, c.selfFollow(10, gap, 30), c.dt)
+			gap += 10*c.dt - d
+		}
+		return gap
+	}
+	normal := steadyGap()
+	flag.Set("person.cacc_headway", "0.6")
+	platoon := steadyGap()
+	assert.Less(t, platoon, platoonMaxDistance)
+	assert.Greater(t, normal, platoonMaxDistance)
+	// 编队后车道可容纳的车辆数（每公里）更多
+	assert.Greater(t, 1000/(platoon+c.length), 1.5*1000/(normal+c.length))
+
+	// 间距过大或车辆类别不同时不编队
+	ahead := &envVehicle{node: leader.vehicle.node, distance: 5}
+	assert.Equal(t, .6, c.getHeadway(ahead))
+	ahead.distance = 20
+	assert.Equal(t, c.self.vehicleAttr.Headway, c.getHeadway(ahead))
+	ahead.distance = 5
+	leader.SetLabel(vehicleClassLabel, vehicleClassTruck)
+	leader.applyLabels()
+	assert.Equal(t, c.self.vehicleAttr.Headway, c.getHeadway(ahead))
+	// 两辆货车之间同样编队
+	c.self.SetLabel(vehicleClassLabel, vehicleClassTruck)
+	c.self.applyLabels()
+	assert.Equal(t, .6, c.getHeadway(ahead))
+	assert.Equal(t, c.self.vehicleAttr.Headway, c.getHeadway(nil))
+}
@@ -0,0 +1,208 @@
+package person
+
+import (
+	"flag"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+var (
+	rightTurnOnRed  = flag.Bool("person.right_turn_on_red", false, "是否允许红灯右转（在停车线前停车后，冲突车道无来车时通过）")
+	gapAcceptance   = flag.Bool("person.gap_acceptance", false, "是否在无信控路口启用间隙接受模型（驶入有非优先冲突点的路口车道前等待冲突车道的可接受间隙）")
+	yieldTimeGap    = flag.Float64("person.yield_time_gap", 4, "让行时可接受的最小时间间隙（秒），冲突车辆到达冲突点的时间小于该值时等待")
+	pedestrianYield = flag.Bool("person.pedestrian_yield", false, "是否在人行横道处进行人车冲突检查（行人在冲突点前等待来车，车辆按路权礼让行人）")
+)
+
+const (
+	yieldPedestrianRange   = 3   // 让行时冲突点前后该范围内有行人则等待（米）
+	yieldStopV             = 0.1 // 让行停车的速度判定阈值（米/秒）
+	yieldStopLineTolerance = 3   // 让行停车位置与停车点的允许偏差（米）
+	crossingBuffer         = 1.5 // 行人在冲突点前等待的位置与冲突点的距离（米）
+	crossingBrakingA       = 4.5 // 判断车辆能否在冲突点前停车时假设的减速度（米/秒²）
+)
+
+// conflictsClear 检查路口车道的冲突点是否可以通过
+// 功能：检查lane上所有本车道不优先的冲突点，冲突车道上的车辆在person.yield_time_gap内不会到达冲突点、且冲突点附近没有行人时返回true
+// 参数：lane-即将驶入的路口车道
+// 返回：是否可以通过
+// 说明：冲突车道上尚未到达冲突点的车辆包括其前驱车道上的车辆
+func (l *controller) conflictsClear(lane entity.ILane) bool {
+	for _, o := range lane.Overlaps() {
+		if o.SelfFirst {
+			continue
+		}
+		if o.Other.Type() == mapv2.LaneType_LANE_TYPE_WALKING {
+			if pedestrianNear(o.Other, o.OtherS) {
+				return false
+			}
+			continue
+		}
+		if vehicleApproaching(o.Other, o.OtherS) {
+			return false
+		}
+		for _, pre := range o.Other.Predecessors() {
+			if vehicleApproaching(pre.Lane, pre.Lane.Length()+o.OtherS) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pedestrianNear 检查人行道上s附近yieldPedestrianRange范围内是否有行人
+func pedestrianNear(lane entity.ILane, s float64) bool {
+	for node := lane.Pedestrians().First(); node != nil; node = node.Next() {
+		if node.S > s-yieldPedestrianRange && node.S < s+yieldPedestrianRange {
+			return true
+		}
+	}
+	return false
+}
+
+// vehicleApproaching 检查车道上是否有车辆正在占用或即将到达s处
+// 功能：车辆车身覆盖s，或车头距s的行驶时间小于person.yield_time_gap时返回true
+// 参数：lane-车道，s-冲突点在该车道坐标系下的位置（可以超出车道长度）
+func vehicleApproaching(lane entity.ILane, s float64) bool {
+	for node := lane.FirstVehicle(); node != nil; node = node.Next() {
+		if node.S-node.L() > s {
+			// 已经驶过冲突点
+			break
+		}
+		if node.S >= s {
+			// 车身覆盖冲突点
+			return true
+		}
+		if v := node.V(); v > yieldStopV && (s-node.S)/v < *yieldTimeGap {
+			return true
+		}
+	}
+	return false
+}
+
+// stoppedAtStopLine 检查车辆是否已在路口车道前的停车点停车
+// 功能：车辆在停车点附近速度降到yieldStopV以下后记录该路口车道，之后即使起步也视为已停车，离开停车点附近后清除记录
+// 参数：e-前方的路口车道
+// 返回：是否已在停车点停车
+func (l *controller) stoppedAtStopLine(e envLane) bool {
+	if e.distance > l.minGap+2+yieldStopLineTolerance {
+		// 尚未到达停车点
+		if l.yieldLane == e.lane {
+			l.yieldLane = nil
+		}
+		return false
+	}
+	if l.yieldLane != e.lane && l.v <= yieldStopV {
+		l.yieldLane = e.lane
+	}
+	return l.yieldLane == e.lane
+}
+
+// canTurnRightOnRed 检查是否可以红灯右转
+// 功能：右转车道为红灯时视为让行：车辆需先在停车点停车，之后冲突点无冲突车辆时通过
+// 参数：e-前方的路口车道
+// 返回：是否可以忽略该红灯
+func (l *controller) canTurnRightOnRed(e envLane) bool {
+	if !*rightTurnOnRed || e.lane.Turn() != mapv2.LaneTurn_LANE_TURN_RIGHT {
+		return false
+	}
+	return l.stoppedAtStopLine(e) && l.conflictsClear(e.lane)
+}
+
+// mustStopAtSign 检查是否需要在停车让行标志前停车
+// 功能：进口道末端有停车让行标志时，车辆必须先在停车点完全停车，之后按间隙接受模型确认冲突点无冲突车辆才能驶入路口
+// 参数：e-前方的路口车道
+// 返回：是否需要在路口前停车
+func (l *controller) mustStopAtSign(e envLane) bool {
+	pre, err := e.lane.UniquePredecessor()
+	if err != nil || !pre.StopSign() {
+		return false
+	}
+	return !(l.stoppedAtStopLine(e) && l.conflictsClear(e.lane))
+}
+
+// mustYield 检查是否需要在无信控路口让行
+// 功能：间隙接受模型，车辆接近无信控路口时，若即将驶入的路口车道上的非优先冲突点没有可接受的间隙则需要停车等待
+// 参数：e-前方的路口车道
+// 返回：是否需要在路口前停车
+// 说明：只在车辆距停车点的行驶时间小于可接受间隙时判断，避免远处车辆因短暂的冲突提前减速
+func (l *controller) mustYield(e envLane) bool {
+	if !*gapAcceptance || e.lane.ParentJunction().HasTrafficLight() {
+		return false
+	}
+	if e.distance > l.minGap+2+yieldStopLineTolerance+*yieldTimeGap*l.v {
+		return false
+	}
+	return !l.conflictsClear(e.lane)
+}
+
+// vehicleCannotStop 检查车道上是否有车辆正在占用s处或已无法在s前停车
+// 参数：lane-车道，s-冲突点在该车道坐标系下的位置（可以超出车道长度）
+func vehicleCannotStop(lane entity.ILane, s float64) bool {
+	for node := lane.FirstVehicle(); node != nil; node = node.Next() {
+		if node.S-node.L() > s {
+			break
+		}
+		if v := node.V(); node.S >= s || s-node.S < v*v/2/crossingBrakingA {
+			return true
+		}
+	}
+	return false
+}
+
+// crossingClear 检查行人在人行道上从from走到to是否可以通过人车冲突点
+// 功能：行人将要越过冲突点前的等待位置时，检查冲突的行车道（含其前驱车道）上的车辆：
+// 行人优先时只在车辆占用冲突点或已无法停车时等待，车辆优先时按间隙接受模型等待
+// 参数：lane-行人所在人行道，from-移动前位置，to-移动后位置
+// 返回：是否可以通过
+func crossingClear(lane entity.ILane, from, to float64) bool {
+	for selfS, o := range lane.Overlaps() {
+		if o.Other.Type() != mapv2.LaneType_LANE_TYPE_DRIVING {
+			continue
+		}
+		var entering bool
+		if to >= from {
+			wait := selfS - crossingBuffer
+			entering = from <= wait && to > wait
+		} else {
+			wait := selfS + crossingBuffer
+			entering = from >= wait && to < wait
+		}
+		if !entering {
+			continue
+		}
+		check := vehicleApproaching
+		if o.SelfFirst {
+			check = vehicleCannotStop
+		}
+		if check(o.Other, o.OtherS) {
+			return false
+		}
+		for _, pre := range o.Other.Predecessors() {
+			if check(pre.Lane, pre.Lane.Length()+o.OtherS) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// mustYieldToPedestrians 检查是否需要在路口车道前礼让行人
+// 功能：即将驶入的路口车道与人行横道冲突且行人优先时，冲突点附近有行人则停车等待
+// 参数：e-前方的路口车道
+// 返回：是否需要在路口前停车
+// 说明：与间隙接受模型相同，只在车辆距停车点的行驶时间小于可接受间隙时判断
+func (l *controller) mustYieldToPedestrians(e envLane) bool {
+	if !*pedestrianYield {
+		return false
+	}
+	if e.distance > l.minGap+2+yieldStopLineTolerance+*yieldTimeGap*l.v {
+		return false
+	}
+	for _, o := range e.lane.Overlaps() {
+		if !o.SelfFirst && o.Other.Type() == mapv2.LaneType_LANE_TYPE_WALKING && pedestrianNear(o.Other, o.OtherS) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package person
+
+import (
+	"flag"
+	"math"
+	"testing"
+
+	"git.fiblab.net/general/common/v2/mathutil"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+func TestRightTurnOnRed(t *testing.T) {
+	defer flag.Set("person.right_turn_on_red", "false")
+	ctx := newTestContext([]*mapv2.Lane{
+		newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+		newTestLanePb(2, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+	}, nil)
+	l1, l2 := ctx.laneManager.Get(1), ctx.laneManager.Get(2)
+	c := newTestController(ctx, l1, 97.5)
+	right := &testJunctionLane{
+		turn:     mapv2.LaneTurn_LANE_TURN_RIGHT,
+		light:    mapv2.LightState_LIGHT_STATE_RED,
+		overlaps: map[float64]entity.Overlap{5: {Other: l2, OtherS: 50}},
+	}
+	ahead := []envLane{{lane: right, distance: 2.5}}
+
+	// 未启用时红灯停车
+	c.v = 0
+	assert.Less(t, c.policyLane(l1, ahead, 97.5).A, 0.)
+
+	// 启用后需要先停车
+	flag.Set("person.right_turn_on_red", "true")
+	c.v = 5
+	assert.NotEqual(t, mathutil.INF, c.policyLane(l1, []envLane{{lane: right, distance: 30}}, 70).A)
+	assert.Less(t, c.policyLane(l1, ahead, 97.5).A, 0.)
+
+	// 停车后冲突车道无来车，直接通过
+	c.v = 0
+	assert.Equal(t, mathutil.INF, c.policyLane(l1, ahead, 97.5).A)
+	c.v = 1
+	assert.Equal(t, mathutil.INF, c.policyLane(l1, ahead, 97.5).A)
+
+	// 冲突车道有车辆即将到达冲突点时等待
+	other := newTestDrivingPerson(ctx, 2, l2, 30)
+	ctx.laneManager.Prepare()
+	other.snapshot.V = 10
+	assert.Less(t, c.policyLane(l1, ahead, 97.5).A, 0.)
+	other.snapshot.V = 2
+	assert.Equal(t, mathutil.INF, c.policyLane(l1, ahead, 97.5).A)
+
+	// 非右转车道不受影响
+	right.turn = mapv2.LaneTurn_LANE_TURN_STRAIGHT
+	assert.Less(t, c.policyLane(l1, ahead, 97.5).A, 0.)
+}
+
+func TestGapAcceptance(t *testing.T) {
+	defer flag.Set("person.gap_acceptance", "false")
+	flag.Set("person.gap_acceptance", "true")
+	ctx := newTestContext([]*mapv2.Lane{
+		newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+		newTestLanePb(2, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+	}, nil)
+	l1, l2 := ctx.laneManager.Get(1), ctx.laneManager.Get(2)
+	// 次路车辆停在无信控路口前，即将驶入的路口车道与主路车道在主路s=50处冲突，主路优先
+	c := newTestController(ctx, l1, 97.5)
+	c.v = 0
+	minor := &testJunctionLane{
+		turn:     mapv2.LaneTurn_LANE_TURN_STRAIGHT,
+		overlaps: map[float64]entity.Overlap{5: {Other: l2, OtherS: 50}},
+	}
+	ahead := []envLane{{lane: minor, distance: 2.5}}
+	assert.Equal(t, mathutil.INF, c.policyLane(l1, ahead, 97.5).A)
+
+	// 主路车辆即将到达冲突点，次路车辆等待
+	major := newTestDrivingPerson(ctx, 2, l2, 30)
+	ctx.laneManager.Prepare()
+	major.snapshot.V = 10
+	assert.Less(t, c.policyLane(l1, ahead, 97.5).A, 0.)
+
+	// 主路车辆占据冲突点时等待，驶过冲突点后通过
+	major.vehicle.node.S = 52
+	assert.Less(t, c.policyLane(l1, ahead, 97.5).A, 0.)
+	major.vehicle.node.S = 60
+	assert.Equal(t, mathutil.INF, c.policyLane(l1, ahead, 97.5).A)
+
+	// 本车道优先或路口有信控时不让行
+	major.vehicle.node.S = 30
+	minor.overlaps[5] = entity.Overlap{Other: l2, OtherS: 50, SelfFirst: true}
+	assert.Equal(t, mathutil.INF, c.policyLane(l1, ahead, 97.5).A)
+	minor.overlaps[5] = entity.Overlap{Other: l2, OtherS: 50}
+	minor.junction.hasTrafficLight = true
+	assert.Equal(t, mathutil.INF, c.policyLane(l1, ahead, 97.5).A)
+}
+
+func TestStopSign(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{
+		newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+		newTestLanePb(2, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+	}, nil)
+	l1 := ctx.laneManager.Get(1)
+	assert.NoError(t, ctx.laneManager.SetLaneStopSign(1, true))
+	assert.Error(t, ctx.laneManager.SetLaneStopSign(3, true))
+	ctx.laneManager.Prepare()
+	assert.True(t, l1.StopSign())
+
+	c := newTestController(ctx, l1, 0)
+	c.dt = .5
+	junctionLane := &testJunctionLane{turn: mapv2.LaneTurn_LANE_TURN_STRAIGHT, pre: l1}
+	// 车辆以10m/s驶向路口，仅考虑跟车与车道策略
+	c.v = 10
+	distance := 100.
+	minV := mathutil.INF
+	for i := 0; i < 200 && distance > 0; i++ {
+		ac := c.policyLane(l1, []envLane{{lane: junctionLane, distance: distance}}, 100-distance)
+		ac.Update(c.policyCarFollow(l1, nil, mathutil.INF))
+		var d float64
+		c.v, d = computeVAndDistance(c.v, ac.A, c.dt)
+		distance -= d
+		if distance <= c.minGap+2+yieldStopLineTolerance {
+			minV = math.Min(minV, c.v)
+		}
+	}
+	// 车辆在停车点停车后驶入路口
+	assert.LessOrEqual(t, minV, yieldStopV)
+	assert.LessOrEqual(t, distance, 0.)
+}
+
+func TestPedestrianCrossing(t *testing.T) {
+	defer flag.Set("person.pedestrian_yield", "false")
+	ctx := newTestContext([]*mapv2.Lane{
+		newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+		newTestLanePb(2, mapv2.LaneType_LANE_TYPE_WALKING, 10),
+	}, nil)
+	l1, l2 := ctx.laneManager.Get(1), ctx.laneManager.Get(2)
+	// 人行横道s=5处与行车道s=50处冲突，车辆优先
+	crossing := &testJunctionLane{overlaps: map[float64]entity.Overlap{5: {Other: l1, OtherS: 50}}}
+	vehicle := newTestDrivingPerson(ctx, 1, l1, 40)
+	ctx.laneManager.Prepare()
+	vehicle.snapshot.V = 10
+
+	// 车辆即将到达冲突点，行人在等待位置前停下；未越过等待位置或已在冲突点上的移动不受影响
+	assert.False(t, crossingClear(crossing, 3, 4))
+	assert.False(t, crossingClear(crossing, 7, 6))
+	assert.True(t, crossingClear(crossing, 1, 2))
+	assert.True(t, crossingClear(crossing, 4, 5))
+	// 车辆驶过冲突点后通过
+	vehicle.vehicle.node.S = 60
+	assert.True(t, crossingClear(crossing, 3, 4))
+
+	// 行人优先时，只有车辆已无法停车时才等待
+	crossing.overlaps[5] = entity.Overlap{Other: l1, OtherS: 50, SelfFirst: true}
+	vehicle.vehicle.node.S = 30
+	assert.True(t, crossingClear(crossing, 3, 4))
+	vehicle.vehicle.node.S = 45
+	assert.False(t, crossingClear(crossing, 3, 4))
+
+	// 车辆礼让冲突点附近优先通行的行人
+	flag.Set("person.pedestrian_yield", "true")
+	c := newTestController(ctx, ctx.laneManager.Get(1), 97.5)
+	c.v = 5
+	junctionLane := &testJunctionLane{overlaps: map[float64]entity.Overlap{5: {Other: l2, OtherS: 5}}}
+	ahead := []envLane{{lane: junctionLane, distance: 2.5}}
+	assert.False(t, c.mustYieldToPedestrians(ahead[0]))
+	l2.AddPedestrian(newPedestrianNode(4, newTestPerson(3, 0, 0)))
+	ctx.laneManager.Prepare()
+	assert.True(t, c.mustYieldToPedestrians(ahead[0]))
+	assert.Less(t, c.policyLane(l1, ahead, 97.5).A, 0.)
+	// 远处车辆不提前减速，车辆优先时不礼让
+	assert.False(t, c.mustYieldToPedestrians(envLane{lane: junctionLane, distance: 80}))
+	junctionLane.overlaps[5] = entity.Overlap{Other: l2, OtherS: 5, SelfFirst: true}
+	assert.False(t, c.mustYieldToPedestrians(ahead[0]))
+}
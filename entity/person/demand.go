@@ -0,0 +1,68 @@
+package person
+
+import (
+	"flag"
+	"math"
+
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
+	"github.com/samber/lo"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	demandScale  = flag.Float64("demand.scale", 1, "出行需求的缩放系数，加载时每人以该概率保留（小于1时），或按系数复制出新ID的人（大于1时），必须为非负数")
+	demandSeed   = flag.Uint64("demand.seed", 0, "出行需求缩放的随机数种子")
+	demandJitter = flag.Float64("demand.clone_jitter", 300, "复制出的人的出发时间随机偏移范围（秒），在[-jitter, jitter]内均匀分布")
+)
+
+// scaleDemand 按系数缩放出行需求
+// 功能：将每个人的期望份数取为scale，整数部分确定保留，小数部分按概率保留；
+// 除第一份保留原人外，其余份数复制为新ID的人，并整体平移其时刻表中的出发时间
+// 参数：pbs-Person的protobuf数据列表，scale-缩放系数
+// 返回：缩放后的Person列表
+// 说明：按输入顺序使用固定种子的随机数，结果可复现
+func scaleDemand(pbs []*personv2.Person, scale float64) []*personv2.Person {
+	generator := randengine.New(*demandSeed)
+	nextID := int32(0)
+	if len(pbs) > 0 {
+		nextID = lo.MaxBy(pbs, func(a, b *personv2.Person) bool { return a.Id > b.Id }).Id + 1
+	}
+	whole, frac := math.Modf(scale)
+	scaled := make([]*personv2.Person, 0, int(float64(len(pbs))*scale)+1)
+	for _, pb := range pbs {
+		n := int(whole)
+		if generator.PTrue(frac) {
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		scaled = append(scaled, pb)
+		for i := 1; i < n; i++ {
+			clone := proto.Clone(pb).(*personv2.Person)
+			clone.Id = nextID
+			nextID++
+			shiftDepartureTimes(clone.Schedules, *demandJitter*(2*generator.Float64()-1))
+			scaled = append(scaled, clone)
+		}
+	}
+	return scaled
+}
+
+// shiftDepartureTimes 平移时刻表中的所有出发时间，结果不小于0
+func shiftDepartureTimes(schedules []*tripv2.Schedule, offset float64) {
+	shift := func(t *float64) *float64 {
+		if t == nil {
+			return nil
+		}
+		return proto.Float64(math.Max(*t+offset, 0))
+	}
+	for _, s := range schedules {
+		s.DepartureTime = shift(s.DepartureTime)
+		for _, trip := range s.Trips {
+			trip.DepartureTime = shift(trip.DepartureTime)
+		}
+	}
+}
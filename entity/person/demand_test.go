@@ -0,0 +1,42 @@
+package person
+
+import (
+	"testing"
+
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDemandScale(t *testing.T) {
+	pbs := make([]*personv2.Person, 1000)
+	for i := range pbs {
+		departure := 600.
+		pbs[i] = &personv2.Person{
+			Id:        int32(i + 1),
+			Schedules: []*tripv2.Schedule{{DepartureTime: &departure, Trips: []*tripv2.Trip{{}}}},
+		}
+	}
+	ids := func(pbs []*personv2.Person) []int32 {
+		return lo.Map(pbs, func(pb *personv2.Person, _ int) int32 { return pb.Id })
+	}
+
+	// 缩小时每人以该概率保留，结果可复现
+	half := scaleDemand(pbs, .5)
+	assert.InDelta(t, 500, len(half), 60)
+	assert.Equal(t, ids(half), ids(scaleDemand(pbs, .5)))
+	assert.Empty(t, scaleDemand(pbs, 0))
+
+	// 放大时复制出新ID的人，出发时间在偏移范围内
+	double := scaleDemand(pbs, 2)
+	assert.Len(t, double, 2000)
+	assert.Len(t, lo.Uniq(ids(double)), 2000)
+	assert.Equal(t, ids(double), ids(scaleDemand(pbs, 2)))
+	for _, pb := range double[:10] {
+		assert.InDelta(t, 600, *pb.Schedules[0].DepartureTime, 300)
+	}
+	// 原人的时刻表不变
+	assert.Equal(t, 600., *pbs[0].Schedules[0].DepartureTime)
+	assert.InDelta(t, 1500, len(scaleDemand(pbs, 1.5)), 80)
+}
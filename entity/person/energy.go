@@ -0,0 +1,28 @@
+package person
+
+import (
+	"flag"
+	"math"
+)
+
+var (
+	fuelIdleRate  = flag.Float64("person.fuel_idle_rate", 0.2, "车辆能耗模型的常数项：怠速燃油消耗率（毫升/秒）")
+	fuelSpeedCoef = flag.Float64("person.fuel_speed_coef", 0.0015, "车辆能耗模型的速度平方项系数（毫升/秒/(米/秒)²）")
+	fuelAccelCoef = flag.Float64("person.fuel_accel_coef", 0.5, "车辆能耗模型的加速度项系数（毫升/秒/(米/秒²)），只计加速时的消耗")
+	co2PerFuel    = flag.Float64("person.co2_per_fuel", 2.31, "每毫升燃油燃烧产生的CO2质量（克/毫升）")
+)
+
+// PersonEnergy 人的累计能耗与排放
+type PersonEnergy struct {
+	Fuel float64 // 累计燃油消耗（毫升）
+	CO2  float64 // 累计CO2排放（克）
+}
+
+// add 按能耗模型累加车辆在dt内的能耗与排放
+// 功能：燃油消耗率 = 常数项 + 速度平方项 + 加速度项（减速时不计），CO2排放与燃油消耗成正比
+// 参数：v-速度（米/秒），a-加速度（米/秒²），dt-时间步长（秒）
+func (e *PersonEnergy) add(v, a, dt float64) {
+	fuel := (*fuelIdleRate + *fuelSpeedCoef*v*v + *fuelAccelCoef*math.Max(a, 0)) * dt
+	e.Fuel += fuel
+	e.CO2 += fuel * *co2PerFuel
+}
@@ -0,0 +1,55 @@
+package person
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonEnergy(t *testing.T) {
+	ctx, m := newTestTrafficScene(2, 1)
+	long, short := m.data[1], m.data[2]
+	short.runtime.V, short.snapshot.V = 2, 2
+	for i := 0; i < 30; i++ {
+		m.PrepareNode()
+		ctx.laneManager.Prepare()
+		m.Prepare()
+		long.updateVehicle(1)
+		if i < 5 {
+			short.updateVehicle(1)
+		}
+	}
+	m.Prepare()
+	longEnergy, err := m.GetPersonEnergy(1)
+	assert.NoError(t, err)
+	shortEnergy, err := m.GetPersonEnergy(2)
+	assert.NoError(t, err)
+	// 行驶距离更长、速度更快的行程能耗与排放更多
+	assert.Greater(t, long.runtime.S-short.runtime.S, 100.)
+	assert.Greater(t, shortEnergy.Fuel, 0.)
+	assert.Greater(t, longEnergy.Fuel, shortEnergy.Fuel)
+	assert.InDelta(t, longEnergy.Fuel*2.31, longEnergy.CO2, 1e-9)
+	_, err = m.GetPersonEnergy(3)
+	assert.Error(t, err)
+}
+
+func TestPersonEnergySubsteps(t *testing.T) {
+	defer flag.Set("sim.substeps", "1")
+	defer flag.Set("sim.disable_noise", "false")
+	defer flag.Set("person.fuel_idle_rate", "0.2")
+	defer flag.Set("person.fuel_accel_coef", "0.5")
+	flag.Set("sim.substeps", "10")
+	flag.Set("sim.disable_noise", "true")
+	flag.Set("person.fuel_idle_rate", "0")
+	flag.Set("person.fuel_accel_coef", "0")
+	ctx, m := newTestTrafficScene(1, 1)
+	p := m.data[1]
+	p.runtime.V, p.snapshot.V = 0, 0
+	m.PrepareNode()
+	ctx.laneManager.Prepare()
+	m.Prepare()
+	p.updateVehicle(1)
+	// 从静止以约3m/s²加速1秒，速度平方项的积分约为3；每个子步按子步开始时的速度计算平均速度
+	assert.InEpsilon(t, 3**fuelSpeedCoef, p.energy.Fuel, .05)
+}
@@ -0,0 +1,39 @@
+package person
+
+// LaneChangeCount 变道次数
+type LaneChangeCount struct {
+	Initiated int32 // 发起的变道次数
+	Completed int32 // 完成的变道次数
+}
+
+// SuccessRate 变道成功率，没有发起变道时返回0
+func (c LaneChangeCount) SuccessRate() float64 {
+	if c.Initiated == 0 {
+		return 0
+	}
+	return float64(c.Completed) / float64(c.Initiated)
+}
+
+// PersonLaneChanges 人的累计变道统计
+type PersonLaneChanges struct {
+	Mandatory     LaneChangeCount // 强制变道（为驶入导航所需的车道）
+	Discretionary LaneChangeCount // 自由变道（为获得更好的行驶条件）
+}
+
+// initiate 记录发起一次变道
+func (c *PersonLaneChanges) initiate(mandatory bool) {
+	if mandatory {
+		c.Mandatory.Initiated++
+	} else {
+		c.Discretionary.Initiated++
+	}
+}
+
+// complete 记录完成一次变道
+func (c *PersonLaneChanges) complete(mandatory bool) {
+	if mandatory {
+		c.Mandatory.Completed++
+	} else {
+		c.Discretionary.Completed++
+	}
+}
@@ -0,0 +1,53 @@
+package person
+
+import (
+	"testing"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/road"
+)
+
+func TestPersonLaneChanges(t *testing.T) {
+	// 右侧直行车道1（400米）与左侧消失车道2（200米），消失车道上的车辆必须汇入车道1
+	through := newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 400)
+	through.LeftLaneIds = []int32{2}
+	merging := newTestLanePb(2, mapv2.LaneType_LANE_TYPE_DRIVING, 200)
+	merging.RightLaneIds = []int32{1}
+	for _, node := range merging.CenterLine.Nodes {
+		node.Y = 3.2
+	}
+	through.ParentId, merging.ParentId = 1, 1
+	ctx := newTestContext([]*mapv2.Lane{through, merging}, nil)
+	rm := road.NewManager(ctx)
+	rm.Init([]*mapv2.Road{{Id: 1, LaneIds: []int32{2, 1}}}, ctx.laneManager)
+	ctx.roadManager = rm
+
+	c := newTestController(ctx, ctx.laneManager.Get(2), 100)
+	p := c.self
+	p.vehicle.controller = c
+	p.runtime.V, p.snapshot.V = 8, 8
+	p.multiModalRoute.VehicleRoute.AtRoad = true
+	p.multiModalRoute.VehicleRoute.Roads = []entity.IRoad{rm.Get(1)}
+	p.multiModalRoute.VehicleRoute.End = entity.RoutePosition{Lane: ctx.laneManager.Get(1), S: 400}
+	m := newTestManager(p)
+	m.ctx = ctx
+	for i := 0; i < 100 && p.runtime.Status == personv2.Status_STATUS_DRIVING; i++ {
+		m.PrepareNode()
+		ctx.laneManager.Prepare()
+		m.Prepare()
+		m.Update(1)
+	}
+	m.Prepare()
+	assert.Equal(t, personv2.Status_STATUS_SLEEP, p.runtime.Status)
+
+	changes, err := m.GetPersonLaneChanges(1)
+	assert.NoError(t, err)
+	assert.Equal(t, LaneChangeCount{Initiated: 1, Completed: 1}, changes.Mandatory)
+	assert.Equal(t, 1., changes.Mandatory.SuccessRate())
+	assert.Zero(t, changes.Discretionary.SuccessRate())
+	_, err = m.GetPersonLaneChanges(2)
+	assert.Error(t, err)
+}
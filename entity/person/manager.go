@@ -1,8 +1,12 @@
 package person
 
 import (
+	"cmp"
+	"flag"
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"git.fiblab.net/general/common/v2/parallel"
 	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
@@ -11,9 +15,17 @@ import (
 	"github.com/samber/lo"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/person/route"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/person/schedule"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/container"
 )
 
+var (
+	statsWarmupSeconds = flag.Float64("stats.warmup_seconds", 0, "仿真预热时长（秒），预热期间的行驶与完成行程不计入全局统计")
+	partitionUpdate    = flag.Bool("person.partition_update", false, "是否按所在道路/路口/AOI划分人并行更新（区域内按ID顺序串行更新），提高结果的可复现性")
+	excludeEmptySched  = flag.Bool("person.exclude_empty_schedule", false, "加载时没有有效时刻表的人不加入更新数组（仍可按ID查询），通过SetSchedule设置时刻表后重新加入")
+	departureWindow    = flag.Float64("person.departure_window", 0, "加载时将每人时刻表中指定的全部出发时间统一在[t, t+window)内随机推迟（秒），避免出发时间相同造成的集中出行，0表示不调整")
+)
+
 // GlobalRuntime 全局运行时数据结构
 // 功能：管理全局运行时数据，包括完成行程数、总行驶时间、总行驶距离
 type GlobalRuntime struct {
@@ -36,10 +48,20 @@ type PersonManager struct {
 
 	personInserted      []*Person // 新加入的人
 	personInsertedMutex sync.Mutex
+	personRemoved       []*Person // 待删除的人
+	personRemovedMutex  sync.Mutex
 	nextPersonID        int32
 
 	snapshot, runtime GlobalRuntime
 	runtimeMtx        sync.Mutex
+
+	tripCondition schedule.TripConditionEvaluator // 行程执行条件判定器
+
+	trajectory *trajectoryWriter // 轨迹JSON Lines输出（未启用时为nil）
+
+	accelerations []float64 // 最近一个输出步驾车的人的加速度样本（stats.accel_histogram）
+
+	activePersons int32 // 上一次更新后仍有出行任务的人数
 }
 
 // NewManager 创建Person管理器实例
@@ -53,6 +75,8 @@ func NewManager(ctx entity.ITaskContext) *PersonManager {
 		persons:             container.NewIncrementalArray[*Person](),
 		personInserted:      make([]*Person, 0),
 		personInsertedMutex: sync.Mutex{},
+		personRemoved:       make([]*Person, 0),
+		personRemovedMutex:  sync.Mutex{},
 		nextPersonID:        10000000,
 	}
 	return m
@@ -68,9 +92,46 @@ func (m *PersonManager) Init(
 	aoiManager entity.IAoiManager,
 	laneManager entity.ILaneManager,
 ) {
+	if *arrivalThreshold <= 0 {
+		log.Fatalf("veh.arrival_threshold must be positive, got %v", *arrivalThreshold)
+	}
+	if *redSpeedFactor < 1 {
+		log.Fatalf("ped.red_speed_factor must be at least 1, got %v", *redSpeedFactor)
+	}
+	switch *lcModel {
+	case lcModelSpeed, lcModelTime, lcModelDistance:
+	default:
+		log.Fatalf("unknown veh.lc_model %q", *lcModel)
+	}
+	if *lcTime <= 0 || *lcDistance <= 0 {
+		log.Fatalf("veh.lc_time and veh.lc_distance must be positive, got %v and %v", *lcTime, *lcDistance)
+	}
+	if *harshAccelThreshold <= 0 || *harshBrakeThreshold <= 0 {
+		log.Fatalf("veh.harsh_accel_threshold and veh.harsh_brake_threshold must be positive, got %v and %v", *harshAccelThreshold, *harshBrakeThreshold)
+	}
+	if *demandScale < 0 {
+		log.Fatalf("demand.scale must be non-negative, got %v", *demandScale)
+	}
+	if *demandScale != 1 {
+		n := len(pbs)
+		pbs = scaleDemand(pbs, *demandScale)
+		log.Infof("scale demand by %v: %d -> %d persons", *demandScale, n, len(pbs))
+	}
 	m.persons = container.NewIncrementalArray[*Person]()
 	persons := parallel.GoMap(pbs, func(pb *personv2.Person) *Person {
 		p := newPerson(m.ctx, m, pb)
+		if *departureWindow > 0 {
+			p.spreadDeparture(*departureWindow)
+		}
+		if *excludeEmptySched {
+			// 提前应用时刻表以剔除无效行程
+			p.ResetScheduleIfNeed()
+			p.snapshot = p.runtime
+			if p.schedule.Empty() {
+				p.dormant.Store(true)
+				return p
+			}
+		}
 		m.persons.Add(p)
 		return p
 	})
@@ -78,6 +139,24 @@ func (m *PersonManager) Init(
 		return p.id, p
 	})
 	m.nextPersonID = lo.Max(lo.Keys(m.data)) + 1
+
+	if *outputJSONL != "" {
+		tw, err := newTrajectoryWriter(*outputJSONL)
+		if err != nil {
+			log.Panicf("failed to create trajectory output %s: %v", *outputJSONL, err)
+		}
+		m.trajectory = tw
+	}
+}
+
+// Close 结束仿真时关闭输出文件
+func (m *PersonManager) Close() {
+	if m.trajectory != nil {
+		if err := m.trajectory.close(); err != nil {
+			log.Errorf("failed to close trajectory output: %v", err)
+		}
+		m.trajectory = nil
+	}
 }
 
 // Get 根据ID获取Person实例
@@ -105,6 +184,17 @@ func (m *PersonManager) GetOrError(id int32) (entity.IPerson, error) {
 	}
 }
 
+// SetTripConditionEvaluator 设置行程执行条件判定器
+// 功能：为所有Person（包括之后新增的Person）设置行程执行条件判定器，条件不成立的行程将被跳过
+// 参数：condition-条件判定器，nil表示不做判定
+// 说明：判定器会在update阶段被并行调用，需要保证线程安全；应在模拟开始前调用
+func (m *PersonManager) SetTripConditionEvaluator(condition schedule.TripConditionEvaluator) {
+	m.tripCondition = condition
+	for _, p := range m.data {
+		p.schedule.SetConditionEvaluator(condition)
+	}
+}
+
 // add 添加新的Person到管理器
 // 功能：动态添加新的Person，支持ID自动分配
 // 参数：pb-Person的protobuf数据
@@ -128,6 +218,39 @@ func (m *PersonManager) add(pb *personv2.Person) *Person {
 	return p
 }
 
+// remove 标记删除Person
+// 功能：标记指定Person待删除，其与车道/AOI的关联在下一次update中解除，并在之后的PrepareNode中从管理器删除
+// 参数：id-Person的唯一标识符
+// 返回：错误信息，Person不存在或位于路口内时返回错误
+func (m *PersonManager) remove(id int32) error {
+	p, ok := m.data[id]
+	if !ok {
+		return fmt.Errorf("no id %d in person data", id)
+	}
+	if p.runtime.Lane != nil && p.runtime.Lane.InJunction() {
+		return fmt.Errorf("person %d in a junction does not support removal", id)
+	}
+	p.removed = true
+	// 未加入更新数组的人需要重新加入，由update解除关联
+	m.wake(p)
+	return nil
+}
+
+// wake 将因时刻表为空未加入更新数组的Person重新加入（下一次PrepareNode后生效）
+// 说明：写入buffer、需在准备或更新阶段应用的修改（时刻表、标签、位置重置、删除）都需要调用
+func (m *PersonManager) wake(p *Person) {
+	if p.dormant.CompareAndSwap(true, false) {
+		m.persons.Add(p)
+	}
+}
+
+// recordRemoved 记录已解除关联、待从管理器删除的Person
+func (m *PersonManager) recordRemoved(p *Person) {
+	m.personRemovedMutex.Lock()
+	defer m.personRemovedMutex.Unlock()
+	m.personRemoved = append(m.personRemoved, p)
+}
+
 // 准备阶段：链表节点更新
 func (m *PersonManager) PrepareNode() {
 	// 新人加入
@@ -138,6 +261,12 @@ func (m *PersonManager) PrepareNode() {
 		m.data[newP.ID()] = newP
 	}
 	m.personInserted = []*Person{}
+	// 删除人
+	for _, p := range m.personRemoved {
+		delete(m.data, p.ID())
+		m.persons.Remove(p)
+	}
+	m.personRemoved = []*Person{}
 
 	// data prepare
 	// 最好不要并行处理，因为共用index，如果一个人同时从车辆中删去又加入行人，可能有问题
@@ -152,18 +281,88 @@ func (m *PersonManager) Prepare() {
 		p.prepare()
 	})
 	m.snapshot = m.runtime
+	// 加速度采样与轨迹输出只在输出步进行（output.interval_steps）
+	if m.ctx.Clock().OutputStep() {
+		if *accelHistogram {
+			m.collectAccelerations()
+		}
+		if m.trajectory != nil {
+			if err := m.trajectory.write(m.ctx.Clock().T, m.persons.Data()); err != nil {
+				log.Errorf("failed to write trajectory output: %v", err)
+			}
+		}
+	}
 	log.Debug("PersonManager: prepare done")
 }
 
 // 更新阶段
 func (m *PersonManager) Update(dt float64) {
-	parallel.GoFor(m.persons.Data(), func(p *Person) { p.update(dt) })
+	var active atomic.Int32
+	update := func(p *Person) {
+		p.update(dt)
+		if !p.idle() {
+			active.Add(1)
+		}
+	}
+	if *partitionUpdate {
+		parallel.GoFor(m.partitions(), func(ps []*Person) {
+			for _, p := range ps {
+				update(p)
+			}
+		})
+	} else {
+		parallel.GoFor(m.persons.Data(), update)
+	}
 	route.CallbackWaitGroup.Wait()
+	m.activePersons = active.Load()
+}
+
+// ActivePersons 获取仍有出行任务的人数
+// 功能：返回上一次更新后不处于睡眠状态或时刻表非空的人数，加上等待加入的新人数
+// 说明：为0时所有人都已结束出行，可用于提前结束仿真
+func (m *PersonManager) ActivePersons() int32 {
+	m.personInsertedMutex.Lock()
+	defer m.personInsertedMutex.Unlock()
+	return m.activePersons + int32(len(m.personInserted))
+}
+
+// partitions 按空间区域划分人
+// 功能：按人所在车道的父对象（道路/路口）或所在AOI划分，区域内按ID排序，不在任何区域内的人单独成组
+// 返回：划分后的人的分组
+// 说明：同一区域内的人总是在同一组内按固定顺序串行更新，减少锁竞争；
+// 跨区域驶入的车辆仍会从其他分组写入车道缓冲区，由车道在准备阶段按ID排序保证结果与调度无关
+func (m *PersonManager) partitions() [][]*Person {
+	groups := make(map[int32][]*Person)
+	res := make([][]*Person, 0)
+	for _, p := range m.persons.Data() {
+		switch {
+		case p.snapshot.Lane != nil:
+			groups[p.snapshot.Lane.ParentID()] = append(groups[p.snapshot.Lane.ParentID()], p)
+		case p.snapshot.Aoi != nil:
+			groups[p.snapshot.Aoi.ID()] = append(groups[p.snapshot.Aoi.ID()], p)
+		default:
+			res = append(res, []*Person{p})
+		}
+	}
+	for _, ps := range groups {
+		slices.SortFunc(ps, func(a, b *Person) int { return cmp.Compare(a.id, b.id) })
+		res = append(res, ps)
+	}
+	return res
+}
+
+// warmupComplete 检查仿真预热是否完成
+// 功能：仿真时间超过stats.warmup_seconds后才开始累计全局统计
+func (m *PersonManager) warmupComplete() bool {
+	return m.ctx.Clock().T > *statsWarmupSeconds
 }
 
 // recordRunning 记录在路上的人车
-// 功能：记录在路上的人车，更新全局运行时数据
+// 功能：记录在路上的人车，更新全局运行时数据（预热期间不记录）
 func (m *PersonManager) recordRunning(dt float64, ds float64) {
+	if !m.warmupComplete() {
+		return
+	}
 	m.runtimeMtx.Lock()
 	defer m.runtimeMtx.Unlock()
 	m.runtime.TravelTime += dt
@@ -171,8 +370,11 @@ func (m *PersonManager) recordRunning(dt float64, ds float64) {
 }
 
 // recordPedestrianTripEnd 记录行程结束
-// 功能：记录行程结束，更新全局运行时数据
+// 功能：记录行程结束，更新全局运行时数据（预热期间不记录）
 func (m *PersonManager) recordTripEnd(p *Person) {
+	if !m.warmupComplete() {
+		return
+	}
 	m.runtimeMtx.Lock()
 	defer m.runtimeMtx.Unlock()
 	m.runtime.NumCompletedTrips++
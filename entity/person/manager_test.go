@@ -0,0 +1,285 @@
+package person
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"slices"
+	"testing"
+
+	"connectrpc.com/connect"
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
+	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStatsWarmup(t *testing.T) {
+	defer flag.Set("stats.warmup_seconds", "0")
+	flag.Set("stats.warmup_seconds", "60")
+	ctx := newTestContext(nil, nil)
+	m := newTestManager()
+	m.ctx = ctx
+
+	// 预热期间完成的行程与行驶不计入统计
+	for ctx.clock.T = 0; ctx.clock.T <= 60; ctx.clock.T += 10 {
+		assert.False(t, m.WarmupComplete())
+		m.recordTripEnd(nil)
+		m.recordRunning(10, 100)
+	}
+	assert.Equal(t, GlobalRuntime{}, m.runtime)
+
+	assert.True(t, m.WarmupComplete())
+	m.recordTripEnd(nil)
+	m.recordRunning(10, 100)
+	assert.Equal(t, GlobalRuntime{NumCompletedTrips: 1, TravelTime: 10, TravelDistance: 100}, m.runtime)
+}
+
+func TestAddAoiAtRuntime(t *testing.T) {
+	// 行车道1与步行道2平行，初始只有AOI 10
+	withWalking := func(a *mapv2.Aoi) *mapv2.Aoi {
+		a.WalkingPositions = []*geov2.LanePosition{{LaneId: 2, S: a.DrivingPositions[0].S}}
+		return a
+	}
+	ctx := newTestContext([]*mapv2.Lane{
+		newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+		newTestLanePb(2, mapv2.LaneType_LANE_TYPE_WALKING, 100),
+	}, []*mapv2.Aoi{withWalking(newTestAoiPb(10, 1, 30))})
+	ctx.router.walking = &routingv2.GetRouteResponse{Journeys: []*routingv2.Journey{{
+		Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
+		Walking: &routingv2.WalkingJourneyBody{
+			Route: []*routingv2.WalkingRouteSegment{{LaneId: 2, MovingDirection: routingv2.MovingDirection_MOVING_DIRECTION_FORWARD}},
+			Eta:   50,
+		},
+	}}}
+
+	// 重复ID、不存在的车道与类型不符的车道均被拒绝
+	assert.Error(t, ctx.aoiManager.AddAoi(newTestAoiPb(10, 1, 80)))
+	assert.Error(t, ctx.aoiManager.AddAoi(newTestAoiPb(20, 3, 80)))
+	bad := newTestAoiPb(20, 1, 80)
+	bad.DrivingPositions[0].LaneId = 2
+	assert.Error(t, ctx.aoiManager.AddAoi(bad))
+
+	assert.NoError(t, ctx.aoiManager.AddAoi(withWalking(newTestAoiPb(20, 1, 80))))
+	assert.Error(t, ctx.aoiManager.AddAoi(newTestAoiPb(20, 1, 80)))
+	_, err := ctx.aoiManager.GetOrError(20)
+	assert.Error(t, err)
+	ctx.aoiManager.PrepareNode()
+	a, err := ctx.aoiManager.GetOrError(20)
+	assert.NoError(t, err)
+	assert.Contains(t, ctx.laneManager.Get(1).Aois(), int32(20))
+	assert.Contains(t, ctx.laneManager.Get(2).Aois(), int32(20))
+	if assert.Len(t, ctx.router.aois, 1) {
+		assert.Equal(t, int32(20), ctx.router.aois[0].Id)
+	}
+
+	// 新AOI可以作为出行目的地
+	p := newTestSleepingPerson(ctx, 1, ctx.aoiManager.Get(10))
+	p.pedestrian.walkingV = 10
+	p.schedule.Set([]*tripv2.Schedule{{
+		Trips: []*tripv2.Trip{{
+			Mode: tripv2.TripMode_TRIP_MODE_WALK_ONLY,
+			End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 20}},
+		}},
+		LoopCount: 1,
+	}}, 0)
+	m := newTestManager(p)
+	m.ctx = ctx
+	ctx.personManager = m
+	for i := 0; i < 20 && !(p.Status() == personv2.Status_STATUS_SLEEP && p.schedule.Empty()); i++ {
+		m.Update(ctx.clock.DT)
+		ctx.aoiManager.PrepareNode()
+		ctx.laneManager.Prepare()
+		ctx.aoiManager.Prepare()
+		m.PrepareNode()
+		m.Prepare()
+	}
+	assert.Equal(t, a, p.Aoi())
+	assert.Equal(t, int32(20), ctx.router.requests[0].End.AoiPosition.AoiId)
+}
+
+func TestStatisticsSnapshotEveryStep(t *testing.T) {
+	defer flag.Set("output.interval_steps", "1")
+	flag.Set("output.interval_steps", "3")
+	ctx := newTestContext(nil, nil)
+	m := newTestManager()
+	m.ctx = ctx
+	ctx.personManager = m
+
+	// 非输出步同样发布统计快照
+	ctx.clock.InternalStep = 1
+	assert.False(t, ctx.clock.OutputStep())
+	m.runtime.NumCompletedTrips = 5
+	m.Prepare()
+	assert.Equal(t, int32(5), m.snapshot.NumCompletedTrips)
+	ctx.clock.T = 60
+	assert.InDelta(t, 5, m.GetNetworkSummary().TripsPerMinute, 1e-9)
+}
+
+func TestPartitionUpdate(t *testing.T) {
+	defer flag.Set("person.partition_update", "false")
+	flag.Set("person.partition_update", "true")
+	_, m := newTestTrafficScene(4, 5)
+	groups := m.partitions()
+	assert.Len(t, groups, 4)
+	for _, ps := range groups {
+		assert.Len(t, ps, 5)
+		assert.True(t, slices.IsSortedFunc(ps, func(a, b *Person) int { return cmp.Compare(a.id, b.id) }))
+		for _, p := range ps {
+			assert.Equal(t, ps[0].snapshot.Lane, p.snapshot.Lane)
+		}
+	}
+
+	// 两次运行的最终位置完全一致
+	first := runTestTrafficScene(4, 5, 50)
+	second := runTestTrafficScene(4, 5, 50)
+	assert.Equal(t, first, second)
+	for _, s := range first {
+		assert.Greater(t, s, 100.)
+	}
+}
+
+func BenchmarkPersonManagerUpdate(b *testing.B) {
+	defer flag.Set("person.partition_update", "false")
+	for _, partition := range []string{"false", "true"} {
+		b.Run("partition="+partition, func(b *testing.B) {
+			flag.Set("person.partition_update", partition)
+			ctx, m := newTestTrafficScene(100, 50)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.PrepareNode()
+				ctx.laneManager.Prepare()
+				m.Prepare()
+				m.Update(.1)
+			}
+		})
+	}
+}
+
+func TestExcludeEmptySchedule(t *testing.T) {
+	defer flag.Set("person.exclude_empty_schedule", "false")
+	flag.Set("person.exclude_empty_schedule", "true")
+	ctx := newTestContext(
+		[]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)},
+		[]*mapv2.Aoi{newTestAoiPb(10, 1, 30), newTestAoiPb(11, 1, 80)},
+	)
+	pb := func(id int32, schedules ...*tripv2.Schedule) *personv2.Person {
+		return &personv2.Person{
+			Id: id,
+			VehicleAttribute: &personv2.VehicleAttribute{
+				Length: 5, Width: 2, MaxSpeed: 30,
+				MaxAcceleration: 3, UsualAcceleration: 2,
+				MaxBrakingAcceleration: -10, UsualBrakingAcceleration: -4.5,
+			},
+			Home:      &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 10}},
+			Schedules: schedules,
+		}
+	}
+	trip := func() *tripv2.Schedule {
+		return &tripv2.Schedule{
+			LoopCount: 1,
+			Trips: []*tripv2.Trip{{
+				Mode: tripv2.TripMode_TRIP_MODE_DRIVE_ONLY,
+				End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 11}},
+			}},
+		}
+	}
+	m := NewManager(ctx)
+	m.Init([]*personv2.Person{pb(1, trip()), pb(2), pb(3)}, &mapv2.Header{}, ctx.aoiManager, ctx.laneManager)
+	m.PrepareNode()
+
+	// 时刻表为空的人不在更新数组中，但仍可查询
+	assert.Equal(t, []int32{1}, lo.Map(m.persons.Data(), func(p *Person, _ int) int32 { return p.id }))
+	p2 := m.Get(2).(*Person)
+	assert.Equal(t, personv2.Status_STATUS_SLEEP, p2.snapshot.Status)
+	assert.Equal(t, ctx.aoiManager.Get(10), p2.snapshot.Aoi)
+	assert.True(t, p2.dormant.Load())
+
+	// 设置时刻表后重新加入更新数组
+	_, err := m.SetSchedule(context.Background(), connect.NewRequest(&personv2.SetScheduleRequest{
+		PersonId: 2, Schedules: []*tripv2.Schedule{trip()},
+	}))
+	assert.NoError(t, err)
+	assert.False(t, p2.dormant.Load())
+	m.PrepareNode()
+	assert.ElementsMatch(t, []int32{1, 2}, lo.Map(m.persons.Data(), func(p *Person, _ int) int32 { return p.id }))
+	m.Prepare()
+	assert.False(t, p2.schedule.Empty())
+
+	// 设置标签同样重新加入更新数组，标签在准备阶段生效
+	p3 := m.Get(3).(*Person)
+	assert.True(t, p3.dormant.Load())
+	assert.NoError(t, m.SetPersonLabel(3, "k", "v"))
+	assert.False(t, p3.dormant.Load())
+	m.PrepareNode()
+	m.Prepare()
+	v, ok, err := m.GetPersonLabel(3, "k")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "v", v)
+}
+
+func TestDepartureWindow(t *testing.T) {
+	defer flag.Set("person.departure_window", "0")
+	flag.Set("person.departure_window", "600")
+	ctx := newTestContext(
+		[]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)},
+		[]*mapv2.Aoi{newTestAoiPb(10, 1, 30), newTestAoiPb(11, 1, 80)},
+	)
+	pbs := make([]*personv2.Person, 100)
+	for i := range pbs {
+		departure, wait := 3600., 100.
+		pbs[i] = &personv2.Person{
+			Id: int32(i + 1),
+			VehicleAttribute: &personv2.VehicleAttribute{
+				Length: 5, Width: 2, MaxSpeed: 30,
+				MaxAcceleration: 3, UsualAcceleration: 2,
+				MaxBrakingAcceleration: -10, UsualBrakingAcceleration: -4.5,
+			},
+			Home: &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 10}},
+			Schedules: []*tripv2.Schedule{{
+				DepartureTime: &departure,
+				LoopCount:     1,
+				Trips: []*tripv2.Trip{
+					{Mode: tripv2.TripMode_TRIP_MODE_DRIVE_ONLY, End: &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 11}}},
+					{Mode: tripv2.TripMode_TRIP_MODE_DRIVE_ONLY, End: &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 10}}, WaitTime: &wait},
+				},
+			}, {
+				LoopCount: 1,
+				Trips: []*tripv2.Trip{
+					{Mode: tripv2.TripMode_TRIP_MODE_DRIVE_ONLY, End: &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 11}}, DepartureTime: proto.Float64(7200)},
+				},
+			}},
+		}
+	}
+	m := NewManager(ctx)
+	m.Init(pbs, &mapv2.Header{}, ctx.aoiManager, ctx.laneManager)
+
+	// 原本同时出发的人在[3600, 4200)内分散出发，之后的trip仍按等待时间相对出发
+	departures := lo.Map(pbs, func(pb *personv2.Person, _ int) float64 { return *pb.Schedules[0].DepartureTime })
+	for _, d := range departures {
+		assert.GreaterOrEqual(t, d, 3600.)
+		assert.Less(t, d, 4200.)
+	}
+	assert.Greater(t, lo.Max(departures)-lo.Min(departures), 400.)
+	assert.Greater(t, len(lo.Uniq(departures)), 90)
+	assert.Equal(t, 100., *pbs[0].Schedules[0].Trips[1].WaitTime)
+	assert.Nil(t, pbs[0].Schedules[0].Trips[1].DepartureTime)
+	// 之后指定的出发时间推迟相同的时长
+	for i, pb := range pbs {
+		assert.InDelta(t, departures[i]+3600, *pb.Schedules[1].Trips[0].DepartureTime, 1e-6)
+	}
+
+	// 使用每个人自己的随机数，结果可复现
+	again := NewManager(ctx)
+	for _, pb := range pbs {
+		pb.Schedules[0].DepartureTime = proto.Float64(3600)
+		pb.Schedules[1].Trips[0].DepartureTime = proto.Float64(7200)
+	}
+	again.Init(pbs, &mapv2.Header{}, ctx.aoiManager, ctx.laneManager)
+	assert.Equal(t, departures, lo.Map(pbs, func(pb *personv2.Person, _ int) float64 { return *pb.Schedules[0].DepartureTime }))
+}
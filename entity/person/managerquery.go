@@ -0,0 +1,76 @@
+package person
+
+import (
+	"container/heap"
+	"slices"
+)
+
+// personDistance 人员与查询点的距离
+type personDistance struct {
+	p  *Person
+	d2 float64 // 距离的平方
+}
+
+// less 按(距离, ID)的字典序比较，保证结果确定
+func (a personDistance) less(b personDistance) bool {
+	if a.d2 != b.d2 {
+		return a.d2 < b.d2
+	}
+	return a.p.ID() < b.p.ID()
+}
+
+// farthestHeap 以最远者为堆顶的最大堆，用于维护当前最近的k个人
+type farthestHeap []personDistance
+
+func (h farthestHeap) Len() int           { return len(h) }
+func (h farthestHeap) Less(i, j int) bool { return h[j].less(h[i]) }
+func (h farthestHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *farthestHeap) Push(x any)        { *h = append(*h, x.(personDistance)) }
+func (h *farthestHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// nearestPersons 获取距离给定点最近的k个person
+// 功能：依据快照中的XYZ坐标，按平面欧氏距离选出最近的k个人
+// 参数：x,y-查询点坐标，k-返回的人数
+// 返回：按距离由近到远排序的人员列表，距离相同时按ID升序
+// 算法说明：
+// 1. 维护大小为k的最大堆，堆顶为当前选中的最远者
+// 2. 遍历所有人，比堆顶更近的人替换堆顶
+// 3. 对最终的k个人排序后返回
+// 说明：时间复杂度O(n log k)，避免对全体人员排序
+func (m *PersonManager) nearestPersons(x, y float64, k int) []*Person {
+	if k <= 0 {
+		return nil
+	}
+	h := make(farthestHeap, 0, k)
+	for _, p := range m.persons.Data() {
+		xyz := p.XYZ()
+		dx, dy := xyz.X-x, xyz.Y-y
+		pd := personDistance{p: p, d2: dx*dx + dy*dy}
+		if len(h) < k {
+			heap.Push(&h, pd)
+		} else if pd.less(h[0]) {
+			h[0] = pd
+			heap.Fix(&h, 0)
+		}
+	}
+	slices.SortFunc(h, func(a, b personDistance) int {
+		if a.less(b) {
+			return -1
+		}
+		if b.less(a) {
+			return 1
+		}
+		return 0
+	})
+	res := make([]*Person, len(h))
+	for i, pd := range h {
+		res[i] = pd.p
+	}
+	return res
+}
@@ -0,0 +1,37 @@
+package person
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNearestPersons(t *testing.T) {
+	persons := []*Person{
+		newTestPerson(5, 3, 4),
+		newTestPerson(1, 0, 5),
+		newTestPerson(2, 1, 1),
+		newTestPerson(7, -1, -1),
+		newTestPerson(3, 10, 0),
+		newTestPerson(4, 0, 0),
+		newTestPerson(6, -4, 3),
+	}
+	m := newTestManager(persons...)
+	// 暴力排序作为参考
+	reference := slices.Clone(persons)
+	slices.SortFunc(reference, func(a, b *Person) int {
+		da := a.XYZ().X*a.XYZ().X + a.XYZ().Y*a.XYZ().Y
+		db := b.XYZ().X*b.XYZ().X + b.XYZ().Y*b.XYZ().Y
+		if da != db {
+			return cmp.Compare(da, db)
+		}
+		return cmp.Compare(a.ID(), b.ID())
+	})
+	for k := 0; k <= len(persons)+1; k++ {
+		got := m.nearestPersons(0, 0, k)
+		want := reference[:min(k, len(reference))]
+		assert.Equal(t, ids(want), ids(got), "k=%d", k)
+	}
+}
@@ -1,16 +1,27 @@
+// Package person 人的管理与模拟。
+//
+// personv2中暂无对应请求消息（或缺少所需字段）的功能以管理器上的导出方法形式提供，
+// 由外部服务直接调用，不经过RPC。
 package person
 
 import (
 	"context"
 	"errors"
+	"math"
 	"net/http"
+	"slices"
 
 	"connectrpc.com/connect"
 	"git.fiblab.net/general/common/v2/parallel"
 	"git.fiblab.net/sim/syncer/v3"
+	"github.com/samber/lo"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
 	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
 	"git.fiblab.net/sim/protos/v2/go/city/person/v2/personv2connect"
+	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
+	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
 )
 
 // Register 将Person管理器注册到Sidecar
@@ -71,6 +82,21 @@ func (m *PersonManager) AddPerson(
 	return connect.NewResponse(res), nil
 }
 
+// RemovePerson 删除person
+// 功能：从仿真中删除指定人员，解除其与车道、AOI的关联
+// 参数：id-人员ID
+// 返回：错误信息
+// 算法说明：
+// 1. 验证人员ID是否存在
+// 2. 检查人员是否在路口内（路口内不支持删除）
+// 3. 标记删除，在下一次update中解除关联，并在之后的PrepareNode中从管理器删除
+func (m *PersonManager) RemovePerson(id int32) error {
+	if err := m.remove(id); err != nil {
+		return connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	return nil
+}
+
 // SetSchedule 修改person的schedule 传入personid、目的地表
 // 功能：修改指定人员的行程安排
 // 参数：ctx-上下文，in-请求参数（包含人员ID和新的行程安排）
@@ -91,6 +117,7 @@ func (m *PersonManager) SetSchedule(
 		if !(p.runtime.Lane != nil && p.runtime.Lane.ParentJunction() != nil) {
 			// log.Infof("SetSchedule: %v, clock.T=%v", req, m.ctx.Clock().T)
 			p.SetSchedules(req.Schedules)
+			m.wake(p)
 		} else {
 			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("person in a junction dose support schedule setting"))
 		}
@@ -98,6 +125,152 @@ func (m *PersonManager) SetSchedule(
 	return connect.NewResponse(&personv2.SetScheduleResponse{}), nil
 }
 
+// AppendSchedule 在person的时刻表末尾追加schedule
+// 功能：与SetSchedule不同，不打断当前出行（包括位于路口内的人），当前时刻表完成后继续前往新的目的地
+// 参数：id-人员ID，schedules-追加的schedule
+// 返回：错误信息，人员不存在时返回错误
+// 说明：追加在下一次prepare后生效
+func (m *PersonManager) AppendSchedule(id int32, schedules []*tripv2.Schedule) error {
+	p, ok := m.data[id]
+	if !ok {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+	}
+	p.AppendSchedules(schedules)
+	m.wake(p)
+	return nil
+}
+
+// SetPersonMaxSpeed 设置person的车辆速度上限
+// 功能：覆盖车辆在控制器中使用的最大速度，用于强制减速等场景控制
+// 参数：id-人员ID，v-速度上限（米/秒），必须为正数
+// 返回：实际生效的速度上限，错误信息
+// 说明：上限不超过车辆属性中的最大速度，超过时等价于取消上限；
+// 实际目标速度仍取该上限与车道限速（含认知偏差）的较小值。
+func (m *PersonManager) SetPersonMaxSpeed(id int32, v float64) (float64, error) {
+	p, ok := m.data[id]
+	if !ok {
+		return 0, connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+	}
+	if v <= 0 {
+		return 0, connect.NewError(connect.CodeInvalidArgument, errors.New("max speed must be positive"))
+	}
+	return p.SetMaxSpeed(v), nil
+}
+
+// GetPersonMaxSpeed 获取person的车辆速度上限
+// 功能：返回车辆当前生效的最大速度（车辆最大速度与外部速度上限的较小值）
+// 参数：id-人员ID
+// 返回：速度上限（米/秒），错误信息
+func (m *PersonManager) GetPersonMaxSpeed(id int32) (float64, error) {
+	p, ok := m.data[id]
+	if !ok {
+		return 0, connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+	}
+	return p.MaxSpeed(), nil
+}
+
+// SetPersonLabel 设置person的标签
+// 功能：新增或修改人的标签，用于动态实验中的人群标记（如标记经历过拥堵的人）
+// 参数：id-人员ID，key-标签键，value-标签值
+// 返回：错误信息
+// 说明：修改写入buffer，在下一步的准备阶段生效，同一步内的行为与查询不受影响；
+// 导航偏好（route_preference）、导航回退（route_fallback）与避开收费道路（avoid_tolls）在下一次导航时生效，
+// 车辆类别（vehicle_class）影响导航与变道，但其加速度与最大速度修正只在创建车辆时读取，修改后不影响已有的跟驰参数。
+func (m *PersonManager) SetPersonLabel(id int32, key, value string) error {
+	p, ok := m.data[id]
+	if !ok {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+	}
+	p.SetLabel(key, value)
+	// 未加入更新数组的人需要重新加入，才能在准备阶段应用修改
+	m.wake(p)
+	return nil
+}
+
+// DeletePersonLabel 删除person的标签
+// 功能：删除人的标签，标签不存在时无操作
+// 参数：id-人员ID，key-标签键
+// 返回：错误信息
+// 说明：与SetPersonLabel相同，在下一步的准备阶段生效
+func (m *PersonManager) DeletePersonLabel(id int32, key string) error {
+	p, ok := m.data[id]
+	if !ok {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+	}
+	p.DeleteLabel(key)
+	m.wake(p)
+	return nil
+}
+
+// GetPersonLabel 获取person的标签
+// 功能：返回人的指定标签值
+// 参数：id-人员ID，key-标签键
+// 返回：标签值，标签是否存在，错误信息
+func (m *PersonManager) GetPersonLabel(id int32, key string) (string, bool, error) {
+	p, ok := m.data[id]
+	if !ok {
+		return "", false, connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+	}
+	value, ok := p.GetLabel(key)
+	return value, ok, nil
+}
+
+// GetPersonEnergy 获取person的累计能耗与排放
+// 功能：返回人开车时按能耗模型（person.fuel_*）累计的燃油消耗与CO2排放，步行不计
+// 参数：id-人员ID
+// 返回：累计能耗与排放（截至上一步），错误信息
+func (m *PersonManager) GetPersonEnergy(id int32) (PersonEnergy, error) {
+	p, ok := m.data[id]
+	if !ok {
+		return PersonEnergy{}, connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+	}
+	return p.energySnapshot, nil
+}
+
+// GetPersonSafetyMetrics 获取person的驾驶安全指标
+// 功能：返回人开车时加速度超过veh.harsh_accel_threshold的急加速次数与减速度超过veh.harsh_brake_threshold的急减速次数
+// 参数：id-人员ID
+// 返回：累计安全指标（截至上一步，按步计数，一步内多个子步只计一次），错误信息
+func (m *PersonManager) GetPersonSafetyMetrics(id int32) (PersonSafetyMetrics, error) {
+	p, ok := m.data[id]
+	if !ok {
+		return PersonSafetyMetrics{}, connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+	}
+	return p.safetySnapshot, nil
+}
+
+// GetPersonLaneChanges 获取person的变道统计
+// 功能：按强制变道（为驶入导航所需的车道）与自由变道分别返回人发起与完成的变道次数，
+// 发起但未完成的变道（被撤销或驶出车道时中断）可由两者之差得到
+// 参数：id-人员ID
+// 返回：累计变道次数（截至上一步），错误信息
+func (m *PersonManager) GetPersonLaneChanges(id int32) (PersonLaneChanges, error) {
+	p, ok := m.data[id]
+	if !ok {
+		return PersonLaneChanges{}, connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+	}
+	return p.laneChangesSnapshot, nil
+}
+
+// GetPersonRoute 获取person当前导航的剩余路径
+// 功能：开车时返回剩余的道路ID序列（含当前道路），步行时返回剩余的步行车道段（含当前车道），供外部可视化绘制规划路径
+// 参数：id-人员ID
+// 返回：剩余路径（驾车或步行journey），不在开车或步行状态时为nil，错误信息
+func (m *PersonManager) GetPersonRoute(id int32) (*routingv2.Journey, error) {
+	p, ok := m.data[id]
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+	}
+	switch p.snapshot.Status {
+	case personv2.Status_STATUS_DRIVING:
+		return p.multiModalRoute.VehicleRoute.ToPb(), nil
+	case personv2.Status_STATUS_WALKING:
+		return p.multiModalRoute.PedestrianRoute.ToPb(), nil
+	default:
+		return nil, nil
+	}
+}
+
 // GetPersons 获取多个person信息
 // 功能：批量获取人员信息，支持ID筛选和状态排除
 // 参数：ctx-上下文，in-请求参数（包含人员ID列表和排除状态）
@@ -136,6 +309,63 @@ func (m *PersonManager) GetPersons(ctx context.Context, in *connect.Request[pers
 	return connect.NewResponse(res), nil
 }
 
+// GetPersonsByLabels 按标签批量获取person信息
+// 功能：在GetPersons的状态排除之外按标签筛选人员，用于按人群（如occupation=student）进行分析
+// 参数：labels-标签筛选条件（所有键值对同时满足，为空表示不筛选），excludeStatuses-排除的状态，returnBase-是否返回人员的基础数据
+// 返回：满足条件的人员信息列表
+// 说明：personv2.GetPersonsRequest中暂无标签筛选字段
+func (m *PersonManager) GetPersonsByLabels(labels map[string]string, excludeStatuses []personv2.Status, returnBase bool) []*personv2.PersonRuntime {
+	persons := m.personsByLabels(labels, excludeStatuses)
+	return lo.Map(persons, func(p *Person, _ int) *personv2.PersonRuntime {
+		return p.ToPersonRuntimePb(returnBase)
+	})
+}
+
+// personsByLabels 并行筛选标签满足全部条件且状态未被排除的person
+func (m *PersonManager) personsByLabels(labels map[string]string, excludeStatuses []personv2.Status) []*Person {
+	return parallel.GoMapFilter(m.persons.Data(), func(p *Person) (*Person, bool) {
+		if slices.Contains(excludeStatuses, p.Status()) {
+			return nil, false
+		}
+		return p, p.matchLabels(labels)
+	})
+}
+
+// GetPersonsInBox 获取位于矩形区域内的person信息
+// 功能：按空间范围批量获取人员信息，用于局部区域的观测
+// 参数：minX,minY,maxX,maxY-矩形区域的边界（含边界），returnBase-是否返回人员的基础数据
+// 返回：区域内人员的信息列表
+// 说明：依据快照中的XYZ坐标进行判断
+func (m *PersonManager) GetPersonsInBox(minX, minY, maxX, maxY float64, returnBase bool) []*personv2.PersonRuntime {
+	persons := m.personsInBox(minX, minY, maxX, maxY)
+	return lo.Map(persons, func(p *Person, _ int) *personv2.PersonRuntime {
+		return p.ToPersonRuntimePb(returnBase)
+	})
+}
+
+// GetNearestPersons 获取距离给定点最近的k个person信息
+// 功能：按空间距离获取人员信息，用于智能体的周边感知
+// 参数：x,y-查询点坐标，k-返回的人数，returnBase-是否返回人员的基础数据
+// 返回：按距离由近到远排序的人员信息列表，距离相同时按ID升序
+// 说明：依据快照中的XYZ坐标计算平面欧氏距离
+func (m *PersonManager) GetNearestPersons(x, y float64, k int, returnBase bool) []*personv2.PersonRuntime {
+	persons := m.nearestPersons(x, y, k)
+	return lo.Map(persons, func(p *Person, _ int) *personv2.PersonRuntime {
+		return p.ToPersonRuntimePb(returnBase)
+	})
+}
+
+// personsInBox 并行筛选快照位置位于矩形区域内的person
+func (m *PersonManager) personsInBox(minX, minY, maxX, maxY float64) []*Person {
+	return parallel.GoMapFilter(m.persons.Data(), func(p *Person) (*Person, bool) {
+		xyz := p.XYZ()
+		if xyz.X < minX || xyz.X > maxX || xyz.Y < minY || xyz.Y > maxY {
+			return nil, false
+		}
+		return p, true
+	})
+}
+
 // ResetPersonPosition 重置person位置
 // 功能：重置指定人员的位置信息
 // 参数：ctx-上下文，in-请求参数（包含人员ID和新位置）
@@ -148,39 +378,71 @@ func (m *PersonManager) GetPersons(ctx context.Context, in *connect.Request[pers
 // 说明：支持动态调整人员位置，仅适用于睡眠状态的人员
 func (m *PersonManager) ResetPersonPosition(ctx context.Context, in *connect.Request[personv2.ResetPersonPositionRequest]) (*connect.Response[personv2.ResetPersonPositionResponse], error) {
 	req := in.Msg
-	p, ok := m.data[req.PersonId]
+	if err := m.resetPosition(req.PersonId, req.Position, false); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	return connect.NewResponse(&personv2.ResetPersonPositionResponse{}), nil
+}
+
+// ForceResetPersonPosition 强制重置person位置
+// 功能：重置任意状态（睡眠、行驶、步行）人员的位置信息
+// 参数：id-人员ID，pos-新位置
+// 返回：错误信息
+// 说明：非睡眠状态的人员会先从当前车道移除并清空导航，再以睡眠状态重置到新位置，
+// 当前trip不会被跳过，之后将从新位置重新发起导航；路口内的人员不支持强制重置。
+// personv2中暂无force字段
+func (m *PersonManager) ForceResetPersonPosition(id int32, pos *geov2.Position) error {
+	if err := m.resetPosition(id, pos, true); err != nil {
+		return connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	return nil
+}
+
+// resetPosition 检查并设置person的重置位置
+// 功能：ResetPersonPosition与ForceResetPersonPosition的公共逻辑
+// 参数：id-人员ID，pos-新位置，force-是否允许重置非睡眠状态的人员
+// 返回：错误信息
+func (m *PersonManager) resetPosition(id int32, pos *geov2.Position, force bool) error {
+	p, ok := m.data[id]
 	if !ok {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("person id does not exist"))
+		return errors.New("person id does not exist")
+	}
+	if pos == nil {
+		return errors.New("no position")
 	}
-	pos := req.Position
 	if pos.AoiPosition != nil && pos.LanePosition != nil {
 		// 同时存在两个逻辑坐标
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("both aoi and lane position exist"))
+		return errors.New("both aoi and lane position exist")
 	}
 	if pos.AoiPosition == nil && pos.LanePosition == nil {
 		// 不存在逻辑坐标
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("no position"))
+		return errors.New("no position")
 	}
 	if pos.AoiPosition != nil {
-		_, err := m.ctx.AoiManager().GetOrError(pos.AoiPosition.AoiId)
-		if err != nil {
-			return nil, connect.NewError(connect.CodeInvalidArgument, err)
+		if _, err := m.ctx.AoiManager().GetOrError(pos.AoiPosition.AoiId); err != nil {
+			return err
 		}
 	}
 	if pos.LanePosition != nil {
-		_, err := m.ctx.LaneManager().GetOrError(pos.LanePosition.LaneId)
-		if err != nil {
-			return nil, connect.NewError(connect.CodeInvalidArgument, err)
+		if _, err := m.ctx.LaneManager().GetOrError(pos.LanePosition.LaneId); err != nil {
+			return err
 		}
 	}
 	if pos.LonglatPosition != nil {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("longlat position is not supported"))
+		return errors.New("longlat position is not supported")
 	}
-	if p.Status() != personv2.Status_STATUS_SLEEP {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("person is not sleeping at aoi or lane, unsupported"))
+	if force {
+		if p.runtime.Lane != nil && p.runtime.Lane.InJunction() {
+			return errors.New("person in a junction does not support force reset")
+		}
+	} else if p.Status() != personv2.Status_STATUS_SLEEP {
+		return errors.New("person is not sleeping at aoi or lane, unsupported")
 	}
 	p.resetPos = pos
-	return connect.NewResponse(&personv2.ResetPersonPositionResponse{}), nil
+	p.resetForce = force
+	// 未加入更新数组的人需要重新加入，才能在更新阶段应用重置
+	m.wake(p)
+	return nil
 }
 
 // GetGlobalStatistics 获取全局统计信息
@@ -189,7 +451,8 @@ func (m *PersonManager) ResetPersonPosition(ctx context.Context, in *connect.Req
 // 返回：全局统计信息响应，错误信息
 // 算法说明：
 // 1. 返回全局统计信息
-// 说明：提供全局统计信息的查询接口
+// 说明：提供全局统计信息的查询接口，统计不含预热期间（stats.warmup_seconds）的数据；
+// 响应中暂无预热状态字段，预热是否完成通过WarmupComplete查询
 func (m *PersonManager) GetGlobalStatistics(ctx context.Context, in *connect.Request[personv2.GetGlobalStatisticsRequest]) (*connect.Response[personv2.GetGlobalStatisticsResponse], error) {
 	res := &personv2.GetGlobalStatisticsResponse{
 		NumCompletedTrips:          m.snapshot.NumCompletedTrips,
@@ -198,3 +461,48 @@ func (m *PersonManager) GetGlobalStatistics(ctx context.Context, in *connect.Req
 	}
 	return connect.NewResponse(res), nil
 }
+
+// NetworkSummary 路网运行概况
+type NetworkSummary struct {
+	MeanSpeed      float64 // 所有有车车道的车道平均车速的平均值（米/秒）
+	NumVehicles    int32   // 在途车辆数
+	NumPedestrians int32   // 在途行人数
+	TripsPerMinute float64 // 平均每分钟完成的行程数（不含预热期间）
+}
+
+// GetNetworkSummary 获取路网运行概况
+// 功能：一次调用返回路网平均车速、在途车辆与行人数、行程完成速率，供看板展示
+// 返回：路网运行概况
+// 说明：所有数据均读取快照，不受同时进行的update影响
+func (m *PersonManager) GetNetworkSummary() NetworkSummary {
+	var summary NetworkSummary
+	laneSpeeds := make(map[entity.ILane][]float64)
+	for _, p := range m.persons.Data() {
+		switch p.snapshot.Status {
+		case personv2.Status_STATUS_DRIVING:
+			summary.NumVehicles++
+			laneSpeeds[p.snapshot.Lane] = append(laneSpeeds[p.snapshot.Lane], p.snapshot.V)
+		case personv2.Status_STATUS_WALKING:
+			summary.NumPedestrians++
+		}
+	}
+	if len(laneSpeeds) > 0 {
+		for _, vs := range laneSpeeds {
+			summary.MeanSpeed += lo.Mean(vs)
+		}
+		summary.MeanSpeed /= float64(len(laneSpeeds))
+	}
+	clock := m.ctx.Clock()
+	start := math.Max(float64(clock.START_STEP)*clock.DT, *statsWarmupSeconds)
+	if elapsed := clock.T - start; elapsed > 0 {
+		summary.TripsPerMinute = float64(m.snapshot.NumCompletedTrips) / elapsed * 60
+	}
+	return summary
+}
+
+// WarmupComplete 查询仿真预热是否完成
+// 功能：返回全局统计是否已开始累计（仿真时间超过stats.warmup_seconds）
+// 返回：true表示预热已完成
+func (m *PersonManager) WarmupComplete() bool {
+	return m.warmupComplete()
+}
@@ -0,0 +1,317 @@
+package person
+
+import (
+	"math"
+	"testing"
+
+	"git.fiblab.net/general/common/v2/mathutil"
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
+	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/junction"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/road"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
+)
+
+func TestPersonsInBox(t *testing.T) {
+	m := newTestManager(
+		newTestPerson(1, 0, 0),
+		newTestPerson(2, 10, 10),
+		newTestPerson(3, 20, 5),
+		newTestPerson(4, -5, 15),
+		newTestPerson(5, 10, 20),
+	)
+	assert.ElementsMatch(t, []int32{1, 2}, ids(m.personsInBox(0, 0, 15, 15)))
+	assert.Empty(t, m.personsInBox(100, 100, 200, 200))
+}
+
+func TestPersonsByLabels(t *testing.T) {
+	labeled := func(id int32, labels map[string]string) *Person {
+		p := newTestPerson(id, 0, 0)
+		p.labels = labels
+		return p
+	}
+	m := newTestManager(
+		labeled(1, map[string]string{"occupation": "student", "gender": "female"}),
+		labeled(2, map[string]string{"occupation": "student", "gender": "male"}),
+		labeled(3, map[string]string{"occupation": "worker", "gender": "female"}),
+		labeled(4, nil),
+	)
+	m.data[2].snapshot.Status = personv2.Status_STATUS_DRIVING
+	assert.ElementsMatch(t, []int32{1, 2}, ids(m.personsByLabels(map[string]string{"occupation": "student"}, nil)))
+	// 多个条件同时满足
+	assert.ElementsMatch(t, []int32{1}, ids(m.personsByLabels(map[string]string{"occupation": "student", "gender": "female"}, nil)))
+	// 与状态排除组合
+	assert.ElementsMatch(t, []int32{1}, ids(m.personsByLabels(map[string]string{"occupation": "student"}, []personv2.Status{personv2.Status_STATUS_DRIVING})))
+	assert.Empty(t, m.personsByLabels(map[string]string{"occupation": "teacher"}, nil))
+	// 无条件时返回所有人
+	assert.ElementsMatch(t, []int32{1, 2, 3, 4}, ids(m.personsByLabels(nil, nil)))
+}
+
+func TestSetPersonLabel(t *testing.T) {
+	p := newTestPerson(1, 0, 0)
+	p.labels = map[string]string{"occupation": "worker", "gender": "male"}
+	m := newTestManager(p, newTestPerson(2, 0, 0))
+
+	assert.NoError(t, m.SetPersonLabel(2, "congested", "true"))
+	assert.NoError(t, m.SetPersonLabel(1, "occupation", "student"))
+	assert.NoError(t, m.DeletePersonLabel(1, "gender"))
+	assert.Error(t, m.SetPersonLabel(3, "congested", "true"))
+	// 修改在准备阶段之前不生效
+	_, ok, err := m.GetPersonLabel(2, "congested")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Empty(t, m.personsByLabels(map[string]string{"congested": "true"}, nil))
+
+	for _, p := range m.persons.Data() {
+		p.applyLabels()
+	}
+	value, ok, err := m.GetPersonLabel(2, "congested")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "true", value)
+	value, _ = p.GetLabel("occupation")
+	assert.Equal(t, "student", value)
+	_, ok = p.GetLabel("gender")
+	assert.False(t, ok)
+	assert.ElementsMatch(t, []int32{2}, ids(m.personsByLabels(map[string]string{"congested": "true"}, nil)))
+	assert.ElementsMatch(t, []int32{1}, ids(m.personsByLabels(map[string]string{"occupation": "student"}, nil)))
+	_, _, err = m.GetPersonLabel(3, "congested")
+	assert.Error(t, err)
+}
+
+func TestRemoveDrivingPerson(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)}, nil)
+	l := ctx.laneManager.Get(1)
+	p := newTestDrivingPerson(ctx, 1, l, 10)
+	ctx.laneManager.Prepare()
+	m := newTestManager(p)
+	m.ctx = ctx
+	assert.Equal(t, 1, l.Vehicles().Len())
+
+	assert.NoError(t, m.remove(1))
+	assert.Error(t, m.remove(2))
+	m.Update(ctx.clock.DT)
+	ctx.laneManager.Prepare()
+	m.PrepareNode()
+
+	assert.Equal(t, 0, l.Vehicles().Len())
+	assert.Equal(t, 0, m.persons.Len())
+	_, err := m.GetOrError(1)
+	assert.Error(t, err)
+}
+
+func TestForceResetDrivingPerson(t *testing.T) {
+	ctx := newTestContext(
+		[]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)},
+		[]*mapv2.Aoi{newTestAoiPb(10, 1, 30), newTestAoiPb(20, 1, 80)},
+	)
+	l := ctx.laneManager.Get(1)
+	p := newTestDrivingPerson(ctx, 1, l, 10)
+	p.schedule.Set([]*tripv2.Schedule{{
+		Trips: []*tripv2.Trip{{
+			Mode: tripv2.TripMode_TRIP_MODE_DRIVE_ONLY,
+			End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 20}},
+		}},
+		LoopCount: 1,
+	}}, 0)
+	ctx.laneManager.Prepare()
+	m := newTestManager(p)
+	m.ctx = ctx
+	ctx.personManager = m
+	target := &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 10}}
+
+	// 非强制重置不允许作用于行驶中的人
+	assert.Error(t, m.resetPosition(1, target, false))
+	assert.NoError(t, m.ForceResetPersonPosition(1, target))
+	m.Update(ctx.clock.DT)
+	ctx.laneManager.Prepare()
+	ctx.aoiManager.Prepare()
+	m.PrepareNode()
+	m.Prepare()
+
+	assert.Equal(t, 0, l.Vehicles().Len())
+	assert.Equal(t, personv2.Status_STATUS_SLEEP, p.Status())
+	assert.Equal(t, int32(10), p.Aoi().ID())
+	assert.Empty(t, ctx.router.requests)
+
+	// 之后的出行从新位置发起导航
+	m.Update(ctx.clock.DT)
+	if assert.Len(t, ctx.router.requests, 1) {
+		assert.Equal(t, int32(10), ctx.router.requests[0].Start.AoiPosition.AoiId)
+	}
+}
+
+func TestSetPersonMaxSpeed(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)}, nil)
+	p := newTestDrivingPerson(ctx, 1, ctx.laneManager.Get(1), 10)
+	p.vehicleAttr = &personv2.VehicleAttribute{
+		Length:                   5,
+		MaxSpeed:                 30,
+		MaxAcceleration:          3,
+		UsualBrakingAcceleration: -4.5,
+		MaxBrakingAcceleration:   -10,
+		MinGap:                   1,
+		Headway:                  1.5,
+	}
+	p.generator = randengine.New(1)
+	p.vehicle.controller = newController(p)
+	m := newTestManager(p)
+
+	// 无前车、车道限速足够高时的稳态速度
+	steadyV := func() float64 {
+		c := p.vehicle.controller
+		c.v, c.dt = 0, 1
+		for i := 0; i < 1000; i++ {
+			c.v = math.Max(0, c.v+c.selfFollow(0, mathutil.INF, 100)*c.dt)
+		}
+		return c.v
+	}
+	assert.InDelta(t, 30, steadyV(), 1)
+
+	_, err := m.SetPersonMaxSpeed(1, 0)
+	assert.Error(t, err)
+	speedCap, err := m.SetPersonMaxSpeed(1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 10., speedCap)
+	assert.InDelta(t, 10, steadyV(), .5)
+
+	// 上限不超过车辆最大速度
+	speedCap, _ = m.SetPersonMaxSpeed(1, 50)
+	assert.Equal(t, 30., speedCap)
+	v, err := m.GetPersonMaxSpeed(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 30., v)
+}
+
+func TestNetworkSummary(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{
+		newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+		newTestLanePb(2, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+	}, nil)
+	l1, l2 := ctx.laneManager.Get(1), ctx.laneManager.Get(2)
+	persons := []*Person{
+		newTestDrivingPerson(ctx, 1, l1, 10),
+		newTestDrivingPerson(ctx, 2, l1, 50),
+		newTestDrivingPerson(ctx, 3, l2, 10),
+		newTestPerson(4, 0, 0),
+		newTestPerson(5, 0, 0),
+	}
+	persons[0].snapshot.V, persons[1].snapshot.V, persons[2].snapshot.V = 10, 20, 4
+	persons[3].snapshot.Status = personv2.Status_STATUS_WALKING
+	persons[4].snapshot.Status = personv2.Status_STATUS_SLEEP
+	m := newTestManager(persons...)
+	m.ctx = ctx
+	m.snapshot.NumCompletedTrips = 30
+	// 未写入快照的运行时数据不影响结果
+	persons[0].runtime.V = 100
+	m.runtime.NumCompletedTrips = 100
+
+	// 车道1平均15m/s，车道2平均4m/s
+	summary := m.GetNetworkSummary()
+	assert.InDelta(t, 9.5, summary.MeanSpeed, 1e-9)
+	assert.Equal(t, int32(3), summary.NumVehicles)
+	assert.Equal(t, int32(1), summary.NumPedestrians)
+	assert.Zero(t, summary.TripsPerMinute)
+
+	ctx.clock.T = 600
+	assert.InDelta(t, 3, m.GetNetworkSummary().TripsPerMinute, 1e-9)
+}
+
+func TestAppendSchedule(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)}, nil)
+	p := newTestDrivingPerson(ctx, 1, ctx.laneManager.Get(1), 10)
+	m := newTestManager(p)
+	m.ctx = ctx
+	trip := func(aoiID int32) []*tripv2.Schedule {
+		return []*tripv2.Schedule{{
+			LoopCount: 1,
+			Trips:     []*tripv2.Trip{{End: &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: aoiID}}}},
+		}}
+	}
+	aoiID := func() int32 { return p.schedule.GetTrip().End.AoiPosition.AoiId }
+	p.schedule.Set(trip(11), 0)
+	p.multiModalRoute.VehicleRoute.End = entity.RoutePosition{Lane: ctx.laneManager.Get(1), S: 90}
+
+	// 行驶中追加行程，当前行程与导航保持不变
+	assert.Error(t, m.AppendSchedule(2, trip(12)))
+	assert.NoError(t, m.AppendSchedule(1, trip(12)))
+	assert.False(t, p.idle())
+	p.prepare()
+	assert.Equal(t, personv2.Status_STATUS_DRIVING, p.runtime.Status)
+	assert.Equal(t, 90., p.multiModalRoute.VehicleRoute.End.S)
+	assert.Equal(t, int32(11), aoiID())
+	// 完成当前行程后前往追加的目的地
+	assert.True(t, p.schedule.CompleteTrip(10))
+	assert.Equal(t, int32(12), aoiID())
+	assert.False(t, p.schedule.CompleteTrip(20))
+
+	// 时刻表为空时追加等同于设置
+	assert.NoError(t, m.AppendSchedule(1, trip(13)))
+	p.prepare()
+	assert.Equal(t, int32(13), aoiID())
+}
+
+func TestGetPersonRoute(t *testing.T) {
+	// 道路1（车道1）-> 路口100（车道3）-> 道路2（车道2）
+	l1 := newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)
+	l2 := newTestLanePb(2, mapv2.LaneType_LANE_TYPE_DRIVING, 100)
+	l3 := newTestLanePb(3, mapv2.LaneType_LANE_TYPE_DRIVING, 10)
+	l1.ParentId, l2.ParentId, l3.ParentId = 1, 2, 100
+	l1.Successors = []*mapv2.LaneConnection{{Id: 3, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD}}
+	l3.Predecessors = []*mapv2.LaneConnection{{Id: 1, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL}}
+	l3.Successors = []*mapv2.LaneConnection{{Id: 2, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD}}
+	l2.Predecessors = []*mapv2.LaneConnection{{Id: 3, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL}}
+	ctx := newTestContext([]*mapv2.Lane{l1, l2, l3}, nil)
+	rm := road.NewManager(ctx)
+	rm.Init([]*mapv2.Road{{Id: 1, LaneIds: []int32{1}}, {Id: 2, LaneIds: []int32{2}}}, ctx.laneManager)
+	jm := junction.NewManager(ctx)
+	jm.Init([]*mapv2.Junction{{
+		Id:                100,
+		LaneIds:           []int32{3},
+		DrivingLaneGroups: []*mapv2.JunctionLaneGroup{{InRoadId: 1, OutRoadId: 2, LaneIds: []int32{3}}},
+	}}, ctx.laneManager, rm)
+	rm.InitAfterJunction(jm)
+	ctx.roadManager = rm
+
+	start := ctx.laneManager.Get(1)
+	c := newTestController(ctx, start, 50)
+	p := c.self
+	p.vehicle.controller = c
+	p.runtime.V, p.snapshot.V = 10, 10
+	p.multiModalRoute.VehicleRoute.ProcessInputJourney(&routingv2.Journey{
+		Type:    routingv2.JourneyType_JOURNEY_TYPE_DRIVING,
+		Driving: &routingv2.DrivingJourneyBody{RoadIds: []int32{1, 2}},
+	}, entity.RoutePosition{Lane: start, S: 50}, entity.RoutePosition{Lane: ctx.laneManager.Get(2), S: 80})
+	m := newTestManager(p)
+	m.ctx = ctx
+
+	journey, err := m.GetPersonRoute(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{1, 2}, journey.Driving.RoadIds)
+
+	// 驶入道路2后剩余路径只包含道路2
+	for i := 0; i < 20 && p.runtime.Lane.ParentRoad() != rm.Get(2); i++ {
+		m.PrepareNode()
+		ctx.laneManager.Prepare()
+		m.Prepare()
+		p.updateVehicle(1)
+	}
+	m.Prepare()
+	journey, err = m.GetPersonRoute(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{2}, journey.Driving.RoadIds)
+
+	// 睡眠中的人没有路径
+	sleeping := newTestPerson(2, 0, 0)
+	sleeping.snapshot.Status = personv2.Status_STATUS_SLEEP
+	journey, err = newTestManager(sleeping).GetPersonRoute(2)
+	assert.NoError(t, err)
+	assert.Nil(t, journey)
+	_, err = m.GetPersonRoute(3)
+	assert.Error(t, err)
+}
@@ -1,6 +1,9 @@
 package person
 
 import (
+	"flag"
+	"math"
+
 	"github.com/samber/lo"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 )
@@ -12,6 +15,15 @@ const (
 	minBikeV       = 1.0  // 最小骑行速度（米/秒）
 	maxVNoise      = .5   // 速度随机扰动最大值（米/秒）
 	shouldNextBias = 1    // 在实际更新位置时相对于orca计算值的增加量
+
+	crowdJamDensity = 5.4   // 行人拥挤模型中的阻塞密度（人/平方米）
+	crowdGamma      = 1.913 // 行人拥挤模型的形状参数（人/平方米）
+	minCrowdFactor  = .1    // 拥挤时步行速度与自由步行速度之比的下限
+)
+
+var (
+	crowdDensity   = flag.Bool("person.crowd_density", false, "是否根据人行道上的行人密度降低步行速度（Weidmann基本图）")
+	redSpeedFactor = flag.Float64("ped.red_speed_factor", 2, "行人在红灯的路口人行道上加速通过时的速度倍数，必须不小于1（1表示不加速）")
 )
 
 // pedestrian 行人实体数据结构
@@ -26,6 +38,19 @@ type pedestrian struct {
 	node *entity.PedestrianNode // 行人在车道链表中的节点
 }
 
+// walkingSpeed 计算行人在车道上的步行速度
+// 功能：在自由步行速度的基础上考虑人行道的拥挤程度，在禁止通行（红灯）的路口人行道上按ped.red_speed_factor加速通过
+func (p *Person) walkingSpeed(lane entity.ILane) float64 {
+	v := p.pedestrian.walkingV
+	if *crowdDensity {
+		v *= crowdFactor(lane)
+	}
+	if lane.IsNoEntry() {
+		v *= *redSpeedFactor // 红灯，赶快走
+	}
+	return v
+}
+
 // updatePedestrian 更新行人状态
 // 功能：执行行人的主要更新逻辑，包括ORCA计算、位置更新、距离统计等
 // 参数：dt-时间步长，pedControlChan-行人控制通道
@@ -41,10 +66,7 @@ func (p *Person) updatePedestrian(dt float64) (isEnd bool) {
 	seg := p.multiModalRoute.PedestrianRoute.Current()
 
 	s := p.S()
-	v := p.pedestrian.walkingV
-	if lane.IsNoEntry() {
-		v *= 2 // 红灯，赶快走
-	}
+	v := p.walkingSpeed(lane)
 	ds := v * dt
 
 	// 将所有新增量加到s上
@@ -108,7 +130,12 @@ func (p *Person) updatePedestrian(dt float64) (isEnd bool) {
 		return
 	}
 
-	// 检测是否发生和车辆的碰撞，如果发生则撤销这次移动
+	// 检测是否发生和车辆的碰撞，如果发生则撤销这次移动，在冲突点前等待
+	// 只检查在同一车道内的移动（人行横道通常为独立车道，行人进入后的首次移动不会越过等待位置）
+	if *pedestrianYield && seg.Lane == p.snapshot.Lane && !crossingClear(seg.Lane, p.snapshot.S, s) {
+		p.runtime.V = 0
+		return
+	}
 	xyz := seg.Lane.GetPositionByS(s)
 
 	p.runtime.IsForward = seg.IsForward()
@@ -139,3 +166,21 @@ func newPedestrianNode(key float64, value entity.IPerson) *entity.PedestrianNode
 func (p *Person) IsForward() bool {
 	return p.snapshot.IsForward
 }
+
+// crowdFactor 计算行人拥挤时的步行速度折减系数
+// 功能：按车道上的行人密度（行人数/车道面积）使用Weidmann基本图计算步行速度与自由步行速度之比
+// 参数：lane-行人所在车道
+// 返回：速度折减系数，范围为[minCrowdFactor, 1]
+// 算法说明：v/v0 = 1 - exp(-γ(1/ρ - 1/ρmax))，密度较低时接近1，接近阻塞密度时趋于0
+func crowdFactor(lane entity.ILane) float64 {
+	area := lane.Length() * lane.Width()
+	n := lane.Pedestrians().Len()
+	if area <= 0 || n == 0 {
+		return 1
+	}
+	density := float64(n) / area
+	if density >= crowdJamDensity {
+		return minCrowdFactor
+	}
+	return math.Max(1-math.Exp(-crowdGamma*(1/density-1/crowdJamDensity)), minCrowdFactor)
+}
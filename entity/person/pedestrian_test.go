@@ -0,0 +1,58 @@
+package person
+
+import (
+	"flag"
+	"testing"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+)
+
+func TestCrowdDensity(t *testing.T) {
+	ctx := newTestContext([]*mapv2.Lane{
+		newTestLanePb(1, mapv2.LaneType_LANE_TYPE_WALKING, 10),
+		newTestLanePb(2, mapv2.LaneType_LANE_TYPE_WALKING, 10),
+	}, nil)
+	empty, crowded := ctx.laneManager.Get(1), ctx.laneManager.Get(2)
+	// 车道面积32平方米，行人较少时保持自由步行速度
+	empty.AddPedestrian(newPedestrianNode(5, newTestPerson(1, 0, 0)))
+	for i := int32(0); i < 100; i++ {
+		crowded.AddPedestrian(newPedestrianNode(float64(i)/10, newTestPerson(i+2, 0, 0)))
+	}
+	ctx.laneManager.Prepare()
+	assert.Greater(t, crowdFactor(empty), .99)
+	assert.Less(t, crowdFactor(crowded), .5)
+	assert.GreaterOrEqual(t, crowdFactor(crowded), minCrowdFactor)
+	for i := int32(0); i < 100; i++ {
+		crowded.AddPedestrian(newPedestrianNode(float64(i)/10, newTestPerson(i+200, 0, 0)))
+	}
+	ctx.laneManager.Prepare()
+	assert.Equal(t, minCrowdFactor, crowdFactor(crowded))
+}
+
+// 测试用人行道，仅实现禁止通行判定
+type testNoEntryLane struct {
+	entity.ILane
+	noEntry bool
+}
+
+func (l *testNoEntryLane) IsNoEntry() bool { return l.noEntry }
+
+func TestRedSpeedFactor(t *testing.T) {
+	defer flag.Set("ped.red_speed_factor", "2")
+	p := newTestPerson(1, 0, 0)
+	p.pedestrian = &pedestrian{walkingV: 1.2}
+	green, red := &testNoEntryLane{}, &testNoEntryLane{noEntry: true}
+
+	// 默认在红灯时加倍
+	assert.Equal(t, 1.2, p.walkingSpeed(green))
+	assert.InDelta(t, 2.4, p.walkingSpeed(red), 1e-9)
+	// 倍数为1时不加速
+	flag.Set("ped.red_speed_factor", "1")
+	assert.Equal(t, 1.2, p.walkingSpeed(red))
+	// 倍数为3时速度变为三倍
+	flag.Set("ped.red_speed_factor", "3")
+	assert.InDelta(t, 3.6, p.walkingSpeed(red), 1e-9)
+	assert.Equal(t, 1.2, p.walkingSpeed(green))
+}
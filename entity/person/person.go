@@ -1,8 +1,10 @@
 package person
 
 import (
+	"flag"
 	"fmt"
 	"math"
+	"sync/atomic"
 
 	"git.fiblab.net/general/common/v2/geometry"
 	"git.fiblab.net/general/common/v2/protoutil"
@@ -15,14 +17,29 @@ import (
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/person/route"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/person/schedule"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/container"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	maxRouteFailures   = flag.Int("person.max_route_failures", 10, "连续导航失败次数达到该值的整数倍时输出警告（<=0表示不警告）")
+	dropOnRouteFailure = flag.Bool("person.drop_on_route_failure", false, "连续导航失败次数达到person.max_route_failures时是否删除该人")
+	compactSleeping    = flag.Bool("person.compact_sleeping", false, "是否释放睡眠状态的人的导航与时刻表缓冲区数据（出发时重新创建），用于降低超大规模人口的内存占用")
+	disableNoise       = flag.Bool("sim.disable_noise", false, "是否关闭所有随机扰动（车辆加速度、车辆与行人属性、行人位置偏移），用于以解析解验证确定性的运动学模型")
 )
 
 const (
 	maxVehicleVNoise           = 5  // 车辆速度随机扰动最大值
 	maxVehicleANoise           = .5 // 车辆加速度随机扰动最大值s
 	maxPedestrianPositionNoise = 2  // 行人位置输出随机扰动最大值
+
+	routePreferenceLabel = "route_preference" // 导航偏好标签，值为routePreferenceEco时驾车出行使用节能导航
+	routePreferenceEco   = "eco"
+	routeFallbackLabel   = "route_fallback" // 导航回退标签，值为routeFallbackWalk时驾车导航失败后改为步行重新导航
+	routeFallbackWalk    = "walk"
+	avoidTollsLabel      = "avoid_tolls" // 避开收费道路标签，值为"true"时驾车导航避开收费道路
 )
 
 // Person 人员实体
@@ -42,6 +59,8 @@ type Person struct {
 	bikeAttr       *personv2.BikeAttribute       // 自行车的属性
 	home           *geov2.Position               // 人的家庭位置
 	labels         map[string]string             // 人的标签
+	labelBuffer    map[string]*string            // 标签修改buffer（nil值表示删除），在准备阶段写入labels
+	vehicleClass   string                        // 车辆类别（由vehicle_class标签解析，为空表示小汽车）
 
 	generator *randengine.Engine // 随机数生成器，以ID为seed
 
@@ -56,12 +75,46 @@ type Person struct {
 	schedule          *schedule.Schedule // 时刻表
 	newSchedule       []*tripv2.Schedule // schedule修改buffer
 	scheduleResetFlag bool               // 时刻表是否被修改
+	appendedSchedule  []*tripv2.Schedule // 追加到时刻表末尾的schedule buffer（不打断当前trip）
 
 	// 导航
-	multiModalRoute *route.MultiModalRoute // 多式联运导航
+	multiModalRoute *route.MultiModalRoute // 多式联运导航（启用person.compact_sleeping时睡眠期间为nil，通过getRoute获取）
+
+	// 重置位置（非强制重置仅支持从Sleep重置）
+	resetPos   *geov2.Position
+	resetForce bool // 是否强制重置（非Sleep状态时先停止当前出行）
+
+	// 导航失败计数
+	routeFailures            int32 // 累计导航失败次数
+	consecutiveRouteFailures int32 // 连续导航失败次数，导航成功后清零
+	walkFallback             bool  // 当前trip的驾车导航已失败，下一次导航改为步行
+
+	// 导航失败时的直线瞬移
+	teleportArrival float64     // 瞬移到达终点的时间
+	teleportAoi     entity.IAoi // 瞬移出发时离开的AOI，瞬移被时刻表重置中止时回到该AOI
+	teleports       int32       // 累计瞬移完成的行程数
+
+	// 能耗与排放（步行不计）
+	energy         PersonEnergy // 累计能耗，在update阶段累加
+	energySnapshot PersonEnergy // 累计能耗快照，供外部接口读取
+
+	// 驾驶安全指标（急加速与急减速次数）
+	safety         PersonSafetyMetrics // 累计安全指标，在update阶段累加
+	safetySnapshot PersonSafetyMetrics // 累计安全指标快照，供外部接口读取
+
+	// 变道统计
+	laneChanges         PersonLaneChanges // 累计变道次数，在update阶段累加
+	laneChangesSnapshot PersonLaneChanges // 累计变道次数快照，供外部接口读取
 
-	// 重置位置（目前仅支持从Sleep重置）
-	resetPos *geov2.Position
+	// AOI容量
+	approach    entity.RoutePosition // 最近一次进入AOI前所在的车道位置
+	aoiRejected entity.IAoi          // 上一步因AOI已满未能进入的AOI（由AOI在准备阶段写入）
+	waitingAoi  entity.IAoi          // 正在出入口处等待进入的AOI
+
+	// 是否已被标记移除（在下一次update中与车道/AOI解除关联，并在之后的PrepareNode中从管理器删除）
+	removed bool
+	// 是否因加载时时刻表为空而未加入管理器的更新数组（person.exclude_empty_schedule）
+	dormant atomic.Bool
 }
 
 // newPerson 创建并初始化一个新的Person实例
@@ -92,12 +145,19 @@ func newPerson(
 		},
 		schedule:    schedule.NewSchedule(ctx, base.GetSchedules()),
 		newSchedule: make([]*tripv2.Schedule, 0),
-		generator:   randengine.New(uint64(base.Id)),
+		generator:   randengine.New(personSeed(ctx, base.Id)),
 	}
 	// // DEBUG
 	// p.vehicleAttr.Length = 15
 	p.multiModalRoute = route.NewMultiModalRoute(ctx, p)
+	p.schedule.SetConditionEvaluator(m.tripCondition)
+	if dwellTimes := ctx.RuntimeConfig().C.DwellTimes; len(dwellTimes) > 0 {
+		p.schedule.SetDwellTimeDistributions(p.generator, lo.MapValues(dwellTimes, func(d config.DwellTime, _ string) schedule.DwellTimeDistribution {
+			return schedule.DwellTimeDistribution{Mean: d.Mean, Std: d.Std}
+		}))
+	}
 	p.SetSchedules(base.GetSchedules())
+	p.resolveVehicleClass()
 	// 属性检查
 	if p.vehicleAttr.MaxSpeed <= 0 {
 		log.Fatalf("person %d (vehicle_attr=%v) vehicle max speed is less than 0, please check the data", p.ID(), p.vehicleAttr)
@@ -129,11 +189,11 @@ func newPerson(
 	// 为车辆属性添加随机扰动
 	// 最大速度
 	p.vehicleAttr.MaxSpeed = math.Max(p.vehicleAttr.MaxSpeed+
-		maxVehicleVNoise*lo.Clamp(.5*p.generator.NormFloat64(), -1, 1),
+		noise(p.generator, maxVehicleVNoise),
 		.1)
 	// 最大刹车加速度
 	p.vehicleAttr.MaxBrakingAcceleration = math.Min(p.vehicleAttr.MaxBrakingAcceleration+
-		maxVehicleANoise*lo.Clamp(.5*p.generator.NormFloat64(), -1, 1),
+		noise(p.generator, maxVehicleANoise),
 		-.1)
 	p.vehicle = &vehicle{
 		length: p.vehicleAttr.Length,
@@ -143,24 +203,22 @@ func newPerson(
 	if base.PedestrianAttribute != nil {
 		walkV = base.PedestrianAttribute.Speed
 	}
-	walkV += maxVNoise * lo.Clamp(.5*p.generator.NormFloat64(), -1, 1)
+	walkV += noise(p.generator, maxVNoise)
 	walkV = math.Max(minWalkV, walkV)
 	bikeV := defaultBikeV
 	if base.BikeAttribute != nil {
 		bikeV = base.BikeAttribute.Speed
 	}
-	bikeV += maxVNoise * lo.Clamp(.5*p.generator.NormFloat64(), -1, 1)
+	bikeV += noise(p.generator, maxVNoise)
 	bikeV = math.Max(minBikeV, bikeV)
 	p.pedestrian = &pedestrian{
-		walkingV:           walkV,
-		bikingV:            bikeV,
-		verticalOffsetRate: p.generator.Float64(),
-		horizontalOffset: lo.Clamp(
-			p.generator.NormFloat64(),
-			-maxPedestrianPositionNoise,
-			maxPedestrianPositionNoise,
-		),
+		walkingV: walkV,
+		bikingV:  bikeV,
 	}
+	if !*disableNoise {
+		p.pedestrian.verticalOffsetRate = p.generator.Float64()
+	}
+	p.pedestrian.horizontalOffset = noise(p.generator, maxPedestrianPositionNoise)
 	// 设置人的初始位置
 	home := base.Home
 	if home.AoiPosition != nil {
@@ -182,6 +240,25 @@ func newPerson(
 	return p
 }
 
+// noise 生成随机扰动
+// 功能：返回截断到[-maxNoise, maxNoise]的正态分布随机扰动（标准差为maxNoise/2）
+// 说明：启用sim.disable_noise时返回0且不消耗随机数
+func noise(e *randengine.Engine, maxNoise float64) float64 {
+	if *disableNoise {
+		return 0
+	}
+	return maxNoise * lo.Clamp(.5*e.NormFloat64(), -1, 1)
+}
+
+// personSeed 获取人的随机数种子
+// 功能：优先使用配置中为该人指定的种子，未指定时以人的ID作为种子
+func personSeed(ctx entity.ITaskContext, id int32) uint64 {
+	if seed, ok := ctx.RuntimeConfig().C.PersonSeeds[id]; ok {
+		return seed
+	}
+	return uint64(id)
+}
+
 func (p *Person) prepareNode() {
 	switch p.runtime.Status {
 	case personv2.Status_STATUS_DRIVING:
@@ -207,12 +284,42 @@ func (p *Person) prepareNode() {
 // 说明：使用缓冲区机制提高并发性能，避免在更新阶段进行写操作
 func (p *Person) prepare() {
 	p.snapshot = p.runtime
+	p.energySnapshot = p.energy
+	p.safetySnapshot = p.safety
+	p.laneChangesSnapshot = p.laneChanges
+	p.applyLabels()
 	switch p.runtime.Status {
 	case personv2.Status_STATUS_DRIVING:
 		p.runtime.Action = Action{}
 	}
 	// 优先执行新的schedule
 	p.ResetScheduleIfNeed()
+	p.appendScheduleIfNeed()
+	if *compactSleeping {
+		p.compact()
+	}
+}
+
+// compact 释放睡眠状态的人的不常用数据
+// 功能：没有未完成导航的睡眠状态的人释放多式联运导航与时刻表缓冲区，降低内存占用
+// 说明：导航对象不包含随机状态，出发时由getRoute重新创建，行为与未释放时一致
+func (p *Person) compact() {
+	if p.runtime.Status != personv2.Status_STATUS_SLEEP || p.multiModalRoute == nil || p.multiModalRoute.Ok() {
+		return
+	}
+	p.multiModalRoute.Wait()
+	p.multiModalRoute = nil
+	if !p.scheduleResetFlag {
+		p.newSchedule = nil
+	}
+}
+
+// getRoute 获取多式联运导航，已被compact释放时重新创建
+func (p *Person) getRoute() *route.MultiModalRoute {
+	if p.multiModalRoute == nil {
+		p.multiModalRoute = route.NewMultiModalRoute(p.ctx, p)
+	}
+	return p.multiModalRoute
 }
 
 // update 更新阶段，执行Person的模拟逻辑
@@ -222,9 +329,24 @@ func (p *Person) prepare() {
 func (p *Person) update(
 	dt float64,
 ) {
+	// 移除人：解除与车道/AOI的关联后交由管理器删除，不再进行模拟
+	if p.removed {
+		p.detach()
+		p.m.recordRemoved(p)
+		return
+	}
 	// 对resetPos的预检查
 	if p.resetPos != nil {
 		if p.runtime.Status != personv2.Status_STATUS_SLEEP {
+			if p.resetForce {
+				// 强制停止当前出行：离开车道/AOI并清空导航后重置位置
+				// 本步不再出发，待快照更新后再从新位置发起导航
+				log.Debugf("person %d force reset position from status %v", p.ID(), p.runtime.Status)
+				p.detach()
+				p.runtime.Aoi = nil
+				p.resetPosition()
+				return
+			}
 			log.Errorf("person %d reset position %v not in sleep status", p.ID(), p.resetPos)
 			p.resetPos = nil
 		}
@@ -232,21 +354,13 @@ func (p *Person) update(
 	switch p.runtime.Status {
 	case personv2.Status_STATUS_SLEEP:
 		if p.resetPos != nil {
-			log.Debugf("person %d reset position to %v", p.ID(), p.resetPos)
-			// 由于限定是SLEEP状态，所以肯定不会isCrowd
-			if p.runtime.Aoi != nil {
-				p.runtime.Aoi.RemovePerson(p)
-			}
-			p.runtime.resetByPbPosition(p.ctx, p.resetPos)
-			// 给Reset到的Aoi或Lane添加人
-			if p.runtime.Aoi != nil {
-				p.runtime.Aoi.AddPerson(p)
-			} else {
-				// 必须是Lane
-				// do nothing
-				var _ struct{}
+			p.resetPosition()
+		}
+		if p.aoiRejected != nil || p.waitingAoi != nil {
+			p.waitForAoi()
+			if p.waitingAoi != nil {
+				return
 			}
-			p.resetPos = nil
 		}
 		// ATTENTION:一段trip的多个journey之间切换过程中必定满足出发时间触发
 		if p.checkDeparture() {
@@ -256,6 +370,10 @@ func (p *Person) update(
 			return
 		}
 	case personv2.Status_STATUS_WAIT_ROUTE:
+		if p.runtime.Teleporting {
+			p.updateTeleport()
+			return
+		}
 		if _, ok := p.routeSuccessful(); !ok {
 			p.runtime.Status = personv2.Status_STATUS_SLEEP
 			return
@@ -267,10 +385,13 @@ func (p *Person) update(
 		if isEnd {
 			end := p.multiModalRoute.GetCurrentEndPosition()
 			// 行人结束路面行为（生命周期结束）的后处理
-			// 步行和开车都只有单个journey
+			// 还有下一段journey时进入换乘AOI，下一步从换乘AOI出发
+			if p.enterTransferAoi(end) {
+				return
+			}
 			// 本行程走完，进入sleep
 			endAoi := end.Aoi
-			p.schedule.NextTrip(p.ctx.Clock().T)
+			p.schedule.CompleteTrip(p.ctx.Clock().T)
 			if endAoi != nil {
 				p.updateComeIn(endAoi, end.XY)
 			} else {
@@ -283,7 +404,10 @@ func (p *Person) update(
 		p.runtime.IsTripEnd = isEnd
 		if isEnd {
 			end := p.multiModalRoute.GetCurrentEndPosition()
-			p.schedule.NextTrip(p.ctx.Clock().T)
+			if p.enterTransferAoi(end) {
+				return
+			}
+			p.schedule.CompleteTrip(p.ctx.Clock().T)
 			if end.Aoi != nil {
 				p.updateComeIn(end.Aoi, end.XY)
 			} else {
@@ -296,6 +420,53 @@ func (p *Person) update(
 	}
 }
 
+// resetPosition 将人以SLEEP状态重置到resetPos
+// 功能：离开当前AOI，按resetPos重置运行时数据，并加入新的AOI（如有）
+// 说明：调用前需保证人不在任何车道链表中
+func (p *Person) resetPosition() {
+	log.Debugf("person %d reset position to %v", p.ID(), p.resetPos)
+	// 由于限定是SLEEP状态，所以肯定不会isCrowd
+	if p.runtime.Aoi != nil {
+		p.runtime.Aoi.RemovePerson(p)
+	}
+	p.runtime.resetByPbPosition(p.ctx, p.resetPos)
+	// 运行时数据已重置（包括瞬移状态），中止可能正在进行的瞬移
+	p.teleportArrival = 0
+	p.teleportAoi = nil
+	// 给Reset到的Aoi或Lane添加人
+	if p.runtime.Aoi != nil {
+		p.runtime.Aoi.AddPerson(p)
+	} else {
+		// 必须是Lane
+		// do nothing
+		var _ struct{}
+	}
+	p.resetPos = nil
+	p.resetForce = false
+}
+
+// detach 解除人与所在车道、AOI的关联，并清空导航
+// 功能：从车道的车辆/行人链表、AOI的人员列表中移除该人（Prepare后生效）
+// 说明：必须在update阶段调用，此时snapshot与runtime一致且链表已完成更新
+func (p *Person) detach() {
+	switch p.runtime.Status {
+	case personv2.Status_STATUS_DRIVING:
+		p.updateLaneVehicleNodes(false)
+		p.vehicle.node, p.vehicle.shadowNode = nil, nil
+	case personv2.Status_STATUS_WALKING:
+		p.snapshot.Lane.RemovePedestrian(p.pedestrian.node)
+		p.pedestrian.node = nil
+	}
+	if p.runtime.Aoi != nil {
+		p.runtime.Aoi.RemovePerson(p)
+	}
+	// 等待可能尚未返回的导航请求，避免回调修改已清空的导航
+	if p.multiModalRoute != nil {
+		p.multiModalRoute.Wait()
+		p.multiModalRoute.Clear()
+	}
+}
+
 // 从室内出来的辅助函数
 func (p *Person) updateGoOut() {
 	switch p.multiModalRoute.MultiModalType {
@@ -340,6 +511,15 @@ func (p *Person) updateGoOut() {
 		p.runtime.XYZ = p.runtime.Lane.GetPositionByS(p.runtime.S)
 		p.pedestrian.node = newPedestrianNode(p.runtime.S, p)
 		p.runtime.Lane.AddPedestrian(p.pedestrian.node)
+	case route.MultiModalType_TELEPORT:
+		// 导航失败，离开起点AOI直线瞬移，到时后进入终点AOI
+		p.runtime.Teleporting = true
+		p.teleportArrival = p.ctx.Clock().T + p.multiModalRoute.TeleportEta
+		p.teleportAoi = p.runtime.Aoi
+		if p.runtime.Aoi != nil {
+			p.runtime.Aoi.RemovePerson(p)
+			p.runtime.Aoi = nil
+		}
 	default:
 		log.Panicf("Bad multiModal type: %v", p.multiModalRoute.MultiModalType)
 	}
@@ -347,6 +527,7 @@ func (p *Person) updateGoOut() {
 
 // 进入室内的辅助函数
 func (p *Person) updateComeIn(endAoi entity.IAoi, endXyOrNil *geometry.Point) {
+	p.approach = entity.RoutePosition{Lane: p.runtime.Lane, S: p.runtime.S}
 	p.runtime.Aoi = endAoi
 	endAoi.AddPerson(p)
 	p.runtime.XYZ = endAoi.Centroid()
@@ -355,6 +536,71 @@ func (p *Person) updateComeIn(endAoi entity.IAoi, endXyOrNil *geometry.Point) {
 	p.runtime.S = 0
 }
 
+// enterTransferAoi 当前journey结束后进入下一段journey
+// 功能：还有下一段journey时进入换乘AOI并返回true，下一步从换乘AOI出发；本行程已走完时返回false
+// 参数：end-当前journey的终点（换乘AOI）
+// 说明：下一段journey导航失效时按导航失败处理（计入失败统计并跳过本行程，或按route_fallback标签改为步行重新导航），
+// 本行程不计入完成数
+func (p *Person) enterTransferAoi(end entity.RoutePosition) bool {
+	hasNext, ok := p.multiModalRoute.NextJourney()
+	if !hasNext {
+		return false
+	}
+	p.updateComeIn(end.Aoi, end.XY)
+	if !ok {
+		log.Debugf("person %d failed to continue the next journey at transfer aoi %d", p.ID(), end.Aoi.ID())
+		p.routeSuccessful()
+	}
+	return true
+}
+
+// updateTeleport 直线瞬移的更新
+// 功能：到达瞬移用时后进入终点AOI并结束本行程，行程计入完成统计与瞬移计数
+func (p *Person) updateTeleport() {
+	if p.ctx.Clock().T < p.teleportArrival {
+		return
+	}
+	end := p.multiModalRoute.GetCurrentEndPosition()
+	p.multiModalRoute.Clear()
+	p.runtime.Teleporting = false
+	p.teleportAoi = nil
+	p.teleports++
+	p.schedule.CompleteTrip(p.ctx.Clock().T)
+	p.updateComeIn(end.Aoi, end.XY)
+	p.m.recordTripEnd(p)
+}
+
+// waitForAoi 处理因AOI已满未能进入的人
+// 功能：刚被拒绝时退到AOI在所连接车道上的出入口处，之后每步重新请求进入，直到被AOI接纳
+// 说明：等待期间保持睡眠状态且不出发；等待的人在路外，不属于任何车道与AOI，不影响道路上的交通
+func (p *Person) waitForAoi() {
+	if p.waitingAoi == nil {
+		// 刚被拒绝，在出入口处等待
+		p.waitingAoi = p.aoiRejected
+		p.runtime.Aoi = nil
+		if p.approach.Lane != nil {
+			p.runtime.XYZ = p.approach.Lane.GetPositionByS(p.approach.S)
+		}
+		p.runtime.Lane = nil
+		p.runtime.S = 0
+	} else if p.aoiRejected == nil {
+		// 上一步的进入请求已被接纳
+		p.runtime.Aoi = p.waitingAoi
+		p.runtime.XYZ = p.waitingAoi.Centroid()
+		p.runtime.Lane = nil
+		p.runtime.S = 0
+		p.waitingAoi = nil
+		return
+	}
+	p.aoiRejected = nil
+	p.waitingAoi.AddPerson(p)
+}
+
+// RejectByAoi AOI已满时通知人未能进入，在下一次update中处理
+func (p *Person) RejectByAoi(a entity.IAoi) {
+	p.aoiRejected = a
+}
+
 // 获取人的ID
 func (p *Person) ID() int32 {
 	if p == nil {
@@ -442,20 +688,152 @@ func (p *Person) GetLabel(key string) (string, bool) {
 	return value, ok
 }
 
+// 设置标签，在下一步的准备阶段生效
+func (p *Person) SetLabel(key, value string) {
+	if p.labelBuffer == nil {
+		p.labelBuffer = make(map[string]*string)
+	}
+	p.labelBuffer[key] = &value
+}
+
+// 删除标签，在下一步的准备阶段生效
+func (p *Person) DeleteLabel(key string) {
+	if p.labelBuffer == nil {
+		p.labelBuffer = make(map[string]*string)
+	}
+	p.labelBuffer[key] = nil
+}
+
+// applyLabels 将标签修改buffer写入标签
+// 说明：labels与基础数据共用同一个map，修改同时反映在返回的基础Protobuf中
+func (p *Person) applyLabels() {
+	if len(p.labelBuffer) == 0 {
+		return
+	}
+	if p.labels == nil {
+		p.labels = make(map[string]string)
+		if p.base != nil {
+			p.base.Labels = p.labels
+		}
+	}
+	for key, value := range p.labelBuffer {
+		if value == nil {
+			delete(p.labels, key)
+		} else {
+			p.labels[key] = *value
+		}
+	}
+	if _, ok := p.labelBuffer[vehicleClassLabel]; ok {
+		p.resolveVehicleClass()
+	}
+	p.labelBuffer = nil
+}
+
+// 检查标签是否满足所有键值对条件
+func (p *Person) matchLabels(labels map[string]string) bool {
+	for key, value := range labels {
+		if v, ok := p.labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveVehicleClass 解析vehicle_class标签得到车辆类别
+// 说明：在创建人和标签修改生效时调用，无法识别的类别只在解析时警告一次并视为小汽车
+func (p *Person) resolveVehicleClass() {
+	p.vehicleClass = vehicleClassCar
+	class, ok := p.labels[vehicleClassLabel]
+	if !ok {
+		return
+	}
+	if _, ok := vehicleClasses[class]; !ok {
+		log.Warnf("person %d: unknown vehicle class %q, use %q", p.id, class, vehicleClassCar)
+		return
+	}
+	p.vehicleClass = class
+}
+
+// 获取车辆类别（vehicle_class标签），未指定或无法识别时为小汽车
+func (p *Person) VehicleClass() string {
+	if p.vehicleClass == "" {
+		return vehicleClassCar
+	}
+	return p.vehicleClass
+}
+
+// 获取驾车导航的选项：避开不允许本车类别通行的道路，avoid_tolls标签为true时避开收费道路
+func (p *Person) RouteOptions() entity.RouteOptions {
+	avoidTolls, _ := p.GetLabel(avoidTollsLabel)
+	return entity.RouteOptions{
+		AvoidTolls:   avoidTolls == "true",
+		VehicleClass: p.VehicleClass(),
+	}
+}
+
+// idle 判断人是否已结束全部出行（处于睡眠状态、时刻表为空且没有待生效的时刻表修改）
+func (p *Person) idle() bool {
+	return p.runtime.Status == personv2.Status_STATUS_SLEEP && p.schedule.Empty() && !p.scheduleResetFlag && len(p.appendedSchedule) == 0
+}
+
 // 设置时刻表
 func (p *Person) SetSchedules(schedules []*tripv2.Schedule) {
 	p.newSchedule = schedules
 	p.scheduleResetFlag = true
 }
 
+// 将时刻表的出发时间在[t, t+window)内随机推迟（加载时调用）
+// 全部时刻表与trip中指定的出发时间统一推迟同一随机时长，保持各trip之间的相对时间不变
+func (p *Person) spreadDeparture(window float64) {
+	if len(p.newSchedule) == 0 {
+		return
+	}
+	delay := window * p.generator.Float64()
+	for _, schedule := range p.newSchedule {
+		if schedule.DepartureTime != nil {
+			schedule.DepartureTime = proto.Float64(*schedule.DepartureTime + delay)
+		}
+		for _, trip := range schedule.Trips {
+			if trip.DepartureTime != nil {
+				trip.DepartureTime = proto.Float64(*trip.DepartureTime + delay)
+			}
+		}
+	}
+}
+
+// 在时刻表末尾追加schedule，不打断当前trip，在准备阶段生效
+func (p *Person) AppendSchedules(schedules []*tripv2.Schedule) {
+	p.appendedSchedule = append(p.appendedSchedule, schedules...)
+}
+
+// 应用追加的schedule（在时刻表重置之后）
+func (p *Person) appendScheduleIfNeed() {
+	if len(p.appendedSchedule) > 0 {
+		p.schedule.Append(p.appendedSchedule, p.ctx.Clock().T)
+		p.appendedSchedule = nil
+	}
+}
+
 func (p *Person) ResetScheduleIfNeed() {
 	if p.scheduleResetFlag {
 		p.schedule.Set(p.newSchedule, p.ctx.Clock().T)
 		p.scheduleResetFlag = false
+		p.walkFallback = false
+		// 中止直线瞬移：瞬移期间人不在任何AOI中，在更新阶段回到出发时离开的AOI
+		if p.runtime.Teleporting {
+			p.runtime.Teleporting = false
+			p.teleportArrival = 0
+			if p.teleportAoi != nil && p.resetPos == nil {
+				p.resetPos = &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: p.teleportAoi.ID()}}
+			}
+			p.teleportAoi = nil
+		}
 		// 强制转为Sleep模式，便于触发新的schedule
 		p.runtime.Status = personv2.Status_STATUS_SLEEP
 		// 清空导航
-		p.multiModalRoute.Clear()
+		if p.multiModalRoute != nil {
+			p.multiModalRoute.Clear()
+		}
 	}
 }
 
@@ -497,8 +875,10 @@ func (p *Person) requestRoute() {
 	if p.runtime.Lane == nil && p.runtime.Aoi == nil {
 		log.Panicf("person %d has neither lane nor aoi", p.ID())
 	}
+	// 驾车导航失败回退为步行时，按步行出行处理
+	isDriving := schedule.IsDrivingTrip(trip) && !p.walkFallback
 	// route还没走完 在外部切换到下一个route 不需要导航
-	if p.multiModalRoute.Ok() {
+	if p.getRoute().Ok() {
 		// do nothing
 	} else {
 		if p.runtime.Lane != nil {
@@ -507,7 +887,7 @@ func (p *Person) requestRoute() {
 			s := p.runtime.S
 			startPosition = entity.RoutePosition{Lane: lane, S: s}
 			// 位置修正
-			if schedule.IsDrivingTrip(trip) {
+			if isDriving {
 				if lane.Type() != mapv2.LaneType_LANE_TYPE_DRIVING {
 					var drivingLane entity.ILane
 					var s float64
@@ -589,9 +969,12 @@ func (p *Person) requestRoute() {
 		p.multiModalRoute.Clear()
 		// 根据trip类型发出不同类型的导航请求
 		var routeType routingv2.RouteType
-		if schedule.IsDrivingTrip(trip) {
+		if isDriving {
 			routeType = routingv2.RouteType_ROUTE_TYPE_DRIVING
-		} else if schedule.IsWalkingTrip(trip) {
+			if preference, _ := p.GetLabel(routePreferenceLabel); preference == routePreferenceEco {
+				routeType = route.RouteTypeEcoDriving
+			}
+		} else if p.walkFallback || schedule.IsWalkingTrip(trip) {
 			routeType = routingv2.RouteType_ROUTE_TYPE_WALKING
 		} else {
 			log.Panicf("Invalid trip mode: %v", trip.Mode)
@@ -602,16 +985,72 @@ func (p *Person) requestRoute() {
 }
 
 // 导航请求是否成功,成功则返回true，否则转到下一trip并返回false
+// 驾车导航失败且人的route_fallback标签为walk时，保留当前trip，下一步改为步行重新导航
 func (p *Person) routeSuccessful() (*tripv2.Trip, bool) {
 	trip := p.schedule.GetTrip()
 	p.multiModalRoute.Wait()
 	if p.multiModalRoute.Ok() {
+		p.consecutiveRouteFailures = 0
+		p.walkFallback = false
 		return trip, true
 	}
+	if !p.walkFallback && schedule.IsDrivingTrip(trip) {
+		if fallback, _ := p.GetLabel(routeFallbackLabel); fallback == routeFallbackWalk {
+			log.Debugf("person %d failed to route by driving, retry by walking", p.ID())
+			p.walkFallback = true
+			return trip, false
+		}
+	}
+	p.walkFallback = false
+	p.recordRouteFailure(trip)
 	p.schedule.NextTrip(p.ctx.Clock().T)
 	return trip, false
 }
 
+// recordRouteFailure 记录导航失败
+// 功能：更新导航失败计数，连续失败次数每达到person.max_route_failures的整数倍时输出一次警告（限制日志频率），
+// 若开启person.drop_on_route_failure则标记删除该人
+func (p *Person) recordRouteFailure(trip *tripv2.Trip) {
+	p.routeFailures++
+	p.consecutiveRouteFailures++
+	n := int32(*maxRouteFailures)
+	if n <= 0 || p.consecutiveRouteFailures%n != 0 {
+		return
+	}
+	log.Warnf("person %d failed to route %d times in a row (total %d), last trip: %v",
+		p.ID(), p.consecutiveRouteFailures, p.routeFailures, trip)
+	if *dropOnRouteFailure {
+		log.Warnf("person %d is dropped due to repeated route failures", p.ID())
+		p.removed = true
+	}
+}
+
+// 设置车辆的速度上限，上限不超过车辆最大速度，返回实际生效的上限
+func (p *Person) SetMaxSpeed(v float64) float64 {
+	p.vehicle.controller.speedCap = math.Min(v, p.vehicleAttr.MaxSpeed)
+	return p.vehicle.controller.speedCap
+}
+
+// 获取车辆当前生效的速度上限
+func (p *Person) MaxSpeed() float64 {
+	return p.vehicle.controller.getMaxV()
+}
+
+// 获取人通过直线瞬移完成的行程数
+func (p *Person) Teleports() int32 {
+	return p.teleports
+}
+
+// 获取人的累计导航失败次数
+func (p *Person) RouteFailures() int32 {
+	return p.routeFailures
+}
+
+// 获取人的连续导航失败次数
+func (p *Person) ConsecutiveRouteFailures() int32 {
+	return p.consecutiveRouteFailures
+}
+
 // 产生人的基础Protobuf
 func (p *Person) ToBasePb() *personv2.Person {
 	pb := protoutil.Clone(p.base)
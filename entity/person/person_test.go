@@ -0,0 +1,518 @@
+package person
+
+import (
+	"flag"
+	"math"
+	goruntime "runtime"
+	"testing"
+
+	"git.fiblab.net/general/common/v2/geometry"
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
+	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/road"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
+)
+
+func TestRouteFailureCounter(t *testing.T) {
+	ctx := newTestContext(
+		[]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)},
+		[]*mapv2.Aoi{newTestAoiPb(10, 1, 30), newTestAoiPb(20, 1, 80)},
+	)
+	p := newTestSleepingPerson(ctx, 1, ctx.aoiManager.Get(10))
+	// 无限循环的单trip时刻表，导航服务总是失败
+	p.schedule.Set([]*tripv2.Schedule{{
+		Trips: []*tripv2.Trip{{
+			Mode: tripv2.TripMode_TRIP_MODE_DRIVE_ONLY,
+			End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 20}},
+		}},
+	}}, 0)
+	m := newTestManager(p)
+	m.ctx = ctx
+	ctx.personManager = m
+
+	defer func(n int, drop bool) { *maxRouteFailures, *dropOnRouteFailure = n, drop }(*maxRouteFailures, *dropOnRouteFailure)
+	*maxRouteFailures, *dropOnRouteFailure = 3, true
+	for i := int32(1); i <= 3; i++ {
+		m.Update(ctx.clock.DT) // SLEEP -> WAIT_ROUTE
+		m.Prepare()
+		m.Update(ctx.clock.DT) // WAIT_ROUTE -> SLEEP（导航失败）
+		m.Prepare()
+		assert.Equal(t, personv2.Status_STATUS_SLEEP, p.Status())
+		assert.Equal(t, i, p.RouteFailures())
+		assert.Equal(t, i, p.ConsecutiveRouteFailures())
+		assert.Equal(t, i == 3, p.removed)
+	}
+}
+
+func TestTeleportFallback(t *testing.T) {
+	defer flag.Set("route.teleport_fallback", "false")
+	flag.Set("route.teleport_fallback", "true")
+	// AOI 10与20的质心相距50米，车道限速10米/秒，瞬移用时为50/10*1.5=7.5秒
+	ctx := newTestContext(
+		[]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)},
+		[]*mapv2.Aoi{newTestAoiPb(10, 1, 30), newTestAoiPb(20, 1, 80)},
+	)
+	p := newTestSleepingPerson(ctx, 1, ctx.aoiManager.Get(10))
+	// 导航服务总是失败
+	p.schedule.Set([]*tripv2.Schedule{{
+		Trips: []*tripv2.Trip{{
+			Mode: tripv2.TripMode_TRIP_MODE_DRIVE_ONLY,
+			End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 20}},
+		}},
+	}}, 0)
+	m := newTestManager(p)
+	m.ctx = ctx
+	ctx.personManager = m
+
+	arrival := -1.
+	teleporting := false
+	for i := 0; i < 20 && arrival < 0; i++ {
+		ctx.clock.InternalStep = int32(i)
+		ctx.clock.T = float64(i)
+		m.Update(ctx.clock.DT)
+		ctx.aoiManager.Prepare()
+		m.Prepare()
+		teleporting = teleporting || p.snapshot.Teleporting
+		if p.Teleports() > 0 {
+			arrival = ctx.clock.T
+		}
+	}
+	assert.True(t, teleporting)
+	// 第0步发出导航请求，第1步导航失败后开始瞬移，第9步（1+7.5之后）到达
+	assert.Equal(t, 9., arrival)
+	assert.Equal(t, personv2.Status_STATUS_SLEEP, p.Status())
+	assert.False(t, p.snapshot.Teleporting)
+	assert.Equal(t, int32(20), p.Aoi().ID())
+	assert.Equal(t, int32(0), p.RouteFailures())
+	assert.Equal(t, int32(1), m.snapshot.NumCompletedTrips)
+}
+
+func TestTeleportResetSchedule(t *testing.T) {
+	defer flag.Set("route.teleport_fallback", "false")
+	flag.Set("route.teleport_fallback", "true")
+	ctx := newTestContext(
+		[]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)},
+		[]*mapv2.Aoi{newTestAoiPb(10, 1, 30), newTestAoiPb(20, 1, 80)},
+	)
+	p := newTestSleepingPerson(ctx, 1, ctx.aoiManager.Get(10))
+	p.schedule.Set([]*tripv2.Schedule{{
+		Trips: []*tripv2.Trip{{
+			Mode: tripv2.TripMode_TRIP_MODE_DRIVE_ONLY,
+			End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 20}},
+		}},
+	}}, 0)
+	m := newTestManager(p)
+	m.ctx = ctx
+	ctx.personManager = m
+
+	step := func(i int) {
+		ctx.clock.InternalStep = int32(i)
+		ctx.clock.T = float64(i)
+		m.Update(ctx.clock.DT)
+		ctx.aoiManager.Prepare()
+		m.Prepare()
+	}
+	// 第0步发出导航请求，第1步导航失败后开始瞬移
+	step(0)
+	step(1)
+	assert.True(t, p.snapshot.Teleporting)
+	assert.Nil(t, p.Aoi())
+
+	// 瞬移途中重置时刻表：中止瞬移并回到出发的AOI，不计入完成的行程
+	p.SetSchedules(nil)
+	for i := 2; i < 12; i++ {
+		step(i)
+	}
+	assert.Equal(t, personv2.Status_STATUS_SLEEP, p.Status())
+	assert.False(t, p.snapshot.Teleporting)
+	assert.Equal(t, int32(10), p.Aoi().ID())
+	assert.Zero(t, p.Teleports())
+	assert.Equal(t, int32(0), m.snapshot.NumCompletedTrips)
+}
+
+func TestRouteFallbackToWalking(t *testing.T) {
+	// 行车道1与步行道2平行，AOI同时连接两条车道
+	aois := []*mapv2.Aoi{newTestAoiPb(10, 1, 30), newTestAoiPb(20, 1, 80)}
+	for _, a := range aois {
+		a.WalkingPositions = []*geov2.LanePosition{{LaneId: 2, S: a.DrivingPositions[0].S}}
+	}
+	ctx := newTestContext([]*mapv2.Lane{
+		newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100),
+		newTestLanePb(2, mapv2.LaneType_LANE_TYPE_WALKING, 100),
+	}, aois)
+	ctx.router.walking = &routingv2.GetRouteResponse{Journeys: []*routingv2.Journey{{
+		Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
+		Walking: &routingv2.WalkingJourneyBody{
+			Route: []*routingv2.WalkingRouteSegment{{LaneId: 2, MovingDirection: routingv2.MovingDirection_MOVING_DIRECTION_FORWARD}},
+			Eta:   50,
+		},
+	}}}
+	p := newTestSleepingPerson(ctx, 1, ctx.aoiManager.Get(10))
+	p.labels = map[string]string{routeFallbackLabel: routeFallbackWalk}
+	p.pedestrian.walkingV = 10
+	p.schedule.Set([]*tripv2.Schedule{{
+		Trips: []*tripv2.Trip{{
+			Mode: tripv2.TripMode_TRIP_MODE_DRIVE_ONLY,
+			End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 20}},
+		}},
+		LoopCount: 1,
+	}}, 0)
+	m := newTestManager(p)
+	m.ctx = ctx
+	ctx.personManager = m
+	step := func() {
+		m.Update(ctx.clock.DT)
+		ctx.laneManager.Prepare()
+		ctx.aoiManager.Prepare()
+		m.PrepareNode()
+		m.Prepare()
+	}
+
+	step() // SLEEP -> WAIT_ROUTE（驾车）
+	step() // WAIT_ROUTE -> SLEEP（驾车导航失败，保留trip）
+	assert.Equal(t, personv2.Status_STATUS_SLEEP, p.Status())
+	assert.False(t, p.schedule.Empty())
+	step() // SLEEP -> WAIT_ROUTE（步行）
+	step() // WAIT_ROUTE -> WALKING
+	assert.Equal(t, personv2.Status_STATUS_WALKING, p.Status())
+	for i := 0; i < 10 && p.Status() == personv2.Status_STATUS_WALKING; i++ {
+		step()
+	}
+
+	// 步行完成行程，不计入导航失败
+	assert.Equal(t, personv2.Status_STATUS_SLEEP, p.Status())
+	assert.Equal(t, int32(20), p.Aoi().ID())
+	assert.True(t, p.schedule.Empty())
+	assert.Zero(t, p.RouteFailures())
+	if assert.Len(t, ctx.router.requests, 2) {
+		assert.Equal(t, routingv2.RouteType_ROUTE_TYPE_DRIVING, ctx.router.requests[0].Type)
+		assert.Equal(t, routingv2.RouteType_ROUTE_TYPE_WALKING, ctx.router.requests[1].Type)
+	}
+}
+
+func TestPersonSeedOverride(t *testing.T) {
+	ctx := newTestContext(
+		[]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)},
+		[]*mapv2.Aoi{newTestAoiPb(10, 1, 30)},
+	)
+	m := newTestManager()
+	m.ctx = ctx
+	// 人的随机扰动结果（车辆最大速度、步行速度与横向偏移）
+	noise := func(id int32) []float64 {
+		p := newPerson(ctx, m, &personv2.Person{
+			Id: id,
+			VehicleAttribute: &personv2.VehicleAttribute{
+				Length: 5, Width: 2, MaxSpeed: 30,
+				MaxAcceleration: 3, UsualAcceleration: 2,
+				MaxBrakingAcceleration: -10, UsualBrakingAcceleration: -4.5,
+			},
+			Home: &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 10}},
+		})
+		return []float64{p.vehicleAttr.MaxSpeed, p.pedestrian.walkingV, p.pedestrian.horizontalOffset}
+	}
+	before1, before2 := noise(1), noise(2)
+	assert.Equal(t, before1, noise(1))
+
+	ctx.runtimeConfig.C.PersonSeeds = map[int32]uint64{1: 12345}
+	assert.NotEqual(t, before1, noise(1))
+	assert.Equal(t, before2, noise(2))
+}
+
+func TestDisableNoise(t *testing.T) {
+	defer flag.Set("sim.disable_noise", "false")
+	flag.Set("sim.disable_noise", "true")
+	ctx := newTestContext(
+		[]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)},
+		[]*mapv2.Aoi{newTestAoiPb(10, 1, 30)},
+	)
+	m := newTestManager()
+	m.ctx = ctx
+	attr := func(id int32) []float64 {
+		p := newPerson(ctx, m, &personv2.Person{
+			Id: id,
+			VehicleAttribute: &personv2.VehicleAttribute{
+				Length: 5, Width: 2, MaxSpeed: 30,
+				MaxAcceleration: 3, UsualAcceleration: 2,
+				MaxBrakingAcceleration: -10, UsualBrakingAcceleration: -4.5,
+			},
+			Home: &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 10}},
+		})
+		return []float64{p.vehicleAttr.MaxSpeed, p.vehicleAttr.MaxBrakingAcceleration, p.pedestrian.horizontalOffset}
+	}
+	assert.Equal(t, []float64{30, -10, 0}, attr(1))
+	assert.Equal(t, attr(1), attr(2))
+
+	// 两条相同车道上的相同车辆（随机数种子不同）轨迹完全一致
+	scene, traffic := newTestTrafficScene(2, 1)
+	p1, p2 := traffic.data[1], traffic.data[2]
+	p1.runtime.V, p1.snapshot.V = 0, 0
+	p2.runtime.V, p2.snapshot.V = 0, 0
+	for i := 0; i < 50; i++ {
+		traffic.PrepareNode()
+		scene.laneManager.Prepare()
+		traffic.Prepare()
+		traffic.Update(1)
+		assert.Equal(t, p1.runtime.S, p2.runtime.S)
+		assert.Equal(t, p1.runtime.V, p2.runtime.V)
+		assert.Equal(t, p1.runtime.Action.A, p2.runtime.Action.A)
+	}
+	assert.Greater(t, p1.runtime.S, 100.)
+}
+
+func TestMotionDirection(t *testing.T) {
+	right := newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)
+	right.LeftLaneIds = []int32{2}
+	left := newTestLanePb(2, mapv2.LaneType_LANE_TYPE_DRIVING, 100)
+	left.RightLaneIds = []int32{1}
+	for _, node := range left.CenterLine.Nodes {
+		node.Y = 3.2
+	}
+	walk := newTestLanePb(3, mapv2.LaneType_LANE_TYPE_WALKING, 100)
+	ctx := newTestContext([]*mapv2.Lane{right, left, walk}, nil)
+	l1, l2 := ctx.laneManager.Get(1), ctx.laneManager.Get(2)
+
+	// 沿车道行驶时朝向为车道切向
+	p := newTestDrivingPerson(ctx, 1, l1, 10)
+	assert.InDelta(t, 0, p.ToMotionPb().Direction, 1e-9)
+
+	// 变道过程中朝向偏离车道切向，向左变道为逆时针偏转
+	p.snapshot.LC = lcRuntime{IsLC: true, ShadowLane: l1, ShadowS: 10, Yaw: .1, CompletedRatio: .3}
+	p.snapshot.Lane = l2
+	assert.InDelta(t, .1, p.ToMotionPb().Direction, 1e-9)
+	p.snapshot.LC.ShadowLane, p.snapshot.Lane = l2, l1
+	assert.InDelta(t, -.1, p.ToMotionPb().Direction, 1e-9)
+
+	// 行人反向行走时朝向与车道切向相反
+	p.snapshot = runtime{Status: personv2.Status_STATUS_WALKING, Lane: ctx.laneManager.Get(3), S: 10}
+	assert.InDelta(t, math.Pi, math.Abs(p.ToMotionPb().Direction), 1e-9)
+	p.snapshot.IsForward = true
+	assert.InDelta(t, 0, p.ToMotionPb().Direction, 1e-9)
+}
+
+func TestCompactSleeping(t *testing.T) {
+	defer flag.Set("person.compact_sleeping", "false")
+	// 运行步行出行场景，返回每一步的位置与状态
+	run := func(compact string) ([]geometry.Point, []personv2.Status) {
+		flag.Set("person.compact_sleeping", compact)
+		ctx, m, p := newTestWalkingScene()
+		m.Prepare()
+		if compact == "true" {
+			assert.Nil(t, p.multiModalRoute)
+		}
+		var xyz []geometry.Point
+		var status []personv2.Status
+		for i := 0; i < 20; i++ {
+			m.Update(ctx.clock.DT)
+			ctx.laneManager.Prepare()
+			ctx.aoiManager.Prepare()
+			m.PrepareNode()
+			m.Prepare()
+			xyz = append(xyz, p.XYZ())
+			status = append(status, p.Status())
+		}
+		// 行程结束后再次释放
+		assert.Equal(t, personv2.Status_STATUS_SLEEP, p.Status())
+		assert.Equal(t, int32(20), p.Aoi().ID())
+		assert.Equal(t, compact == "true", p.multiModalRoute == nil)
+		return xyz, status
+	}
+	xyz, status := run("false")
+	compactXYZ, compactStatus := run("true")
+	assert.Equal(t, xyz, compactXYZ)
+	assert.Equal(t, status, compactStatus)
+	assert.Contains(t, status, personv2.Status_STATUS_WALKING)
+}
+
+func BenchmarkCompactSleeping(b *testing.B) {
+	defer flag.Set("person.compact_sleeping", "false")
+	ctx, _, _ := newTestWalkingScene()
+	a := ctx.aoiManager.Get(10)
+	heap := func() uint64 {
+		var ms goruntime.MemStats
+		goruntime.GC()
+		goruntime.ReadMemStats(&ms)
+		return ms.HeapAlloc
+	}
+	for _, compact := range []string{"false", "true"} {
+		b.Run("compact="+compact, func(b *testing.B) {
+			flag.Set("person.compact_sleeping", compact)
+			for i := 0; i < b.N; i++ {
+				before := heap()
+				persons := make([]*Person, 10000)
+				for j := range persons {
+					persons[j] = newTestSleepingPerson(ctx, int32(j), a)
+					persons[j].prepare()
+				}
+				b.ReportMetric(float64(heap()-before)/float64(len(persons)), "B/person")
+				goruntime.KeepAlive(persons)
+			}
+		})
+	}
+}
+
+// newTestParkAndRideScene 创建驾车从AOI 10到停车场AOI 30，再步行到AOI 20的场景，返回管理器与人
+// extra为附加在驾车、步行两段journey之后的journey
+func newTestParkAndRideScene(extra ...*routingv2.Journey) (*testContext, *PersonManager, *Person) {
+	driving := newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)
+	walking := newTestLanePb(2, mapv2.LaneType_LANE_TYPE_WALKING, 100)
+	driving.ParentId, walking.ParentId = 1, 1
+	aois := []*mapv2.Aoi{newTestAoiPb(10, 1, 10), newTestAoiPb(30, 1, 50), newTestAoiPb(20, 1, 80)}
+	for _, a := range aois[1:] {
+		a.WalkingPositions = []*geov2.LanePosition{{LaneId: 2, S: a.DrivingPositions[0].S}}
+	}
+	ctx := newTestContext([]*mapv2.Lane{driving, walking}, aois)
+	rm := road.NewManager(ctx)
+	rm.Init([]*mapv2.Road{{Id: 1, LaneIds: []int32{1, 2}}}, ctx.laneManager)
+	ctx.roadManager = rm
+
+	p := newTestSleepingPerson(ctx, 1, ctx.aoiManager.Get(10))
+	p.vehicleAttr = &personv2.VehicleAttribute{
+		Length:                           5,
+		MaxSpeed:                         30,
+		MaxAcceleration:                  3,
+		UsualBrakingAcceleration:         -4.5,
+		MaxBrakingAcceleration:           -10,
+		MinGap:                           1,
+		Headway:                          1.5,
+		LaneMaxSpeedRecognitionDeviation: 1,
+	}
+	p.generator = randengine.New(1)
+	p.vehicle.controller = newController(p)
+	p.pedestrian.walkingV = 10
+	walkingJourney := func() *routingv2.Journey {
+		return &routingv2.Journey{
+			Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
+			Walking: &routingv2.WalkingJourneyBody{
+				Route: []*routingv2.WalkingRouteSegment{{LaneId: 2, MovingDirection: routingv2.MovingDirection_MOVING_DIRECTION_FORWARD}},
+				Eta:   3,
+			},
+		}
+	}
+	p.schedule.Set([]*tripv2.Schedule{{
+		Trips: []*tripv2.Trip{{
+			Mode: tripv2.TripMode_TRIP_MODE_DRIVE_ONLY,
+			End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 20}},
+			Routes: append([]*routingv2.Journey{{
+				Type:    routingv2.JourneyType_JOURNEY_TYPE_DRIVING,
+				Driving: &routingv2.DrivingJourneyBody{RoadIds: []int32{1}, Eta: 10},
+			}, walkingJourney()}, extra...),
+		}},
+		LoopCount: 1,
+	}}, 0)
+	m := newTestManager(p)
+	m.ctx = ctx
+	ctx.personManager = m
+	return ctx, m, p
+}
+
+// stepTestParkAndRide 运行n步，返回人依次经历的状态
+func stepTestParkAndRide(ctx *testContext, m *PersonManager, p *Person, n int, each func()) []personv2.Status {
+	var status []personv2.Status
+	for i := 0; i < n; i++ {
+		m.Update(ctx.clock.DT)
+		ctx.laneManager.Prepare()
+		ctx.aoiManager.Prepare()
+		m.PrepareNode()
+		m.Prepare()
+		if len(status) == 0 || status[len(status)-1] != p.Status() {
+			status = append(status, p.Status())
+		}
+		if each != nil {
+			each()
+		}
+	}
+	return status
+}
+
+func TestParkAndRide(t *testing.T) {
+	ctx, m, p := newTestParkAndRideScene()
+	transferred := false
+	status := stepTestParkAndRide(ctx, m, p, 40, func() {
+		if p.Status() == personv2.Status_STATUS_SLEEP && p.Aoi().ID() == 30 {
+			transferred = true
+		}
+	})
+	// 两段journey依次完成，换乘时在停车场内短暂停留，不重新导航
+	assert.True(t, transferred)
+	assert.Equal(t, []personv2.Status{
+		personv2.Status_STATUS_WAIT_ROUTE,
+		personv2.Status_STATUS_DRIVING,
+		personv2.Status_STATUS_SLEEP,
+		personv2.Status_STATUS_WAIT_ROUTE,
+		personv2.Status_STATUS_WALKING,
+		personv2.Status_STATUS_SLEEP,
+	}, status)
+	assert.Equal(t, int32(20), p.Aoi().ID())
+	assert.Empty(t, ctx.router.requests)
+	assert.False(t, p.multiModalRoute.Ok())
+}
+
+func TestParkAndRideTransferFailure(t *testing.T) {
+	// 第二、三段均为步行，之间没有换乘AOI，驾车结束后无法继续
+	ctx, m, p := newTestParkAndRideScene(&routingv2.Journey{
+		Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
+		Walking: &routingv2.WalkingJourneyBody{
+			Route: []*routingv2.WalkingRouteSegment{{LaneId: 2, MovingDirection: routingv2.MovingDirection_MOVING_DIRECTION_FORWARD}},
+			Eta:   3,
+		},
+	})
+	status := stepTestParkAndRide(ctx, m, p, 40, nil)
+	// 停留在驾车段的终点（停车场），按导航失败处理且不计入完成的行程
+	assert.Equal(t, []personv2.Status{
+		personv2.Status_STATUS_WAIT_ROUTE,
+		personv2.Status_STATUS_DRIVING,
+		personv2.Status_STATUS_SLEEP,
+	}, status)
+	assert.Equal(t, int32(30), p.Aoi().ID())
+	assert.Equal(t, int32(1), p.routeFailures)
+	assert.Equal(t, int32(0), m.runtime.NumCompletedTrips)
+	assert.True(t, p.schedule.Empty())
+	assert.False(t, p.multiModalRoute.Ok())
+}
+
+func TestAoiCapacity(t *testing.T) {
+	ctx, _, p1 := newTestWalkingScene()
+	p2 := newTestSleepingPerson(ctx, 2, ctx.aoiManager.Get(10))
+	p2.pedestrian.walkingV = 10
+	p2.schedule.Set([]*tripv2.Schedule{{
+		Trips: []*tripv2.Trip{{
+			Mode: tripv2.TripMode_TRIP_MODE_WALK_ONLY,
+			End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 20}},
+		}},
+		LoopCount: 1,
+	}}, 0)
+	m := newTestManager(p1, p2)
+	m.ctx = ctx
+	ctx.personManager = m
+	assert.Error(t, ctx.aoiManager.SetAoiCapacity(30, 1))
+	assert.NoError(t, ctx.aoiManager.SetAoiCapacity(20, 1))
+	run := func(steps int) {
+		for i := 0; i < steps; i++ {
+			m.Update(ctx.clock.DT)
+			ctx.laneManager.Prepare()
+			ctx.aoiManager.Prepare()
+			m.PrepareNode()
+			m.Prepare()
+		}
+	}
+	run(20)
+	assert.Equal(t, int32(1), ctx.aoiManager.Get(20).Capacity())
+
+	// 同时到达时按ID顺序接纳，第二个人在人行道上的出入口处等待，不占用车道
+	assert.Equal(t, personv2.Status_STATUS_SLEEP, p1.Status())
+	assert.Equal(t, int32(20), p1.Aoi().ID())
+	assert.Equal(t, personv2.Status_STATUS_SLEEP, p2.Status())
+	assert.Nil(t, p2.Aoi())
+	assert.Nil(t, p2.Lane())
+	assert.Equal(t, ctx.laneManager.Get(2).GetPositionByS(80), p2.snapshot.XYZ)
+
+	// 扩容后进入
+	assert.NoError(t, ctx.aoiManager.SetAoiCapacity(20, 2))
+	run(3)
+	assert.Equal(t, int32(20), p2.Aoi().ID())
+	assert.Nil(t, p2.Lane())
+}
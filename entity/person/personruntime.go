@@ -1,6 +1,8 @@
 package person
 
 import (
+	"math"
+
 	"git.fiblab.net/general/common/v2/geometry"
 	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
 	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
@@ -16,6 +18,7 @@ type lcRuntime struct {
 	ShadowS        float64      // 映射到变道前所在车道的位置
 	Yaw            float64      // 变道过程车头相对于前进方向的偏转角（弧度，总是为正，0代表不转向）
 	CompletedRatio float64      // 已完成的变道比例
+	Mandatory      bool         // 是否为强制变道
 }
 
 // InShadowLane 检查是否占据阴影车道
@@ -49,9 +52,14 @@ type runtime struct {
 	Action Action    // 车辆行为
 	LC     lcRuntime // 以下成员在变道时使用，仅当IsLC == true不为空时有意义
 
+	ZipperTarget  entity.ILane   // 在消失车道末端等待拉链式合流时的目标车道（nil表示不在等待）
+	ZipperYieldTo entity.IPerson // 拉链式合流中本车在当前车道上让行的车辆（每条车道只让行一次，驶入后继车道后清除）
+
 	// 行人的Runtime
 
 	IsForward bool // 是否正向行走
+
+	Teleporting bool // 是否正在因导航失败直线瞬移（route.teleport_fallback），期间状态保持为WAIT_ROUTE
 }
 
 // clearLaneChange 清除变道状态
@@ -80,6 +88,32 @@ func (rt *runtime) toPbPosition(ctx entity.ITaskContext) *geov2.Position {
 	return position
 }
 
+// direction 计算人的朝向
+// 功能：根据所在车道的切向角度计算人的朝向，车辆变道时叠加车头偏转角，行人反向行走时取反方向
+// 返回：朝向角（弧度，x轴正方向为0，逆时针为正，范围[-π, π]），不在车道上时为0
+func (rt *runtime) direction() float64 {
+	if rt.Lane == nil {
+		return 0
+	}
+	dir := rt.Lane.GetDirectionByS(rt.S).Direction
+	switch rt.Status {
+	case personv2.Status_STATUS_DRIVING:
+		if rt.LC.IsLC {
+			// 向左变道时车头逆时针偏转，向右变道时顺时针偏转
+			if rt.LC.ShadowLane.LeftLane() == rt.Lane {
+				dir += rt.LC.Yaw
+			} else {
+				dir -= rt.LC.Yaw
+			}
+		}
+	case personv2.Status_STATUS_WALKING:
+		if !rt.IsForward {
+			dir += math.Pi
+		}
+	}
+	return math.Atan2(math.Sin(dir), math.Cos(dir))
+}
+
 // ToPb 转换为protobuf人员运动数据
 // 功能：将运行时数据转换为protobuf格式的人员运动信息
 // 参数：ctx-任务上下文，self-人员实体
@@ -87,12 +121,13 @@ func (rt *runtime) toPbPosition(ctx entity.ITaskContext) *geov2.Position {
 // 说明：包含位置、速度、加速度、方向、活动等完整信息
 func (rt *runtime) ToPb(ctx entity.ITaskContext, self entity.IPerson) *personv2.PersonMotion {
 	pb := &personv2.PersonMotion{
-		Id:       self.ID(),
-		Status:   rt.Status,
-		Position: rt.toPbPosition(ctx),
-		V:        rt.V,
-		A:        rt.Action.A,
-		L:        self.Length(),
+		Id:        self.ID(),
+		Status:    rt.Status,
+		Position:  rt.toPbPosition(ctx),
+		V:         rt.V,
+		A:         rt.Action.A,
+		Direction: rt.direction(),
+		L:         self.Length(),
 	}
 	return pb
 }
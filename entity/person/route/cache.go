@@ -0,0 +1,168 @@
+package route
+
+import (
+	"container/list"
+	"flag"
+	"math"
+	"slices"
+	"sync"
+
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
+	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
+	"git.fiblab.net/sim/routing/v2/router/algo"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	routeCacheSize            = flag.Int("route.cache_size", 0, "本地导航结果LRU缓存的容量（<=0表示不缓存）")
+	routeCachePositionQuantum = flag.Float64("route.cache_position_quantum", 10, "导航缓存中车道位置S坐标的量化步长（米）")
+)
+
+// 导航缓存中的位置
+type routeCachePosition struct {
+	aoiID   int32 // AOI ID（车道位置时为-1）
+	laneID  int32 // 车道ID（AOI位置时为-1）
+	sBucket int64 // 量化后的S坐标
+}
+
+// 导航缓存的键
+type routeCacheKey struct {
+	typ          routingv2.RouteType
+	avoidTolls   bool
+	vehicleClass string
+	start, end   routeCachePosition
+	timeIndex    int // 路由库的时间片下标，同一时间片内道路代价相同
+}
+
+// 将位置转换为缓存位置，AOI内指定了XY坐标的位置不缓存
+func newRouteCachePosition(pb *geov2.Position) (routeCachePosition, bool) {
+	if aoi := pb.GetAoiPosition(); aoi != nil {
+		if pb.XyPosition != nil {
+			return routeCachePosition{}, false
+		}
+		return routeCachePosition{aoiID: aoi.AoiId, laneID: -1}, true
+	}
+	if lane := pb.GetLanePosition(); lane != nil {
+		return routeCachePosition{
+			aoiID:   -1,
+			laneID:  lane.LaneId,
+			sBucket: int64(math.Floor(lane.S / *routeCachePositionQuantum)),
+		}, true
+	}
+	return routeCachePosition{}, false
+}
+
+// 根据导航请求生成缓存键，返回false表示该请求不可缓存
+// laneRoad为车道ID->所属道路（路口）ID，起终点位于同一道路的请求不缓存：
+// 量化后起终点S的先后关系可能与请求相反，而两者的导航结果完全不同
+func newRouteCacheKey(in *routingv2.GetRouteRequest, opts RouteOptions, laneRoad map[int32]int32) (routeCacheKey, bool) {
+	start, ok := newRouteCachePosition(in.Start)
+	if !ok {
+		return routeCacheKey{}, false
+	}
+	end, ok := newRouteCachePosition(in.End)
+	if !ok {
+		return routeCacheKey{}, false
+	}
+	if start.laneID >= 0 && end.laneID >= 0 && laneRoad[start.laneID] == laneRoad[end.laneID] {
+		return routeCacheKey{}, false
+	}
+	return routeCacheKey{
+		typ:          in.GetType(),
+		avoidTolls:   opts.AvoidTolls,
+		vehicleClass: opts.VehicleClass,
+		start:        start,
+		end:          end,
+		timeIndex:    algo.TimeToIndex(in.Time),
+	}, true
+}
+
+// 导航结果LRU缓存（并发安全）
+// 缓存与返回的均为导航结果的深拷贝，调用方可以自由修改返回值
+type routeCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List // 按最近使用排序，表头为最近使用
+	items map[routeCacheKey]*list.Element
+}
+
+type routeCacheEntry struct {
+	key   routeCacheKey
+	res   *routingv2.GetRouteResponse
+	roads []int32 // 结果中驾车经过的道路，用于按道路使缓存失效
+}
+
+func newRouteCache(size int) *routeCache {
+	return &routeCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[routeCacheKey]*list.Element),
+	}
+}
+
+// 查询缓存
+func (c *routeCache) get(key routeCacheKey) (*routingv2.GetRouteResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return proto.Clone(e.Value.(*routeCacheEntry).res).(*routingv2.GetRouteResponse), true
+}
+
+// 写入缓存，超出容量时淘汰最久未使用的结果
+func (c *routeCache) put(key routeCacheKey, res *routingv2.GetRouteResponse) {
+	res = proto.Clone(res).(*routingv2.GetRouteResponse)
+	var roads []int32
+	for _, j := range res.Journeys {
+		if j.Driving != nil {
+			roads = append(roads, j.Driving.RoadIds...)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		entry := e.Value.(*routeCacheEntry)
+		entry.res, entry.roads = res, roads
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&routeCacheEntry{key: key, res: res, roads: roads})
+	for c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*routeCacheEntry).key)
+	}
+}
+
+// 删除驾车经过给定道路的结果，timeIndex不为nil时只删除该时间片的结果
+func (c *routeCache) removeRoads(roads map[int32]bool, timeIndex *int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.items {
+		if timeIndex != nil && key.timeIndex != *timeIndex {
+			continue
+		}
+		if slices.ContainsFunc(e.Value.(*routeCacheEntry).roads, func(id int32) bool { return roads[id] }) {
+			c.ll.Remove(e)
+			delete(c.items, key)
+		}
+	}
+}
+
+// 清空缓存
+func (c *routeCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	clear(c.items)
+}
+
+// 缓存的结果数量
+func (c *routeCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,77 @@
+package route
+
+import (
+	"flag"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
+	"git.fiblab.net/sim/routing/v2/router"
+	"github.com/samber/lo"
+)
+
+// 节能驾车导航的请求类型
+// routingv2.RouteType中暂无对应枚举值，因此使用枚举范围外的取值，仅由本地导航服务处理，返回的journey类型为驾车
+const RouteTypeEcoDriving routingv2.RouteType = 1001
+
+var (
+	ecoSignalStops     = flag.Float64("route.eco_signal_stops", 0.5, "节能导航中车辆在每个信控路口的预计停车次数")
+	ecoEnergyTimeValue = flag.Float64("route.eco_energy_time_value", 1, "节能导航中每千焦能耗折算的时间代价（秒/千焦），使道路的能耗代价与路口内车道的时间代价、限行与避开收费的惩罚（秒）单位一致")
+)
+
+const (
+	ecoVehicleMass  = 1500                         // 车辆质量（千克）
+	ecoRollingForce = ecoVehicleMass * 9.81 * 0.01 // 滚动阻力（牛），滚阻系数0.01
+	ecoDragFactor   = 0.5 * 1.2 * 0.3 * 2.2        // 空气阻力系数（牛/(米/秒)^2），空气密度1.2、风阻系数0.3、迎风面积2.2平方米
+)
+
+// ecoRoadCost 道路的能耗代价
+// 功能：以恒定车速通过道路的能耗加上在路口停车再启动损失的动能，作为节能导航的边权
+// 参数：length-道路长度（米），v-道路限速（米/秒），stops-道路末端路口的预计停车次数
+// 返回：能耗代价（千焦）
+func ecoRoadCost(length, v, stops float64) float64 {
+	cruise := length * (ecoRollingForce + ecoDragFactor*v*v)
+	stop := stops * 0.5 * ecoVehicleMass * v * v
+	return (cruise + stop) / 1000
+}
+
+// newEcoRouter 创建节能导航使用的路由器
+// 功能：将道路边权替换为能耗代价按route.eco_energy_time_value折算的时间代价，信控路口作为后继路口的道路额外计入预计停车的能耗
+// 参数：mapData-地图数据
+// 返回：道路边权为能耗折算时间代价（秒）的路由器
+// 说明：外部路由库不提供路口内车道边权的修改接口，路口内车道仍使用原有的时间代价（含转向惩罚），
+// 因此道路的能耗需折算为时间后再与之相加
+func newEcoRouter(mapData *mapv2.Map) *router.Router {
+	r := router.New(mapData, nil)
+	signalized := make(map[int32]bool, len(mapData.Junctions))
+	for _, j := range mapData.Junctions {
+		signalized[j.Id] = len(j.Phases) >= 2 || (j.FixedProgram != nil && len(j.FixedProgram.Phases) > 0)
+	}
+	lanes := lo.SliceToMap(mapData.Lanes, func(l *mapv2.Lane) (int32, *mapv2.Lane) {
+		return l.Id, l
+	})
+	for _, road := range mapData.Roads {
+		var length, v float64
+		count := 0
+		stops := 0.
+		for _, laneID := range road.LaneIds {
+			lane := lanes[laneID]
+			if lane.Type != mapv2.LaneType_LANE_TYPE_DRIVING {
+				continue
+			}
+			length += lane.Length
+			v += lane.MaxSpeed
+			count++
+			for _, suc := range lane.Successors {
+				if signalized[lanes[suc.Id].ParentId] {
+					stops = *ecoSignalStops
+				}
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		cost := ecoRoadCost(length/float64(count), v/float64(count), stops) * *ecoEnergyTimeValue
+		updateRoadCost(r, road.Id, func(float64) float64 { return cost })
+	}
+	return r
+}
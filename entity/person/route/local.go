@@ -1,140 +1,655 @@
 package route
 
 import (
+	"flag"
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
 	"sync"
 
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
 	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
 	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
 	"git.fiblab.net/sim/routing/v2/router"
+	"git.fiblab.net/sim/routing/v2/router/algo"
+	"github.com/samber/lo"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 )
 
+var (
+	tollTimeValue     = flag.Float64("route.toll_time_value", 60, "收费道路每单位费用折算的导航时间代价（秒）")
+	tollAvoidPenalty  = flag.Float64("route.toll_avoid_penalty", 1e5, "避开收费道路时对收费道路施加的导航时间惩罚（秒）")
+	restrictedPenalty = flag.Float64("route.restricted_road_penalty", 1e6, "车辆类别不允许通行的道路施加的导航时间惩罚（秒）")
+	maxConcurrency    = flag.Int("route.max_concurrency", 0, "本地导航回调版本同时运行的最大协程数（<=0表示不限制，每个请求一个协程）")
+)
+
+// 导航选项
+type RouteOptions = entity.RouteOptions
+
+// 导航器变体，按车辆类别限行、避开收费道路与节能导航组合道路代价
+type routerVariant struct {
+	class      string // 车辆类别（该类别没有不允许通行的道路时为空）
+	avoidTolls bool   // 是否避开收费道路（没有收费道路时为false）
+	eco        bool   // 是否为节能导航（道路代价为能耗折算的时间代价）
+}
+
 // 本地导航服务
 type LocalRouter struct {
-	router *router.Router
+	mapData     *mapv2.Map
+	router      *router.Router                   // 默认导航器
+	variants    map[routerVariant]*router.Router // 导航器变体（首次请求时创建，包含默认导航器）
+	variantMu   sync.Mutex                       // 保护variants与restricted的创建
+	tolls       map[int32]float64                // 道路ID->收费金额
+	tolled      []int32                          // 收费金额大于0的道路
+	roadClasses map[int32]map[string]bool        // 道路ID->允许通行的车辆类别（仅包含有限制的道路）
+	restricted  map[string][]int32               // 车辆类别->不允许该类别通行的道路（首次请求时计算）
+	penalties   map[int32]float64                // 道路ID->额外时间代价（如临时事件）
+	cache       *routeCache                      // 导航结果缓存（未启用时为nil）
+	laneRoad    map[int32]int32                  // 车道ID->所属道路（路口）ID，用于判断请求能否缓存
+	roadCosts   map[int32]*roadCostOverride      // 通过SetRoadCost修改的道路代价，创建导航器时应用
+	mu          sync.RWMutex                     // 保护导航器的重建与道路代价的修改，查询时持有读锁
 
 	wg sync.WaitGroup
+
+	// 限制并发时的待处理请求队列与工作协程
+	poolMu  sync.Mutex
+	queue   []routeJob
+	workers int
+}
+
+// 通过SetRoadCost修改的道路代价（不含通行费）
+type roadCostOverride struct {
+	all    *float64        // 所有时间片的代价（未修改时为nil）
+	slices map[int]float64 // 时间片下标->代价，在all之后修改
+}
+
+// 回调版本的导航请求
+type routeJob struct {
+	in      *routingv2.GetRouteRequest
+	opts    RouteOptions
+	process func(res *routingv2.GetRouteResponse)
+	ch      chan struct{}
 }
 
 // 创建本地导航服务
+// tolls为道路ID->收费金额，默认导航在收费道路上增加折算的时间代价，避开收费道路的导航则增加较大的惩罚
 func NewLocalRouter(
 	mapData *mapv2.Map,
+	tolls map[int32]float64,
 ) *LocalRouter {
 	r := &LocalRouter{
-		router: router.New(mapData, nil),
+		mapData:    mapData,
+		tolls:      tolls,
+		restricted: make(map[string][]int32),
+		laneRoad:   make(map[int32]int32, len(mapData.Lanes)),
+		roadCosts:  make(map[int32]*roadCostOverride),
+	}
+	for roadID, toll := range tolls {
+		if toll > 0 {
+			r.tolled = append(r.tolled, roadID)
+		}
+	}
+	for _, lane := range mapData.Lanes {
+		r.laneRoad[lane.Id] = lane.ParentId
+	}
+	if *routeCacheSize > 0 {
+		r.cache = newRouteCache(*routeCacheSize)
+	}
+	r.build()
+	return r
+}
+
+// 根据地图数据构建默认导航器，其他导航器变体在下次请求时重新创建
+func (l *LocalRouter) build() {
+	l.variants = make(map[routerVariant]*router.Router)
+	l.router = l.newRouter(routerVariant{})
+	l.variants[routerVariant{}] = l.router
+}
+
+// 创建导航器变体：以地图默认代价（节能导航为能耗折算的时间代价）为基础，应用通过SetRoadCost修改的道路代价，
+// 再叠加通行费与限行道路的代价
+// 说明：调用方需持有写锁，或持有读锁与variantMu
+func (l *LocalRouter) newRouter(v routerVariant) *router.Router {
+	var r *router.Router
+	if v.eco {
+		r = newEcoRouter(l.mapData)
+	} else {
+		r = router.New(l.mapData, nil)
+	}
+	extras := append(slices.Clone(l.tolled), l.restrictedRoads(v.class)...)
+	extras = append(extras, lo.Keys(l.penalties)...)
+	for _, roadID := range lo.Uniq(extras) {
+		if extra := l.roadExtra(v, roadID); extra != 0 {
+			addRoadCost(r, roadID, extra)
+		}
+	}
+	if v.eco {
+		// 节能导航的道路代价由能耗折算，不应用通行时间代价的修改
+		return r
+	}
+	for roadID, o := range l.roadCosts {
+		if o.all != nil {
+			if err := l.setVariantRoadCost(r, v, roadID, *o.all, nil); err != nil {
+				log.Warnf("restore cost of road %d failed: %v", roadID, err)
+			}
+		}
+		for i, cost := range o.slices {
+			t := float64(i * algo.TIME_SLICE_INTERVAl)
+			if err := l.setVariantRoadCost(r, v, roadID, cost, &t); err != nil {
+				log.Warnf("restore cost of road %d failed: %v", roadID, err)
+			}
+		}
+	}
+	return r
+}
+
+// 道路在导航器变体中叠加的额外代价
+// 收费道路：避开收费道路时为惩罚，否则为通行费折算的时间代价（节能导航不折算）；
+// 不允许该车辆类别通行的道路：额外叠加限行惩罚；通过SetRoadPenalties设置的时间代价（节能导航不叠加）
+func (l *LocalRouter) roadExtra(v routerVariant, roadID int32) float64 {
+	extra := 0.
+	if !v.eco {
+		extra += l.penalties[roadID]
+	}
+	if toll := l.tolls[roadID]; toll > 0 {
+		if v.avoidTolls {
+			extra += *tollAvoidPenalty
+		} else if !v.eco {
+			extra += toll * *tollTimeValue
+		}
+	}
+	if v.class != "" {
+		if allowed, ok := l.roadClasses[roadID]; ok && !allowed[v.class] {
+			extra += *restrictedPenalty
+		}
+	}
+	return extra
+}
+
+// 不允许车辆类别通行的道路，每个类别只计算一次
+// 说明：调用方需持有写锁，或持有读锁与variantMu
+func (l *LocalRouter) restrictedRoads(class string) []int32 {
+	if class == "" {
+		return nil
 	}
+	if roads, ok := l.restricted[class]; ok {
+		return roads
+	}
+	roads := make([]int32, 0)
+	for roadID, allowed := range l.roadClasses {
+		if !allowed[class] {
+			roads = append(roads, roadID)
+		}
+	}
+	l.restricted[class] = roads
+	return roads
+}
+
+// 获取导航选项对应的导航器变体，首次请求时创建
+// 选项不影响结果时（没有收费道路、该车辆类别没有不允许通行的道路）复用对应的导航器
+// 说明：调用方需持有读锁
+func (l *LocalRouter) getRouter(opts RouteOptions, eco bool) *router.Router {
+	l.variantMu.Lock()
+	defer l.variantMu.Unlock()
+	v := routerVariant{avoidTolls: opts.AvoidTolls && len(l.tolled) > 0, eco: eco}
+	if len(l.restrictedRoads(opts.VehicleClass)) > 0 {
+		v.class = opts.VehicleClass
+	}
+	if r, ok := l.variants[v]; ok {
+		return r
+	}
+	r := l.newRouter(v)
+	l.variants[v] = r
 	return r
 }
 
+// 运行时添加AOI，使其可以作为导航的起终点
+// 说明：路由库不支持增量添加AOI，因此以添加后的地图数据重建导航器并清空导航缓存，
+// 此前通过SetRoadCost修改的道路代价在重建后重新应用
+func (l *LocalRouter) AddAois(aois []*mapv2.Aoi) {
+	l.mu.Lock()
+	l.mapData.Aois = append(l.mapData.Aois, aois...)
+	l.build()
+	l.mu.Unlock()
+	l.InvalidateCache()
+}
+
+// 设置道路允许通行的车辆类别（道路ID->类别列表，未包含的道路不限制），并清空导航缓存
+// 说明：按车辆类别的导航器变体在下次请求时重新创建
+func (l *LocalRouter) SetRoadAllowedClasses(classes map[int32][]string) {
+	roadClasses := make(map[int32]map[string]bool, len(classes))
+	for roadID, cs := range classes {
+		roadClasses[roadID] = make(map[string]bool, len(cs))
+		for _, c := range cs {
+			roadClasses[roadID][c] = true
+		}
+	}
+	l.mu.Lock()
+	l.roadClasses = roadClasses
+	l.restricted = make(map[string][]int32)
+	for v := range l.variants {
+		if v.class != "" {
+			delete(l.variants, v)
+		}
+	}
+	l.mu.Unlock()
+	l.InvalidateCache()
+}
+
+// 设置道路的额外时间代价（道路ID->代价，如临时事件），替换此前设置的全部额外代价，并清空导航缓存
+// 说明：额外代价叠加在道路代价之上，与SetRoadCost修改的代价相互独立
+func (l *LocalRouter) SetRoadPenalties(penalties map[int32]float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer l.InvalidateCache()
+	old := l.penalties
+	l.penalties = penalties
+	for _, roadID := range lo.Uniq(append(lo.Keys(old), lo.Keys(penalties)...)) {
+		delta := penalties[roadID] - old[roadID]
+		if delta == 0 {
+			continue
+		}
+		for v, r := range l.variants {
+			if !v.eco {
+				addRoadCost(r, roadID, delta)
+			}
+		}
+	}
+}
+
+// 修改道路的时间代价（不含通行费），并使经过该道路的导航缓存失效
+// t为nil时修改所有时间片，否则只修改t所在的时间片；收费道路仍按原方式叠加通行费代价
+func (l *LocalRouter) SetRoadCost(roadID int32, cost float64, t *float64) error {
+	return l.SetRoadCosts(map[int32]float64{roadID: cost}, t)
+}
+
+// 批量修改道路的时间代价（道路ID->代价，不含通行费），全部修改完成后使经过这些道路的导航缓存失效
+// t为nil时修改所有时间片，否则只修改t所在的时间片
+// 说明：修改期间持有写锁，与正在进行的查询互斥
+func (l *LocalRouter) SetRoadCosts(costs map[int32]float64, t *float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer l.invalidateRoads(lo.MapValues(costs, func(float64, int32) bool { return true }), t)
+	for roadID, cost := range costs {
+		l.recordRoadCost(roadID, cost, t)
+		for v, r := range l.variants {
+			if v.eco {
+				continue
+			}
+			if err := l.setVariantRoadCost(r, v, roadID, cost, t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 记录修改后的道路代价，修改所有时间片时覆盖此前对单个时间片的修改
+// 说明：调用方需持有写锁
+func (l *LocalRouter) recordRoadCost(roadID int32, cost float64, t *float64) {
+	o, ok := l.roadCosts[roadID]
+	if !ok {
+		o = &roadCostOverride{}
+		l.roadCosts[roadID] = o
+	}
+	if t == nil {
+		o.all = &cost
+		o.slices = nil
+		return
+	}
+	if o.slices == nil {
+		o.slices = make(map[int]float64)
+	}
+	o.slices[algo.TimeToIndex(*t)] = cost
+}
+
+// 修改导航器变体中道路的时间代价，并叠加该变体的额外代价
+func (l *LocalRouter) setVariantRoadCost(r *router.Router, v routerVariant, roadID int32, cost float64, t *float64) error {
+	c := cost + l.roadExtra(v, roadID)
+	if t != nil {
+		return r.SetRoadCost(roadID, c, t)
+	}
+	updateRoadCost(r, roadID, func(float64) float64 { return c })
+	return nil
+}
+
+// 清空导航缓存，在道路代价变化（如封路、限速调整）后调用
+func (l *LocalRouter) InvalidateCache() {
+	if l.cache != nil {
+		l.cache.clear()
+	}
+}
+
+// 使驾车经过给定道路的导航缓存失效，t不为nil时只使t所在时间片的结果失效
+// 说明：未经过这些道路的结果仍然保留，道路代价降低后其中的路线可能不再最优，直到被淘汰
+func (l *LocalRouter) invalidateRoads(roads map[int32]bool, t *float64) {
+	if l.cache == nil {
+		return
+	}
+	if t == nil {
+		l.cache.removeRoads(roads, nil)
+		return
+	}
+	i := algo.TimeToIndex(*t)
+	l.cache.removeRoads(roads, &i)
+}
+
+// 在所有时间片上为道路增加额外的时间代价
+func addRoadCost(r *router.Router, roadID int32, extra float64) {
+	updateRoadCost(r, roadID, func(cost float64) float64 { return cost + extra })
+}
+
+// 在所有时间片上修改道路的代价
+// 说明：路由库按时间片整体替换边权的接口不会生效，因此逐个时间片修改
+func updateRoadCost(r *router.Router, roadID int32, update func(cost float64) float64) {
+	for i := 0; i < algo.TIME_SLICE_LENGTH; i++ {
+		t := float64(i * algo.TIME_SLICE_INTERVAl)
+		cost, err := r.GetRoadCost(roadID, &t)
+		if err != nil {
+			log.Warnf("update cost of road %d failed: %v", roadID, err)
+			return
+		}
+		if err := r.SetRoadCost(roadID, update(cost), &t); err != nil {
+			log.Warnf("update cost of road %d failed: %v", roadID, err)
+			return
+		}
+	}
+}
+
 // 路径规划（回调版本）
 func (l *LocalRouter) GetRoute(
 	in *routingv2.GetRouteRequest,
 	process func(res *routingv2.GetRouteResponse),
 ) chan struct{} {
-	ch := make(chan struct{})
+	return l.GetRouteWithOptions(in, RouteOptions{}, process)
+}
+
+// 路径规划（回调版本，带导航选项）
+func (l *LocalRouter) GetRouteWithOptions(
+	in *routingv2.GetRouteRequest,
+	opts RouteOptions,
+	process func(res *routingv2.GetRouteResponse),
+) chan struct{} {
+	job := routeJob{in: in, opts: opts, process: process, ch: make(chan struct{})}
 	l.wg.Add(1)
-	go func() {
-		defer l.wg.Done()
-		// response
-		res := &routingv2.GetRouteResponse{}
-		// 请求处理
-		start, end := in.Start, in.End
-		// ATTENTION: 内联导航不再检查数据范围和格式
-		switch in.GetType() {
-		case routingv2.RouteType_ROUTE_TYPE_DRIVING, routingv2.RouteType_ROUTE_TYPE_TAXI:
-			var journeyType routingv2.JourneyType
-			if in.GetType() == routingv2.RouteType_ROUTE_TYPE_DRIVING {
-				journeyType = routingv2.JourneyType_JOURNEY_TYPE_DRIVING
-			} else if in.GetType() == routingv2.RouteType_ROUTE_TYPE_TAXI {
-				journeyType = routingv2.JourneyType_JOURNEY_TYPE_BY_TAXI
+	if *maxConcurrency <= 0 {
+		go l.run(job)
+		return job.ch
+	}
+	// 请求进入队列，工作协程不足上限时新建工作协程，调用方不会被阻塞
+	l.poolMu.Lock()
+	l.queue = append(l.queue, job)
+	if l.workers < *maxConcurrency {
+		l.workers++
+		go l.work()
+	}
+	l.poolMu.Unlock()
+	return job.ch
+}
+
+// 执行一个回调版本的导航请求，完成回调后关闭通知通道
+func (l *LocalRouter) run(job routeJob) {
+	defer l.wg.Done()
+	job.process(l.route(job.in, job.opts))
+	close(job.ch)
+}
+
+// 工作协程：依次处理队列中的请求，队列为空时退出
+func (l *LocalRouter) work() {
+	for {
+		l.poolMu.Lock()
+		if len(l.queue) == 0 {
+			l.workers--
+			l.poolMu.Unlock()
+			return
+		}
+		job := l.queue[0]
+		l.queue[0] = routeJob{}
+		l.queue = l.queue[1:]
+		l.poolMu.Unlock()
+		l.run(job)
+	}
+}
+
+// 执行路径规划，优先使用缓存的结果
+func (l *LocalRouter) route(in *routingv2.GetRouteRequest, opts RouteOptions) *routingv2.GetRouteResponse {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.routeLocked(in, opts)
+}
+
+// 执行路径规划，优先使用缓存的结果
+// 说明：调用方需持有读锁，查询与写入缓存期间道路代价不会被修改
+func (l *LocalRouter) routeLocked(in *routingv2.GetRouteRequest, opts RouteOptions) *routingv2.GetRouteResponse {
+	key, cacheable := newRouteCacheKey(in, opts, l.laneRoad)
+	if cacheable && l.cache != nil {
+		if res, ok := l.cache.get(key); ok {
+			return res
+		}
+	}
+	res := l.search(in, opts)
+	if cacheable && l.cache != nil {
+		l.cache.put(key, res)
+	}
+	return res
+}
+
+// 执行路径规划
+// 说明：调用方需持有读锁
+func (l *LocalRouter) search(in *routingv2.GetRouteRequest, opts RouteOptions) *routingv2.GetRouteResponse {
+	r := l.router
+	// response
+	res := &routingv2.GetRouteResponse{}
+	// 请求处理
+	start, end := in.Start, in.End
+	// ATTENTION: 内联导航不再检查数据范围和格式
+	switch in.GetType() {
+	case routingv2.RouteType_ROUTE_TYPE_DRIVING, routingv2.RouteType_ROUTE_TYPE_TAXI:
+		var journeyType routingv2.JourneyType
+		if in.GetType() == routingv2.RouteType_ROUTE_TYPE_DRIVING {
+			journeyType = routingv2.JourneyType_JOURNEY_TYPE_DRIVING
+		} else if in.GetType() == routingv2.RouteType_ROUTE_TYPE_TAXI {
+			journeyType = routingv2.JourneyType_JOURNEY_TYPE_BY_TAXI
+		}
+		if roadIDs, cost, err := l.getRouter(opts, false).SearchDriving(start, end, in.Time); err != nil {
+			// log.Warnf("search driving failed from %v to %v at t=%f: %v", start, end, in.Time, err)
+		} else {
+			res.Journeys = append(res.Journeys, &routingv2.Journey{
+				Type: journeyType,
+				Driving: &routingv2.DrivingJourneyBody{
+					RoadIds: roadIDs,
+					Eta:     cost,
+				},
+			})
+		}
+	case RouteTypeEcoDriving:
+		if roadIDs, _, err := l.getRouter(opts, true).SearchDriving(start, end, in.Time); err == nil {
+			// 节能导航的代价为能耗，预计用时按默认导航的道路时间代价估计（不含路口）
+			eta := 0.
+			for _, roadID := range roadIDs {
+				if cost, err := l.router.GetRoadCost(roadID, &in.Time); err == nil {
+					eta += cost
+				}
+			}
+			res.Journeys = append(res.Journeys, &routingv2.Journey{
+				Type: routingv2.JourneyType_JOURNEY_TYPE_DRIVING,
+				Driving: &routingv2.DrivingJourneyBody{
+					RoadIds: roadIDs,
+					Eta:     eta,
+				},
+			})
+		}
+	case routingv2.RouteType_ROUTE_TYPE_WALKING:
+		if segments, cost, err := r.SearchWalking(start, end, in.Time); err != nil {
+			log.Warnf("search walking failed from %v to %v at t=%f: %v", start, end, in.Time, err)
+		} else {
+			res.Journeys = append(res.Journeys, &routingv2.Journey{
+				Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
+				Walking: &routingv2.WalkingJourneyBody{
+					Route: segments,
+					Eta:   cost,
+				},
+			})
+		}
+	case routingv2.RouteType_ROUTE_TYPE_BUS, routingv2.RouteType_ROUTE_TYPE_SUBWAY, routingv2.RouteType_ROUTE_TYPE_BUS_SUBWAY:
+		var availableSublineTypes []mapv2.SublineType
+		var ptType string
+		if in.GetType() == routingv2.RouteType_ROUTE_TYPE_BUS {
+			availableSublineTypes = []mapv2.SublineType{mapv2.SublineType_SUBLINE_TYPE_BUS}
+			ptType = "bus"
+		} else if in.GetType() == routingv2.RouteType_ROUTE_TYPE_SUBWAY {
+			availableSublineTypes = []mapv2.SublineType{mapv2.SublineType_SUBLINE_TYPE_SUBWAY}
+			ptType = "subway"
+		} else if in.GetType() == routingv2.RouteType_ROUTE_TYPE_BUS_SUBWAY {
+			availableSublineTypes = []mapv2.SublineType{mapv2.SublineType_SUBLINE_TYPE_BUS, mapv2.SublineType_SUBLINE_TYPE_SUBWAY}
+			ptType = "bus, subway"
+		}
+		log.Debugf("Search %v route from %v to %v", ptType, start, end)
+		if startWalkSegments, startWalkCost, transferSegment, transferCost, endWalkSegments, endWalkCost, err := r.SearchBus(start, end, in.Time, availableSublineTypes); err != nil {
+			log.Warnf("search bus failed from %v to %v at t=%f: %v", start, end, in.Time, err)
+		} else {
+			// 步行去车站
+			if startWalkSegments != nil {
+				res.Journeys = append(res.Journeys, &routingv2.Journey{
+					Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
+					Walking: &routingv2.WalkingJourneyBody{
+						Route: startWalkSegments,
+						Eta:   startWalkCost,
+					},
+				})
 			}
-			if roadIDs, cost, err := l.router.SearchDriving(start, end, in.Time); err != nil {
-				// log.Warnf("search driving failed from %v to %v at t=%f: %v", start, end, in.Time, err)
-			} else {
+			// 车站->车站
+			if len(transferSegment) > 0 {
 				res.Journeys = append(res.Journeys, &routingv2.Journey{
-					Type: journeyType,
-					Driving: &routingv2.DrivingJourneyBody{
-						RoadIds: roadIDs,
-						Eta:     cost,
+					Type: routingv2.JourneyType_JOURNEY_TYPE_BY_BUS,
+					ByBus: &routingv2.BusJourneyBody{
+						Transfers: transferSegment,
+						Eta:       transferCost,
 					},
 				})
 			}
-		case routingv2.RouteType_ROUTE_TYPE_WALKING:
-			if segments, cost, err := l.router.SearchWalking(start, end, in.Time); err != nil {
-				log.Warnf("search walking failed from %v to %v at t=%f: %v", start, end, in.Time, err)
-			} else {
+			// 步行去车站
+			if endWalkSegments != nil {
 				res.Journeys = append(res.Journeys, &routingv2.Journey{
 					Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
 					Walking: &routingv2.WalkingJourneyBody{
-						Route: segments,
-						Eta:   cost,
+						Route: endWalkSegments,
+						Eta:   endWalkCost,
 					},
 				})
 			}
-		case routingv2.RouteType_ROUTE_TYPE_BUS, routingv2.RouteType_ROUTE_TYPE_SUBWAY, routingv2.RouteType_ROUTE_TYPE_BUS_SUBWAY:
-			var availableSublineTypes []mapv2.SublineType
-			var ptType string
-			if in.GetType() == routingv2.RouteType_ROUTE_TYPE_BUS {
-				availableSublineTypes = []mapv2.SublineType{mapv2.SublineType_SUBLINE_TYPE_BUS}
-				ptType = "bus"
-			} else if in.GetType() == routingv2.RouteType_ROUTE_TYPE_SUBWAY {
-				availableSublineTypes = []mapv2.SublineType{mapv2.SublineType_SUBLINE_TYPE_SUBWAY}
-				ptType = "subway"
-			} else if in.GetType() == routingv2.RouteType_ROUTE_TYPE_BUS_SUBWAY {
-				availableSublineTypes = []mapv2.SublineType{mapv2.SublineType_SUBLINE_TYPE_BUS, mapv2.SublineType_SUBLINE_TYPE_SUBWAY}
-				ptType = "bus, subway"
-			}
-			log.Debugf("Search %v route from %v to %v", ptType, start, end)
-			if startWalkSegments, startWalkCost, transferSegment, transferCost, endWalkSegments, endWalkCost, err := l.router.SearchBus(start, end, in.Time, availableSublineTypes); err != nil {
-				log.Warnf("search bus failed from %v to %v at t=%f: %v", start, end, in.Time, err)
-			} else {
-				// 步行去车站
-				if startWalkSegments != nil {
-					res.Journeys = append(res.Journeys, &routingv2.Journey{
-						Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
-						Walking: &routingv2.WalkingJourneyBody{
-							Route: startWalkSegments,
-							Eta:   startWalkCost,
-						},
-					})
-				}
-				// 车站->车站
-				if len(transferSegment) > 0 {
-					res.Journeys = append(res.Journeys, &routingv2.Journey{
-						Type: routingv2.JourneyType_JOURNEY_TYPE_BY_BUS,
-						ByBus: &routingv2.BusJourneyBody{
-							Transfers: transferSegment,
-							Eta:       transferCost,
-						},
-					})
-				}
-				// 步行去车站
-				if endWalkSegments != nil {
-					res.Journeys = append(res.Journeys, &routingv2.Journey{
-						Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
-						Walking: &routingv2.WalkingJourneyBody{
-							Route: endWalkSegments,
-							Eta:   endWalkCost,
-						},
-					})
-				}
-			}
-
-		default:
-			log.Panic("wrong routing type")
 		}
 
-		process(res)
-		close(ch)
-	}()
-	return ch
+	default:
+		log.Panic("wrong routing type")
+	}
+
+	return res
 }
 
 // 路径规划（同步版本）
 func (l *LocalRouter) GetRouteSync(in *routingv2.GetRouteRequest) *routingv2.GetRouteResponse {
+	return l.GetRouteSyncWithOptions(in, RouteOptions{})
+}
+
+// 路径规划（同步版本，带导航选项）
+func (l *LocalRouter) GetRouteSyncWithOptions(in *routingv2.GetRouteRequest, opts RouteOptions) *routingv2.GetRouteResponse {
 	var res *routingv2.GetRouteResponse
 	process := func(r *routingv2.GetRouteResponse) {
 		res = r
 	}
-	<-l.GetRoute(in, process)
+	<-l.GetRouteWithOptions(in, opts, process)
 	return res
 }
+
+// 批量路径规划（同步版本）
+// 请求分配给数量为GOMAXPROCS的工作协程并行处理，返回结果与请求一一对应
+// 说明：整个批次期间持有读锁，道路代价的修改与导航器重建会等待批次完成，各请求基于同一份道路代价；
+// 工作协程不再重复获取读锁，避免写锁等待时读锁重入导致死锁
+func (l *LocalRouter) GetRoutesBatch(reqs []*routingv2.GetRouteRequest) []*routingv2.GetRouteResponse {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	res := make([]*routingv2.GetRouteResponse, len(reqs))
+	workers := min(runtime.GOMAXPROCS(0), len(reqs))
+	indices := make(chan int, len(reqs))
+	for i := range reqs {
+		indices <- i
+	}
+	close(indices)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				res[i] = l.routeLocked(reqs[i], RouteOptions{})
+			}
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+// 计算AOI之间的出行时间矩阵
+// 功能：对aoiIDs中的每一对AOI按出行方式mode在t时刻进行导航，返回N*N的预计用时矩阵（秒），用于可达性分析
+// 返回：出行时间矩阵，mode不是支持的出行方式时返回错误
+// 说明：矩阵第i行第j列为从aoiIDs[i]到aoiIDs[j]的预计用时（多段出行时为各段之和），对角线为0，无法到达时为+Inf；
+// 全部请求通过GetRoutesBatch批量并行处理
+func (l *LocalRouter) ComputeTravelTimeMatrix(aoiIDs []int32, t float64, mode routingv2.RouteType) ([][]float64, error) {
+	switch mode {
+	case routingv2.RouteType_ROUTE_TYPE_DRIVING, routingv2.RouteType_ROUTE_TYPE_TAXI,
+		routingv2.RouteType_ROUTE_TYPE_WALKING,
+		routingv2.RouteType_ROUTE_TYPE_BUS, routingv2.RouteType_ROUTE_TYPE_SUBWAY, routingv2.RouteType_ROUTE_TYPE_BUS_SUBWAY:
+	default:
+		return nil, fmt.Errorf("unsupported route type %v", mode)
+	}
+	n := len(aoiIDs)
+	reqs := make([]*routingv2.GetRouteRequest, 0, n*(n-1))
+	for i, from := range aoiIDs {
+		for j, to := range aoiIDs {
+			if i == j {
+				continue
+			}
+			reqs = append(reqs, &routingv2.GetRouteRequest{
+				Type:  mode,
+				Start: &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: from}},
+				End:   &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: to}},
+				Time:  t,
+			})
+		}
+	}
+	res := l.GetRoutesBatch(reqs)
+	matrix := make([][]float64, n)
+	k := 0
+	for i := range matrix {
+		matrix[i] = make([]float64, n)
+		for j := range matrix[i] {
+			if i == j {
+				continue
+			}
+			matrix[i][j] = journeysEta(res[k])
+			k++
+		}
+	}
+	return matrix, nil
+}
+
+// journeysEta 导航结果中各段出行的预计用时之和，没有结果时为+Inf
+func journeysEta(res *routingv2.GetRouteResponse) float64 {
+	if len(res.Journeys) == 0 {
+		return math.Inf(1)
+	}
+	eta := 0.
+	for _, j := range res.Journeys {
+		switch {
+		case j.Driving != nil:
+			eta += j.Driving.Eta
+		case j.Walking != nil:
+			eta += j.Walking.Eta
+		case j.ByBus != nil:
+			eta += j.ByBus.Eta
+		}
+	}
+	return eta
+}
@@ -0,0 +1,612 @@
+package route
+
+import (
+	"math"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+const testJunctionIDBase = 3_0000_0000
+
+// testNetwork 测试路网，每条道路只有一条行车道
+// 几何坐标统一置于原点，使导航的A*启发函数为0，结果只由边权决定
+type testNetwork struct {
+	m          *mapv2.Map
+	roadLanes  map[int32]*mapv2.Lane // 道路ID->道路上的行车道
+	nextLaneID int32
+}
+
+func newTestNetwork() *testNetwork {
+	return &testNetwork{
+		m:          &mapv2.Map{Header: &mapv2.Header{}},
+		roadLanes:  make(map[int32]*mapv2.Lane),
+		nextLaneID: 1,
+	}
+}
+
+func (n *testNetwork) addLane(parentID int32, length, maxSpeed float64, turn mapv2.LaneTurn) *mapv2.Lane {
+	lane := &mapv2.Lane{
+		Id:       n.nextLaneID,
+		Type:     mapv2.LaneType_LANE_TYPE_DRIVING,
+		Turn:     turn,
+		Length:   length,
+		MaxSpeed: maxSpeed,
+		CenterLine: &geov2.Polyline{
+			Nodes: []*geov2.XYPosition{{X: 0, Y: 0}, {X: 0, Y: 0}},
+		},
+		ParentId: parentID,
+	}
+	n.nextLaneID++
+	n.m.Lanes = append(n.m.Lanes, lane)
+	return lane
+}
+
+// addRoad 添加道路（长度：米，限速：米/秒）
+func (n *testNetwork) addRoad(id int32, length, maxSpeed float64) {
+	lane := n.addLane(id, length, maxSpeed, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.roadLanes[id] = lane
+	n.m.Roads = append(n.m.Roads, &mapv2.Road{Id: id, LaneIds: []int32{lane.Id}})
+}
+
+// connect 在路口junction内添加从道路from到道路to的车道
+func (n *testNetwork) connect(junction, from, to int32, turn mapv2.LaneTurn) {
+	fromLane, toLane := n.roadLanes[from], n.roadLanes[to]
+	lane := n.addLane(testJunctionIDBase+junction, 10, 10, turn)
+	lane.Predecessors = []*mapv2.LaneConnection{{Id: fromLane.Id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL}}
+	lane.Successors = []*mapv2.LaneConnection{{Id: toLane.Id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD}}
+	fromLane.Successors = append(fromLane.Successors, &mapv2.LaneConnection{Id: lane.Id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD})
+	toLane.Predecessors = append(toLane.Predecessors, &mapv2.LaneConnection{Id: lane.Id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL})
+}
+
+func (n *testNetwork) position(road int32, s float64) *geov2.Position {
+	return &geov2.Position{LanePosition: &geov2.LanePosition{LaneId: n.roadLanes[road].Id, S: s}}
+}
+
+func (n *testNetwork) drivingRequest(from, to int32) *routingv2.GetRouteRequest {
+	return &routingv2.GetRouteRequest{
+		Type:  routingv2.RouteType_ROUTE_TYPE_DRIVING,
+		Start: n.position(from, 0),
+		End:   n.position(to, 0),
+	}
+}
+
+// newShortcutNetwork 创建包含捷径的路网
+// 1 -> J1 -> 2（捷径，1000米）-> J2 -> 5
+// 1 -> J1 -> 3（600米）-> J3 -> 4（600米）-> J2 -> 5
+// 限速均为10米/秒，捷径比绕行快36秒
+func newShortcutNetwork() *testNetwork {
+	n := newTestNetwork()
+	n.addRoad(1, 500, 10)
+	n.addRoad(2, 1000, 10)
+	n.addRoad(3, 600, 10)
+	n.addRoad(4, 600, 10)
+	n.addRoad(5, 500, 10)
+	n.connect(1, 1, 2, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.connect(1, 1, 3, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.connect(3, 3, 4, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.connect(2, 2, 5, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.connect(2, 4, 5, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	return n
+}
+
+func drivingRoadIDs(t *testing.T, res *routingv2.GetRouteResponse) []int32 {
+	if !assert.Len(t, res.Journeys, 1) {
+		return nil
+	}
+	return res.Journeys[0].Driving.RoadIds
+}
+
+func TestTollRouting(t *testing.T) {
+	n := newShortcutNetwork()
+	req := n.drivingRequest(1, 5)
+
+	free := NewLocalRouter(n.m, nil)
+	freeRes := free.GetRouteSync(req)
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, freeRes))
+	// 没有收费道路时，避开收费道路的选项不影响结果
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, free.GetRouteSyncWithOptions(req, RouteOptions{AvoidTolls: true})))
+
+	// 捷径收费0.5，折算为30秒，仍比绕行快
+	tolled := NewLocalRouter(n.m, map[int32]float64{2: 0.5})
+	res := tolled.GetRouteSync(req)
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, res))
+	assert.InDelta(t, freeRes.Journeys[0].Driving.Eta+0.5**tollTimeValue, res.Journeys[0].Driving.Eta, 1e-6)
+
+	// 避开收费道路时绕行
+	res = tolled.GetRouteSyncWithOptions(req, RouteOptions{AvoidTolls: true})
+	assert.Equal(t, []int32{1, 3, 4, 5}, drivingRoadIDs(t, res))
+}
+
+func TestVehicleClassRouting(t *testing.T) {
+	defer withRouteCache(16)()
+	n := newShortcutNetwork()
+	r := NewLocalRouter(n.m, nil)
+	req := n.drivingRequest(1, 5)
+	truck := RouteOptions{VehicleClass: "truck"}
+	car := RouteOptions{VehicleClass: "car"}
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, truck)))
+
+	// 捷径禁止货车通行后，货车绕行，小汽车仍走捷径
+	r.SetRoadAllowedClasses(map[int32][]string{2: {"car", "bus"}})
+	assert.Zero(t, r.cache.len())
+	assert.Equal(t, []int32{1, 3, 4, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, truck)))
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, car)))
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSync(req)))
+
+	// 动态导航代价同样作用于按车辆类别的导航
+	assert.NoError(t, r.SetRoadCost(3, 1000, nil))
+	assert.Equal(t, []int32{1, 3, 4, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, truck)))
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, car)))
+
+	// 取消限制后货车恢复走捷径
+	r.SetRoadAllowedClasses(nil)
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, truck)))
+}
+
+func TestCombinedRouteOptions(t *testing.T) {
+	n := newShortcutNetwork()
+	req := n.drivingRequest(1, 5)
+	r := NewLocalRouter(n.m, map[int32]float64{2: 0.5})
+	r.SetRoadAllowedClasses(map[int32][]string{4: {"car"}})
+
+	// 小汽车不受限行影响，仍然可以避开收费道路
+	car := RouteOptions{VehicleClass: "car", AvoidTolls: true}
+	assert.Equal(t, []int32{1, 3, 4, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, car)))
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, RouteOptions{VehicleClass: "car"})))
+
+	// 货车同时避开收费道路与限行道路，限行惩罚更大时走收费道路
+	truck := RouteOptions{VehicleClass: "truck", AvoidTolls: true}
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, truck)))
+
+	// 节能导航同样避开限行道路
+	n = newSignalizedNetwork()
+	req = n.drivingRequest(1, 5)
+	req.Type = RouteTypeEcoDriving
+	r = NewLocalRouter(n.m, nil)
+	r.SetRoadAllowedClasses(map[int32][]string{6: {"car"}})
+	assert.Equal(t, []int32{1, 6, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, RouteOptions{VehicleClass: "car"})))
+	assert.Equal(t, []int32{1, 2, 3, 4, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, RouteOptions{VehicleClass: "truck"})))
+}
+
+// signalize 为路口添加两个可用相位，使其成为信控路口
+func (n *testNetwork) signalize(junction int32) {
+	n.m.Junctions = append(n.m.Junctions, &mapv2.Junction{
+		Id: testJunctionIDBase + junction,
+		Phases: []*mapv2.AvailablePhase{
+			{States: []mapv2.LightState{mapv2.LightState_LIGHT_STATE_GREEN}},
+			{States: []mapv2.LightState{mapv2.LightState_LIGHT_STATE_RED}},
+		},
+	})
+}
+
+// newSignalizedNetwork 创建快速路线经过多个信控路口的路网
+// 快速路线：1 -> J1 -> 2 -> J2(信控) -> 3 -> J3(信控) -> 4 -> J5 -> 5，每段400米，限速20米/秒
+// 慢速路线：1 -> J1 -> 6 -> J5 -> 5，1300米，限速12米/秒
+func newSignalizedNetwork() *testNetwork {
+	n := newTestNetwork()
+	n.addRoad(1, 500, 10)
+	n.addRoad(2, 400, 20)
+	n.addRoad(3, 400, 20)
+	n.addRoad(4, 400, 20)
+	n.addRoad(5, 500, 10)
+	n.addRoad(6, 1300, 12)
+	n.connect(1, 1, 2, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.connect(1, 1, 6, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.connect(2, 2, 3, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.connect(3, 3, 4, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.connect(5, 4, 5, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.connect(5, 6, 5, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+	n.signalize(2)
+	n.signalize(3)
+	return n
+}
+
+func TestEcoRouting(t *testing.T) {
+	n := newSignalizedNetwork()
+	r := NewLocalRouter(n.m, nil)
+	req := n.drivingRequest(1, 5)
+
+	// 最快路线经过信控路口
+	assert.Equal(t, []int32{1, 2, 3, 4, 5}, drivingRoadIDs(t, r.GetRouteSync(req)))
+
+	// 节能路线避开高速且频繁停车的路线
+	req.Type = RouteTypeEcoDriving
+	res := r.GetRouteSync(req)
+	assert.Equal(t, []int32{1, 6, 5}, drivingRoadIDs(t, res))
+	assert.Equal(t, routingv2.JourneyType_JOURNEY_TYPE_DRIVING, res.Journeys[0].Type)
+	assert.Positive(t, res.Journeys[0].Driving.Eta)
+}
+
+func TestEcoRoadCost(t *testing.T) {
+	// 同样长度下，车速越高、停车越多能耗越大
+	assert.Less(t, ecoRoadCost(1000, 10, 0), ecoRoadCost(1000, 20, 0))
+	assert.Less(t, ecoRoadCost(1000, 10, 0), ecoRoadCost(1000, 10, 1))
+	assert.InDelta(t, ecoRollingForce, ecoRoadCost(1000, 0, 0), 1e-9)
+}
+
+// withRouteCache 在测试期间启用导航缓存
+func withRouteCache(size int) func() {
+	old := *routeCacheSize
+	*routeCacheSize = size
+	return func() { *routeCacheSize = old }
+}
+
+// newGridNetwork 创建size*size个路口的双向网格路网，返回路网与全部道路ID
+// 路口(i,j)向右、向上分别连接道路，道路长度均为200米，限速10米/秒
+func newGridNetwork(size int32) (*testNetwork, []int32) {
+	n := newTestNetwork()
+	id := func(i, j int32) int32 { return i*size + j }
+	roadID := int32(1)
+	// in[j]/out[j]：驶入/驶出路口j的道路
+	in := make(map[int32][]int32)
+	out := make(map[int32][]int32)
+	for i := int32(0); i < size; i++ {
+		for j := int32(0); j < size; j++ {
+			for _, next := range [][2]int32{{i + 1, j}, {i, j + 1}} {
+				if next[0] >= size || next[1] >= size {
+					continue
+				}
+				a, b := id(i, j), id(next[0], next[1])
+				for _, pair := range [][2]int32{{a, b}, {b, a}} {
+					n.addRoad(roadID, 200, 10)
+					out[pair[0]] = append(out[pair[0]], roadID)
+					in[pair[1]] = append(in[pair[1]], roadID)
+					roadID++
+				}
+			}
+		}
+	}
+	roads := make([]int32, 0, roadID-1)
+	for r := int32(1); r < roadID; r++ {
+		roads = append(roads, r)
+	}
+	for j := int32(0); j < size*size; j++ {
+		for _, from := range in[j] {
+			for _, to := range out[j] {
+				n.connect(j, from, to, mapv2.LaneTurn_LANE_TURN_STRAIGHT)
+			}
+		}
+	}
+	return n, roads
+}
+
+func TestRouteCache(t *testing.T) {
+	n, roads := newGridNetwork(4)
+	uncached := NewLocalRouter(n.m, nil)
+	defer withRouteCache(16)()
+	cached := NewLocalRouter(n.m, nil)
+
+	// 网格路网中存在等代价的路线，因此与未缓存结果比较预计用时，与首次缓存结果比较完整内容
+	first := make(map[int32]*routingv2.GetRouteResponse)
+	for i := 0; i < 3; i++ {
+		for _, to := range roads[len(roads)-4:] {
+			req := n.drivingRequest(roads[0], to)
+			want := uncached.GetRouteSync(req)
+			got := cached.GetRouteSync(req)
+			if !assert.Len(t, got.Journeys, 1) {
+				return
+			}
+			assert.InDelta(t, want.Journeys[0].Driving.Eta, got.Journeys[0].Driving.Eta, 1e-6)
+			if i == 0 {
+				first[to] = proto.Clone(got).(*routingv2.GetRouteResponse)
+			} else {
+				assert.True(t, proto.Equal(first[to], got))
+			}
+			// 修改返回值不影响缓存
+			got.Journeys = nil
+		}
+	}
+	assert.Equal(t, 4, cached.cache.len())
+
+	// 同一车道上量化后位置相同的请求命中缓存
+	req := n.drivingRequest(roads[0], roads[len(roads)-1])
+	req.Start.LanePosition.S = 1
+	assert.True(t, proto.Equal(first[roads[len(roads)-1]], cached.GetRouteSync(req)))
+	assert.Equal(t, 4, cached.cache.len())
+
+	// 超出容量时淘汰最久未使用的结果
+	for _, to := range roads[:20] {
+		cached.GetRouteSync(n.drivingRequest(roads[len(roads)-1], to))
+	}
+	assert.Equal(t, 16, cached.cache.len())
+}
+
+func TestRouteCacheSameRoad(t *testing.T) {
+	defer withRouteCache(16)()
+	n := newShortcutNetwork()
+	r := NewLocalRouter(n.m, nil)
+
+	// 同一道路上量化后起终点相同但先后关系相反的请求结果不同，均不缓存
+	forward := &routingv2.GetRouteRequest{
+		Type:  routingv2.RouteType_ROUTE_TYPE_DRIVING,
+		Start: n.position(1, 101),
+		End:   n.position(1, 105),
+	}
+	backward := &routingv2.GetRouteRequest{
+		Type:  routingv2.RouteType_ROUTE_TYPE_DRIVING,
+		Start: n.position(1, 105),
+		End:   n.position(1, 101),
+	}
+	assert.Equal(t, []int32{1}, drivingRoadIDs(t, r.GetRouteSync(forward)))
+	assert.Empty(t, r.GetRouteSync(backward).Journeys)
+	assert.Equal(t, []int32{1}, drivingRoadIDs(t, r.GetRouteSync(forward)))
+	assert.Zero(t, r.cache.len())
+}
+
+func TestRouteCacheInvalidate(t *testing.T) {
+	defer withRouteCache(16)()
+	n := newShortcutNetwork()
+	r := NewLocalRouter(n.m, nil)
+	req := n.drivingRequest(1, 5)
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSync(req)))
+	assert.Equal(t, 1, r.cache.len())
+
+	// 捷径拥堵后缓存失效，重新规划为绕行
+	assert.NoError(t, r.SetRoadCost(2, 1000, nil))
+	assert.Zero(t, r.cache.len())
+	assert.Equal(t, []int32{1, 3, 4, 5}, drivingRoadIDs(t, r.GetRouteSync(req)))
+
+	// 只有经过代价变化道路的结果失效
+	assert.NoError(t, r.SetRoadCost(2, 2000, nil))
+	assert.Equal(t, 1, r.cache.len())
+	assert.NoError(t, r.SetRoadCost(4, 2000, nil))
+	assert.Zero(t, r.cache.len())
+}
+
+func TestSetRoadCostTimeSlice(t *testing.T) {
+	n := newShortcutNetwork()
+	r := NewLocalRouter(n.m, nil)
+	req := n.drivingRequest(1, 5)
+	later := n.drivingRequest(1, 5)
+	later.Time = 3600
+
+	// 只修改t所在时间片的代价（动态导航代价），其他时间片的导航不受影响
+	now := 0.
+	assert.NoError(t, r.SetRoadCost(2, 1000, &now))
+	assert.Equal(t, []int32{1, 3, 4, 5}, drivingRoadIDs(t, r.GetRouteSync(req)))
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSync(later)))
+}
+
+func TestSetRoadPenalties(t *testing.T) {
+	n := newShortcutNetwork()
+	r := NewLocalRouter(n.m, map[int32]float64{2: 0.1})
+	req := n.drivingRequest(1, 5)
+	avoid := RouteOptions{AvoidTolls: true}
+	assert.NoError(t, r.SetRoadCost(3, 100, nil))
+	base, err := r.router.GetRoadCost(4, nil)
+	assert.NoError(t, err)
+
+	// 额外代价作用于所有导航器，与修改的道路代价叠加
+	r.SetRoadPenalties(map[int32]float64{2: 300})
+	assert.Equal(t, []int32{1, 3, 4, 5}, drivingRoadIDs(t, r.GetRouteSync(req)))
+	r.SetRoadPenalties(map[int32]float64{4: 300})
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSync(req)))
+	assert.Equal(t, []int32{1, 3, 4, 5}, drivingRoadIDs(t, r.GetRouteSyncWithOptions(req, avoid)))
+
+	// 清除额外代价后恢复
+	r.SetRoadPenalties(nil)
+	cost, err := r.router.GetRoadCost(4, nil)
+	assert.NoError(t, err)
+	assert.InDelta(t, base, cost, 1e-6)
+}
+
+func TestAddAois(t *testing.T) {
+	n := newShortcutNetwork()
+	r := NewLocalRouter(n.m, nil)
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSync(n.drivingRequest(1, 5))))
+
+	// 在道路5上添加AOI后可以驾车前往
+	r.AddAois([]*mapv2.Aoi{{
+		Id:               100,
+		Positions:        []*geov2.XYPosition{{X: 0, Y: 0}},
+		DrivingPositions: []*geov2.LanePosition{{LaneId: n.roadLanes[5].Id, S: 100}},
+	}})
+	req := n.drivingRequest(1, 5)
+	req.End = &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 100}}
+	assert.Equal(t, []int32{1, 2, 5}, drivingRoadIDs(t, r.GetRouteSync(req)))
+}
+
+func TestAddAoisKeepsRoadCost(t *testing.T) {
+	n := newShortcutNetwork()
+	r := NewLocalRouter(n.m, nil)
+	assert.NoError(t, r.SetRoadCost(2, 1000, nil))
+	now := 3600.
+	assert.NoError(t, r.SetRoadCost(3, 1000, &now))
+
+	// 重建导航器后修改过的道路代价仍然生效
+	r.AddAois([]*mapv2.Aoi{{
+		Id:               100,
+		Positions:        []*geov2.XYPosition{{X: 0, Y: 0}},
+		DrivingPositions: []*geov2.LanePosition{{LaneId: n.roadLanes[5].Id, S: 100}},
+	}})
+	assert.Equal(t, []int32{1, 3, 4, 5}, drivingRoadIDs(t, r.GetRouteSync(n.drivingRequest(1, 5))))
+	cost, err := r.router.GetRoadCost(3, &now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000., cost)
+}
+
+// addAoi 在道路上添加只有驾车位置的AOI
+func (n *testNetwork) addAoi(id, road int32, s float64) {
+	n.m.Aois = append(n.m.Aois, &mapv2.Aoi{
+		Id:               id,
+		Positions:        []*geov2.XYPosition{{X: 0, Y: 0}},
+		DrivingPositions: []*geov2.LanePosition{{LaneId: n.roadLanes[road].Id, S: s}},
+	})
+}
+
+func TestComputeTravelTimeMatrix(t *testing.T) {
+	// 单向路网：道路5上的AOI无法到达道路1
+	n := newShortcutNetwork()
+	n.addAoi(100, 1, 100)
+	n.addAoi(101, 5, 100)
+	m, err := NewLocalRouter(n.m, nil).ComputeTravelTimeMatrix([]int32{100, 101}, 0, routingv2.RouteType_ROUTE_TYPE_DRIVING)
+	assert.NoError(t, err)
+	assert.Len(t, m, 2)
+	assert.Equal(t, 0., m[0][0])
+	assert.Equal(t, 0., m[1][1])
+	assert.Greater(t, m[0][1], 0.)
+	assert.False(t, math.IsInf(m[0][1], 1))
+	assert.True(t, math.IsInf(m[1][0], 1))
+
+	// 双向网格路网：往返用时相近
+	g, _ := newGridNetwork(3)
+	g.addAoi(100, 1, 100)
+	g.addAoi(101, 12, 100)
+	g.addAoi(102, 20, 100)
+	ids := []int32{100, 101, 102}
+	m, err = NewLocalRouter(g.m, nil).ComputeTravelTimeMatrix(ids, 0, routingv2.RouteType_ROUTE_TYPE_DRIVING)
+	assert.NoError(t, err)
+	for i := range ids {
+		assert.Equal(t, 0., m[i][i])
+		for j := range ids {
+			if i != j {
+				assert.Greater(t, m[i][j], 0.)
+				assert.InEpsilon(t, m[i][j], m[j][i], 0.5)
+			}
+		}
+	}
+	m, err = NewLocalRouter(g.m, nil).ComputeTravelTimeMatrix(nil, 0, routingv2.RouteType_ROUTE_TYPE_DRIVING)
+	assert.NoError(t, err)
+	assert.Empty(t, m)
+
+	// 不支持的出行方式在发出请求前返回错误
+	_, err = NewLocalRouter(g.m, nil).ComputeTravelTimeMatrix(ids, 0, routingv2.RouteType(-1))
+	assert.Error(t, err)
+}
+
+func BenchmarkRouteCache(b *testing.B) {
+	n, roads := newGridNetwork(10)
+	reqs := make([]*routingv2.GetRouteRequest, 0, 20)
+	for i := 0; i < 20; i++ {
+		reqs = append(reqs, n.drivingRequest(roads[i], roads[len(roads)-1-i]))
+	}
+	run := func(b *testing.B, r *LocalRouter) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r.GetRouteSync(reqs[i%len(reqs)])
+		}
+	}
+	b.Run("uncached", func(b *testing.B) {
+		run(b, NewLocalRouter(n.m, nil))
+	})
+	b.Run("cached", func(b *testing.B) {
+		defer withRouteCache(len(reqs))()
+		run(b, NewLocalRouter(n.m, nil))
+	})
+}
+
+// gridRequests 生成网格路网中的批量驾车导航请求
+func gridRequests(n *testNetwork, roads []int32, count int) []*routingv2.GetRouteRequest {
+	reqs := make([]*routingv2.GetRouteRequest, count)
+	for i := range reqs {
+		from := roads[(i*7)%len(roads)]
+		to := roads[(i*13+5)%len(roads)]
+		reqs[i] = n.drivingRequest(from, to)
+	}
+	return reqs
+}
+
+func TestGetRoutesBatch(t *testing.T) {
+	n, roads := newGridNetwork(5)
+	r := NewLocalRouter(n.m, nil)
+	reqs := gridRequests(n, roads, 200)
+	res := r.GetRoutesBatch(reqs)
+	assert.Len(t, res, len(reqs))
+	for i, req := range reqs {
+		want := r.GetRouteSync(req)
+		if !assert.Len(t, res[i].Journeys, len(want.Journeys)) || len(want.Journeys) == 0 {
+			continue
+		}
+		// 等代价路线的选择可能不同，比较预计用时与起终点道路
+		got := res[i].Journeys[0].Driving
+		assert.InDelta(t, want.Journeys[0].Driving.Eta, got.Eta, 1e-6)
+		assert.Equal(t, want.Journeys[0].Driving.RoadIds[0], got.RoadIds[0])
+		assert.Equal(t, want.Journeys[0].Driving.RoadIds[len(want.Journeys[0].Driving.RoadIds)-1], got.RoadIds[len(got.RoadIds)-1])
+	}
+	assert.Empty(t, r.GetRoutesBatch(nil))
+}
+
+func BenchmarkGetRoutesBatch(b *testing.B) {
+	n, roads := newGridNetwork(10)
+	r := NewLocalRouter(n.m, nil)
+	reqs := gridRequests(n, roads, 10000)
+	b.Run("sync", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, req := range reqs {
+				r.GetRouteSync(req)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.GetRoutesBatch(reqs)
+		}
+	})
+}
+
+// withMaxConcurrency 在测试期间限制回调版本导航的并发协程数
+func withMaxConcurrency(limit int) func() {
+	old := *maxConcurrency
+	*maxConcurrency = limit
+	return func() { *maxConcurrency = old }
+}
+
+func TestGetRouteMaxConcurrency(t *testing.T) {
+	defer withMaxConcurrency(4)()
+	n, roads := newGridNetwork(5)
+	r := NewLocalRouter(n.m, nil)
+	reqs := gridRequests(n, roads, 2000)
+
+	var active, peak, done atomic.Int32
+	base := runtime.NumGoroutine()
+	peakGoroutines := 0
+	chs := make([]chan struct{}, len(reqs))
+	for i, req := range reqs {
+		chs[i] = r.GetRoute(req, func(res *routingv2.GetRouteResponse) {
+			cur := active.Add(1)
+			for old := peak.Load(); cur > old && !peak.CompareAndSwap(old, cur); old = peak.Load() {
+			}
+			time.Sleep(10 * time.Microsecond)
+			active.Add(-1)
+			done.Add(1)
+		})
+		peakGoroutines = max(peakGoroutines, runtime.NumGoroutine()-base)
+	}
+	for _, ch := range chs {
+		<-ch
+	}
+	r.wg.Wait()
+	// 所有回调都被调用且通知通道全部关闭，并发数不超过上限
+	// 新增协程数只包含工作协程（留出运行时后台协程的余量），不随请求数增长
+	assert.Equal(t, int32(len(reqs)), done.Load())
+	assert.LessOrEqual(t, peak.Load(), int32(4))
+	assert.Less(t, peakGoroutines, 50)
+	assert.Zero(t, r.workers)
+	assert.Empty(t, r.queue)
+}
+
+func BenchmarkGetRouteBurst(b *testing.B) {
+	n, roads := newGridNetwork(10)
+	reqs := gridRequests(n, roads, 1000)
+	run := func(b *testing.B, limit int) {
+		defer withMaxConcurrency(limit)()
+		r := NewLocalRouter(n.m, nil)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, req := range reqs {
+				r.GetRoute(req, func(*routingv2.GetRouteResponse) {})
+			}
+			r.wg.Wait()
+		}
+	}
+	b.Run("unbounded", func(b *testing.B) { run(b, 0) })
+	b.Run("bounded", func(b *testing.B) { run(b, runtime.GOMAXPROCS(0)) })
+}
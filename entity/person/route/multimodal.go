@@ -1,6 +1,9 @@
 package route
 
 import (
+	"cmp"
+	"slices"
+
 	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
 	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
 	"github.com/samber/lo"
@@ -11,8 +14,9 @@ import (
 type MultiModalType int32
 
 const (
-	MultiModalType_WALK  MultiModalType = iota // 步行
-	MultiModalType_DRIVE                       // 开车
+	MultiModalType_WALK     MultiModalType = iota // 步行
+	MultiModalType_DRIVE                          // 开车
+	MultiModalType_TELEPORT                       // 导航失败时的直线瞬移（route.teleport_fallback）
 )
 
 type MultiModalRoute struct {
@@ -25,8 +29,9 @@ type MultiModalRoute struct {
 	MultiModalType  MultiModalType              // 当前导航的类型
 	VehicleRoute    *VehicleRoute               // 车辆导航
 	PedestrianRoute *PedestrianRoute            // 行人导航
-	indexJourney    int                         // 当前journey下标 假设步行和开车都只有一个journey
+	indexJourney    int                         // 当前journey下标，多段journey之间在换乘AOI中依次切换
 	ForceEnd        bool                        // 强制结束此段导航 person瞬移到route终点
+	TeleportEta     float64                     // 直线瞬移的用时（秒），仅MultiModalType_TELEPORT时有意义
 }
 
 // 创建多式联运路径规划
@@ -111,8 +116,8 @@ func (r *MultiModalRoute) ProduceRouting(trip *tripv2.Trip, startPosition entity
 		End:   target,
 		Time:  r.ctx.Clock().T,
 	}
-	// 发送路径规划请求
-	r.waitCh = r.ctx.Router().GetRoute(req, r.ProcessRouting)
+	// 发送路径规划请求，驾车时按人的车辆类别与收费偏好选择道路
+	r.waitCh = r.ctx.Router().GetRouteWithOptions(req, r.p.RouteOptions(), r.ProcessRouting)
 }
 
 func (r *MultiModalRoute) ProcessRouting(res *routingv2.GetRouteResponse) {
@@ -139,26 +144,86 @@ func (r *MultiModalRoute) ProcessRouting(res *routingv2.GetRouteResponse) {
 	})
 
 	if len(res.Journeys) == 0 {
-		r.ok = false
+		r.ok = *teleportFallback && r.processTeleport()
 		return
 	}
 	r.base = res
 	r.indexJourney = 0
 	r.ok = true
 	r.ForceEnd = false
-	firstJourney := r.base.Journeys[0]
-	switch firstJourney.Type {
+	if !r.processJourney(r.Start) {
+		r.ok = false
+	}
+}
+
+// 处理当前下标的journey，start为该journey的起点
+// 不是最后一段journey时，终点为与下一段journey之间的换乘AOI，找不到换乘AOI时返回false
+func (r *MultiModalRoute) processJourney(start entity.RoutePosition) bool {
+	journey := r.base.Journeys[r.indexJourney]
+	end := r.End
+	if r.indexJourney+1 < len(r.base.Journeys) {
+		aoi := transferAoi(r.ctx, journey, r.base.Journeys[r.indexJourney+1])
+		if aoi == nil {
+			log.Warnf("MultiModalRoute: no transfer aoi between journey %d and %d, personID=%v, routeResponse=%v", r.indexJourney, r.indexJourney+1, r.p.ID(), r.base)
+			return false
+		}
+		end = entity.RoutePosition{Aoi: aoi}
+	}
+	switch journey.Type {
 	case routingv2.JourneyType_JOURNEY_TYPE_WALKING:
 		r.MultiModalType = MultiModalType_WALK
-		routeEnd := r.End
-		r.PedestrianRoute.ProcessInputJourney(firstJourney, r.Start, routeEnd)
+		r.PedestrianRoute.ProcessInputJourney(journey, start, end)
 	case routingv2.JourneyType_JOURNEY_TYPE_DRIVING:
 		r.MultiModalType = MultiModalType_DRIVE
-		r.VehicleRoute.ProcessInputJourney(firstJourney, r.Start, r.End)
+		r.VehicleRoute.ProcessInputJourney(journey, start, end)
 	default:
 		log.Panic("MultiModalRoute: unsupported journeyType")
 	}
+	return true
+}
+
+// 查找两段journey之间的换乘AOI（如停车换乘的停车场）
+// 驾车与步行之间换乘时，换乘AOI须连接驾车道路的最右侧车道与步行路径端点所在的人行道，其余组合不支持
+func transferAoi(ctx entity.ITaskContext, from, to *routingv2.Journey) entity.IAoi {
+	var road entity.IRoad
+	var walkingLaneID int32
+	switch {
+	case from.Type == routingv2.JourneyType_JOURNEY_TYPE_DRIVING && to.Type == routingv2.JourneyType_JOURNEY_TYPE_WALKING:
+		road = ctx.RoadManager().Get(from.Driving.RoadIds[len(from.Driving.RoadIds)-1])
+		walkingLaneID = to.Walking.Route[0].LaneId
+	case from.Type == routingv2.JourneyType_JOURNEY_TYPE_WALKING && to.Type == routingv2.JourneyType_JOURNEY_TYPE_DRIVING:
+		road = ctx.RoadManager().Get(to.Driving.RoadIds[0])
+		walkingLaneID = from.Walking.Route[len(from.Walking.Route)-1].LaneId
+	default:
+		return nil
+	}
+	aois := lo.Values(road.RightestDrivingLane().Aois())
+	slices.SortFunc(aois, func(a, b entity.IAoi) int { return cmp.Compare(a.ID(), b.ID()) })
+	for _, aoi := range aois {
+		if _, ok := aoi.WalkingLanes()[walkingLaneID]; ok {
+			return aoi
+		}
+	}
+	return nil
+}
 
+// 进入下一段journey，起点为当前journey的终点（换乘AOI）
+// 没有下一段journey时清空导航（本次出行的route已走完，下次出行需重新导航），hasNext返回false；
+// 有下一段journey但无法处理时（如找不到之后的换乘AOI）导航失效，hasNext返回true、ok返回false
+func (r *MultiModalRoute) NextJourney() (hasNext, ok bool) {
+	if !r.ok || r.indexJourney+1 >= len(r.base.Journeys) {
+		r.Clear()
+		return false, false
+	}
+	start := r.GetCurrentEndPosition()
+	r.indexJourney++
+	r.VehicleRoute.Clear()
+	r.PedestrianRoute.Clear()
+	if !r.processJourney(entity.RoutePosition{Aoi: start.Aoi}) {
+		r.Clear()
+		return true, false
+	}
+	return true, true
 }
 
 func (r *MultiModalRoute) GetCurrentStartPosition() entity.RoutePosition {
@@ -168,6 +233,8 @@ func (r *MultiModalRoute) GetCurrentStartPosition() entity.RoutePosition {
 		curPosition = r.VehicleRoute.GetCurrentStartPosition()
 	case MultiModalType_WALK:
 		curPosition = r.PedestrianRoute.GetCurrentStartPosition()
+	case MultiModalType_TELEPORT:
+		curPosition = r.Start
 	default:
 		log.Panic("MultiModalRoute: invalid MultiModalType")
 	}
@@ -181,6 +248,8 @@ func (r *MultiModalRoute) GetCurrentEndPosition() entity.RoutePosition {
 		curPosition = r.VehicleRoute.GetCurrentEndPosition()
 	case MultiModalType_WALK:
 		curPosition = r.PedestrianRoute.GetCurrentEndPosition()
+	case MultiModalType_TELEPORT:
+		curPosition = r.End
 	default:
 		log.Panic("MultiModalRoute: invalid MultiModalType")
 	}
@@ -261,12 +261,12 @@ func (r *PedestrianRoute) GetCurrentEndPosition() entity.RoutePosition {
 	return r.End
 }
 
-// 将PedestrianRoute转为Protobuf格式
+// 将PedestrianRoute的当前剩余路由转为Protobuf格式
 func (r *PedestrianRoute) ToPb() *routingv2.Journey {
 	pb := &routingv2.Journey{
 		Type: routingv2.JourneyType_JOURNEY_TYPE_WALKING,
 		Walking: &routingv2.WalkingJourneyBody{
-			Route: lo.Map(r.route, func(seg PedestrianSegment, _ int) *routingv2.WalkingRouteSegment {
+			Route: lo.Map(r.route[r.indexRoute:], func(seg PedestrianSegment, _ int) *routingv2.WalkingRouteSegment {
 				return &routingv2.WalkingRouteSegment{
 					LaneId:          seg.Lane.ID(),
 					MovingDirection: seg.Direction,
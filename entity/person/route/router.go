@@ -6,6 +6,7 @@ import (
 )
 
 // New 初始化导航服务
-func New(input *input.Input) entity.IRouter {
-	return NewLocalRouter(input.Map)
+// tolls为道路ID->收费金额
+func New(input *input.Input, tolls map[int32]float64) entity.IRouter {
+	return NewLocalRouter(input.Map, tolls)
 }
@@ -0,0 +1,35 @@
+package route
+
+import (
+	"flag"
+
+	"git.fiblab.net/general/common/v2/geometry"
+	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
+)
+
+var (
+	teleportFallback = flag.Bool("route.teleport_fallback", false, "导航失败时是否回退为直线瞬移（按直线距离与地图平均车速估计用时并乘以惩罚系数，到时后直接进入终点AOI），避免跳过行程导致需求失真")
+	teleportPenalty  = flag.Float64("route.teleport_penalty", 1.5, "直线瞬移用时的惩罚系数")
+)
+
+// processTeleport 导航失败时生成直线瞬移的导航结果
+// 功能：起点与终点均为AOI时，按两者质心的直线距离/地图平均车速*惩罚系数计算瞬移用时，导航视为成功
+// 返回：是否生成了瞬移导航
+// 说明：起点或终点为车道位置时人在车道上的节点无法直接移除，不进行瞬移
+func (r *MultiModalRoute) processTeleport() bool {
+	if r.Start.Aoi == nil || r.End.Aoi == nil {
+		return false
+	}
+	v := r.ctx.LaneManager().MeanSpeed()
+	if v <= 0 {
+		return false
+	}
+	from, to := r.Start.Aoi.Centroid(), r.End.Aoi.Centroid()
+	r.TeleportEta = geometry.Distance2D(from, to) / v * *teleportPenalty
+	r.base = &routingv2.GetRouteResponse{}
+	r.indexJourney = 0
+	r.MultiModalType = MultiModalType_TELEPORT
+	r.ok = true
+	r.ForceEnd = false
+	return true
+}
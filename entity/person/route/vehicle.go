@@ -1,6 +1,7 @@
 package route
 
 import (
+	"flag"
 	"fmt"
 	"math"
 	"sync"
@@ -14,14 +15,35 @@ import (
 
 var CallbackWaitGroup sync.WaitGroup
 
+var (
+	// https://jtgl.beijing.gov.cn/jgj/94220/aqcs/139634/index.html
+	viewDistanceFactor = flag.Float64("veh.view_distance_factor", 12, "车辆观察距离与车速之比（秒），一般情况下观察距离等于汽车在12秒内通过的路程")
+	minViewDistance    = flag.Float64("veh.min_view_distance", 50, "车辆最小观察距离（米）")
+)
+
 const (
-	viewDistanceFactor = 12   // 在一般情况下，观察距离应等于汽车在12秒内所通过的路程。如果车速为每小时60公里，则观察距离应为200米。
-	minViewDistance    = 50   // 最小观察距离
-	minLCDistance      = 10.0 // 最小强制变道距离
-	maxLCDistance      = 30.0 // 最大强制变道距离
-	lcFactor           = 3    // 强制变道时间比例参数
+	minLCDistance = 10.0 // 最小强制变道距离
+	maxLCDistance = 30.0 // 最大强制变道距离
+	lcFactor      = 3    // 强制变道时间比例参数
 )
 
+// ViewRange 车辆观察距离参数
+// 功能：观察距离等于车辆在Factor秒内通过的路程，且不小于Min，控制器的前方环境探测与导航的变道探测共用
+type ViewRange struct {
+	Factor float64 // 观察距离与车速之比（秒）
+	Min    float64 // 最小观察距离（米）
+}
+
+// NewViewRange 根据veh.view_distance_factor与veh.min_view_distance创建观察距离参数
+func NewViewRange() ViewRange {
+	return ViewRange{Factor: *viewDistanceFactor, Min: *minViewDistance}
+}
+
+// Distance 计算车速为v时的观察距离
+func (r ViewRange) Distance(v float64) float64 {
+	return math.Max(v*r.Factor, r.Min)
+}
+
 type JunctionCandidate struct {
 	// Lanes和PreLanes一一对应，即PreLanes[i]是Lanes[i]的前驱
 	// PreLanes按从左到右排列
@@ -44,6 +66,7 @@ type VehicleRoute struct {
 	Start, End entity.RoutePosition // 导航起点终点
 	waitCh     chan struct{}        // 路径规划请求等待通道
 	ok         bool                 // 导航请求是否成功
+	view       ViewRange            // 变道探测的观察距离参数
 
 	// 路径的组成：start -> roads[0] -> juncLaneGroups[0] -> roads[1] -> ... -> roads[n-1] -> end
 	// Vehicle对Route的使用方式：
@@ -58,7 +81,7 @@ type VehicleRoute struct {
 	Roads                  []entity.IRoad      // 路径中的所有road
 	JuncLaneGroups         []JunctionCandidate // 路径中的所有路口（长度总是等于roads或者比roads少1）
 	Eta                    float64             // 预计到达用时
-	EtaFreeFlow            float64             // 预计到达用时（道路最高限速+路口不计算）
+	EtaFreeFlow            float64             // 预计到达用时（道路行车道平均限速+路口不计算）
 	EstimatedTotalDistance float64             // 估计的总行驶距离（米）
 }
 
@@ -67,6 +90,7 @@ func NewVehicleRoute(ctx entity.ITaskContext, p entity.IPerson) *VehicleRoute {
 		ctx:    ctx,
 		p:      p,
 		waitCh: nil,
+		view:   NewViewRange(),
 	}
 }
 
@@ -103,7 +127,7 @@ func (r *VehicleRoute) Ok() bool {
 }
 
 // 根据指示的进入路口前的车道，找到"最适合"的junction lane
-// 最适合：offset差距最小（可能不为0，即不为直行可达的）
+// 最适合：offset差距最小（可能不为0，即不为直行可达的），再下一个路口的offset差距也相同时选择ID最小的车道
 func (r *VehicleRoute) GetJunctionLaneByPreLane(preLane entity.ILane, juncIndex int) (entity.ILane, int) {
 	if juncIndex >= len(r.JuncLaneGroups) {
 		return nil, 0
@@ -137,7 +161,7 @@ func (r *VehicleRoute) GetJunctionLaneByPreLane(preLane entity.ILane, juncIndex
 			log.Panicf("VehicleRoute: juncLane %v has no successor: err=%v", juncLane, err)
 		}
 		_, nextDelta := r.GetJunctionLaneByPreLane(nextPreLane, juncIndex+1)
-		if nextDelta < minNextDelta {
+		if nextDelta < minNextDelta || (nextDelta == minNextDelta && juncLane.ID() < bestLane.ID()) {
 			minNextDelta = nextDelta
 			bestLane = juncLane
 		}
@@ -222,7 +246,7 @@ func (r *VehicleRoute) GetLCScan(curLane entity.ILane, curS float64, curV float6
 	// 后续还有路口 向前一直探测
 	// ATTENTION:现在计算探测距离使用的是最大限速而不是车的实际速度
 	//viewDistance := math.Max(curV*VIEW_DISTANCE_FACTOR, MIN_VIEW_DISTANCE)
-	viewDistance := math.Max(curLane.MaxV()*viewDistanceFactor, minViewDistance)
+	viewDistance := r.view.Distance(curLane.MaxV())
 	scanDistance := curLane.Length() - curS // 已经向前探测的距离
 	juncIndex := 0                          // 探测到的JuncLaneGroups下标
 	scanJuncs := []JunctionCandidate{}
@@ -311,31 +335,7 @@ func (r *VehicleRoute) GetLCScan(curLane entity.ILane, curS float64, curV float6
 	}}
 }
 
-// func (r *VehicleRoute) ProduceRouting(trip *tripv2.Trip, startPosition entity.RoutePosition, canUsePreroute bool) {
-// 	target := trip.End
-// 	r.Start = startPosition
-// 	// 记录路径规划终点
-// 	r.End = newRoutePosition(r.ctx, target)
-// 	r.ok = false
-// 	// 如果有预计算的路径规划结果，直接使用
-// 	if canUsePreroute && len(trip.Routes) != 0 {
-// 		r.ProcessRouting(&routingv2.GetRouteResponse{
-// 			Journeys: trip.Routes,
-// 		})
-// 		r.waitCh = nil
-// 		return
-// 	}
-// 	// 没有预计算的路径规划结果，发送请求
-// 	req := &routingv2.GetRouteRequest{
-// 		Start: newPbPosition(r.Start),
-// 		End:   target,
-// 		Type:  routingv2.RouteType_ROUTE_TYPE_DRIVING,
-// 		Time:  r.ctx.Clock().T,
-// 	}
-// 	// 发送请求
-// 	r.waitCh = r.ctx.Router().GetRoute(req, r.ProcessRouting)
-// }
-
+// 同步请求路径规划（有可用的预计算路径时直接使用），返回的结果交由ProcessRouting处理
 func (r *VehicleRoute) ProduceRoutingWithoutProcess(
 	trip *tripv2.Trip,
 	startPosition entity.RoutePosition,
@@ -363,8 +363,13 @@ func (r *VehicleRoute) ProduceRoutingWithoutProcess(
 	return r.ctx.Router().GetRouteSync(req)
 }
 
-// 处理路径规划的共同逻辑
-func (r *VehicleRoute) processJourneyCommon(roadIDs []int32, eta float64) {
+// 处理单个驾车journey，ProcessRouting与ProcessInputJourney共用
+// 根据导航结果补全起终点、生成道路与路口车道组，要求最后一条道路与终点所在道路一致
+func (r *VehicleRoute) processJourney(pb *routingv2.Journey) {
+	if pb.Type != routingv2.JourneyType_JOURNEY_TYPE_DRIVING || pb.Driving == nil || len(pb.Driving.RoadIds) == 0 {
+		log.Panicf("VehicleRoute: wrong journey %v", pb)
+	}
+	roadIDs := pb.Driving.RoadIds
 	// 根据导航结果推断补全起点和终点的内容
 	if r.Start.Lane == nil {
 		roadID := roadIDs[0]
@@ -386,6 +391,7 @@ func (r *VehicleRoute) processJourneyCommon(roadIDs []int32, eta float64) {
 	}
 
 	// -> junction lane group
+	class := r.p.VehicleClass()
 	r.JuncLaneGroups = make([]JunctionCandidate, len(roadIDs)-1)
 	for i := 0; i < len(roadIDs)-1; i++ {
 		inRoad := r.Roads[i]
@@ -398,6 +404,13 @@ func (r *VehicleRoute) processJourneyCommon(roadIDs []int32, eta float64) {
 		if !ok {
 			log.Panicf("VehicleRoute: road %v and %v are not connected, please patch the map first", inRoad.ID(), outRoad.ID())
 		}
+		// 只使用路口车道及其进口车道都允许本车类别通行的车道组，都不允许时保留全部车道以保证可达
+		if allowed := lo.Filter(lanes, func(l entity.ILane, _ int) bool {
+			pre, err := l.UniquePredecessor()
+			return l.AllowsClass(class) && (err != nil || pre.AllowsClass(class))
+		}); len(allowed) > 0 {
+			lanes = allowed
+		}
 		hasTrafficLight := true
 		candidate := JunctionCandidate{
 			Junction: junc,
@@ -418,52 +431,48 @@ func (r *VehicleRoute) processJourneyCommon(roadIDs []int32, eta float64) {
 	}
 	r.AtRoad = true
 	r.ok = true
-	r.Eta = eta
-	// 预计到达用时（道路最高限速+路口不计算）
+	r.Eta = pb.Driving.Eta
+	r.estimateFreeFlow()
+
+	// 如果最后一条road与r.End.Lane不匹配，报错
+	if lastRoad := r.Roads[len(r.Roads)-1]; lastRoad != r.End.Lane.ParentRoad() {
+		log.Panicf("VehicleRoute: last road %v in journey %v does not match end %v", lastRoad, pb, r.End)
+	}
+}
+
+// 估计自由流下的预计到达用时与总行驶距离（按道路行车道平均限速行驶，路口不计算）
+func (r *VehicleRoute) estimateFreeFlow() {
 	r.EtaFreeFlow = 0
 	r.EstimatedTotalDistance = 0
 	// 1. 计算起点到第一个路口的时间
 	road := r.Roads[0]
 	d := road.GetAvgDrivingL() - r.Start.S
 	r.EstimatedTotalDistance += d
-	r.EtaFreeFlow += d / road.MaxV()
+	r.EtaFreeFlow += d / road.MeanMaxV()
 	// 2. 计算第一个路口到最后一个路口的时间
 	for i := 0; i < len(r.JuncLaneGroups); i++ {
 		road := r.Roads[i+1]
 		d := road.GetAvgDrivingL()
 		r.EstimatedTotalDistance += d
-		r.EtaFreeFlow += d / road.MaxV()
+		r.EtaFreeFlow += d / road.MeanMaxV()
 	}
 	// 3. 计算最后一个路口到终点的时间
 	road = r.Roads[len(r.Roads)-1]
 	d = r.End.S
 	r.EstimatedTotalDistance += d
-	r.EtaFreeFlow += d / road.MaxV()
+	r.EtaFreeFlow += d / road.MeanMaxV()
 }
 
-// TODO: 存在两个重复的ProcessRouting相关函数
+// 处理路径规划结果（路径规划回调与预计算路径共用），结果为空时导航失败
 func (r *VehicleRoute) ProcessRouting(res *routingv2.GetRouteResponse) {
 	if len(res.Journeys) == 0 {
 		r.ok = false
 		return
 	}
-	roadIDs := res.Journeys[0].Driving.RoadIds
-
-	// res check
-	if !(len(res.Journeys) == 1 &&
-		res.Journeys[0].Type == *routingv2.JourneyType_JOURNEY_TYPE_DRIVING.Enum() &&
-		res.Journeys[0].Driving != nil &&
-		len(res.Journeys[0].Driving.RoadIds) > 0) {
-		log.Panic("VehicleRoute: wrong res")
-	}
-
-	// 处理共同的路径规划逻辑
-	r.processJourneyCommon(roadIDs, res.Journeys[0].Driving.Eta)
-
-	// 如果最后一条road与r.End.Lane不匹配，报错
-	if lastRoad := r.Roads[len(r.Roads)-1]; lastRoad != r.End.Lane.ParentRoad() {
-		log.Panicf("VehicleRoute: last road %v in route result %v does not match end %v", lastRoad, res, r.End)
+	if len(res.Journeys) != 1 {
+		log.Panicf("VehicleRoute: wrong res %v", res)
 	}
+	r.processJourney(res.Journeys[0])
 }
 
 // 将VehicleRoute的当前剩余路由转为Protobuf格式
@@ -480,18 +489,12 @@ func (r *VehicleRoute) ToPb() *routingv2.Journey {
 	return pb
 }
 
-// 处理输入的单个journey
+// 处理输入的单个journey（多式联运导航中的驾车段），start与end为该段的起终点
 func (r *VehicleRoute) ProcessInputJourney(pb *routingv2.Journey, start, end entity.RoutePosition) {
-	if pb.Type != routingv2.JourneyType_JOURNEY_TYPE_DRIVING {
-		log.Panic("VehicleRoute: unsupported journeyType")
-	}
 	r.waitCh = nil
 	r.Start = start
 	r.End = end
-	roadIDs := pb.Driving.RoadIds
-
-	// 处理共同的路径规划逻辑
-	r.processJourneyCommon(roadIDs, pb.Driving.Eta)
+	r.processJourney(pb)
 }
 
 // 得到当前route的起始位置
@@ -0,0 +1,210 @@
+package route
+
+import (
+	"errors"
+	"testing"
+
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	routingv2 "git.fiblab.net/sim/protos/v2/go/city/routing/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/clock"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/lane"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/road"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
+)
+
+// testContext 只包含时钟、运行时配置、车道与道路管理器的测试用任务上下文
+type testContext struct {
+	entity.ITaskContext
+	clock         *clock.Clock
+	runtimeConfig *config.RuntimeConfig
+	laneManager   *lane.LaneManager
+	roadManager   *road.RoadManager
+}
+
+func (ctx *testContext) Clock() *clock.Clock                  { return ctx.clock }
+func (ctx *testContext) RuntimeConfig() *config.RuntimeConfig { return ctx.runtimeConfig }
+func (ctx *testContext) LaneManager() entity.ILaneManager     { return ctx.laneManager }
+func (ctx *testContext) RoadManager() entity.IRoadManager     { return ctx.roadManager }
+
+func TestEtaFreeFlow(t *testing.T) {
+	// 双车道道路，两条车道长200米、限速均为10米/秒
+	lanes := make([]*mapv2.Lane, 0, 2)
+	for i := int32(1); i <= 2; i++ {
+		lanes = append(lanes, &mapv2.Lane{
+			Id:       i,
+			Type:     mapv2.LaneType_LANE_TYPE_DRIVING,
+			MaxSpeed: 10,
+			Width:    3.2,
+			CenterLine: &geov2.Polyline{Nodes: []*geov2.XYPosition{
+				{X: 0, Y: float64(i) * 3.2},
+				{X: 200, Y: float64(i) * 3.2},
+			}},
+			ParentId: 1,
+		})
+	}
+	ctx := &testContext{
+		clock:         clock.New(config.ControlStep{Total: 100, Interval: 1}),
+		runtimeConfig: config.NewRuntimeConfig(config.Config{}),
+	}
+	ctx.laneManager = lane.NewManager(ctx)
+	ctx.laneManager.Init(lanes)
+	ctx.roadManager = road.NewManager(ctx)
+	ctx.roadManager.Init([]*mapv2.Road{{Id: 1, LaneIds: []int32{1, 2}}}, ctx.laneManager)
+	rd := ctx.roadManager.Get(1)
+	assert.Equal(t, 10., rd.MeanMaxV())
+	assert.Equal(t, 10., rd.MaxMaxV())
+
+	// 自由流用时为长度/限速，而非长度/(2*限速)
+	r := &VehicleRoute{
+		Start: entity.RoutePosition{Lane: ctx.laneManager.Get(1), S: 20},
+		End:   entity.RoutePosition{Lane: ctx.laneManager.Get(1), S: 180},
+		Roads: []entity.IRoad{rd},
+	}
+	r.estimateFreeFlow()
+	assert.Positive(t, r.EstimatedTotalDistance)
+	assert.InDelta(t, r.EstimatedTotalDistance/10, r.EtaFreeFlow, 1e-9)
+}
+
+// 以下为只实现导航处理所需方法的测试用实体
+
+type stubContext struct {
+	entity.ITaskContext
+	roads map[int32]*stubRoad
+}
+
+func (ctx *stubContext) RoadManager() entity.IRoadManager { return &stubRoadManager{roads: ctx.roads} }
+
+type stubRoadManager struct {
+	entity.IRoadManager
+	roads map[int32]*stubRoad
+}
+
+func (m *stubRoadManager) Get(id int32) entity.IRoad { return m.roads[id] }
+
+type stubPerson struct{ entity.IPerson }
+
+func (p *stubPerson) ID() int32            { return 1 }
+func (p *stubPerson) VehicleClass() string { return "car" }
+
+type stubRoad struct {
+	entity.IRoad
+	id   int32
+	lane *stubLane
+	next *stubJunction
+}
+
+func (r *stubRoad) ID() int32                         { return r.id }
+func (r *stubRoad) RightestDrivingLane() entity.ILane { return r.lane }
+func (r *stubRoad) DrivingSuccessor() entity.IJunction {
+	if r.next == nil {
+		return nil
+	}
+	return r.next
+}
+func (r *stubRoad) GetAvgDrivingL() float64 { return 100 }
+func (r *stubRoad) MeanMaxV() float64       { return 10 }
+
+type stubJunction struct {
+	entity.IJunction
+	lanes map[[2]int32][]entity.ILane
+}
+
+func (j *stubJunction) DrivingLaneGroup(in, out entity.IRoad) ([]entity.ILane, float64, float64, bool) {
+	lanes, ok := j.lanes[[2]int32{in.ID(), out.ID()}]
+	return lanes, 0, 0, ok
+}
+
+type stubLane struct {
+	entity.ILane
+	id     int32
+	road   *stubRoad
+	pre    *stubLane
+	next   *stubLane
+	offset int
+}
+
+func (l *stubLane) ID() int32                     { return l.id }
+func (l *stubLane) ParentRoad() entity.IRoad      { return l.road }
+func (l *stubLane) AllowsClass(class string) bool { return true }
+func (l *stubLane) OffsetInRoad() int             { return l.offset }
+func (l *stubLane) UniqueSuccessor() (entity.ILane, error) {
+	if l.next == nil {
+		return nil, errors.New("no successor")
+	}
+	return l.next, nil
+}
+func (l *stubLane) UniquePredecessor() (entity.ILane, error) {
+	if l.pre == nil {
+		return nil, errors.New("no predecessor")
+	}
+	return l.pre, nil
+}
+
+func TestProcessRoutingEntryPoints(t *testing.T) {
+	// 道路1 -> 路口（车道21）-> 道路2
+	road1, road2 := &stubRoad{id: 1}, &stubRoad{id: 2}
+	road1.lane = &stubLane{id: 11, road: road1}
+	road2.lane = &stubLane{id: 12, road: road2}
+	junctionLane := &stubLane{id: 21, pre: road1.lane}
+	road1.next = &stubJunction{lanes: map[[2]int32][]entity.ILane{{1, 2}: {junctionLane}}}
+	ctx := &stubContext{roads: map[int32]*stubRoad{1: road1, 2: road2}}
+	start := entity.RoutePosition{Lane: road1.lane, S: 10}
+	end := entity.RoutePosition{Lane: road2.lane, S: 50}
+	journey := func() *routingv2.Journey {
+		return &routingv2.Journey{
+			Type:    routingv2.JourneyType_JOURNEY_TYPE_DRIVING,
+			Driving: &routingv2.DrivingJourneyBody{RoadIds: []int32{1, 2}, Eta: 30},
+		}
+	}
+
+	// 路径规划回调与多式联运输入得到相同的导航
+	byResponse := NewVehicleRoute(ctx, &stubPerson{})
+	byResponse.Start, byResponse.End = start, end
+	byResponse.ProcessRouting(&routingv2.GetRouteResponse{Journeys: []*routingv2.Journey{journey()}})
+	byJourney := NewVehicleRoute(ctx, &stubPerson{})
+	byJourney.ProcessInputJourney(journey(), start, end)
+	for _, r := range []*VehicleRoute{byResponse, byJourney} {
+		assert.True(t, r.Ok())
+		assert.Equal(t, []entity.IRoad{road1, road2}, r.Roads)
+		assert.Len(t, r.JuncLaneGroups, 1)
+		assert.Equal(t, []entity.ILane{junctionLane}, r.JuncLaneGroups[0].Lanes)
+		assert.Equal(t, []entity.ILane{road1.lane}, r.JuncLaneGroups[0].PreLanes)
+	}
+	assert.Equal(t, byResponse.Roads, byJourney.Roads)
+	assert.Equal(t, byResponse.JuncLaneGroups, byJourney.JuncLaneGroups)
+	assert.Equal(t, byResponse.Eta, byJourney.Eta)
+	assert.Equal(t, byResponse.EtaFreeFlow, byJourney.EtaFreeFlow)
+
+	// 空结果导航失败，错误的journey在两个入口都报错
+	byResponse.ProcessRouting(&routingv2.GetRouteResponse{})
+	assert.False(t, byResponse.Ok())
+	wrong := journey()
+	wrong.Driving.RoadIds = nil
+	assert.Panics(t, func() {
+		byResponse.ProcessRouting(&routingv2.GetRouteResponse{Journeys: []*routingv2.Journey{wrong}})
+	})
+	assert.Panics(t, func() { byJourney.ProcessInputJourney(wrong, start, end) })
+}
+
+func TestJunctionLaneTieBreak(t *testing.T) {
+	// 进入路口前的车道位于中间（offset 1），左右两侧的前驱车道与其offset差距相同，且都是路径中的最后一个路口
+	road := &stubRoad{id: 1}
+	cur := &stubLane{id: 11, road: road, offset: 1}
+	left, right := &stubLane{id: 10, road: road, offset: 0}, &stubLane{id: 12, road: road, offset: 2}
+	out := &stubLane{id: 31}
+	low, high := &stubLane{id: 21, pre: right, next: out}, &stubLane{id: 22, pre: left, next: out}
+	// 无论候选车道的顺序如何，总是选择ID最小的路口车道
+	for _, lanes := range [][2]*stubLane{{low, high}, {high, low}} {
+		r := NewVehicleRoute(&stubContext{}, &stubPerson{})
+		r.JuncLaneGroups = []JunctionCandidate{{
+			Lanes:    []entity.ILane{lanes[0], lanes[1]},
+			PreLanes: []entity.ILane{lanes[0].pre, lanes[1].pre},
+		}}
+		lane, delta := r.GetJunctionLaneByPreLane(cur, 0)
+		assert.Equal(t, entity.ILane(low), lane)
+		assert.Zero(t, delta)
+	}
+}
@@ -0,0 +1,40 @@
+package person
+
+import "flag"
+
+var (
+	harshAccelThreshold = flag.Float64("veh.harsh_accel_threshold", 2.5, "急加速的判定阈值（米/秒²），一步内任一子步的加速度大于该值时计为一次急加速，必须为正数")
+	harshBrakeThreshold = flag.Float64("veh.harsh_brake_threshold", 4, "急减速的判定阈值（米/秒²），一步内任一子步的减速度大于该值时计为一次急减速，必须为正数")
+)
+
+// PersonSafetyMetrics 人的驾驶安全指标
+type PersonSafetyMetrics struct {
+	HarshAccels int32 // 累计急加速次数（发生急加速的步数）
+	HarshBrakes int32 // 累计急减速次数（发生急减速的步数）
+}
+
+// add 累计一步的急加速与急减速次数，同一步内的多个子步只计一次
+func (s *PersonSafetyMetrics) add(h harshStep) {
+	if h.accel {
+		s.HarshAccels++
+	}
+	if h.brake {
+		s.HarshBrakes++
+	}
+}
+
+// harshStep 一步内各子步的急加速与急减速情况
+type harshStep struct {
+	accel bool // 是否有子步急加速
+	brake bool // 是否有子步急减速
+}
+
+// observe 按子步的加速度记录急加速与急减速
+// 参数：a-加速度（米/秒²）
+func (h *harshStep) observe(a float64) {
+	if a > *harshAccelThreshold {
+		h.accel = true
+	} else if a < -*harshBrakeThreshold {
+		h.brake = true
+	}
+}
@@ -0,0 +1,47 @@
+package person
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonSafetyMetrics(t *testing.T) {
+	ctx, m := newTestTrafficScene(1, 2)
+	follower, leader := m.data[1], m.data[2]
+	// 前车停止，后车以10m/s在15米的间距外驶近，需要紧急制动
+	leader.runtime.V, leader.snapshot.V = 0, 0
+	m.PrepareNode()
+	ctx.laneManager.Prepare()
+	m.Prepare()
+	follower.updateVehicle(1)
+	m.Prepare()
+	metrics, err := m.GetPersonSafetyMetrics(1)
+	assert.NoError(t, err)
+	assert.Less(t, follower.snapshot.Action.A, -4.)
+	assert.Equal(t, int32(1), metrics.HarshBrakes)
+	assert.Zero(t, metrics.HarshAccels)
+	metrics, err = m.GetPersonSafetyMetrics(2)
+	assert.NoError(t, err)
+	assert.Equal(t, PersonSafetyMetrics{}, metrics)
+	_, err = m.GetPersonSafetyMetrics(3)
+	assert.Error(t, err)
+}
+
+func TestPersonSafetyMetricsSubsteps(t *testing.T) {
+	defer flag.Set("sim.substeps", "1")
+	flag.Set("sim.substeps", "10")
+	ctx, m := newTestTrafficScene(1, 2)
+	follower, leader := m.data[1], m.data[2]
+	leader.runtime.V, leader.snapshot.V = 0, 0
+	m.PrepareNode()
+	ctx.laneManager.Prepare()
+	m.Prepare()
+	follower.updateVehicle(1)
+	m.Prepare()
+	// 多个子步急减速，一步内只计一次
+	metrics, err := m.GetPersonSafetyMetrics(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), metrics.HarshBrakes)
+}
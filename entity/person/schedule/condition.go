@@ -0,0 +1,22 @@
+package schedule
+
+import tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
+
+// TripConditionEvaluator 行程执行条件判定器
+// 功能：在行程被激活前判定其执行条件是否成立，条件不成立时该行程会被跳过
+// 说明：条件通常依赖模拟器外部的状态（如经济模块中的货币量），因此由使用者提供实现
+type TripConditionEvaluator interface {
+	// Evaluate 判定行程是否应当执行
+	// 参数：trip-待执行的行程，time-当前时间
+	// 返回：true表示执行该行程，false表示跳过
+	Evaluate(trip *tripv2.Trip, time float64) bool
+}
+
+// TripConditionFunc 函数形式的行程执行条件判定器
+// 功能：允许直接使用回调函数作为TripConditionEvaluator
+type TripConditionFunc func(trip *tripv2.Trip, time float64) bool
+
+// Evaluate 调用回调函数判定行程是否应当执行
+func (f TripConditionFunc) Evaluate(trip *tripv2.Trip, time float64) bool {
+	return f(trip, time)
+}
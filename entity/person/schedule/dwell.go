@@ -0,0 +1,36 @@
+package schedule
+
+import (
+	"math"
+
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
+)
+
+// DwellTimeDistribution 活动停留时间分布（对数正态分布）
+// 功能：描述人员在某类活动地点的停留时间分布，用于替代行程中固定的等待时间
+// 说明：使用均值和标准差描述，采样前换算为对数正态分布的参数
+type DwellTimeDistribution struct {
+	Mean float64 // 停留时间均值（秒）
+	Std  float64 // 停留时间标准差（秒）
+}
+
+// Sample 采样一个停留时间（非线程安全）
+// 功能：按对数正态分布采样停留时间
+// 参数：generator-随机数生成器
+// 返回：停留时间（秒），非负
+// 算法说明：
+// 1. sigma² = ln(1 + std² / mean²)
+// 2. mu = ln(mean) - sigma² / 2
+// 3. 返回 exp(mu + sigma * N(0, 1))
+// 说明：均值非正时返回0，标准差非正时直接返回均值
+func (d DwellTimeDistribution) Sample(generator *randengine.Engine) float64 {
+	if d.Mean <= 0 {
+		return 0
+	}
+	if d.Std <= 0 {
+		return d.Mean
+	}
+	sigma2 := math.Log(1 + d.Std*d.Std/(d.Mean*d.Mean))
+	mu := math.Log(d.Mean) - sigma2/2
+	return math.Exp(mu + math.Sqrt(sigma2)*generator.NormFloat64())
+}
@@ -1,6 +1,7 @@
 package schedule
 
 import (
+	"flag"
 	"fmt"
 
 	"git.fiblab.net/general/common/v2/mathutil"
@@ -10,6 +11,12 @@ import (
 	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
 	"github.com/samber/lo"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
+)
+
+var (
+	singlePass     = flag.Bool("schedule.single_pass", false, "是否忽略LoopCount，使所有schedule只执行一次（用于单次出行需求分析）")
+	maxTripsPerson = flag.Int("sim.max_trips_per_person", 0, "每个人自设置时刻表以来最多完成的行程数，达到后清空剩余时刻表（<=0表示不限制）")
 )
 
 // Schedule 时刻表
@@ -23,6 +30,13 @@ type Schedule struct {
 	TripIndex       int32              // 当前trip下标
 	loopCount       int32              // schedule循环计数器
 	lastTripEndTime float64            // 上次trip结束时间
+	completedTrips  int32              // 自设置时刻表以来完成的trip数
+
+	condition TripConditionEvaluator // 行程执行条件判定器（为nil时所有行程均执行）
+
+	generator  *randengine.Engine               // 随机数生成器（使用人的生成器）
+	dwellTimes map[string]DwellTimeDistribution // 按活动类型配置的停留时间分布
+	dwellTime  *float64                         // 本次采样的停留时间，非nil时替代下一个trip的等待时间
 }
 
 // NewSchedule 创建一个时刻表实例
@@ -56,6 +70,41 @@ func (s *Schedule) Base() []*tripv2.Schedule {
 // 3. 处理schedule间的等待时间或出发时间
 // 4. 当所有schedule完成时返回false
 func (s *Schedule) NextTrip(time float64) bool {
+	// 跳过的trip未实际执行，不采样停留时间
+	s.dwellTime = nil
+	return s.next(time)
+}
+
+// next 推进到下一个满足执行条件的trip
+func (s *Schedule) next(time float64) bool {
+	if !s.advance(time) {
+		return false
+	}
+	return s.skipUnsatisfiedTrips(time)
+}
+
+// CompleteTrip 完成当前trip并进入下一个trip，返回是否成功（是否还有trip）
+// 功能：记录完成的trip数，达到sim.max_trips_per_person时清空剩余时刻表
+// 参数：time-当前时间
+// 说明：导航失败等未完成的trip应调用NextTrip跳过，不计入完成数，也不采样停留时间
+func (s *Schedule) CompleteTrip(time float64) bool {
+	s.completedTrips++
+	if *maxTripsPerson > 0 && s.completedTrips >= int32(*maxTripsPerson) && len(s.base) > 0 {
+		s.lastTripEndTime = time
+		s.base = make([]*tripv2.Schedule, 0)
+		s.ScheduleIndex, s.TripIndex, s.loopCount = 0, 0, 0
+		s.dwellTime = nil
+		return false
+	}
+	s.sampleDwellTime(s.GetTrip())
+	return s.next(time)
+}
+
+// advance 将下标推进到下一个trip
+// 功能：NextTrip的核心逻辑，不考虑行程执行条件
+// 参数：time-当前时间
+// 返回：true表示还有行程，false表示所有行程已完成
+func (s *Schedule) advance(time float64) bool {
 	if len(s.base) == 0 {
 		return false
 	}
@@ -63,7 +112,7 @@ func (s *Schedule) NextTrip(time float64) bool {
 	s.lastTripEndTime = time
 	if s.TripIndex++; s.TripIndex == int32(len(schedule.Trips)) {
 		s.TripIndex = 0
-		if s.loopCount++; schedule.LoopCount > 0 && s.loopCount >= schedule.LoopCount {
+		if s.loopCount++; loopDone(schedule, s.loopCount) {
 			s.loopCount = 0
 			if s.ScheduleIndex++; s.ScheduleIndex == int32(len(s.base)) {
 				s.base = make([]*tripv2.Schedule, 0)
@@ -81,6 +130,76 @@ func (s *Schedule) NextTrip(time float64) bool {
 	return true
 }
 
+// loopDone 判断schedule是否已完成全部循环
+// 功能：LoopCount<=0表示无限循环；启用schedule.single_pass时任何schedule只执行一次
+// 参数：schedule-当前schedule，loopCount-已完成的循环次数
+// 返回：true表示应进入下一个schedule
+func loopDone(schedule *tripv2.Schedule, loopCount int32) bool {
+	if *singlePass {
+		return loopCount >= 1
+	}
+	return schedule.LoopCount > 0 && loopCount >= schedule.LoopCount
+}
+
+// SetConditionEvaluator 设置行程执行条件判定器
+// 功能：设置后每次进入新的trip时都会判定其执行条件，条件不成立的trip将被跳过
+// 参数：condition-条件判定器，nil表示不做判定
+func (s *Schedule) SetConditionEvaluator(condition TripConditionEvaluator) {
+	s.condition = condition
+}
+
+// skipUnsatisfiedTrips 跳过执行条件不成立的trip
+// 功能：从当前trip开始，依次跳过条件判定为false的trip，直到遇到可执行的trip
+// 参数：time-当前时间
+// 返回：true表示找到可执行的trip，false表示所有行程已完成
+// 说明：为避免无限循环的schedule中所有trip均不满足条件导致死循环，
+// 连续跳过的trip数超过时刻表中trip总数时视为时刻表结束
+func (s *Schedule) skipUnsatisfiedTrips(time float64) bool {
+	if s.condition == nil {
+		return len(s.base) != 0
+	}
+	maxSkip := 0
+	for _, schedule := range s.base {
+		maxSkip += len(schedule.Trips)
+	}
+	for skip := 0; len(s.base) != 0; skip++ {
+		trip := s.GetTrip()
+		if s.condition.Evaluate(trip, time) {
+			return true
+		}
+		if skip >= maxSkip {
+			log.Warnf("all trips are skipped by condition, clear the schedule")
+			s.base = make([]*tripv2.Schedule, 0)
+			s.ScheduleIndex, s.TripIndex, s.loopCount = 0, 0, 0
+			return false
+		}
+		log.Debugf("trip %v is skipped by condition", trip)
+		s.advance(time)
+	}
+	return false
+}
+
+// SetDwellTimeDistributions 设置按活动类型划分的停留时间分布
+// 功能：设置后，每个trip完成时会按该trip的活动类型采样停留时间，作为下一个trip的等待时间
+// 参数：generator-随机数生成器，dwellTimes-活动类型到停留时间分布的映射
+// 说明：未配置分布的活动类型仍使用trip中固定的等待时间，指定出发时间的trip不受影响
+func (s *Schedule) SetDwellTimeDistributions(generator *randengine.Engine, dwellTimes map[string]DwellTimeDistribution) {
+	s.generator = generator
+	s.dwellTimes = dwellTimes
+}
+
+// sampleDwellTime 按已结束trip的活动类型采样停留时间
+func (s *Schedule) sampleDwellTime(ended *tripv2.Trip) {
+	s.dwellTime = nil
+	if ended == nil || len(s.dwellTimes) == 0 {
+		return
+	}
+	if d, ok := s.dwellTimes[ended.GetActivity()]; ok {
+		dwellTime := d.Sample(s.generator)
+		s.dwellTime = &dwellTime
+	}
+}
+
 // GetTrip 获取当前trip
 // 功能：返回当前正在执行的行程
 // 返回：当前行程，如果没有则返回nil
@@ -100,6 +219,39 @@ func (s *Schedule) GetTrip() *tripv2.Trip {
 // 参数：base-新的时刻表数据，time-当前时间
 // 说明：过滤无效的行程，重置索引和计数器
 func (s *Schedule) Set(base []*tripv2.Schedule, time float64) {
+	okBase := s.filterValid(base)
+	s.base = okBase
+	s.ScheduleIndex, s.TripIndex, s.loopCount = 0, 0, 0
+	s.completedTrips = 0
+	s.dwellTime = nil
+	if len(okBase) == 0 {
+		s.lastTripEndTime = time
+		return
+	}
+	if lastDepartureTime := okBase[0].DepartureTime; lastDepartureTime != nil {
+		s.lastTripEndTime = *lastDepartureTime
+	} else if waitTime := okBase[0].WaitTime; waitTime != nil {
+		s.lastTripEndTime = time + *waitTime
+	} else {
+		s.lastTripEndTime = time
+	}
+	s.skipUnsatisfiedTrips(time)
+}
+
+// Append 在时刻表末尾追加schedule
+// 功能：不打断当前trip，当前时刻表的schedule全部完成后继续执行追加的schedule
+// 参数：base-追加的时刻表数据，time-当前时间
+// 说明：过滤无效的行程；时刻表为空时等同于Set；当前schedule为无限循环时追加的schedule不会被执行
+func (s *Schedule) Append(base []*tripv2.Schedule, time float64) {
+	if len(s.base) == 0 {
+		s.Set(base, time)
+		return
+	}
+	s.base = append(s.base, s.filterValid(base)...)
+}
+
+// filterValid 过滤时刻表中终点无效的行程，不包含有效行程的schedule被移除
+func (s *Schedule) filterValid(base []*tripv2.Schedule) []*tripv2.Schedule {
 	// 错误检查
 	okBase := make([]*tripv2.Schedule, 0, len(base))
 	for _, schedule := range base {
@@ -124,20 +276,7 @@ func (s *Schedule) Set(base []*tripv2.Schedule, time float64) {
 			okBase = append(okBase, schedule)
 		}
 	}
-
-	s.base = okBase
-	s.ScheduleIndex, s.TripIndex, s.loopCount = 0, 0, 0
-	if len(okBase) == 0 {
-		s.lastTripEndTime = time
-		return
-	}
-	if lastDepartureTime := okBase[0].DepartureTime; lastDepartureTime != nil {
-		s.lastTripEndTime = *lastDepartureTime
-	} else if waitTime := okBase[0].WaitTime; waitTime != nil {
-		s.lastTripEndTime = time + *waitTime
-	} else {
-		s.lastTripEndTime = time
-	}
+	return okBase
 }
 
 // Empty 判断时刻表是否为空
@@ -150,7 +289,7 @@ func (s *Schedule) Empty() bool {
 // GetDepartureTime 获取当前trip的出发时间
 // 功能：计算当前行程的出发时间
 // 返回：出发时间，如果没有行程则返回无穷大
-// 说明：优先使用行程的出发时间，其次使用等待时间
+// 说明：优先使用行程的出发时间，其次使用采样的停留时间，最后使用等待时间
 func (s *Schedule) GetDepartureTime() float64 {
 	if len(s.base) == 0 {
 		//没有日程则返回∞
@@ -163,6 +302,9 @@ func (s *Schedule) GetDepartureTime() float64 {
 		}
 		return *departureTime
 	}
+	if s.dwellTime != nil {
+		return s.lastTripEndTime + *s.dwellTime
+	}
 	if waitTime := trip.WaitTime; waitTime != nil {
 		return s.lastTripEndTime + *waitTime
 	} else {
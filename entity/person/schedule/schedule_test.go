@@ -0,0 +1,171 @@
+package schedule_test
+
+import (
+	"flag"
+	"testing"
+
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
+	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/person/schedule"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
+)
+
+func newTrip(aoiID int32) *tripv2.Trip {
+	return &tripv2.Trip{
+		End: &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: aoiID}},
+	}
+}
+
+func newSchedules(aoiIDs ...int32) []*tripv2.Schedule {
+	trips := make([]*tripv2.Trip, 0, len(aoiIDs))
+	for _, id := range aoiIDs {
+		trips = append(trips, newTrip(id))
+	}
+	return []*tripv2.Schedule{{Trips: trips, LoopCount: 1}}
+}
+
+func tripAoiID(s *schedule.Schedule) int32 {
+	return s.GetTrip().End.AoiPosition.AoiId
+}
+
+func TestConditionFalseSkipsTrip(t *testing.T) {
+	s := schedule.NewSchedule(nil, nil)
+	s.SetConditionEvaluator(schedule.TripConditionFunc(func(trip *tripv2.Trip, _ float64) bool {
+		return trip.End.AoiPosition.AoiId != 2
+	}))
+	s.Set(newSchedules(1, 2, 3), 0)
+	assert.Equal(t, int32(1), tripAoiID(s))
+	assert.True(t, s.NextTrip(10))
+	assert.Equal(t, int32(3), tripAoiID(s))
+	assert.False(t, s.NextTrip(20))
+	assert.True(t, s.Empty())
+}
+
+func TestConditionTrueExecutesTrip(t *testing.T) {
+	s := schedule.NewSchedule(nil, nil)
+	s.SetConditionEvaluator(schedule.TripConditionFunc(func(*tripv2.Trip, float64) bool {
+		return true
+	}))
+	s.Set(newSchedules(1, 2, 3), 0)
+	for _, id := range []int32{1, 2, 3} {
+		assert.Equal(t, id, tripAoiID(s))
+		s.NextTrip(0)
+	}
+	assert.True(t, s.Empty())
+}
+
+func TestConditionFirstTripSkipped(t *testing.T) {
+	s := schedule.NewSchedule(nil, nil)
+	s.SetConditionEvaluator(schedule.TripConditionFunc(func(trip *tripv2.Trip, _ float64) bool {
+		return trip.End.AoiPosition.AoiId != 1
+	}))
+	s.Set(newSchedules(1, 2), 0)
+	assert.Equal(t, int32(2), tripAoiID(s))
+}
+
+func TestConditionAllFalseInfiniteLoop(t *testing.T) {
+	s := schedule.NewSchedule(nil, nil)
+	s.SetConditionEvaluator(schedule.TripConditionFunc(func(*tripv2.Trip, float64) bool {
+		return false
+	}))
+	schedules := newSchedules(1, 2)
+	schedules[0].LoopCount = 0
+	s.Set(schedules, 0)
+	assert.True(t, s.Empty())
+	assert.False(t, s.NextTrip(0))
+}
+
+func TestDwellTimeMean(t *testing.T) {
+	const mean, std, n = 3600., 900., 20000
+	activity := "work"
+	trip := newTrip(1)
+	trip.Activity = &activity
+	s := schedule.NewSchedule(nil, nil)
+	s.SetDwellTimeDistributions(randengine.New(0), map[string]schedule.DwellTimeDistribution{
+		activity: {Mean: mean, Std: std},
+	})
+	s.Set([]*tripv2.Schedule{{Trips: []*tripv2.Trip{trip}}}, 0)
+	sum := 0.
+	for i := 0; i < n; i++ {
+		assert.True(t, s.CompleteTrip(0))
+		sum += s.GetDepartureTime()
+	}
+	assert.InDelta(t, mean, sum/n, mean*0.02)
+}
+
+func TestDwellTimeSkippedTrip(t *testing.T) {
+	activity := "work"
+	waitTime := 60.
+	trip := newTrip(1)
+	trip.Activity = &activity
+	trip.WaitTime = &waitTime
+	s := schedule.NewSchedule(nil, nil)
+	s.SetDwellTimeDistributions(randengine.New(0), map[string]schedule.DwellTimeDistribution{
+		activity: {Mean: 3600, Std: 900},
+	})
+	s.Set([]*tripv2.Schedule{{Trips: []*tripv2.Trip{trip}}}, 0)
+	// 跳过的trip不采样停留时间，使用固定的等待时间
+	assert.True(t, s.NextTrip(100))
+	assert.Equal(t, 160., s.GetDepartureTime())
+	assert.True(t, s.CompleteTrip(100))
+	assert.NotEqual(t, 160., s.GetDepartureTime())
+	assert.True(t, s.NextTrip(100))
+	assert.Equal(t, 160., s.GetDepartureTime())
+}
+
+func TestDwellTimeDisabled(t *testing.T) {
+	waitTime := 60.
+	trip := newTrip(1)
+	trip.WaitTime = &waitTime
+	s := schedule.NewSchedule(nil, nil)
+	s.Set([]*tripv2.Schedule{{Trips: []*tripv2.Trip{trip}}}, 0)
+	for i := 0; i < 10; i++ {
+		assert.True(t, s.NextTrip(100))
+		assert.Equal(t, 160., s.GetDepartureTime())
+	}
+}
+
+func TestSinglePass(t *testing.T) {
+	assert.NoError(t, flag.Set("schedule.single_pass", "true"))
+	defer flag.Set("schedule.single_pass", "false")
+
+	s := schedule.NewSchedule(nil, nil)
+	schedules := newSchedules(1, 2)
+	schedules[0].LoopCount = 0
+	s.Set(schedules, 0)
+	assert.Equal(t, int32(1), tripAoiID(s))
+	assert.True(t, s.NextTrip(10))
+	assert.Equal(t, int32(2), tripAoiID(s))
+	// 无限循环的schedule执行一遍后结束
+	assert.False(t, s.NextTrip(20))
+	assert.True(t, s.Empty())
+	assert.Nil(t, s.GetTrip())
+}
+
+func TestMaxTripsPerPerson(t *testing.T) {
+	assert.NoError(t, flag.Set("sim.max_trips_per_person", "3"))
+	defer flag.Set("sim.max_trips_per_person", "0")
+
+	s := schedule.NewSchedule(nil, nil)
+	schedules := newSchedules(1, 2)
+	schedules[0].LoopCount = 0
+	s.Set(schedules, 0)
+	// 无限循环的schedule完成3次trip后清空
+	assert.True(t, s.CompleteTrip(10))
+	assert.Equal(t, int32(2), tripAoiID(s))
+	assert.True(t, s.CompleteTrip(20))
+	assert.Equal(t, int32(1), tripAoiID(s))
+	assert.False(t, s.CompleteTrip(30))
+	assert.True(t, s.Empty())
+	assert.Nil(t, s.GetTrip())
+
+	// 跳过的trip不计入完成数，重新设置时刻表后重新计数
+	s.Set(schedules, 40)
+	assert.True(t, s.NextTrip(50))
+	assert.True(t, s.NextTrip(60))
+	assert.True(t, s.CompleteTrip(70))
+	assert.True(t, s.CompleteTrip(80))
+	assert.False(t, s.CompleteTrip(90))
+	assert.True(t, s.Empty())
+}
@@ -0,0 +1,80 @@
+package person
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"git.fiblab.net/general/common/v2/parallel"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+)
+
+var (
+	outputJSONL = flag.String("output.jsonl", "", "轨迹JSON Lines输出文件路径（为空表示不输出），每个输出步为每个在路上的人写入一行")
+)
+
+// trajectoryRecord 轨迹输出中的一行
+type trajectoryRecord struct {
+	T        float64 `json:"t"`
+	PersonID int32   `json:"person_id"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	V        float64 `json:"v"`
+	Status   string  `json:"status"`
+	Teleport bool    `json:"teleport,omitempty"` // 是否正在因导航失败直线瞬移（位置为起点）
+}
+
+// trajectoryWriter 轨迹JSON Lines输出
+// 功能：将在路上（驾车或步行）的人的快照位置与速度逐行写入文件，便于快速绘图；正在直线瞬移的人同样输出并标记teleport
+type trajectoryWriter struct {
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+// newTrajectoryWriter 创建轨迹输出，打开（覆盖）指定文件
+func newTrajectoryWriter(path string) (*trajectoryWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	return &trajectoryWriter{file: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// write 写入一个输出步的轨迹
+// 功能：并行收集人的快照数据，再按人员顺序依次写入
+// 参数：t-当前仿真时间，persons-所有人
+func (tw *trajectoryWriter) write(t float64, persons []*Person) error {
+	records := parallel.GoMapFilter(persons, func(p *Person) (trajectoryRecord, bool) {
+		status := p.Status()
+		if status != personv2.Status_STATUS_DRIVING && status != personv2.Status_STATUS_WALKING && !p.snapshot.Teleporting {
+			return trajectoryRecord{}, false
+		}
+		return trajectoryRecord{
+			T:        t,
+			PersonID: p.ID(),
+			X:        p.snapshot.XYZ.X,
+			Y:        p.snapshot.XYZ.Y,
+			V:        p.snapshot.V,
+			Status:   status.String(),
+			Teleport: p.snapshot.Teleporting,
+		}, true
+	})
+	for _, r := range records {
+		if err := tw.enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close 写出缓冲区并关闭文件
+func (tw *trajectoryWriter) close() error {
+	if err := tw.w.Flush(); err != nil {
+		tw.file.Close()
+		return err
+	}
+	return tw.file.Close()
+}
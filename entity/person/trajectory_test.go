@@ -0,0 +1,49 @@
+package person
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrajectoryOutput(t *testing.T) {
+	ctx := newTestContext(
+		[]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)},
+		[]*mapv2.Aoi{newTestAoiPb(10, 1, 30)},
+	)
+	l := ctx.laneManager.Get(1)
+	m := newTestManager(
+		newTestDrivingPerson(ctx, 1, l, 10),
+		newTestDrivingPerson(ctx, 2, l, 50),
+		newTestSleepingPerson(ctx, 3, ctx.aoiManager.Get(10)),
+	)
+	m.ctx = ctx
+	path := filepath.Join(t.TempDir(), "trajectory.jsonl")
+	tw, err := newTrajectoryWriter(path)
+	assert.NoError(t, err)
+	m.trajectory = tw
+
+	// 3个输出步，每步只输出2个在路上的人
+	for i := 1; i <= 3; i++ {
+		ctx.clock.InternalStep++
+		ctx.clock.T = float64(i)
+		m.Prepare()
+	}
+	m.Close()
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 6)
+	var first trajectoryRecord
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, trajectoryRecord{
+		T: 1, PersonID: 1, X: 10, Y: 0, V: 0, Status: personv2.Status_STATUS_DRIVING.String(),
+	}, first)
+}
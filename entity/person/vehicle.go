@@ -1,6 +1,7 @@
 package person
 
 import (
+	"flag"
 	"math"
 
 	"git.fiblab.net/general/common/v2/geometry"
@@ -8,8 +9,9 @@ import (
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 )
 
-const (
-	closeToEnd = 5 // 车辆到达终点的判定范围（米）
+var (
+	substeps         = flag.Int("sim.substeps", 1, "车辆跟驰与位置积分的子步数，每个仿真步内以dt/n的步长迭代n次（信控与输出仍按dt更新）")
+	arrivalThreshold = flag.Float64("veh.arrival_threshold", 5, "车辆到达终点的判定范围（米），必须为正数")
 )
 
 // vehicle 车辆实体数据结构
@@ -60,21 +62,21 @@ func (p *Person) updateLaneVehicleNodes(needIndexMaintenance bool) {
 		if !p.snapshot.LC.InShadowLane() && !p.runtime.LC.InShadowLane() {
 			// do nothing
 		} else if p.snapshot.LC.InShadowLane() && !p.runtime.LC.InShadowLane() {
-			p.snapshot.LC.ShadowLane.RemoveVehicle(p.vehicle.shadowNode)
+			p.snapshot.LC.ShadowLane.RemoveShadowVehicle(p.vehicle.shadowNode)
 		} else if !p.snapshot.LC.InShadowLane() && p.runtime.LC.InShadowLane() {
-			p.runtime.LC.ShadowLane.AddVehicle(p.vehicle.shadowNode)
+			p.runtime.LC.ShadowLane.AddShadowVehicle(p.vehicle.shadowNode)
 		} else {
 			if p.snapshot.LC.ShadowLane != p.runtime.LC.ShadowLane {
-				p.snapshot.LC.ShadowLane.RemoveVehicle(p.vehicle.shadowNode)
+				p.snapshot.LC.ShadowLane.RemoveShadowVehicle(p.vehicle.shadowNode)
 				p.vehicle.shadowNode = newVehicleNode(p.runtime.LC.ShadowS, p)
-				p.runtime.LC.ShadowLane.AddVehicle(p.vehicle.shadowNode)
+				p.runtime.LC.ShadowLane.AddShadowVehicle(p.vehicle.shadowNode)
 			}
 		}
 	} else {
 		// 不维护数据
 		p.snapshot.Lane.RemoveVehicle(p.vehicle.node)
 		if p.snapshot.LC.InShadowLane() {
-			p.snapshot.LC.ShadowLane.RemoveVehicle(p.vehicle.shadowNode)
+			p.snapshot.LC.ShadowLane.RemoveShadowVehicle(p.vehicle.shadowNode)
 		}
 	}
 }
@@ -89,6 +91,9 @@ func (p *Person) updateLaneVehicleNodes(needIndexMaintenance bool) {
 // 3. 处理停车状态
 // 4. 处理离开停车点
 // 5. 强制结束处理
+// 说明：sim.substeps>1时将dt均分为多个子步依次执行控制与位置积分，本车以运行时状态决策，
+// 周围车辆的位置按快照速度外推到当前子步；车道链表只在全部子步结束后更新，
+// 因此车辆驶入新车道或变道状态改变后，剩余子步沿用上一子步的加速度
 func (p *Person) updateVehicle(dt float64) (isEnd bool) {
 	// DEBUG, node一致性
 	if p.runtime.LC.InShadowLane() {
@@ -96,12 +101,26 @@ func (p *Person) updateVehicle(dt float64) (isEnd bool) {
 			log.Panicf("vehicle: vehicle %v shadowNode is nil", p.ID())
 		}
 	}
-	p.runtime.Action = p.vehicle.controller.update(dt)
 	// 到最后一个step了，不管到没到目的地，都进行清理操作
 	forceEnd := p.ctx.Clock().InternalStep+1 == p.ctx.Clock().END_STEP
-	p.runtime.forceClearVehicleRuntime(forceEnd)
-	skipToEnd := p.refreshRuntime(p.runtime.Action, dt)
-	reachTarget := p.checkCloseToEndAndRefreshRuntime(skipToEnd)
+	n := max(*substeps, 1)
+	subDT := dt / float64(n)
+	reachTarget := false
+	var harsh harshStep
+	for i := 0; i < n && !reachTarget; i++ {
+		if i == 0 || p.nodesConsistent() {
+			p.runtime.Action = p.vehicle.controller.update(subDT, float64(i)*subDT)
+		} else {
+			// 车道链表与运行时状态不一致，控制器无法感知周围车辆，只继续积分
+			p.runtime.Action.LCTarget = nil
+		}
+		p.runtime.forceClearVehicleRuntime(forceEnd)
+		harsh.observe(p.runtime.Action.A)
+		skipToEnd := p.refreshRuntime(p.runtime.Action, subDT)
+		reachTarget = p.checkCloseToEndAndRefreshRuntime(skipToEnd)
+	}
+	// 累计急加速与急减速次数，按步计数
+	p.safety.add(harsh)
 	if reachTarget || forceEnd {
 		// 增量更新车道索引（不再维护数据）
 		p.updateLaneVehicleNodes(false)
@@ -114,6 +133,13 @@ func (p *Person) updateVehicle(dt float64) (isEnd bool) {
 	return
 }
 
+// 检查运行时的车道与变道状态是否与快照（即车道链表中的节点）一致
+func (p *Person) nodesConsistent() bool {
+	return p.runtime.Lane == p.snapshot.Lane &&
+		p.runtime.LC.IsLC == p.snapshot.LC.IsLC &&
+		p.runtime.LC.ShadowLane == p.snapshot.LC.ShadowLane
+}
+
 // 计算本时刻的速度与移动距离
 // v(t)=v(t-1)+acc*dt, ds=v(t-1)*dt+acc*dt*dt/2
 func computeVAndDistance(v, a, dt float64) (float64, float64) {
@@ -127,7 +153,8 @@ func computeVAndDistance(v, a, dt float64) (float64, float64) {
 
 func (p *Person) refreshRuntime(ac Action, dt float64) (skipToEnd bool) {
 	// ATTENTION: 注意v.runtime.Motion不是指针
-	v, d := computeVAndDistance(p.V(), ac.A, dt)
+	v0 := p.runtime.V // 本子步开始时的速度
+	v, d := computeVAndDistance(v0, ac.A, dt)
 
 	// 阿克曼转向动力学
 
@@ -185,17 +212,22 @@ func (p *Person) refreshRuntime(ac Action, dt float64) (skipToEnd bool) {
 					ShadowLane:     newRuntime.LC.ShadowLane,
 					ShadowS:        newRuntime.LC.ShadowS,
 					CompletedRatio: 0,
+					Mandatory:      ac.LCMandatory,
 				}
+				p.laneChanges.initiate(ac.LCMandatory)
 				log.Debugf("vehicle: 情况3 %v LC %v", p.ID(), newRuntime.LC)
 				newRuntime.Lane = ac.LCTarget
 				newRuntime.S = ac.LCTarget.ProjectFromLane(newRuntime.LC.ShadowLane, newRuntime.LC.ShadowS)
 			} else if ac.LCTarget == newRuntime.Lane.LeftLane() || ac.LCTarget == newRuntime.Lane.RightLane() {
 				// 情况4
+				p.laneChanges.complete(newRuntime.LC.Mandatory)
 				newRuntime.LC = lcRuntime{
 					IsLC:           true,
 					ShadowLane:     newRuntime.Lane,
 					CompletedRatio: 0,
+					Mandatory:      ac.LCMandatory,
 				}
+				p.laneChanges.initiate(ac.LCMandatory)
 				log.Debugf("vehicle: 情况4 %v LC %v", p.ID(), newRuntime.LC)
 				newRuntime.Lane = ac.LCTarget
 				newRuntime.S = ac.LCTarget.ProjectFromLane(newRuntime.Lane, newRuntime.S)
@@ -218,7 +250,9 @@ func (p *Person) refreshRuntime(ac Action, dt float64) (skipToEnd bool) {
 				IsLC:           true,
 				ShadowLane:     newRuntime.Lane,
 				CompletedRatio: 0,
+				Mandatory:      ac.LCMandatory,
 			}
+			p.laneChanges.initiate(ac.LCMandatory)
 			log.Debugf("vehicle: 情况else %v LC %v", p.ID(), newRuntime.LC)
 			newRuntime.S = ac.LCTarget.ProjectFromLane(newRuntime.Lane, newRuntime.S)
 			newRuntime.Lane = ac.LCTarget
@@ -232,6 +266,7 @@ func (p *Person) refreshRuntime(ac Action, dt float64) (skipToEnd bool) {
 		// 处理变道状态
 		if ratio >= 1 {
 			// 变道已经完成
+			p.laneChanges.complete(newRuntime.LC.Mandatory)
 			newRuntime.clearLaneChange()
 		} else {
 			newRuntime.LC.CompletedRatio = ratio
@@ -252,6 +287,8 @@ func (p *Person) refreshRuntime(ac Action, dt float64) (skipToEnd bool) {
 	p.runtime = newRuntime
 	// 更新车辆速度
 	p.runtime.V = v
+	// 累计能耗，速度取本子步的平均速度
+	p.energy.add((v0+v)/2, ac.A, dt)
 	// 更新统计
 	p.m.recordRunning(dt, d)
 	return skipToEnd
@@ -267,9 +304,10 @@ func (p *Person) driveStraightAndRefreshLocation(rt *runtime, ds float64, dt flo
 				p.ID(), rt.LC)
 		}
 		rt.clearLaneChange()
+		rt.ZipperYieldTo = nil
 		for s > lane.Length() {
 			s -= lane.Length()
-			lane = p.multiModalRoute.VehicleRoute.Next(lane, p.snapshot.S, p.snapshot.V)
+			lane = p.multiModalRoute.VehicleRoute.Next(lane, p.runtime.S, p.runtime.V)
 			if lane == nil {
 				return true
 			}
@@ -282,7 +320,7 @@ func (p *Person) driveStraightAndRefreshLocation(rt *runtime, ds float64, dt flo
 
 // 检查车辆是否到达目标地点，是则返回true
 func (p *Person) checkCloseToEndAndRefreshRuntime(skipToEnd bool) bool {
-	if skipToEnd || (p.runtime.Lane.ParentRoad() == p.multiModalRoute.VehicleRoute.End.Lane.ParentRoad() && p.multiModalRoute.VehicleRoute.End.S-p.runtime.S <= closeToEnd) {
+	if skipToEnd || (p.runtime.Lane.ParentRoad() == p.multiModalRoute.VehicleRoute.End.Lane.ParentRoad() && p.multiModalRoute.VehicleRoute.End.S-p.runtime.S <= *arrivalThreshold) {
 		// 到达目的地，设置motion为目的地的路面位置（供人进入aoi时选择gate）
 		p.runtime.Lane = p.multiModalRoute.VehicleRoute.End.Lane
 		p.runtime.S = p.multiModalRoute.VehicleRoute.End.S
@@ -0,0 +1,140 @@
+package person
+
+import (
+	"flag"
+	"math"
+	"testing"
+
+	"git.fiblab.net/general/common/v2/mathutil"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/road"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/randengine"
+)
+
+func TestVehicleSubsteps(t *testing.T) {
+	defer flag.Set("sim.substeps", "1")
+	const (
+		n  = 20  // 车辆数，每辆车独占一条车道以消除加速度随机扰动的影响
+		v0 = 20. // 初速度
+		dt = 5.  // 较大的仿真步长
+	)
+	lanes := make([]*mapv2.Lane, n)
+	roads := make([]*mapv2.Road, n)
+	for i := range lanes {
+		id := int32(i + 1)
+		lanes[i] = newTestLanePb(id, mapv2.LaneType_LANE_TYPE_DRIVING, 10000)
+		lanes[i].MaxSpeed = 30
+		roads[i] = &mapv2.Road{Id: id, LaneIds: []int32{id}}
+	}
+	attr := &personv2.VehicleAttribute{
+		Length:                           5,
+		MaxSpeed:                         30,
+		MaxAcceleration:                  3,
+		UsualBrakingAcceleration:         -4.5,
+		MaxBrakingAcceleration:           -10,
+		MinGap:                           1,
+		Headway:                          1.5,
+		LaneMaxSpeedRecognitionDeviation: 1,
+	}
+
+	// 无前车时IDM模型一步内行驶距离的参考值（以极小步长积分）
+	ref := func() float64 {
+		c := &controller{
+			maxA: attr.MaxAcceleration, usualBrakingA: attr.UsualBrakingAcceleration, maxBrakingA: attr.MaxBrakingAcceleration,
+			idmDelta: 4, idmComfortB: attr.UsualBrakingAcceleration,
+		}
+		v, d := v0, 0.
+		h := dt / 10000
+		for i := 0; i < 10000; i++ {
+			var dd float64
+			v, dd = computeVAndDistance(v, c.followImpl(v, 30, 0, mathutil.INF, 0, 0), h)
+			d += dd
+		}
+		return d
+	}()
+
+	// 所有车辆一步内行驶距离与参考值之差的平均值
+	meanError := func(substeps string) float64 {
+		flag.Set("sim.substeps", substeps)
+		ctx := newTestContext(lanes, nil)
+		rm := road.NewManager(ctx)
+		rm.Init(roads, ctx.laneManager)
+		persons := make([]*Person, n)
+		for i := range persons {
+			id := int32(i + 1)
+			l := ctx.laneManager.Get(id)
+			p := newTestDrivingPerson(ctx, id, l, 0)
+			p.vehicleAttr = attr
+			p.generator = randengine.New(uint64(i))
+			p.vehicle.controller = newController(p)
+			p.runtime.V, p.snapshot.V = v0, v0
+			p.multiModalRoute.VehicleRoute.AtRoad = true
+			p.multiModalRoute.VehicleRoute.Roads = []entity.IRoad{rm.Get(id)}
+			p.multiModalRoute.VehicleRoute.End = entity.RoutePosition{Lane: l, S: 9000}
+			persons[i] = p
+		}
+		m := newTestManager(persons...)
+		m.ctx = ctx
+		sum := 0.
+		for _, p := range persons {
+			assert.False(t, p.updateVehicle(dt))
+			sum += p.runtime.S - ref
+		}
+		return math.Abs(sum / n)
+	}
+	coarse := meanError("1")
+	fine := meanError("10")
+	assert.Less(t, fine, coarse)
+	assert.Less(t, fine, 1.)
+}
+
+func TestVehicleSubstepsLeader(t *testing.T) {
+	defer flag.Set("sim.substeps", "1")
+	defer flag.Set("sim.disable_noise", "false")
+	flag.Set("sim.disable_noise", "true")
+	// 后车一步后的速度：前车在本步开始时位于前方15米处，与后车同以10m/s行驶
+	followerV := func(substeps string) float64 {
+		flag.Set("sim.substeps", substeps)
+		ctx, m := newTestTrafficScene(1, 2)
+		m.PrepareNode()
+		ctx.laneManager.Prepare()
+		m.Prepare()
+		follower := m.data[1]
+		assert.False(t, follower.updateVehicle(1))
+		return follower.runtime.V
+	}
+	coarse := followerV("1")
+	// 子步中前车位置随时间外推，间距不会因前车静止而被低估导致后车急刹
+	fine := followerV("10")
+	assert.InDelta(t, coarse, fine, .5)
+	assert.Greater(t, fine, 9.)
+}
+
+func TestArrivalThreshold(t *testing.T) {
+	defer flag.Set("veh.arrival_threshold", "5")
+	ctx := newTestContext([]*mapv2.Lane{newTestLanePb(1, mapv2.LaneType_LANE_TYPE_DRIVING, 100)}, nil)
+	l := ctx.laneManager.Get(1)
+
+	// 车辆每次前进0.5米，返回判定到达时距终点的距离
+	arriveAt := func(threshold string) float64 {
+		flag.Set("veh.arrival_threshold", threshold)
+		p := newTestDrivingPerson(ctx, 1, l, 80)
+		p.multiModalRoute.VehicleRoute.End = entity.RoutePosition{Lane: l, S: 95}
+		for s := 80.; s <= 95; s += 0.5 {
+			p.runtime.S = s
+			if p.checkCloseToEndAndRefreshRuntime(false) {
+				assert.Equal(t, 95., p.runtime.S)
+				assert.Zero(t, p.runtime.V)
+				return 95 - s
+			}
+		}
+		t.Fatalf("vehicle did not arrive with threshold %s", threshold)
+		return 0
+	}
+	assert.Equal(t, 5., arriveAt("5"))
+	assert.Equal(t, 1., arriveAt("1"))
+	assert.Less(t, arriveAt("0.5"), arriveAt("5"))
+}
@@ -10,6 +10,8 @@ type Action struct {
 	A        float64      // 加速度（米/秒²）
 	LCTarget entity.ILane // 变道目标车道
 	LCPhi    float64      // 变道过程的前轮角度（弧度）
+	// 是否为强制变道（为驶入导航所需的车道而必须进行的变道）
+	LCMandatory bool
 
 	AheadVDistance float64 // 到前方车辆的距离（米）
 }
@@ -32,6 +34,7 @@ func (a *Action) Update(others ...Action) {
 			}
 			a.LCTarget = o.LCTarget
 			a.LCPhi = o.LCPhi
+			a.LCMandatory = o.LCMandatory
 		}
 	}
 }
@@ -55,9 +58,10 @@ func (a *Action) SetBrakeAcc(brakeDistance, v float64) {
 
 // startLaneChange 开始变道
 // 功能：设置变道目标车道和变道角度
-// 参数：lcTarget-变道目标车道，lcPhi-变道角度（弧度）
+// 参数：lcTarget-变道目标车道，lcPhi-变道角度（弧度），mandatory-是否为强制变道
 // 说明：用于初始化变道动作的参数
-func (a *Action) startLaneChange(lcTarget entity.ILane, lcPhi float64) {
+func (a *Action) startLaneChange(lcTarget entity.ILane, lcPhi float64, mandatory bool) {
 	a.LCTarget = lcTarget
 	a.LCPhi = lcPhi
+	a.LCMandatory = mandatory
 }
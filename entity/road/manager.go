@@ -1,16 +1,24 @@
 package road
 
 import (
+	"flag"
 	"fmt"
+	"math"
 
 	"git.fiblab.net/general/common/v2/parallel"
 	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
 	"git.fiblab.net/sim/protos/v2/go/city/map/v2/mapv2connect"
+	"git.fiblab.net/sim/routing/v2/router/algo"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 
 	"github.com/samber/lo"
 )
 
+var (
+	dynamicRouteCostInterval  = flag.Int("road.dynamic_route_cost_interval", 0, "将道路观测通行时间写入导航服务的间隔步数（<=0表示不更新，导航使用静态代价）")
+	dynamicRouteCostThreshold = flag.Float64("road.dynamic_route_cost_threshold", 0.1, "道路观测通行时间相对上次写入导航服务的值的变化比例超过该值时才重新写入（只有经过这些道路的导航缓存失效），0表示每次都写入")
+)
+
 // RoadManager Road管理器
 // 功能：管理所有Road实体，提供创建、查找、初始化、输出等功能
 type RoadManager struct {
@@ -20,6 +28,9 @@ type RoadManager struct {
 
 	data  map[int32]*Road
 	roads []*Road
+
+	routeCosts     map[int32]float64 // 当前时间片内已写入导航服务的道路代价
+	routeCostSlice int               // routeCosts所在的导航时间片
 }
 
 // NewManager 创建Road管理器实例
@@ -79,3 +90,38 @@ func (m *RoadManager) GetOrError(id int32) (entity.IRoad, error) {
 		return road, nil
 	}
 }
+
+// Update 更新阶段
+// 功能：启用动态导航代价时，每隔固定步数将各道路的观测通行时间写入导航服务当前时间片的道路代价，使导航反映拥堵情况
+// 说明：需在车道更新完成后调用；同一时间片内只写入观测通行时间相对上次写入的值变化超过road.dynamic_route_cost_threshold的道路，
+// 进入新的时间片时写入全部道路
+func (m *RoadManager) Update() {
+	interval := int32(*dynamicRouteCostInterval)
+	if interval <= 0 || m.ctx.Clock().InternalStep%interval != 0 {
+		return
+	}
+	t := m.ctx.Clock().T
+	if slice := algo.TimeToIndex(t); m.routeCosts == nil || slice != m.routeCostSlice {
+		m.routeCosts = make(map[int32]float64, len(m.roads))
+		m.routeCostSlice = slice
+	}
+	costs := make(map[int32]float64)
+	for _, r := range m.roads {
+		if len(r.drivingLanes) == 0 {
+			continue
+		}
+		cost := r.ObservedTravelTime()
+		if last, ok := m.routeCosts[r.id]; ok && math.Abs(cost-last) <= *dynamicRouteCostThreshold*last {
+			continue
+		}
+		costs[r.id] = cost
+		m.routeCosts[r.id] = cost
+	}
+	if len(costs) == 0 {
+		return
+	}
+	// 批量写入，只有经过这些道路的导航缓存失效
+	if err := m.ctx.Router().SetRoadCosts(costs, &t); err != nil {
+		log.Warnf("set road costs failed: %v", err)
+	}
+}
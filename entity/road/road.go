@@ -4,9 +4,14 @@ import (
 	"fmt"
 
 	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/samber/lo"
 	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
 )
 
+const (
+	minObservedV = 0.5 // 估计通行时间时的最低车速（米/秒），避免完全拥堵时通行时间无穷大
+)
+
 // Road 道路实体
 // 功能：表示地图中的道路，包含车道集合、路口连接、交通状态等信息
 type Road struct {
@@ -22,14 +27,16 @@ type Road struct {
 	drivingPredecessor entity.IJunction // 前驱路口
 	drivingSuccessor   entity.IJunction // 后继路口
 
-	originalMaxV float64 // 道路最大车速均值
+	originalMaxV    float64 // 行车道限速均值（地图中的设计值）
+	originalMaxMaxV float64 // 行车道限速最大值（地图中的设计值）
+	toll            float64 // 通行费（0表示不收费）
 }
 
 // newRoad 创建并初始化一个新的Road实例
 // 功能：根据基础数据创建Road对象，初始化车道、车速、类型分类等配置
 // 参数：ctx-任务上下文，base-基础Road数据，laneManager-车道管理器
 // 返回：初始化完成的Road实例
-// 说明：按车道类型分类存储，计算平均最大车速
+// 说明：按车道类型分类存储，计算行车道的平均限速
 func newRoad(ctx entity.ITaskContext, base *mapv2.Road, laneManager entity.ILaneManager) *Road {
 	r := &Road{
 		ctx:     ctx,
@@ -37,6 +44,8 @@ func newRoad(ctx entity.ITaskContext, base *mapv2.Road, laneManager entity.ILane
 		name:    base.Name,
 		laneIDs: base.LaneIds,
 		lanes:   make(map[int32]entity.ILane),
+		// 地图中暂无收费字段，从配置中按道路ID读取
+		toll: ctx.RuntimeConfig().C.RoadTolls[base.Id],
 	}
 
 	// 道路车速、长度
@@ -50,6 +59,7 @@ func newRoad(ctx entity.ITaskContext, base *mapv2.Road, laneManager entity.ILane
 		case mapv2.LaneType_LANE_TYPE_DRIVING:
 			r.drivingLanes = append(r.drivingLanes, lane)
 			r.originalMaxV += lane.MaxV()
+			r.originalMaxMaxV = max(r.originalMaxMaxV, lane.MaxV())
 			drivingLaneCount++
 		case mapv2.LaneType_LANE_TYPE_WALKING:
 			r.walkingLanes = append(r.walkingLanes, lane)
@@ -58,6 +68,9 @@ func newRoad(ctx entity.ITaskContext, base *mapv2.Road, laneManager entity.ILane
 			log.Panicf("Unknown lane type: %d", lane.Type())
 		}
 	}
+	if drivingLaneCount > 0 {
+		r.originalMaxV /= float64(drivingLaneCount)
+	}
 
 	return r
 }
@@ -129,6 +142,13 @@ func (r *Road) RightestDrivingLane() entity.ILane {
 	return r.drivingLanes[len(r.drivingLanes)-1]
 }
 
+// Toll 获取道路通行费
+// 功能：返回车辆通过该道路需要支付的费用
+// 返回：通行费，0表示不收费
+func (r *Road) Toll() float64 {
+	return r.toll
+}
+
 // DrivingPredecessor 获取前驱Junction
 // 功能：返回Road的前驱路口，即车辆进入Road的路口
 // 返回：前驱路口对象
@@ -180,13 +200,45 @@ func (r *Road) ProjectToNearestWalkingLane(drivingLane entity.ILane, s float64)
 	return walkingLane, walkingS
 }
 
-// MaxV 获取道路限速（车道限速的最大值）
-// 功能：返回道路的设计最大车速，基于所有行车道的平均限速
-// 返回：道路最大车速
+// MaxV 获取道路限速
+// 功能：与MeanMaxV相同，返回地图中所有行车道限速的平均值
+// 返回：道路限速，没有行车道时为0
+//
+// Deprecated: 含义不明确，使用MeanMaxV或MaxMaxV
 func (r *Road) MaxV() float64 {
+	return r.MeanMaxV()
+}
+
+// MeanMaxV 获取道路行车道限速的平均值
+// 功能：返回地图中所有行车道设计限速的平均值，不随运行时修改的车道限速变化
+// 返回：平均限速，没有行车道时为0
+func (r *Road) MeanMaxV() float64 {
 	return r.originalMaxV
 }
 
+// MaxMaxV 获取道路行车道限速的最大值
+// 功能：返回地图中所有行车道设计限速的最大值，不随运行时修改的车道限速变化
+// 返回：最大限速，没有行车道时为0
+func (r *Road) MaxMaxV() float64 {
+	return r.originalMaxMaxV
+}
+
+// MaxVAt 获取道路上指定位置的代表性限速
+// 功能：返回覆盖位置s的行车道（长度不小于s）当前限速的平均值，反映运行时对车道限速的修改
+// 参数：s-道路上的位置坐标（米）
+// 返回：代表性限速，没有行车道时为0
+// 说明：s超出所有行车道长度时使用全部行车道；与MeanMaxV的区别在于使用车道的当前限速而非地图中的设计值
+func (r *Road) MaxVAt(s float64) float64 {
+	lanes := lo.Filter(r.drivingLanes, func(l entity.ILane, _ int) bool { return l.Length() >= s })
+	if len(lanes) == 0 {
+		lanes = r.drivingLanes
+	}
+	if len(lanes) == 0 {
+		return 0
+	}
+	return lo.SumBy(lanes, func(l entity.ILane) float64 { return l.MaxV() }) / float64(len(lanes))
+}
+
 // GetAvgDrivingL 获取道路行车道平均长度
 // 功能：计算所有行车道的平均长度
 // 返回：行车道平均长度
@@ -198,6 +250,24 @@ func (r *Road) GetAvgDrivingL() float64 {
 	return sumL / float64(len(r.drivingLanes))
 }
 
+// ObservedTravelTime 获取道路的观测通行时间
+// 功能：根据各行车道平滑后的平均车速估计车辆通过道路所需的时间，用于动态导航代价
+// 返回：通行时间（秒），没有行车道时返回0
+// 算法说明：
+// 1. 累加各行车道的平滑车速并取平均作为道路车速（不低于minObservedV）
+// 2. 通行时间 = 行车道平均长度 / 道路车速
+func (r *Road) ObservedTravelTime() float64 {
+	if len(r.drivingLanes) == 0 {
+		return 0
+	}
+	sumV := .0
+	for _, l := range r.drivingLanes {
+		sumV += l.AvgV()
+	}
+	v := max(sumV/float64(len(r.drivingLanes)), minObservedV)
+	return r.GetAvgDrivingL() / v
+}
+
 // Name 获取Road的名称
 // 功能：返回Road的名称，用于显示和标识
 // 返回：Road的名称
@@ -0,0 +1,152 @@
+package road
+
+import (
+	"flag"
+	"testing"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"git.fiblab.net/sim/routing/v2/router/algo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/clock"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
+)
+
+// 测试用行车道，仅实现长度、限速与平滑车速
+type testLane struct {
+	entity.ILane
+	length, maxV, avgV float64
+}
+
+func (l *testLane) Length() float64                         { return l.length }
+func (l *testLane) MaxV() float64                           { return l.maxV }
+func (l *testLane) AvgV() float64                           { return l.avgV }
+func (l *testLane) Type() mapv2.LaneType                    { return mapv2.LaneType_LANE_TYPE_DRIVING }
+func (l *testLane) SetParentRoadWhenInit(entity.IRoad, int) {}
+
+// 测试用车道管理器，仅实现按ID获取车道
+type testLaneManager struct {
+	entity.ILaneManager
+	lanes map[int32]*testLane
+}
+
+func (m *testLaneManager) Get(id int32) entity.ILane { return m.lanes[id] }
+
+// 测试用上下文，仅实现时钟、运行时配置与导航服务
+type testContext struct {
+	entity.ITaskContext
+	clock         *clock.Clock
+	runtimeConfig *config.RuntimeConfig
+	router        *testRouter
+}
+
+func (ctx *testContext) Clock() *clock.Clock                  { return ctx.clock }
+func (ctx *testContext) RuntimeConfig() *config.RuntimeConfig { return ctx.runtimeConfig }
+func (ctx *testContext) Router() entity.IRouter               { return ctx.router }
+
+// 测试用导航服务，记录写入的道路代价
+type testRouter struct {
+	entity.IRouter
+	costs map[int32]float64
+	times []float64
+}
+
+func (r *testRouter) SetRoadCosts(costs map[int32]float64, t *float64) error {
+	for roadID, cost := range costs {
+		r.costs[roadID] = cost
+	}
+	r.times = append(r.times, *t)
+	return nil
+}
+
+func newTestRoad(id int32, avgV ...float64) *Road {
+	r := &Road{id: id}
+	for _, v := range avgV {
+		r.drivingLanes = append(r.drivingLanes, &testLane{length: 100, maxV: 10, avgV: v})
+	}
+	return r
+}
+
+func TestObservedTravelTime(t *testing.T) {
+	freeFlow := 100. / 10
+	assert.InDelta(t, freeFlow, newTestRoad(1, 10, 10).ObservedTravelTime(), 1e-9)
+
+	// 拥堵道路的观测通行时间高于自由流通行时间
+	congested := newTestRoad(1, 2, 4)
+	assert.InDelta(t, 100./3, congested.ObservedTravelTime(), 1e-9)
+	assert.Greater(t, congested.ObservedTravelTime(), freeFlow)
+
+	// 完全停滞时按最低车速计算，没有行车道时为0
+	assert.InDelta(t, 100/minObservedV, newTestRoad(1, 0).ObservedTravelTime(), 1e-9)
+	assert.Zero(t, newTestRoad(1).ObservedTravelTime())
+}
+
+func TestDynamicRouteCost(t *testing.T) {
+	defer flag.Set("road.dynamic_route_cost_interval", "0")
+	ctx := &testContext{
+		clock:  clock.New(config.ControlStep{Total: 100, Interval: 1}),
+		router: &testRouter{costs: make(map[int32]float64)},
+	}
+	m := NewManager(ctx)
+	m.roads = []*Road{newTestRoad(1, 10), newTestRoad(2, 2), newTestRoad(3)}
+
+	// 未启用时不修改导航代价
+	m.Update()
+	assert.Empty(t, ctx.router.costs)
+
+	flag.Set("road.dynamic_route_cost_interval", "10")
+	ctx.clock.InternalStep, ctx.clock.T = 15, 15
+	m.Update()
+	assert.Empty(t, ctx.router.costs)
+
+	ctx.clock.InternalStep, ctx.clock.T = 20, 20
+	m.Update()
+	assert.Equal(t, map[int32]float64{1: 10, 2: 50}, ctx.router.costs)
+	// 所有道路的代价通过一次批量调用写入
+	assert.Equal(t, []float64{20}, ctx.router.times)
+
+	// 同一时间片内只写入通行时间变化超过阈值的道路
+	m.roads[0].drivingLanes[0].(*testLane).avgV = 9.5
+	m.roads[1].drivingLanes[0].(*testLane).avgV = 4
+	clear(ctx.router.costs)
+	ctx.clock.InternalStep, ctx.clock.T = 30, 30
+	m.Update()
+	assert.Equal(t, map[int32]float64{2: 25}, ctx.router.costs)
+
+	// 没有道路变化时不写入
+	ctx.clock.InternalStep, ctx.clock.T = 40, 40
+	m.Update()
+	assert.Equal(t, []float64{20, 30}, ctx.router.times)
+
+	// 进入新的时间片时写入全部道路
+	clear(ctx.router.costs)
+	next := float64(algo.TIME_SLICE_INTERVAl)
+	ctx.clock.InternalStep, ctx.clock.T = int32(next), next
+	m.Update()
+	assert.Len(t, ctx.router.costs, 2)
+}
+
+func TestRoadMaxV(t *testing.T) {
+	// 双车道道路，限速分别为10与20，其中第二条车道只有60米
+	lm := &testLaneManager{lanes: map[int32]*testLane{
+		1: {length: 100, maxV: 10},
+		2: {length: 60, maxV: 20},
+	}}
+	ctx := &testContext{runtimeConfig: config.NewRuntimeConfig(config.Config{})}
+	r := newRoad(ctx, &mapv2.Road{Id: 1, LaneIds: []int32{1, 2}}, lm)
+
+	// 道路限速为车道限速的平均值，而非总和
+	assert.Equal(t, 15., r.MaxV())
+	assert.Equal(t, 15., r.MeanMaxV())
+	assert.Equal(t, 20., r.MaxMaxV())
+	assert.Equal(t, 15., r.MaxVAt(30))
+	// 第二条车道不覆盖的位置只考虑第一条车道
+	assert.Equal(t, 10., r.MaxVAt(80))
+	assert.Equal(t, 15., r.MaxVAt(200))
+
+	// 运行时修改车道限速后，设计限速不变，指定位置的限速随之变化
+	lm.lanes[2].maxV = 30
+	assert.Equal(t, 15., r.MaxV())
+	assert.Equal(t, 20., r.MaxVAt(30))
+	assert.Zero(t, newTestRoad(2).MaxVAt(0))
+}
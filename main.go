@@ -107,6 +107,11 @@ func main() {
 		case "economy":
 			// 创建经济模拟器实例
 			economySimulator := ecosim.NewServer()
+			// 经济系统内置动态随仿真步推进
+			if c.Control.EconomyTick.Enable {
+				economySimulator.SetTickConfig(c.Control.EconomyTick)
+				t.RegisterTicker(economySimulator)
+			}
 
 			// 注册经济模拟器服务
 			sidecar.Register(
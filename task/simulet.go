@@ -11,6 +11,7 @@ const (
 
 var (
 	heartBeatInterval = flag.Int("log.heartbeat_interval", 100, "心跳日志间隔步数")
+	stopWhenIdle      = flag.Bool("sim.stop_when_idle", false, "是否在所有人都处于睡眠状态且时刻表为空时提前结束仿真")
 )
 
 // prepare 准备阶段，每步执行一次
@@ -42,6 +43,9 @@ func (ctx *Context) prepare() {
 		)
 	}
 
+	// 运行时添加的AOI会修改车道的AOI列表，先于其他准备操作串行执行
+	ctx.aoiManager.PrepareNode()
+
 	// Prepare
 	var wg sync.WaitGroup
 
@@ -126,6 +130,22 @@ func (ctx *Context) update() {
 		}()
 	}
 	wg.Wait()
+	// 道路通行时间依赖本步更新后的车道车速
+	ctx.roadManager.Update() // road
+	// 扩展模块（如经济系统）
+	for _, t := range ctx.tickers {
+		t.Tick(ctx.clock.T)
+	}
+}
+
+// idle 检查是否可以提前结束仿真
+// 功能：启用sim.stop_when_idle时，所有人都已结束出行（睡眠状态且时刻表为空）则返回true
+func (ctx *Context) idle() bool {
+	if !*stopWhenIdle || ctx.personManager.ActivePersons() > 0 {
+		return false
+	}
+	log.Infof("all persons are idle at step %d, stop early", ctx.clock.InternalStep)
+	return true
 }
 
 // Run 运行
@@ -142,16 +162,36 @@ func (ctx *Context) Run() {
 		log.Debugf("step %d: NotifyStepReady complete", ctx.clock.InternalStep)
 		ctx.update()
 		log.Debugf("step %d: update complete", ctx.clock.InternalStep)
+		ctx.clock.WaitRealtime()
 		close := false
-		if ctx.clock.InternalStep+1 >= ctx.clock.END_STEP {
+		idle := ctx.idle()
+		if ctx.clock.InternalStep+1 >= ctx.clock.END_STEP || idle {
 			close = ctx.sidecar.Step(true)
 		} else {
 			close = ctx.sidecar.Step(false)
 		}
-		if close || ctx.closed.Load() {
+		if close || idle || ctx.closed.Load() {
+			break
+		}
+	}
+	log.Infof("engine complete")
+	ctx.personManager.Close()
+	ctx.Close()
+}
+
+// RunStandalone 不经过syncer运行独立上下文
+// 功能：初始化后逐步执行准备与更新阶段，直到END_STEP或所有人都已结束出行（sim.stop_when_idle）
+// 说明：用于NewStandaloneContext创建的上下文
+func (ctx *Context) RunStandalone() {
+	ctx.Init()
+	for ctx.clock.InternalStep+1 < ctx.clock.END_STEP {
+		ctx.prepare()
+		ctx.update()
+		if ctx.idle() {
 			break
 		}
 	}
 	log.Infof("engine complete")
+	ctx.personManager.Close()
 	ctx.Close()
 }
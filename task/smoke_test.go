@@ -0,0 +1,431 @@
+package task
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"math"
+	"slices"
+	"testing"
+
+	"connectrpc.com/connect"
+	geov2 "git.fiblab.net/sim/protos/v2/go/city/geo/v2"
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	personv2 "git.fiblab.net/sim/protos/v2/go/city/person/v2"
+	tripv2 "git.fiblab.net/sim/protos/v2/go/city/trip/v2"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/aoi"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/entity/person"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/config"
+	"github.com/tsinghua-fib-lab/agentsociety-sim-oss/utils/input"
+)
+
+// newSmokeLane 创建沿x轴、长200米的车道
+func newSmokeLane(id int32, typ mapv2.LaneType, y float64) *mapv2.Lane {
+	return &mapv2.Lane{
+		Id:       id,
+		Type:     typ,
+		Turn:     mapv2.LaneTurn_LANE_TURN_STRAIGHT,
+		MaxSpeed: 10,
+		Width:    3.2,
+		CenterLine: &geov2.Polyline{Nodes: []*geov2.XYPosition{
+			{X: 0, Y: y},
+			{X: 200, Y: y},
+		}},
+		ParentId: 1,
+	}
+}
+
+// newSmokeAoi 创建以(s, 10)为中心、同时连接行车道与步行道s位置的方形AOI
+func newSmokeAoi(id int32, s float64) *mapv2.Aoi {
+	return &mapv2.Aoi{
+		Id: id,
+		Positions: []*geov2.XYPosition{
+			{X: s - 5, Y: 5}, {X: s + 5, Y: 5}, {X: s + 5, Y: 15}, {X: s - 5, Y: 15}, {X: s - 5, Y: 5},
+		},
+		DrivingPositions: []*geov2.LanePosition{{LaneId: 1, S: s}},
+		WalkingPositions: []*geov2.LanePosition{{LaneId: 2, S: s}},
+	}
+}
+
+// newSmokePerson 创建住在AOI 10、出行一次的人
+func newSmokePerson(id int32, mode tripv2.TripMode, dest int32) *personv2.Person {
+	return &personv2.Person{
+		Id: id,
+		VehicleAttribute: &personv2.VehicleAttribute{
+			Length: 5, Width: 2, MaxSpeed: 30,
+			MaxAcceleration: 3, UsualAcceleration: 2,
+			MaxBrakingAcceleration: -10, UsualBrakingAcceleration: -4.5,
+			MinGap: 1, Headway: 1.5,
+		},
+		Home: &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: 10}},
+		Schedules: []*tripv2.Schedule{{
+			LoopCount: 1,
+			Trips: []*tripv2.Trip{{
+				Mode: mode,
+				End:  &geov2.Position{AoiPosition: &geov2.AoiPosition{AoiId: dest}},
+			}},
+		}},
+	}
+}
+
+// newSmokeInput 冒烟测试用的微型地图与人员
+// 一条道路包含一条行车道与一条平行的步行道，道路上有3个AOI；
+// 一人从AOI 10驾车前往AOI 20，一人从AOI 10步行前往AOI 11
+func newSmokeInput() *input.Input {
+	return &input.Input{
+		Map: &mapv2.Map{
+			Header: &mapv2.Header{},
+			Lanes: []*mapv2.Lane{
+				newSmokeLane(1, mapv2.LaneType_LANE_TYPE_DRIVING, 0),
+				newSmokeLane(2, mapv2.LaneType_LANE_TYPE_WALKING, -3),
+			},
+			Roads: []*mapv2.Road{{Id: 1, LaneIds: []int32{1, 2}}},
+			Aois:  []*mapv2.Aoi{newSmokeAoi(10, 20), newSmokeAoi(11, 60), newSmokeAoi(20, 180)},
+		},
+		Persons: &personv2.Persons{Persons: []*personv2.Person{
+			newSmokePerson(1, tripv2.TripMode_TRIP_MODE_DRIVE_ONLY, 20),
+			newSmokePerson(2, tripv2.TripMode_TRIP_MODE_WALK_ONLY, 11),
+		}},
+	}
+}
+
+func TestStandaloneSmoke(t *testing.T) {
+	c := config.Config{}
+	c.Control.Step = config.ControlStep{Start: 0, Total: 100, Interval: 1}
+	ctx := NewStandaloneContext(c, newSmokeInput())
+	defer ctx.Close()
+
+	assert.NotPanics(t, func() {
+		ctx.Init()
+		for ctx.clock.InternalStep+1 < ctx.clock.END_STEP {
+			ctx.prepare()
+			ctx.update()
+		}
+	})
+	res, err := ctx.personManager.(*person.PersonManager).GetGlobalStatistics(
+		context.Background(), connect.NewRequest(&personv2.GetGlobalStatisticsRequest{}),
+	)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, res.Msg.NumCompletedTrips, int32(1))
+}
+
+func TestStopWhenIdle(t *testing.T) {
+	defer flag.Set("sim.stop_when_idle", "false")
+	flag.Set("sim.stop_when_idle", "true")
+	c := config.Config{}
+	c.Control.Step = config.ControlStep{Start: 0, Total: 1000, Interval: 1}
+	ctx := NewStandaloneContext(c, newSmokeInput())
+
+	assert.NotPanics(t, ctx.RunStandalone)
+	// 两人各出行一次，结束后所有人都处于睡眠状态且时刻表为空，远早于END_STEP结束
+	assert.Less(t, ctx.clock.InternalStep, int32(200))
+	assert.Zero(t, ctx.personManager.ActivePersons())
+}
+
+func TestAoiEvents(t *testing.T) {
+	defer flag.Set("aoi.max_events", "0")
+	flag.Set("aoi.max_events", "100")
+	c := config.Config{}
+	c.Control.Step = config.ControlStep{Start: 0, Total: 100, Interval: 1}
+	ctx := NewStandaloneContext(c, newSmokeInput())
+	defer ctx.Close()
+
+	ctx.Init()
+	var events []aoi.AoiEvent
+	for ctx.clock.InternalStep+1 < ctx.clock.END_STEP {
+		ctx.prepare()
+		ctx.update()
+		events = append(events, ctx.aoiManager.(*aoi.AoiManager).GetAoiEvents()...)
+	}
+	// 驾车的人离开家（AOI 10）并在到达后进入AOI 20各一次
+	count := func(aoiID int32, typ aoi.AoiEventType) int {
+		return lo.CountBy(events, func(e aoi.AoiEvent) bool {
+			return e.PersonID == 1 && e.AoiID == aoiID && e.Type == typ
+		})
+	}
+	assert.Equal(t, 1, count(20, aoi.AoiEventEnter))
+	assert.Equal(t, 1, count(10, aoi.AoiEventLeave))
+	assert.Zero(t, count(20, aoi.AoiEventLeave))
+	assert.True(t, slices.IsSortedFunc(events, func(x, y aoi.AoiEvent) int { return cmp.Compare(x.T, y.T) }))
+}
+
+// testTicker 记录每次推进时的仿真时间
+type testTicker struct {
+	ts []float64
+}
+
+func (t *testTicker) Tick(now float64) { t.ts = append(t.ts, now) }
+
+func TestRegisterTicker(t *testing.T) {
+	c := config.Config{}
+	c.Control.Step = config.ControlStep{Start: 0, Total: 5, Interval: 1}
+	ctx := NewStandaloneContext(c, newSmokeInput())
+	ticker := &testTicker{}
+	ctx.RegisterTicker(ticker)
+	ctx.RunStandalone()
+	// 每步更新阶段之后推进一次
+	assert.Equal(t, []float64{1, 2, 3, 4}, ticker.ts)
+}
+
+// 十字路口测试地图的路口ID
+const crossJunctionID = 3_0000_0000
+
+// crossDirs 十字路口四个进口的行驶方向：东行、西行、北行、南行
+var crossDirs = [4][2]float64{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+
+// crossPoint 方向d上沿行驶方向坐标t处的点，车辆靠右行驶，与路口中心线偏移1.6米
+func crossPoint(d int, t float64) *geov2.XYPosition {
+	dx, dy := crossDirs[d][0], crossDirs[d][1]
+	return &geov2.XYPosition{X: t*dx + 1.6*dy, Y: t*dy - 1.6*dx}
+}
+
+// newCrossInput 创建只有直行车道的十字路口地图与轻交通需求
+// 方向d的进口道ID为d+1（长200米），路口内车道ID为d+9（长20米，与两条垂直方向的车道交叉），出口道ID为d+5（长200米），
+// 每条车道构成一条道路；每个进口各有3辆车，从进口道上的AOI 10+d驾车前往出口道上的AOI 20+d，
+// 按8秒间隔出发，不同进口错开2秒
+func newCrossInput(fixed bool) *input.Input {
+	m := &mapv2.Map{Header: &mapv2.Header{}}
+	junction := &mapv2.Junction{Id: crossJunctionID}
+	persons := make([]*personv2.Person, 0)
+	for d := range crossDirs {
+		in, out, inner := int32(d+1), int32(d+5), int32(d+9)
+		newLane := func(id, parent int32, from, to float64) *mapv2.Lane {
+			return &mapv2.Lane{
+				Id:         id,
+				Type:       mapv2.LaneType_LANE_TYPE_DRIVING,
+				Turn:       mapv2.LaneTurn_LANE_TURN_STRAIGHT,
+				MaxSpeed:   10,
+				Width:      3.2,
+				CenterLine: &geov2.Polyline{Nodes: []*geov2.XYPosition{crossPoint(d, from), crossPoint(d, to)}},
+				ParentId:   parent,
+			}
+		}
+		head := func(id int32) []*mapv2.LaneConnection {
+			return []*mapv2.LaneConnection{{Id: id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD}}
+		}
+		tail := func(id int32) []*mapv2.LaneConnection {
+			return []*mapv2.LaneConnection{{Id: id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL}}
+		}
+		inLane, innerLane, outLane := newLane(in, in, -210, -10), newLane(inner, crossJunctionID, -10, 10), newLane(out, out, 10, 210)
+		inLane.Successors = head(inner)
+		innerLane.Predecessors, innerLane.Successors = tail(in), head(out)
+		outLane.Predecessors = tail(inner)
+		// 与垂直方向e的路口内车道交叉于两条车道偏移之和处
+		for e := range crossDirs {
+			if crossDirs[e][0]*crossDirs[d][0]+crossDirs[e][1]*crossDirs[d][1] != 0 {
+				continue
+			}
+			p := crossPoint(e, 0)
+			selfS := p.X*crossDirs[d][0] + p.Y*crossDirs[d][1] + 10
+			q := crossPoint(d, 0)
+			otherS := q.X*crossDirs[e][0] + q.Y*crossDirs[e][1] + 10
+			innerLane.Overlaps = append(innerLane.Overlaps, &mapv2.LaneOverlap{
+				Self:      &geov2.LanePosition{LaneId: inner, S: selfS},
+				Other:     &geov2.LanePosition{LaneId: int32(e + 9), S: otherS},
+				SelfFirst: d < e,
+			})
+		}
+		m.Lanes = append(m.Lanes, inLane, innerLane, outLane)
+		m.Roads = append(m.Roads, &mapv2.Road{Id: in, LaneIds: []int32{in}}, &mapv2.Road{Id: out, LaneIds: []int32{out}})
+		junction.LaneIds = append(junction.LaneIds, inner)
+		junction.DrivingLaneGroups = append(junction.DrivingLaneGroups, &mapv2.JunctionLaneGroup{
+			InRoadId: in, OutRoadId: out, LaneIds: []int32{inner},
+		})
+
+		// 进口道S=20处与出口道S=180处的AOI
+		newAoi := func(id, laneID int32, s, t float64) *mapv2.Aoi {
+			c := crossPoint(d, t)
+			rx, ry := 5*crossDirs[d][1], -5*crossDirs[d][0]
+			return &mapv2.Aoi{
+				Id: id,
+				Positions: []*geov2.XYPosition{
+					{X: c.X + rx - 2, Y: c.Y + ry - 2}, {X: c.X + rx + 2, Y: c.Y + ry - 2},
+					{X: c.X + rx + 2, Y: c.Y + ry + 2}, {X: c.X + rx - 2, Y: c.Y + ry + 2},
+					{X: c.X + rx - 2, Y: c.Y + ry - 2},
+				},
+				DrivingPositions: []*geov2.LanePosition{{LaneId: laneID, S: s}},
+			}
+		}
+		m.Aois = append(m.Aois, newAoi(int32(10+d), in, 20, -190), newAoi(int32(20+d), out, 180, 190))
+		for k := 0; k < 3; k++ {
+			p := newSmokePerson(int32(d*3+k+1), tripv2.TripMode_TRIP_MODE_DRIVE_ONLY, int32(20+d))
+			p.Home.AoiPosition.AoiId = int32(10 + d)
+			departure := float64(8*k + 2*d)
+			p.Schedules[0].DepartureTime = &departure
+			persons = append(persons, p)
+		}
+	}
+	if fixed {
+		// 东西向与南北向各30秒绿灯的两相位固定信控
+		g, r := mapv2.LightState_LIGHT_STATE_GREEN, mapv2.LightState_LIGHT_STATE_RED
+		junction.FixedProgram = &mapv2.TrafficLight{
+			JunctionId: crossJunctionID,
+			Phases: []*mapv2.Phase{
+				{Duration: 30, States: []mapv2.LightState{g, g, r, r}},
+				{Duration: 30, States: []mapv2.LightState{r, r, g, g}},
+			},
+		}
+	}
+	m.Junctions = []*mapv2.Junction{junction}
+	return &input.Input{Map: m, Persons: &personv2.Persons{Persons: persons}}
+}
+
+// runCross 运行十字路口场景
+// 返回：每步结束时已完成的行程数、全部行程的总通行时间、路口内冲突点被两辆车同时占用的次数
+func runCross(t *testing.T, reservation bool) (completed []int32, travelTime float64, collisions int) {
+	c := config.Config{}
+	c.Control.Step = config.ControlStep{Start: 0, Total: 300, Interval: 1}
+	if reservation {
+		c.Control.ReservationJunctions = []int32{crossJunctionID}
+	} else {
+		c.Control.PreferFixedLight = true
+	}
+	ctx := NewStandaloneContext(c, newCrossInput(!reservation))
+	defer ctx.Close()
+
+	ctx.Init()
+	// occupied 车道上s处（前后各留半个车道宽度）是否有车辆
+	occupied := func(l entity.ILane, s float64) bool {
+		for node := l.FirstVehicle(); node != nil; node = node.Next() {
+			if node.S+1.6 >= s && node.S-node.L()-1.6 <= s {
+				return true
+			}
+		}
+		return false
+	}
+	m := ctx.personManager.(*person.PersonManager)
+	for ctx.clock.InternalStep+1 < ctx.clock.END_STEP {
+		ctx.prepare()
+		for id := int32(9); id <= 12; id++ {
+			l := ctx.laneManager.Get(id)
+			for s, o := range l.Overlaps() {
+				if l.ID() < o.Other.ID() && occupied(l, s) && occupied(o.Other, o.OtherS) {
+					collisions++
+				}
+			}
+		}
+		ctx.update()
+		res, err := m.GetGlobalStatistics(context.Background(), connect.NewRequest(&personv2.GetGlobalStatisticsRequest{}))
+		assert.NoError(t, err)
+		completed = append(completed, res.Msg.NumCompletedTrips)
+		travelTime = res.Msg.RunningTotalTravelTime
+	}
+	return
+}
+
+func TestReservationJunction(t *testing.T) {
+	resCompleted, resTime, resCollisions := runCross(t, true)
+	fixedCompleted, fixedTime, _ := runCross(t, false)
+
+	// 预约控制下冲突车道的车辆不会同时占用冲突点
+	assert.Zero(t, resCollisions)
+	// 轻交通需求下所有行程完成，且预约控制的车辆无需等待红灯，完成行程的速度更快
+	assert.Equal(t, int32(12), resCompleted[len(resCompleted)-1])
+	assert.Equal(t, int32(12), fixedCompleted[len(fixedCompleted)-1])
+	for i := range resCompleted {
+		assert.GreaterOrEqual(t, resCompleted[i], fixedCompleted[i], "step %d", i)
+	}
+	assert.True(t, slices.ContainsFunc(lo.Range(len(resCompleted)), func(i int) bool {
+		return resCompleted[i] > fixedCompleted[i]
+	}))
+	assert.Less(t, resTime, fixedTime)
+}
+
+// newTollInput 创建包含收费捷径的地图
+// 1 -> J1 -> 2（收费捷径，200米）-> J2 -> 4
+// 1 -> J1 -> 3（绕行，约241米）-> J2（约100米的路口车道）-> 4
+// 限速均为10米/秒；两人从道路1上的AOI 10驾车前往道路4上的AOI 20，其中2号带有avoid_tolls标签
+func newTollInput() *input.Input {
+	newLane := func(id, parent int32, length float64, nodes ...*geov2.XYPosition) *mapv2.Lane {
+		return &mapv2.Lane{
+			Id:         id,
+			Type:       mapv2.LaneType_LANE_TYPE_DRIVING,
+			Turn:       mapv2.LaneTurn_LANE_TURN_STRAIGHT,
+			Length:     length,
+			MaxSpeed:   10,
+			Width:      3.2,
+			CenterLine: &geov2.Polyline{Nodes: nodes},
+			ParentId:   parent,
+		}
+	}
+	connect := func(pre, lane, suc *mapv2.Lane) {
+		pre.Successors = append(pre.Successors, &mapv2.LaneConnection{Id: lane.Id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD})
+		lane.Predecessors = []*mapv2.LaneConnection{{Id: pre.Id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL}}
+		lane.Successors = []*mapv2.LaneConnection{{Id: suc.Id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD}}
+		suc.Predecessors = append(suc.Predecessors, &mapv2.LaneConnection{Id: lane.Id, Type: mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL})
+	}
+	newAoi := func(id, laneID int32, s, x float64) *mapv2.Aoi {
+		return &mapv2.Aoi{
+			Id: id,
+			Positions: []*geov2.XYPosition{
+				{X: x - 5, Y: 5}, {X: x + 5, Y: 5}, {X: x + 5, Y: 15}, {X: x - 5, Y: 15}, {X: x - 5, Y: 5},
+			},
+			DrivingPositions: []*geov2.LanePosition{{LaneId: laneID, S: s}},
+		}
+	}
+	j1, j2 := int32(crossJunctionID+1), int32(crossJunctionID+2)
+	l1 := newLane(1, 1, 200, &geov2.XYPosition{X: 0, Y: 0}, &geov2.XYPosition{X: 200, Y: 0})
+	l2 := newLane(2, 2, 200, &geov2.XYPosition{X: 210, Y: 0}, &geov2.XYPosition{X: 410, Y: 0})
+	l3 := newLane(3, 3, 100*math.Sqrt2+100, &geov2.XYPosition{X: 210, Y: 0}, &geov2.XYPosition{X: 310, Y: 100}, &geov2.XYPosition{X: 410, Y: 100})
+	l4 := newLane(4, 4, 200, &geov2.XYPosition{X: 420, Y: 0}, &geov2.XYPosition{X: 620, Y: 0})
+	l5 := newLane(5, j1, 10, &geov2.XYPosition{X: 200, Y: 0}, &geov2.XYPosition{X: 210, Y: 0})
+	l6 := newLane(6, j1, 10, &geov2.XYPosition{X: 200, Y: 0}, &geov2.XYPosition{X: 210, Y: 0})
+	l7 := newLane(7, j2, 10, &geov2.XYPosition{X: 410, Y: 0}, &geov2.XYPosition{X: 420, Y: 0})
+	l8 := newLane(8, j2, math.Hypot(10, 100), &geov2.XYPosition{X: 410, Y: 100}, &geov2.XYPosition{X: 420, Y: 0})
+	connect(l1, l5, l2)
+	connect(l1, l6, l3)
+	connect(l2, l7, l4)
+	connect(l3, l8, l4)
+	m := &mapv2.Map{
+		Header: &mapv2.Header{},
+		Lanes:  []*mapv2.Lane{l1, l2, l3, l4, l5, l6, l7, l8},
+		Roads: lo.Map([]int32{1, 2, 3, 4}, func(id int32, _ int) *mapv2.Road {
+			return &mapv2.Road{Id: id, LaneIds: []int32{id}}
+		}),
+		Junctions: []*mapv2.Junction{
+			{Id: j1, LaneIds: []int32{5, 6}, DrivingLaneGroups: []*mapv2.JunctionLaneGroup{
+				{InRoadId: 1, OutRoadId: 2, LaneIds: []int32{5}},
+				{InRoadId: 1, OutRoadId: 3, LaneIds: []int32{6}},
+			}},
+			{Id: j2, LaneIds: []int32{7, 8}, DrivingLaneGroups: []*mapv2.JunctionLaneGroup{
+				{InRoadId: 2, OutRoadId: 4, LaneIds: []int32{7}},
+				{InRoadId: 3, OutRoadId: 4, LaneIds: []int32{8}},
+			}},
+		},
+		Aois: []*mapv2.Aoi{newAoi(10, 1, 20, 20), newAoi(20, 4, 180, 600)},
+	}
+	avoider := newSmokePerson(2, tripv2.TripMode_TRIP_MODE_DRIVE_ONLY, 20)
+	avoider.Labels = map[string]string{"avoid_tolls": "true"}
+	return &input.Input{Map: m, Persons: &personv2.Persons{Persons: []*personv2.Person{
+		newSmokePerson(1, tripv2.TripMode_TRIP_MODE_DRIVE_ONLY, 20),
+		avoider,
+	}}}
+}
+
+func TestAvoidTollsLabel(t *testing.T) {
+	c := config.Config{}
+	c.Control.Step = config.ControlStep{Start: 0, Total: 100, Interval: 1}
+	// 捷径收费折算的时间代价小于绕行多花的时间
+	c.Control.RoadTolls = map[int32]float64{2: 0.1}
+	ctx := NewStandaloneContext(c, newTollInput())
+	defer ctx.Close()
+
+	ctx.Init()
+	m := ctx.personManager.(*person.PersonManager)
+	routes := make(map[int32][]int32)
+	for ctx.clock.InternalStep+1 < ctx.clock.END_STEP && len(routes) < 2 {
+		ctx.prepare()
+		ctx.update()
+		for _, id := range []int32{1, 2} {
+			if j, err := m.GetPersonRoute(id); assert.NoError(t, err) && j.GetDriving() != nil && routes[id] == nil {
+				routes[id] = j.GetDriving().RoadIds
+			}
+		}
+	}
+	// 默认走收费捷径，带有avoid_tolls标签的人绕行
+	assert.Equal(t, []int32{1, 2, 4}, routes[1])
+	assert.Equal(t, []int32{1, 3, 4}, routes[2])
+}
@@ -45,6 +45,11 @@ func waitForServerReady(addr string, retryCount int, interval time.Duration) err
 	return fmt.Errorf("server `%v` did not become ready after %d retries", addr, retryCount)
 }
 
+// Ticker 每步更新阶段之后推进的扩展模块（如经济系统）
+type Ticker interface {
+	Tick(t float64) // t-当前仿真时间（秒）
+}
+
 // Context 仿真任务上下文
 // 功能：包含一次仿真任务的所有变量和状态，替代原来的全局变量
 // 说明：管理仿真系统的所有组件，包括时钟、管理器、配置、输出等
@@ -85,6 +90,9 @@ type Context struct {
 
 	// 用于初始化的输入
 	initRes *input.Input
+
+	// 每步更新阶段之后推进的扩展模块
+	tickers []Ticker
 }
 
 // NewContext 创建新的仿真任务上下文
@@ -136,11 +144,7 @@ func NewContext(
 	ctx.runtimeConfig = config.NewRuntimeConfig(c)
 
 	// 新建各类模拟对象
-	ctx.laneManager = lane.NewManager(ctx)
-	ctx.aoiManager = aoi.NewManager(ctx)
-	ctx.roadManager = road.NewManager(ctx)
-	ctx.junctionManager = junction.NewManager(ctx)
-	ctx.personManager = person.NewManager(ctx)
+	ctx.newManagers()
 
 	ctx.clock.Register(ctx.sidecar)
 	ctx.junctionManager.Register(ctx.sidecar)
@@ -160,6 +164,37 @@ func NewContext(
 	return ctx
 }
 
+// NewStandaloneContext 创建不依赖syncer的独立仿真任务上下文
+// 功能：直接使用给定的输入数据创建上下文，不下载数据、不注册RPC服务
+// 参数：c-配置对象，initRes-已加载的地图与人员数据
+// 返回：未初始化的Context实例，通过RunStandalone运行，或调用Init后通过prepare/update逐步推进
+// 说明：用于测试与离线运行，Run依赖sidecar，不能在独立上下文上调用
+func NewStandaloneContext(c config.Config, initRes *input.Input) *Context {
+	ctx := &Context{
+		job:            "standalone",
+		sidecarCloseCh: make(chan struct{}),
+		initRes:        initRes,
+	}
+	ctx.clock = clock.New(c.Control.Step)
+	ctx.runtimeConfig = config.NewRuntimeConfig(c)
+	ctx.newManagers()
+	return ctx
+}
+
+// newManagers 新建各类模拟对象的管理器
+func (ctx *Context) newManagers() {
+	ctx.laneManager = lane.NewManager(ctx)
+	ctx.aoiManager = aoi.NewManager(ctx)
+	ctx.roadManager = road.NewManager(ctx)
+	ctx.junctionManager = junction.NewManager(ctx)
+	ctx.personManager = person.NewManager(ctx)
+}
+
+// RegisterTicker 注册每步更新阶段之后推进的扩展模块，需在运行前调用
+func (ctx *Context) RegisterTicker(t Ticker) {
+	ctx.tickers = append(ctx.tickers, t)
+}
+
 func (ctx *Context) GetInput() *input.Input {
 	return ctx.initRes
 }
@@ -220,6 +255,12 @@ func (ctx *Context) Init() {
 	ctx.junctionManager.Init(mapData.Junctions, ctx.laneManager, ctx.roadManager)
 	// road初始化其中的前驱后继路口
 	ctx.roadManager.InitAfterJunction(ctx.junctionManager)
+	// 使用信控程序文件覆盖地图中的信控
+	if len(initRes.TrafficLights) > 0 {
+		if err := ctx.junctionManager.SetTrafficLightPrograms(initRes.TrafficLights); err != nil {
+			log.Panicf("failed to set traffic light programs: %v", err)
+		}
+	}
 
 	// 完成地图构建后，开始构建person
 	ctx.personManager.Init(
@@ -228,13 +269,18 @@ func (ctx *Context) Init() {
 		ctx.aoiManager, ctx.laneManager,
 	)
 	// router
-	ctx.router = route.New(initRes)
+	ctx.router = route.New(initRes, ctx.runtimeConfig.C.RoadTolls)
 }
 
 func (ctx *Context) Close() {
 	if ctx.closed.Load() {
 		return
 	}
+	if ctx.sidecar == nil {
+		// 独立上下文没有需要关闭的服务
+		ctx.closed.Store(true)
+		return
+	}
 	ctx.sidecar.Close()
 	// wait for graceful stop
 	<-ctx.sidecarCloseCh
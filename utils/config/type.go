@@ -47,6 +47,8 @@ type Input struct {
 	URI    string     `yaml:"uri"`              // MongoDB连接字符串
 	Map    InputPath  `yaml:"map"`              // 地图
 	Person *InputPath `yaml:"person,omitempty"` // 人员
+	// 信控程序文件（JSON，路口ID->mapv2.TrafficLight），加载后覆盖对应路口在地图中的固定信控程序
+	TrafficLight string `yaml:"traffic_light,omitempty"`
 }
 
 // ControlStep 指定模拟器模拟时间范围和间隔的配置项
@@ -58,12 +60,76 @@ type ControlStep struct {
 	Interval float64 `yaml:"interval"` // 每步的时间间隔
 }
 
+// DwellTime 活动停留时间分布的配置项
+// 功能：定义某类活动停留时间的对数正态分布参数
+// 说明：以均值和标准差描述，单位为秒
+type DwellTime struct {
+	Mean float64 `yaml:"mean"` // 均值
+	Std  float64 `yaml:"std"`  // 标准差
+}
+
+// ClearanceTime 路口过渡相位时长的配置项
+// 功能：覆盖指定路口最大压力信控的黄灯、全红与行人清空时间
+// 说明：未填写的字段使用对应的全局flag，单位为秒
+type ClearanceTime struct {
+	Yellow          *float64 `yaml:"yellow,omitempty"`           // 黄灯时间
+	AllRed          *float64 `yaml:"all_red,omitempty"`          // 全红时间
+	PedestrianClear *float64 `yaml:"pedestrian_clear,omitempty"` // 行人清空时间
+}
+
+// IDMProfile 驾驶员画像的IDM跟驰参数
+// 功能：按驾驶员画像覆盖全局的IDM参数，未设置的字段使用全局flag（person.idm_*）
+type IDMProfile struct {
+	Delta            *float64 `yaml:"delta,omitempty"`             // 加速度指数
+	ComfortableDecel *float64 `yaml:"comfortable_decel,omitempty"` // 舒适减速度（米/秒²，正数）
+	TimeGap          *float64 `yaml:"time_gap,omitempty"`          // 期望车头时距（秒）
+}
+
+// AttractionPoint AOI吸引力曲线的控制点
+// 功能：定义一天中某一时刻AOI作为出行目的地的相对权重
+// 说明：控制点之间线性插值，曲线按天循环
+type AttractionPoint struct {
+	T      float64 `yaml:"t"`      // 一天中的时刻（秒）
+	Weight float64 `yaml:"weight"` // 权重
+}
+
+// EconomyTick 经济系统内置动态的配置项
+// 功能：启用后经济系统在每个仿真步的更新阶段之后推进一次（见ecosim.EconomySim.Tick）
+// 说明：只在启用economy扩展时生效
+type EconomyTick struct {
+	Enable           bool    `yaml:"enable"`                      // 是否启用
+	PriceAdjustment  float64 `yaml:"price_adjustment,omitempty"`  // 企业价格调整系数，每步价格按超额需求比例乘以该系数调整，未设置时为0.05
+	InterestInterval int32   `yaml:"interest_interval,omitempty"` // 银行计息间隔（步数），0表示不计息
+	RecordInterval   int32   `yaml:"record_interval,omitempty"`   // 统计局记录间隔（步数），未设置时每步记录
+	RecordWindow     int32   `yaml:"record_window,omitempty"`     // 统计局保留的最近记录数，超出时删除最早的记录，未设置时为1000
+}
+
 // Control 模拟器控制配置
 // 功能：定义仿真系统的核心控制参数
 // 说明：包含时间控制、区域范围、功能开关等核心配置
 type Control struct {
 	Step             ControlStep `yaml:"step"`
 	PreferFixedLight bool        `yaml:"prefer_fixed_light,omitempty"` // 优先使用固定相位信控，如果不存在则使用最大
+	// 按活动类型（trip.activity）配置的停留时间分布，未配置时使用trip中固定的等待时间
+	DwellTimes map[string]DwellTime `yaml:"dwell_times,omitempty"`
+	// 采用预约控制（无信号灯，按时空窗口为车辆分配通行许可）的路口ID列表
+	ReservationJunctions []int32 `yaml:"reservation_junctions,omitempty"`
+	// 按路口ID配置的最大压力信控过渡相位时长，未配置的路口使用全局flag
+	JunctionClearanceTimes map[int32]ClearanceTime `yaml:"junction_clearance_times,omitempty"`
+	// 按道路ID配置的收费金额（地图中暂无收费字段），未配置的道路不收费
+	RoadTolls map[int32]float64 `yaml:"road_tolls,omitempty"`
+	// 按人的ID指定随机数种子（仍叠加rand.seed_offset），未配置的人以ID作为种子
+	PersonSeeds map[int32]uint64 `yaml:"person_seeds,omitempty"`
+	// 按车道ID配置允许通行的车辆类别（如公交专用道["bus"]、禁止货车["car","bus"]），未配置的车道不限制
+	LaneAllowedClasses map[int32][]string `yaml:"lane_allowed_classes,omitempty"`
+	// 按AOI ID配置的容纳人数上限（地图中暂无容量字段），未配置的AOI不限制
+	AoiCapacities map[int32]int32 `yaml:"aoi_capacities,omitempty"`
+	// 按AOI ID配置的吸引力曲线（地图中暂无该字段），供外部需求生成器选择目的地，未配置的AOI权重恒为1
+	AoiAttractions map[int32][]AttractionPoint `yaml:"aoi_attractions,omitempty"`
+	// 按驾驶员画像（人的driver_profile标签）配置的IDM跟驰参数，未配置的画像使用全局flag
+	DriverProfiles map[string]IDMProfile `yaml:"driver_profiles,omitempty"`
+	// 经济系统内置动态（价格调整、银行计息、统计局记录），默认关闭
+	EconomyTick EconomyTick `yaml:"economy_tick,omitempty"`
 }
 
 // Config YAML配置文件的根结构
@@ -25,6 +25,10 @@ import (
 type Input struct {
 	Map     *mapv2.Map
 	Persons *personv2.Persons
+
+	MapIssues []string // 地图校验报告：车道连接中方向矛盾或不对称的问题（只报告，不阻止加载）
+
+	TrafficLights map[int32]*mapv2.TrafficLight // 覆盖地图固定信控程序的信控程序（路口ID->信控程序，可选）
 }
 
 // Init 下载数据
@@ -38,19 +42,21 @@ type Input struct {
 //   - 文件加载：从指定文件加载地图
 //   - 数据库加载：从MongoDB加载地图
 //
-// 4. ID集合构建：构建各种地图元素的ID集合用于验证
-// 5. 人员数据加载：
+// 4. 地图校验：检查车道前驱后继连接的一致性，问题记录在校验报告中
+// 5. ID集合构建：构建各种地图元素的ID集合用于验证
+// 6. 人员数据加载：
 //   - 文件加载：支持单个或多个文件
 //   - 数据库加载：从MongoDB加载并支持数据迁移
 //   - 数据验证：检查位置信息的有效性
 //
-// 6. 人员筛选：
+// 7. 人员筛选：
 //   - 目标ID筛选：只加载指定ID的人员
 //   - 数量限制：限制加载的人员数量
 //   - 行程限制：限制每个人员的行程数量
 //
-// 7. 路况数据加载：并行加载路况信息
-// 8. 数据验证：确保所有数据的完整性和一致性
+// 8. 信控程序加载：从单独的文件读取覆盖地图的信控程序
+// 9. 路况数据加载：并行加载路况信息
+// 10. 数据验证：确保所有数据的完整性和一致性
 // 说明：这是数据加载的主入口，确保仿真所需的所有数据都正确加载
 func Init(config config.Config, cacheDir string) (res *Input) {
 	useCache := preCheckCache(cacheDir)
@@ -85,6 +91,12 @@ func Init(config config.Config, cacheDir string) (res *Input) {
 		res.Map = mustLoad[mapv2.Map](client, config.Input.Map, cacheDir, nil, nil)
 	}
 
+	// 校验车道连接，报告可能导致运行时panic的问题
+	res.MapIssues = validateLaneConnections(res.Map)
+	for _, issue := range res.MapIssues {
+		log.Warnf("map validation: %s", issue)
+	}
+
 	ids := mapIDs{
 		aoiIDs:         make(map[int32]struct{}),
 		drivingLaneIDs: make(map[int32]struct{}),
@@ -106,6 +118,19 @@ func Init(config config.Config, cacheDir string) (res *Input) {
 		ids.junctionIDs[v.Id] = struct{}{}
 	}
 
+	if config.Input.TrafficLight != "" {
+		tls, err := LoadTrafficLights(config.Input.TrafficLight)
+		if err != nil {
+			log.Panicf("failed to load traffic lights from file: %v", err)
+		}
+		for id := range tls {
+			if _, ok := ids.junctionIDs[id]; !ok {
+				log.Panicf("traffic light file contains unknown junction %d", id)
+			}
+		}
+		res.TrafficLights = tls
+	}
+
 	personIDs := make(map[int32]struct{})
 	if config.Input.Person != nil {
 		if config.Input.Person.File != "" {
@@ -0,0 +1,40 @@
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// LoadTrafficLights 从文件读取信控程序
+// 功能：读取以路口ID为键、信控程序（mapv2.TrafficLight的JSON格式）为值的JSON对象，用于覆盖地图中的固定信控程序
+// 参数：path-文件路径
+// 返回：路口ID->信控程序，错误信息
+// 说明：信控程序中未填写junction_id时使用键中的路口ID，填写时必须与键一致
+func LoadTrafficLights(path string) (map[int32]*mapv2.TrafficLight, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[int32]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("bad traffic light file %s: %w", path, err)
+	}
+	tls := make(map[int32]*mapv2.TrafficLight, len(raw))
+	for id, msg := range raw {
+		tl := &mapv2.TrafficLight{}
+		if err := protojson.Unmarshal(msg, tl); err != nil {
+			return nil, fmt.Errorf("bad traffic light of junction %d: %w", id, err)
+		}
+		if tl.JunctionId == 0 {
+			tl.JunctionId = id
+		} else if tl.JunctionId != id {
+			return nil, fmt.Errorf("traffic light with junction id %d is keyed by %d", tl.JunctionId, id)
+		}
+		tls[id] = tl
+	}
+	return tls, nil
+}
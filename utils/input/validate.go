@@ -0,0 +1,76 @@
+package input
+
+import (
+	"fmt"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+)
+
+// validateLaneConnections 检查车道前驱后继连接的一致性
+// 功能：遍历所有车道的前驱与后继，找出方向矛盾或不对称的连接，返回问题描述列表（不panic）
+// 参数：m-地图数据
+// 返回：问题描述列表，按车道在地图中的顺序排列
+// 算法说明：
+// 1. 连接的车道不存在
+// 2. 行车道的后继以尾部相接或前驱以头部相接（意味着逆向行驶）
+// 3. 不对称：A的后继（头部相接）为B，但B的前驱中没有A；或A的前驱（尾部相接）为B，但B的后继中没有A
+// 说明：人行道可以双向通行，允许头头、尾尾相接，只检查连接的存在性与对称性；
+// 这些问题在运行时会表现为车道投影失败等panic，在加载时提前报告便于定位地图数据的错误
+func validateLaneConnections(m *mapv2.Map) []string {
+	lanes := make(map[int32]*mapv2.Lane, len(m.Lanes))
+	for _, l := range m.Lanes {
+		lanes[l.Id] = l
+	}
+	// hasConn 检查车道l是否有到other的指定类型连接
+	hasConn := func(conns []*mapv2.LaneConnection, other int32, typ mapv2.LaneConnectionType) bool {
+		for _, c := range conns {
+			if c.Id == other && c.Type == typ {
+				return true
+			}
+		}
+		return false
+	}
+	var issues []string
+	for _, l := range m.Lanes {
+		driving := l.Type == mapv2.LaneType_LANE_TYPE_DRIVING
+		for _, c := range l.Successors {
+			other, ok := lanes[c.Id]
+			if !ok {
+				issues = append(issues, fmt.Sprintf("lane %d: successor %d does not exist", l.Id, c.Id))
+				continue
+			}
+			if driving && c.Type != mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD {
+				issues = append(issues, fmt.Sprintf("lane %d: successor %d connected by %v (wrong-way)", l.Id, c.Id, c.Type))
+				continue
+			}
+			// 尾部->头部：对方的前驱中应有本车道（尾部相接）；尾部->尾部：对方的后继中应有本车道（尾部相接）
+			back := other.Predecessors
+			if c.Type == mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL {
+				back = other.Successors
+			}
+			if !hasConn(back, l.Id, mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL) {
+				issues = append(issues, fmt.Sprintf("lane %d: successor %d does not connect back", l.Id, c.Id))
+			}
+		}
+		for _, c := range l.Predecessors {
+			other, ok := lanes[c.Id]
+			if !ok {
+				issues = append(issues, fmt.Sprintf("lane %d: predecessor %d does not exist", l.Id, c.Id))
+				continue
+			}
+			if driving && c.Type != mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL {
+				issues = append(issues, fmt.Sprintf("lane %d: predecessor %d connected by %v (wrong-way)", l.Id, c.Id, c.Type))
+				continue
+			}
+			// 头部<-尾部：对方的后继中应有本车道（头部相接）；头部<-头部：对方的前驱中应有本车道（头部相接）
+			back := other.Successors
+			if c.Type == mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD {
+				back = other.Predecessors
+			}
+			if !hasConn(back, l.Id, mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD) {
+				issues = append(issues, fmt.Sprintf("lane %d: predecessor %d does not connect back", l.Id, c.Id))
+			}
+		}
+	}
+	return issues
+}
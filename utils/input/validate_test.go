@@ -0,0 +1,40 @@
+package input
+
+import (
+	"testing"
+
+	mapv2 "git.fiblab.net/sim/protos/v2/go/city/map/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLaneConnections(t *testing.T) {
+	head := mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_HEAD
+	tail := mapv2.LaneConnectionType_LANE_CONNECTION_TYPE_TAIL
+	newMap := func() *mapv2.Map {
+		// 行车道1 -> 2，人行道3与4尾尾相接
+		return &mapv2.Map{Lanes: []*mapv2.Lane{
+			{Id: 1, Type: mapv2.LaneType_LANE_TYPE_DRIVING, Successors: []*mapv2.LaneConnection{{Id: 2, Type: head}}},
+			{Id: 2, Type: mapv2.LaneType_LANE_TYPE_DRIVING, Predecessors: []*mapv2.LaneConnection{{Id: 1, Type: tail}}},
+			{Id: 3, Type: mapv2.LaneType_LANE_TYPE_WALKING, Successors: []*mapv2.LaneConnection{{Id: 4, Type: tail}}},
+			{Id: 4, Type: mapv2.LaneType_LANE_TYPE_WALKING, Successors: []*mapv2.LaneConnection{{Id: 3, Type: tail}}},
+		}}
+	}
+	assert.Empty(t, validateLaneConnections(newMap()))
+
+	// 行车道的后继以尾部相接（逆向）
+	m := newMap()
+	m.Lanes[0].Successors[0].Type = tail
+	assert.Equal(t, []string{
+		"lane 1: successor 2 connected by LANE_CONNECTION_TYPE_TAIL (wrong-way)",
+		"lane 2: predecessor 1 does not connect back",
+	}, validateLaneConnections(m))
+
+	// 不对称与不存在的连接
+	m = newMap()
+	m.Lanes[3].Successors = nil
+	m.Lanes[1].Predecessors = append(m.Lanes[1].Predecessors, &mapv2.LaneConnection{Id: 5, Type: tail})
+	assert.Equal(t, []string{
+		"lane 2: predecessor 5 does not exist",
+		"lane 3: successor 4 does not connect back",
+	}, validateLaneConnections(m))
+}
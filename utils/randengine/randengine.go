@@ -2,8 +2,10 @@
 package randengine
 
 import (
+	"container/heap"
 	"flag"
 	"log"
+	"math"
 	"sync"
 
 	"golang.org/x/exp/rand"
@@ -146,3 +148,158 @@ func (e *Engine) DiscreteDistributionSafe(weight []float64) int32 {
 	}
 	return int32(len(weight))
 }
+
+// Gamma 生成Gamma分布随机数（非线程安全）
+// 功能：按形状参数shape与尺度参数scale生成Gamma分布的随机数，均值为shape*scale，方差为shape*scale^2
+// 参数：shape-形状参数（>0），scale-尺度参数（>0）
+// 返回：(0, +∞)范围内的随机数
+// 算法说明：
+// 1. shape>=1时使用Marsaglia-Tsang方法：d=shape-1/3，c=1/sqrt(9d)，
+// 对标准正态随机数x取v=(1+cx)^3，以对数判据接受d*v
+// 2. shape<1时先生成Gamma(shape+1)，再乘以U^(1/shape)
+// 说明：参数不为正数时panic
+func (e *Engine) Gamma(shape, scale float64) float64 {
+	if !(shape > 0) || !(scale > 0) {
+		log.Panicf("randengine: Gamma: invalid shape %f or scale %f", shape, scale)
+	}
+	if shape < 1 {
+		u := e.Float64()
+		return e.Gamma(shape+1, scale) * math.Pow(u, 1/shape)
+	}
+	d := shape - 1./3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		var x, v float64
+		for v <= 0 {
+			x = e.NormFloat64()
+			v = 1 + c*x
+		}
+		v = v * v * v
+		u := e.Float64()
+		if u < 1-0.0331*x*x*x*x {
+			return d * v * scale
+		}
+		if math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v * scale
+		}
+	}
+}
+
+// GammaSafe 生成Gamma分布随机数（线程安全）
+// 功能：线程安全版本的Gamma方法
+// 参数：shape-形状参数（>0），scale-尺度参数（>0）
+// 返回：(0, +∞)范围内的随机数
+func (e *Engine) GammaSafe(shape, scale float64) float64 {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.Gamma(shape, scale)
+}
+
+// Beta 生成Beta分布随机数（非线程安全）
+// 功能：按参数alpha与beta生成Beta分布的随机数，均值为alpha/(alpha+beta)，
+// 方差为alpha*beta/((alpha+beta)^2*(alpha+beta+1))
+// 参数：alpha、beta-形状参数（>0）
+// 返回：[0, 1]范围内的随机数
+// 算法说明：
+// 1. 生成相互独立的X~Gamma(alpha, 1)与Y~Gamma(beta, 1)
+// 2. 返回X/(X+Y)
+// 说明：参数不为正数时panic；参数极小时X、Y可能同时下溢为0，此时按均值返回
+func (e *Engine) Beta(alpha, beta float64) float64 {
+	if !(alpha > 0) || !(beta > 0) {
+		log.Panicf("randengine: Beta: invalid alpha %f or beta %f", alpha, beta)
+	}
+	x := e.Gamma(alpha, 1)
+	y := e.Gamma(beta, 1)
+	if x+y == 0 {
+		return alpha / (alpha + beta)
+	}
+	return x / (x + y)
+}
+
+// BetaSafe 生成Beta分布随机数（线程安全）
+// 功能：线程安全版本的Beta方法
+// 参数：alpha、beta-形状参数（>0）
+// 返回：[0, 1]范围内的随机数
+func (e *Engine) BetaSafe(alpha, beta float64) float64 {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.Beta(alpha, beta)
+}
+
+// WeightedSample 按权重不放回地抽取k个下标（非线程安全）
+// 功能：从weights中抽取k个互不相同的下标，每次抽取的概率与剩余下标的权重成正比
+// 参数：weights-权重数组（>=0，权重为0的下标不会被抽中），k-抽取数量
+// 返回：抽中的下标，按抽中顺序排列（第一个下标即按权重抽取一次的结果）；
+// k超过正权重的下标数量时只返回全部正权重的下标
+// 算法说明：
+// 1. 使用带指数跳跃的加权蓄水池抽样（A-ExpJ）：每个下标的键为u^(1/w)，保留键最大的k个
+// 2. 蓄水池填满后按最小键计算可跳过的累计权重，只为少数下标生成随机数
+// 3. 为避免下溢，键以对数形式log(u)/w保存
+// 说明：权重为负数或非数时panic
+func (e *Engine) WeightedSample(weights []float64, k int) []int {
+	for _, w := range weights {
+		if !(w >= 0) {
+			log.Panicf("randengine: WeightedSample: invalid weight %f", w)
+		}
+	}
+	if k <= 0 {
+		return []int{}
+	}
+	r := &reservoir{}
+	var skip float64 // 剩余可跳过的累计权重
+	for i, w := range weights {
+		if w == 0 {
+			continue
+		}
+		if r.Len() < k {
+			heap.Push(r, reservoirItem{index: i, key: math.Log(e.Float64()) / w})
+			if r.Len() == k {
+				skip = math.Log(e.Float64()) / r.items[0].key
+			}
+			continue
+		}
+		if skip -= w; skip > 0 {
+			continue
+		}
+		// 新下标的键需大于当前最小键，在(T^w, 1)中均匀抽取u
+		tw := math.Exp(w * r.items[0].key)
+		u := tw + (1-tw)*e.Float64()
+		r.items[0] = reservoirItem{index: i, key: math.Log(u) / w}
+		heap.Fix(r, 0)
+		skip = math.Log(e.Float64()) / r.items[0].key
+	}
+	res := make([]int, r.Len())
+	for i := len(res) - 1; i >= 0; i-- {
+		res[i] = heap.Pop(r).(reservoirItem).index
+	}
+	return res
+}
+
+// WeightedSampleSafe 按权重不放回地抽取k个下标（线程安全）
+// 功能：线程安全版本的WeightedSample方法
+func (e *Engine) WeightedSampleSafe(weights []float64, k int) []int {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.WeightedSample(weights, k)
+}
+
+// 蓄水池中的下标
+type reservoirItem struct {
+	index int
+	key   float64 // 对数形式的键
+}
+
+// 按键排序的最小堆
+type reservoir struct {
+	items []reservoirItem
+}
+
+func (r *reservoir) Len() int           { return len(r.items) }
+func (r *reservoir) Less(i, j int) bool { return r.items[i].key < r.items[j].key }
+func (r *reservoir) Swap(i, j int)      { r.items[i], r.items[j] = r.items[j], r.items[i] }
+func (r *reservoir) Push(x any)         { r.items = append(r.items, x.(reservoirItem)) }
+func (r *reservoir) Pop() any {
+	x := r.items[len(r.items)-1]
+	r.items = r.items[:len(r.items)-1]
+	return x
+}
@@ -0,0 +1,116 @@
+package randengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// meanVar 计算样本均值与方差
+func meanVar(samples []float64) (mean, variance float64) {
+	for _, x := range samples {
+		mean += x
+	}
+	mean /= float64(len(samples))
+	for _, x := range samples {
+		variance += (x - mean) * (x - mean)
+	}
+	variance /= float64(len(samples) - 1)
+	return
+}
+
+func TestGamma(t *testing.T) {
+	const n = 100000
+	e := New(1)
+	for _, c := range []struct{ shape, scale float64 }{
+		{0.5, 2}, {1, 1}, {2.5, 3}, {9, 0.5},
+	} {
+		samples := make([]float64, n)
+		for i := range samples {
+			samples[i] = e.Gamma(c.shape, c.scale)
+			assert.Positive(t, samples[i])
+		}
+		mean, variance := meanVar(samples)
+		wantMean, wantVar := c.shape*c.scale, c.shape*c.scale*c.scale
+		assert.InDelta(t, wantMean, mean, wantMean*0.02, "shape=%v scale=%v", c.shape, c.scale)
+		assert.InDelta(t, wantVar, variance, wantVar*0.05, "shape=%v scale=%v", c.shape, c.scale)
+	}
+	assert.Panics(t, func() { e.Gamma(0, 1) })
+	assert.Panics(t, func() { e.Gamma(1, -1) })
+}
+
+func TestBeta(t *testing.T) {
+	const n = 100000
+	e := New(1)
+	for _, c := range []struct{ alpha, beta float64 }{
+		{0.5, 0.5}, {2, 5}, {3, 1},
+	} {
+		samples := make([]float64, n)
+		for i := range samples {
+			samples[i] = e.BetaSafe(c.alpha, c.beta)
+			assert.True(t, samples[i] >= 0 && samples[i] <= 1)
+		}
+		mean, variance := meanVar(samples)
+		sum := c.alpha + c.beta
+		wantMean, wantVar := c.alpha/sum, c.alpha*c.beta/(sum*sum*(sum+1))
+		assert.InDelta(t, wantMean, mean, 0.01, "alpha=%v beta=%v", c.alpha, c.beta)
+		assert.InDelta(t, wantVar, variance, wantVar*0.05, "alpha=%v beta=%v", c.alpha, c.beta)
+	}
+	assert.Panics(t, func() { e.Beta(-1, 1) })
+}
+
+func TestWeightedSample(t *testing.T) {
+	const n = 100000
+	e := New(1)
+	weights := []float64{1, 0, 2, 3, 4}
+
+	// 抽取1个时，频率与权重成正比
+	counts := make([]int, len(weights))
+	for i := 0; i < n; i++ {
+		res := e.WeightedSample(weights, 1)
+		assert.Len(t, res, 1)
+		counts[res[0]]++
+	}
+	for i, w := range weights {
+		assert.InDelta(t, w/10, float64(counts[i])/n, 0.01, "index %d", i)
+	}
+
+	// 抽取多个时下标互不相同，且第一个下标仍按权重分布
+	clear(counts)
+	for i := 0; i < n; i++ {
+		res := e.WeightedSample(weights, 3)
+		assert.Len(t, res, 3)
+		assert.NotContains(t, res, 1)
+		assert.NotEqual(t, res[0], res[1])
+		assert.NotEqual(t, res[1], res[2])
+		assert.NotEqual(t, res[0], res[2])
+		counts[res[0]]++
+	}
+	for i, w := range weights {
+		assert.InDelta(t, w/10, float64(counts[i])/n, 0.01, "index %d", i)
+	}
+
+	// k超过正权重下标数量时返回全部正权重下标
+	assert.ElementsMatch(t, []int{0, 2, 3, 4}, e.WeightedSample(weights, 10))
+	assert.Empty(t, e.WeightedSample(weights, 0))
+	assert.Panics(t, func() { e.WeightedSample([]float64{1, -1}, 1) })
+}
+
+func TestWeightedSampleLong(t *testing.T) {
+	// 长数组触发指数跳跃，抽中下标的频率仍与权重成正比
+	const n = 20000
+	e := New(2)
+	weights := make([]float64, 100)
+	for i := range weights {
+		weights[i] = float64(i%4 + 1)
+	}
+	counts := make([]float64, 4)
+	for i := 0; i < n; i++ {
+		for _, idx := range e.WeightedSample(weights, 1) {
+			counts[idx%4]++
+		}
+	}
+	for i := range counts {
+		assert.InDelta(t, float64(i+1)/10, counts[i]/n, 0.015)
+	}
+}